@@ -0,0 +1,95 @@
+package pbft
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errBackendPanic = fmt.Errorf("backend panicked")
+
+// BackendPanicEvent is emitted when a backend callback panics. The panic is recovered
+// and the engine moves to the faulted state instead of taking down the process
+type BackendPanicEvent struct {
+	// Method is the backend method that panicked
+	Method string
+
+	// Panic is the value recovered from the panic
+	Panic interface{}
+}
+
+func (e *BackendPanicEvent) EventName() string {
+	return "BackendPanic"
+}
+
+// backendPanicError is the error of a backend callback that panicked
+type backendPanicError struct {
+	method string
+	value  interface{}
+}
+
+func (e *backendPanicError) Error() string {
+	return fmt.Sprintf("%v: method=%s, panic=%v", errBackendPanic, e.method, e.value)
+}
+
+func (e *backendPanicError) Unwrap() error {
+	return errBackendPanic
+}
+
+// recoverBackend calls the backend callback and reports a panic in it as an error, without
+// moving the state machine to the faulted state. It is used for the callbacks made on
+// behalf of the peers (see preflightSeal), the engine ones are called through guard
+func recoverBackend(method string, fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &backendPanicError{method: method, value: r}
+		}
+	}()
+	fn()
+	return nil
+}
+
+// guard calls the backend callback and recovers from a panic in it. On panic it moves
+// the state machine to the faulted state, emits a BackendPanicEvent and returns the error
+func (p *Pbft) guard(method string, fn func()) error {
+	err := recoverBackend(method, fn)
+	var panicErr *backendPanicError
+	if !errors.As(err, &panicErr) {
+		return nil
+	}
+	p.logger.Printf("[ERROR] %v", err)
+	p.health.setErr(err)
+	p.emit(&BackendPanicEvent{Method: method, Panic: panicErr.value})
+	p.setState(FaultedState)
+	return err
+}
+
+// guardedValidatorSet calls the validator set of the backend through guard. On panic the
+// engine is faulted and the zero value is returned
+type guardedValidatorSet struct {
+	ValidatorSet
+
+	p *Pbft
+}
+
+func (v *guardedValidatorSet) CalcProposer(round uint64) (proposer NodeID) {
+	_ = v.p.guard("CalcProposer", func() { proposer = v.ValidatorSet.CalcProposer(round) })
+	return proposer
+}
+
+func (v *guardedValidatorSet) Includes(id NodeID) (ok bool) {
+	_ = v.p.guard("Includes", func() { ok = v.ValidatorSet.Includes(id) })
+	return ok
+}
+
+func (v *guardedValidatorSet) Len() (size int) {
+	_ = v.p.guard("Len", func() { size = v.ValidatorSet.Len() })
+	return size
+}
+
+// unguardedValidatorSet returns the validator set without the guard, to look for its optional interfaces
+func unguardedValidatorSet(validators ValidatorSet) ValidatorSet {
+	if guarded, ok := validators.(*guardedValidatorSet); ok {
+		return guarded.ValidatorSet
+	}
+	return validators
+}
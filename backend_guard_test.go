@@ -0,0 +1,116 @@
+package pbft
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuard_BuildProposalPanic(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.backend.(*mockBackend).HookBuildProposalHandler(func() (*Proposal, error) {
+		panic("boom")
+	})
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	m.setState(AcceptState)
+
+	m.runCycle(context.Background())
+
+	assert.True(t, m.IsState(FaultedState))
+	assert.True(t, errors.Is(m.Health().LastError, errBackendPanic))
+	// the pre-build notification is followed by the panic
+	assert.Len(t, events, 2)
+	assert.IsType(t, &PreBuildEvent{}, events[0])
+	assert.Equal(t, "BuildProposal", events[1].(*BackendPanicEvent).Method)
+	assert.Equal(t, "boom", events[1].(*BackendPanicEvent).Panic)
+}
+
+func TestGuard_ValidatePanic(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "C")
+	m.backend.(*mockBackend).HookValidateHandler(func(p *Proposal) error {
+		panic("boom")
+	})
+	m.setState(AcceptState)
+
+	m.emitMsg(&MessageReq{
+		From:     "A",
+		Type:     MessageReq_Preprepare,
+		Proposal: mockProposal,
+		View:     ViewMsg(1, 0),
+	})
+	m.runCycle(context.Background())
+
+	assert.True(t, m.IsState(FaultedState))
+	assert.Empty(t, m.respMsg)
+}
+
+type panicValidatorSetBackend struct {
+	*mockBackend
+}
+
+func (p *panicValidatorSetBackend) ValidatorSet() ValidatorSet {
+	panic("boom")
+}
+
+func TestGuard_SetBackendPanic(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+
+	err := m.SetBackend(&panicValidatorSetBackend{mockBackend: m.backend.(*mockBackend)})
+	assert.True(t, errors.Is(err, errBackendPanic))
+}
+
+func TestGuard_RunStopsOnFault(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.backend.(*mockBackend).HookBuildProposalHandler(func() (*Proposal, error) {
+		panic("boom")
+	})
+
+	// Run returns instead of taking down the process
+	m.Run(context.Background())
+	assert.Equal(t, FaultedState, m.GetState())
+}
+
+type panicProposerSet struct {
+	ValidatorSet
+}
+
+func (p *panicProposerSet) CalcProposer(round uint64) NodeID {
+	panic("boom")
+}
+
+type panicProposerBackend struct {
+	*mockBackend
+}
+
+func (b *panicProposerBackend) ValidatorSet() ValidatorSet {
+	return &panicProposerSet{b.mockBackend.ValidatorSet()}
+}
+
+func TestGuard_ValidatorSetPanic(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	assert.NoError(t, m.SetBackend(&panicProposerBackend{mockBackend: m.backend.(*mockBackend)}))
+	m.setState(AcceptState)
+
+	m.runCycle(context.Background())
+
+	assert.True(t, m.IsState(FaultedState))
+	assert.Empty(t, m.respMsg)
+	assert.Equal(t, "CalcProposer", events[len(events)-1].(*BackendPanicEvent).Method)
+}
+
+func TestRecoverBackend(t *testing.T) {
+	err := recoverBackend("ValidateCommit", func() { panic("boom") })
+	assert.ErrorIs(t, err, errBackendPanic)
+	assert.EqualError(t, err, "backend panicked: method=ValidateCommit, panic=boom")
+
+	assert.NoError(t, recoverBackend("ValidateCommit", func() {}))
+}
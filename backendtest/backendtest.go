@@ -0,0 +1,321 @@
+// Package backendtest provides a conformance suite for the implementations of pbft.Backend,
+// in the spirit of net/http/httptest for the http handlers. Run drives a network of backends
+// through scripted consensus flows and reports the violations of the backend contract (i.e. a
+// non deterministic CalcProposer or a proposal accepted by Validate but rejected by Insert).
+// Only the mandatory Backend interface is exercised, the optional interfaces are not
+package backendtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+const (
+	// heights is the number of heights finalized by the consensus flow
+	heights = 3
+
+	// consensusTimeout is the time given to the network to finalize the heights
+	consensusTimeout = 30 * time.Second
+)
+
+// Node is a validator of the network under test
+type Node struct {
+	// Backend is the backend of the validator
+	Backend pbft.Backend
+
+	// Key is the signing key of the validator
+	Key pbft.SignKey
+}
+
+// Factory creates the validators of a fresh network, all at the same height. The validator
+// set of every backend must contain the keys of all the nodes
+type Factory func(t *testing.T) []*Node
+
+// Run runs the conformance suite against the networks created by the factory
+func Run(t *testing.T, factory Factory) {
+	t.Run("ValidatorSet", func(t *testing.T) {
+		report(t, checkValidatorSet(factory(t)))
+	})
+	t.Run("Proposal", func(t *testing.T) {
+		report(t, checkProposal(factory(t)))
+	})
+	t.Run("Consensus", func(t *testing.T) {
+		report(t, checkConsensus(factory(t), heights, consensusTimeout))
+	})
+}
+
+func report(t *testing.T, errs []error) {
+	t.Helper()
+	for _, err := range errs {
+		t.Error(err)
+	}
+}
+
+// checkValidatorSet checks that every node includes the whole network in its validator set
+// and that the proposer of a round is a member, deterministic and the same on every node
+func checkValidatorSet(nodes []*Node) []error {
+	if len(nodes) == 0 {
+		return []error{fmt.Errorf("the factory returned no nodes")}
+	}
+	errs := []error{}
+	sets := make([]pbft.ValidatorSet, len(nodes))
+	for i, n := range nodes {
+		sets[i] = n.Backend.ValidatorSet()
+		if sets[i] == nil {
+			return []error{fmt.Errorf("node %s: ValidatorSet returned nil", n.Key.NodeID())}
+		}
+		if sets[i].Len() != len(nodes) {
+			errs = append(errs, fmt.Errorf("node %s: ValidatorSet has %d validators, expected %d", n.Key.NodeID(), sets[i].Len(), len(nodes)))
+		}
+		for _, other := range nodes {
+			if !sets[i].Includes(other.Key.NodeID()) {
+				errs = append(errs, fmt.Errorf("node %s: ValidatorSet does not include %s", n.Key.NodeID(), other.Key.NodeID()))
+			}
+		}
+	}
+	for round := uint64(0); round < uint64(2*len(nodes)); round++ {
+		expected := sets[0].CalcProposer(round)
+		for i, set := range sets {
+			id := nodes[i].Key.NodeID()
+			proposer := set.CalcProposer(round)
+			if again := set.CalcProposer(round); again != proposer {
+				errs = append(errs, fmt.Errorf("node %s: CalcProposer is not deterministic: round=%d, first=%s, second=%s", id, round, proposer, again))
+			}
+			if again := nodes[i].Backend.ValidatorSet().CalcProposer(round); again != proposer {
+				errs = append(errs, fmt.Errorf("node %s: CalcProposer changes with a new ValidatorSet: round=%d, first=%s, second=%s", id, round, proposer, again))
+			}
+			if !set.Includes(proposer) {
+				errs = append(errs, fmt.Errorf("node %s: proposer %s of round %d is not a validator", id, proposer, round))
+			}
+			if proposer != expected {
+				errs = append(errs, fmt.Errorf("node %s: proposer of round %d is %s, node %s computes %s", id, round, proposer, nodes[0].Key.NodeID(), expected))
+			}
+		}
+	}
+	return errs
+}
+
+// checkProposal checks that the proposal built by the proposer of the first round is
+// accepted by the rest of the nodes, consistently and without being modified
+func checkProposal(nodes []*Node) []error {
+	if len(nodes) == 0 {
+		return []error{fmt.Errorf("the factory returned no nodes")}
+	}
+	height := nodes[0].Backend.Height()
+	for _, n := range nodes {
+		if n.Backend.Height() != height {
+			return []error{fmt.Errorf("node %s: Height is %d, node %s is at %d", n.Key.NodeID(), n.Backend.Height(), nodes[0].Key.NodeID(), height)}
+		}
+	}
+
+	proposer := nodes[0].Backend.ValidatorSet().CalcProposer(0)
+	var builder *Node
+	for _, n := range nodes {
+		n.Backend.Init(&pbft.RoundInfo{
+			IsProposer: n.Key.NodeID() == proposer,
+			Proposer:   proposer,
+			View:       pbft.ViewMsg(height, 0),
+			Quorum:     pbft.QuorumSize(len(nodes)),
+			StartTime:  time.Now(),
+		})
+		if n.Key.NodeID() == proposer {
+			builder = n
+		}
+	}
+	if builder == nil {
+		return []error{fmt.Errorf("proposer %s is not one of the nodes", proposer)}
+	}
+	proposal, err := builder.Backend.BuildProposal()
+	if err != nil {
+		return []error{fmt.Errorf("node %s: BuildProposal failed: %v", proposer, err)}
+	}
+	if proposal == nil || len(proposal.Hash) == 0 {
+		return []error{fmt.Errorf("node %s: BuildProposal returned a proposal without hash", proposer)}
+	}
+
+	errs := []error{}
+	for _, n := range nodes {
+		if n == builder {
+			continue
+		}
+		received := proposal.Copy()
+		err := n.Backend.Validate(received)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: Validate rejects the proposal of %s: %v", n.Key.NodeID(), proposer, err))
+		}
+		if !bytes.Equal(received.Hash, proposal.Hash) || !bytes.Equal(received.Data, proposal.Data) {
+			errs = append(errs, fmt.Errorf("node %s: Validate modified the data or the hash of the proposal", n.Key.NodeID()))
+		}
+		if again := n.Backend.Validate(proposal.Copy()); (again == nil) != (err == nil) {
+			errs = append(errs, fmt.Errorf("node %s: Validate is not deterministic: first=%v, second=%v", n.Key.NodeID(), err, again))
+		}
+	}
+	return errs
+}
+
+// checkConsensus runs the engine on every node until the network finalizes the heights and
+// checks that the backends agree on the inserted proposals and advance their height
+func checkConsensus(nodes []*Node, heights uint64, timeout time.Duration) []error {
+	if len(nodes) == 0 {
+		return []error{fmt.Errorf("the factory returned no nodes")}
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	violations := &violations{}
+	transport := &transport{}
+	backends := make([]*recorder, len(nodes))
+	for i, n := range nodes {
+		backends[i] = &recorder{Backend: n.Backend, id: n.Key.NodeID(), violations: violations, validated: map[string]struct{}{}}
+		engine := pbft.New(n.Key, transport, pbft.WithLogger(log.New(ioutil.Discard, "", 0)))
+		transport.engines = append(transport.engines, engine)
+	}
+
+	target := nodes[0].Backend.Height() + heights
+	wg := sync.WaitGroup{}
+	for i, engine := range transport.engines {
+		wg.Add(1)
+		go func(engine *pbft.Pbft, backend *recorder) {
+			defer wg.Done()
+			for backend.Height() < target && ctx.Err() == nil {
+				if err := engine.SetBackend(backend); err != nil {
+					violations.add(fmt.Errorf("node %s: SetBackend failed: %v", backend.id, err))
+					return
+				}
+				engine.Run(ctx)
+			}
+		}(engine, backends[i])
+	}
+	wg.Wait()
+
+	errs := violations.list()
+	if ctx.Err() != nil {
+		errs = append(errs, fmt.Errorf("the network did not finalize %d heights in %s", heights, timeout))
+	}
+	for number := target - heights; number < target; number++ {
+		var expected *pbft.SealedProposal
+		for _, backend := range backends {
+			inserted := backend.insertedAt(number)
+			if inserted == nil {
+				continue
+			}
+			if expected == nil {
+				expected = inserted
+			} else if !expected.Proposal.Equal(inserted.Proposal) {
+				errs = append(errs, fmt.Errorf("node %s: inserted proposal %x at height %d, expected %x", backend.id, inserted.Proposal.Hash, number, expected.Proposal.Hash))
+			}
+		}
+	}
+	return errs
+}
+
+// violations collects the contract violations found while the engines run
+type violations struct {
+	lock sync.Mutex
+	errs []error
+}
+
+func (v *violations) add(err error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.errs = append(v.errs, err)
+}
+
+func (v *violations) list() []error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return append([]error{}, v.errs...)
+}
+
+// recorder wraps the backend under test to check the calls of the engine
+type recorder struct {
+	pbft.Backend
+	id         pbft.NodeID
+	violations *violations
+
+	lock      sync.Mutex
+	validated map[string]struct{}
+	inserted  []*pbft.SealedProposal
+}
+
+func (r *recorder) BuildProposal() (*pbft.Proposal, error) {
+	proposal, err := r.Backend.BuildProposal()
+	if err == nil && proposal != nil {
+		r.accept(proposal.Hash)
+	}
+	return proposal, err
+}
+
+func (r *recorder) Validate(proposal *pbft.Proposal) error {
+	hash := append([]byte{}, proposal.Hash...)
+	err := r.Backend.Validate(proposal)
+	if err == nil {
+		r.accept(hash)
+	}
+	return err
+}
+
+func (r *recorder) Insert(p *pbft.SealedProposal) error {
+	height := r.Backend.Height()
+	err := r.Backend.Insert(p)
+
+	r.lock.Lock()
+	_, accepted := r.validated[string(p.Proposal.Hash)]
+	if err == nil {
+		r.inserted = append(r.inserted, p)
+	}
+	r.lock.Unlock()
+
+	if err != nil {
+		if accepted {
+			r.violations.add(fmt.Errorf("node %s: Insert rejects the proposal %x accepted at height %d: %v", r.id, p.Proposal.Hash, p.Number, err))
+		}
+		return err
+	}
+	if after := r.Backend.Height(); after != height+1 {
+		r.violations.add(fmt.Errorf("node %s: Height is %d after the insertion at height %d, expected %d", r.id, after, height, height+1))
+	}
+	return nil
+}
+
+func (r *recorder) accept(hash []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.validated[string(hash)] = struct{}{}
+}
+
+// insertedAt returns the proposal inserted at the height, nil if none
+func (r *recorder) insertedAt(number uint64) *pbft.SealedProposal {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, p := range r.inserted {
+		if p.Number == number {
+			return p
+		}
+	}
+	return nil
+}
+
+// transport delivers the messages to every engine of the network
+type transport struct {
+	engines []*pbft.Pbft
+}
+
+func (t *transport) Gossip(msg *pbft.MessageReq) error {
+	for _, engine := range t.engines {
+		go engine.PushMessage(msg.Copy())
+	}
+	return nil
+}
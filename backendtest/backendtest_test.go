@@ -0,0 +1,180 @@
+package backendtest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+type key string
+
+func (k key) NodeID() pbft.NodeID {
+	return pbft.NodeID(k)
+}
+
+func (k key) Sign(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+// validators is a round robin validator set
+type validators []pbft.NodeID
+
+func (v validators) CalcProposer(round uint64) pbft.NodeID {
+	return v[round%uint64(len(v))]
+}
+
+func (v validators) Includes(id pbft.NodeID) bool {
+	for _, validator := range v {
+		if validator == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (v validators) Len() int {
+	return len(v)
+}
+
+// chain is a backend that finalizes the height number as proposal
+type chain struct {
+	lock       sync.Mutex
+	validators validators
+	height     uint64
+}
+
+func (c *chain) proposal(height uint64) *pbft.Proposal {
+	data := []byte(fmt.Sprintf("block %d", height))
+	hash := sha256.Sum256(data)
+	return &pbft.Proposal{Data: data, Time: time.Unix(int64(height), 0), Hash: hash[:]}
+}
+
+func (c *chain) BuildProposal() (*pbft.Proposal, error) {
+	return c.proposal(c.Height()), nil
+}
+
+func (c *chain) Validate(p *pbft.Proposal) error {
+	if !p.Equal(c.proposal(c.Height())) {
+		return fmt.Errorf("unexpected proposal")
+	}
+	return nil
+}
+
+func (c *chain) Insert(p *pbft.SealedProposal) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.height = p.Number + 1
+	return nil
+}
+
+func (c *chain) Height() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.height
+}
+
+func (c *chain) ValidatorSet() pbft.ValidatorSet {
+	return c.validators
+}
+
+func (c *chain) Init(*pbft.RoundInfo) {}
+
+func (c *chain) IsStuck(num uint64) (uint64, bool) {
+	return 0, false
+}
+
+func (c *chain) ValidateCommit(from pbft.NodeID, seal []byte) error {
+	return nil
+}
+
+func newNetwork(num int, backend func(set validators) pbft.Backend) []*Node {
+	set := validators{}
+	for i := 0; i < num; i++ {
+		set = append(set, pbft.NodeID(fmt.Sprintf("node_%d", i)))
+	}
+	nodes := []*Node{}
+	for _, id := range set {
+		nodes = append(nodes, &Node{Backend: backend(set), Key: key(id)})
+	}
+	return nodes
+}
+
+func TestRun(t *testing.T) {
+	Run(t, func(t *testing.T) []*Node {
+		return newNetwork(4, func(set validators) pbft.Backend {
+			return &chain{validators: set, height: 1}
+		})
+	})
+}
+
+// randomProposer picks a different proposer on every call
+type randomProposer struct {
+	validators
+}
+
+func (r randomProposer) CalcProposer(round uint64) pbft.NodeID {
+	return r.validators[rand.Intn(len(r.validators))]
+}
+
+type randomProposerChain struct {
+	*chain
+}
+
+func (r *randomProposerChain) ValidatorSet() pbft.ValidatorSet {
+	return randomProposer{r.validators}
+}
+
+func TestCheckValidatorSet_NonDeterministic(t *testing.T) {
+	nodes := newNetwork(16, func(set validators) pbft.Backend {
+		return &randomProposerChain{&chain{validators: set, height: 1}}
+	})
+	errs := checkValidatorSet(nodes)
+	assert.NotEmpty(t, errs)
+	assert.True(t, strings.Contains(errs[0].Error(), "not deterministic"))
+}
+
+// tamperingChain rewrites the proposals it validates
+type tamperingChain struct {
+	*chain
+}
+
+func (c *tamperingChain) Validate(p *pbft.Proposal) error {
+	p.Hash = []byte{0x1}
+	return nil
+}
+
+func TestCheckProposal_Tampering(t *testing.T) {
+	nodes := newNetwork(4, func(set validators) pbft.Backend {
+		return &tamperingChain{&chain{validators: set, height: 1}}
+	})
+	errs := checkProposal(nodes)
+	assert.Len(t, errs, 3)
+	assert.True(t, strings.Contains(errs[0].Error(), "modified"))
+}
+
+// rejectingChain accepts the proposals but fails to insert them
+type rejectingChain struct {
+	*chain
+}
+
+func (c *rejectingChain) Insert(p *pbft.SealedProposal) error {
+	return fmt.Errorf("invalid block")
+}
+
+func TestCheckConsensus_InsertRejectsValidated(t *testing.T) {
+	nodes := newNetwork(4, func(set validators) pbft.Backend {
+		return &rejectingChain{&chain{validators: set, height: 1}}
+	})
+	errs := checkConsensus(nodes, 1, time.Second)
+	assert.NotEmpty(t, errs)
+	assert.True(t, strings.Contains(errs[0].Error(), "Insert rejects"))
+}
@@ -0,0 +1,197 @@
+package pbft
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxProposalChunks is the maximum number of chunks accepted for a single proposal
+const maxProposalChunks = 4096
+
+var errInvalidChunk = fmt.Errorf("invalid proposal chunk")
+
+func WithProposalChunkSize(size int) ConfigOption {
+	return func(c *Config) {
+		c.ProposalChunkSize = size
+	}
+}
+
+// splitProposal splits the proposal data in chunks of the given size
+func splitProposal(data []byte, size int) [][]byte {
+	chunks := [][]byte{}
+	for len(data) > size {
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	return append(chunks, data)
+}
+
+// chunkedProposal holds the chunks received for a single proposal
+type chunkedProposal struct {
+	view   *View
+	count  uint32
+	chunks map[uint32][]byte
+	size   uint64
+}
+
+// chunkBuffer reassembles the proposals streamed in chunks
+type chunkBuffer struct {
+	lock      sync.Mutex
+	proposals map[string]*chunkedProposal
+
+	// size is the estimated memory of the buffered chunks
+	size uint64
+
+	// limit is the maximum size of the buffered chunks, zero if unbounded (see WithMemoryBudget)
+	limit uint64
+}
+
+func newChunkBuffer() *chunkBuffer {
+	return &chunkBuffer{
+		proposals: map[string]*chunkedProposal{},
+	}
+}
+
+func chunkKey(from NodeID, view *View, hash []byte) string {
+	return fmt.Sprintf("%s/%d/%d/%x", from, view.Sequence, view.Round, hash)
+}
+
+// add stores a proposal chunk
+func (c *chunkBuffer) add(msg *MessageReq) error {
+	if msg.ChunkCount == 0 || msg.ChunkCount > maxProposalChunks {
+		return fmt.Errorf("%w: number of chunks %d", errInvalidChunk, msg.ChunkCount)
+	}
+	if msg.ChunkIndex >= msg.ChunkCount {
+		return fmt.Errorf("%w: chunk index %d out of range", errInvalidChunk, msg.ChunkIndex)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := chunkKey(msg.From, msg.View, msg.Hash)
+	size := chunkSize(msg.Proposal)
+	if c.limit != 0 && c.size+size > c.limit {
+		c.evictAfter(msg.View)
+		if c.size+size > c.limit {
+			return fmt.Errorf("chunk buffer full: size=%d, limit=%d", c.size, c.limit)
+		}
+	}
+	proposal, ok := c.proposals[key]
+	if !ok {
+		proposal = &chunkedProposal{
+			view:   msg.View.Copy(),
+			count:  msg.ChunkCount,
+			chunks: map[uint32][]byte{},
+		}
+		c.proposals[key] = proposal
+	}
+	if proposal.count != msg.ChunkCount {
+		return fmt.Errorf("%w: inconsistent number of chunks: expected=%d, found=%d", errInvalidChunk, proposal.count, msg.ChunkCount)
+	}
+	if prev, ok := proposal.chunks[msg.ChunkIndex]; ok {
+		proposal.size -= chunkSize(prev)
+		c.size -= chunkSize(prev)
+	}
+	proposal.chunks[msg.ChunkIndex] = msg.Proposal
+	proposal.size += size
+	c.size += size
+	return nil
+}
+
+// chunkSize returns the estimated memory retained by the chunk
+func chunkSize(chunk []byte) uint64 {
+	return uint64(msgOverhead + len(chunk))
+}
+
+// evictAfter removes the chunks of the proposals after the given view
+func (c *chunkBuffer) evictAfter(view *View) {
+	for key, proposal := range c.proposals {
+		if cmpView(proposal.view, view) > 0 {
+			c.delete(key)
+		}
+	}
+}
+
+// delete removes the chunks of the proposal
+func (c *chunkBuffer) delete(key string) {
+	c.size -= c.proposals[key].size
+	delete(c.proposals, key)
+}
+
+// memory returns the estimated memory of the buffered chunks
+func (c *chunkBuffer) memory() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.size
+}
+
+// assemble returns the proposal announced by the preprepare message if all its chunks were received
+func (c *chunkBuffer) assemble(preprepare *MessageReq) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	proposal, ok := c.proposals[chunkKey(preprepare.From, preprepare.View, preprepare.Hash)]
+	if !ok || proposal.count != preprepare.ChunkCount || len(proposal.chunks) != int(proposal.count) {
+		return nil, false
+	}
+	data := []byte{}
+	for i := uint32(0); i < proposal.count; i++ {
+		data = append(data, proposal.chunks[i]...)
+	}
+	return data, true
+}
+
+// prune removes the chunks of the proposals older than the given view
+func (c *chunkBuffer) prune(current *View) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key, proposal := range c.proposals {
+		if cmpView(proposal.view, current) < 0 {
+			c.delete(key)
+		}
+	}
+}
+
+// gossipChunks sends the chunks of the proposal announced by the preprepare message
+func (p *Pbft) gossipChunks(preprepare *MessageReq, chunks [][]byte) {
+	for i, chunk := range chunks {
+		msg := &MessageReq{
+			Type:       MessageReq_ProposalChunk,
+			From:       preprepare.From,
+			ChainID:    preprepare.ChainID,
+			View:       preprepare.View.Copy(),
+			Hash:       preprepare.Hash,
+			ChunkIndex: uint32(i),
+			ChunkCount: uint32(len(chunks)),
+		}
+		msg.SetProposal(chunk)
+		p.transportGossip(msg)
+	}
+}
+
+// waitForChunks waits until all the chunks of the proposal announced by
+// the preprepare message are received or the timeout expires
+func (p *Pbft) waitForChunks(span trace.Span, preprepare *MessageReq, timeout time.Duration) ([]byte, bool) {
+	timeoutCh := p.timer.reset("Chunks", timeout)
+	defer p.timer.stop()
+
+	for {
+		if data, ok := p.chunks.assemble(preprepare); ok {
+			return data, true
+		}
+
+		select {
+		case <-timeoutCh:
+			span.AddEvent("ChunksTimeout")
+			return nil, false
+		case <-p.ctx.Done():
+			return nil, false
+		case <-p.updateCh:
+		}
+	}
+}
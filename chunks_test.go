@@ -0,0 +1,139 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proposalChunkMsgs(from NodeID, view *View, hash []byte, chunks [][]byte) []*MessageReq {
+	msgs := []*MessageReq{}
+	for i, chunk := range chunks {
+		msgs = append(msgs, &MessageReq{
+			Type:       MessageReq_ProposalChunk,
+			From:       from,
+			View:       view.Copy(),
+			Hash:       hash,
+			Proposal:   chunk,
+			ChunkIndex: uint32(i),
+			ChunkCount: uint32(len(chunks)),
+		})
+	}
+	return msgs
+}
+
+func TestChunks_SplitProposal(t *testing.T) {
+	assert.Equal(t, [][]byte{{0x1, 0x2}, {0x3, 0x4}, {0x5}}, splitProposal([]byte{0x1, 0x2, 0x3, 0x4, 0x5}, 2))
+	assert.Equal(t, [][]byte{{0x1, 0x2}}, splitProposal([]byte{0x1, 0x2}, 2))
+}
+
+func TestChunks_Buffer(t *testing.T) {
+	c := newChunkBuffer()
+	msgs := proposalChunkMsgs("A", ViewMsg(1, 0), digest, splitProposal(mockProposal1, 3))
+
+	preprepare := &MessageReq{
+		Type:       MessageReq_Preprepare,
+		From:       "A",
+		View:       ViewMsg(1, 0),
+		Hash:       digest,
+		ChunkCount: 2,
+	}
+
+	require.NoError(t, c.add(msgs[1]))
+	_, ok := c.assemble(preprepare)
+	assert.False(t, ok)
+
+	require.NoError(t, c.add(msgs[0]))
+	data, ok := c.assemble(preprepare)
+	assert.True(t, ok)
+	assert.Equal(t, mockProposal1, data)
+
+	// inconsistent chunks
+	bad := msgs[0].Copy()
+	bad.ChunkCount = 3
+	assert.Error(t, c.add(bad))
+	bad.ChunkIndex = 5
+	assert.Error(t, c.add(bad))
+
+	c.prune(ViewMsg(1, 1))
+	_, ok = c.assemble(preprepare)
+	assert.False(t, ok)
+}
+
+func TestTransition_AcceptState_Proposer_Chunks(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.ProposalChunkSize = 2
+	m.setState(AcceptState)
+	m.setProposal(&Proposal{
+		Data: mockProposal,
+		Hash: digest,
+	})
+
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence: 1,
+		outgoing: 4, // preprepare, 2 chunks and prepare
+		state:    ValidateState,
+	})
+	preprepare := m.respMsg[0]
+	assert.Equal(t, MessageReq_Preprepare, preprepare.Type)
+	assert.Equal(t, uint32(2), preprepare.ChunkCount)
+	assert.Empty(t, preprepare.Proposal)
+
+	data := []byte{}
+	for _, msg := range m.respMsg[1:3] {
+		assert.Equal(t, MessageReq_ProposalChunk, msg.Type)
+		data = append(data, msg.Proposal...)
+	}
+	assert.Equal(t, mockProposal, data)
+}
+
+func TestTransition_AcceptState_Validator_Chunks(t *testing.T) {
+	i := newMockPbft(t, []string{"A", "B", "C"}, "B")
+	i.state.view = ViewMsg(1, 0)
+	i.setState(AcceptState)
+
+	i.emitMsg(&MessageReq{
+		From:       "A",
+		Type:       MessageReq_Preprepare,
+		View:       ViewMsg(1, 0),
+		ChunkCount: 2,
+	})
+	for _, msg := range proposalChunkMsgs("A", ViewMsg(1, 0), digest, splitProposal(mockProposal, 2)) {
+		i.emitMsg(msg)
+	}
+
+	i.runCycle(context.Background())
+
+	i.expect(expectResult{
+		sequence: 1,
+		state:    ValidateState,
+		outgoing: 1, // prepare
+	})
+	assert.Equal(t, mockProposal, i.state.proposal.Data)
+}
+
+func TestTransition_AcceptState_Validator_ChunksTimeout(t *testing.T) {
+	i := newMockPbft(t, []string{"A", "B", "C"}, "B")
+	i.state.view = ViewMsg(1, 0)
+	i.setState(AcceptState)
+
+	i.emitMsg(&MessageReq{
+		From:       "A",
+		Type:       MessageReq_Preprepare,
+		View:       ViewMsg(1, 0),
+		ChunkCount: 2,
+	})
+	// only one of the chunks is received
+	i.emitMsg(proposalChunkMsgs("A", ViewMsg(1, 0), digest, splitProposal(mockProposal, 2))[0])
+
+	i.runCycle(context.Background())
+
+	i.expect(expectResult{
+		sequence: 1,
+		state:    RoundChangeState,
+	})
+}
@@ -0,0 +1,314 @@
+package pbft
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtocolVersion is the version of the message protocol exchanged in the handshake
+const ProtocolVersion = 1
+
+const (
+	// CodecJSON encodes the messages as JSON
+	CodecJSON = "json"
+
+	// CodecProtobuf encodes the messages with protobuf, see messages.proto
+	CodecProtobuf = "protobuf"
+)
+
+// Codec encodes and decodes the messages exchanged with the peers. Decoding an encoded
+// message must return the message in canonical form (see canonicalMessage), a codec
+// that loses or alters any other field must fail the encoding instead
+type Codec interface {
+	// Name is the identifier of the codec used in the handshake
+	Name() string
+
+	// Encode encodes the message
+	Encode(msg *MessageReq) ([]byte, error)
+
+	// Decode decodes the message
+	Decode(data []byte) (*MessageReq, error)
+}
+
+// Handshake is exchanged by the transport with every peer on connection, so that
+// nodes speaking different encodings can coexist during a rolling upgrade
+type Handshake struct {
+	// Version is the protocol version of the node
+	Version uint32
+
+	// Codecs are the codecs supported by the node in order of preference
+	Codecs []string
+}
+
+func WithCodecs(codecs ...Codec) ConfigOption {
+	return func(c *Config) {
+		c.Codecs = codecs
+	}
+}
+
+// Handshake returns the handshake of the local node, to be sent by the transport to its peers
+func (p *Pbft) Handshake() *Handshake {
+	h := &Handshake{
+		Version: ProtocolVersion,
+		Codecs:  []string{},
+	}
+	for _, codec := range p.config.Codecs {
+		h.Codecs = append(h.Codecs, codec.Name())
+	}
+	return h
+}
+
+// NegotiateCodec returns the codec to use with the peer that sent the handshake:
+// the first codec in the local order of preference that is also supported by the peer
+func (p *Pbft) NegotiateCodec(remote *Handshake) (Codec, error) {
+	if remote.Version != ProtocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d", remote.Version)
+	}
+	for _, codec := range p.config.Codecs {
+		for _, name := range remote.Codecs {
+			if codec.Name() == name {
+				return codec, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no common codec: local=%v, remote=%v", p.Handshake().Codecs, remote.Codecs)
+}
+
+// canonicalMessage returns the message with the empty byte slices and committed seals
+// set to nil. The codecs do not distinguish between nil and empty values
+func canonicalMessage(msg *MessageReq) *MessageReq {
+	msg = msg.Copy()
+	msg.Seal = nilIfEmpty(msg.Seal)
+	msg.Hash = nilIfEmpty(msg.Hash)
+	msg.Proposal = nilIfEmpty(msg.Proposal)
+	if len(msg.CommittedSeals) == 0 {
+		msg.CommittedSeals = nil
+	}
+	for i := range msg.CommittedSeals {
+		msg.CommittedSeals[i].Seal = nilIfEmpty(msg.CommittedSeals[i].Seal)
+	}
+	return msg
+}
+
+func nilIfEmpty(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+// JSONCodec encodes the messages as JSON
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string {
+	return CodecJSON
+}
+
+func (JSONCodec) Encode(msg *MessageReq) ([]byte, error) {
+	// json replaces the invalid utf8 sequences of the strings
+	if !utf8.ValidString(string(msg.From)) {
+		return nil, fmt.Errorf("sender is not valid utf8: %q", msg.From)
+	}
+	for _, seal := range msg.CommittedSeals {
+		if !utf8.ValidString(string(seal.Signer)) {
+			return nil, fmt.Errorf("seal signer is not valid utf8: %q", seal.Signer)
+		}
+	}
+	return json.Marshal(msg)
+}
+
+func (JSONCodec) Decode(data []byte) (*MessageReq, error) {
+	msg := &MessageReq{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return canonicalMessage(msg), nil
+}
+
+// ProtoCodec encodes the messages with the protobuf schema of messages.proto. The encoding
+// is the one of the standard protobuf marshalers: the fields in order of their number and
+// the zero values omitted. Only that canonical encoding is accepted by Decode
+type ProtoCodec struct{}
+
+func (ProtoCodec) Name() string {
+	return CodecProtobuf
+}
+
+func (ProtoCodec) Encode(msg *MessageReq) ([]byte, error) {
+	if msg.View == nil {
+		return nil, errViewMissing
+	}
+	var view []byte
+	view = appendProtoVarint(view, 1, msg.View.Sequence)
+	view = appendProtoVarint(view, 2, msg.View.Round)
+	view = appendProtoVarint(view, 3, msg.View.Term)
+
+	var b []byte
+	b = appendProtoVarint(b, 1, uint64(msg.Type))
+	b = appendProtoBytes(b, 2, []byte(msg.From))
+	b = appendProtoBytes(b, 3, msg.Seal)
+	b = appendProtoMessage(b, 4, view)
+	b = appendProtoBytes(b, 5, msg.Hash)
+	b = appendProtoBytes(b, 6, msg.Proposal)
+	b = appendProtoVarint(b, 7, msg.ChainID)
+	b = appendProtoVarint(b, 8, uint64(msg.ChunkCount))
+	b = appendProtoVarint(b, 9, uint64(msg.ChunkIndex))
+	for _, seal := range msg.CommittedSeals {
+		var s []byte
+		s = appendProtoBytes(s, 1, []byte(seal.Signer))
+		s = appendProtoBytes(s, 2, seal.Seal)
+		b = appendProtoMessage(b, 10, s)
+	}
+	b = appendProtoVarint(b, 11, uint64(msg.RoundChangeReason))
+	return b, nil
+}
+
+func (c ProtoCodec) Decode(data []byte) (*MessageReq, error) {
+	msg := &MessageReq{}
+	err := consumeProto(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case typ == protowire.VarintType && num != 4 && num != 10:
+			v, n := protowire.ConsumeVarint(b)
+			switch num {
+			case 1:
+				msg.Type = MsgType(v)
+			case 7:
+				msg.ChainID = v
+			case 8:
+				msg.ChunkCount = uint32(v)
+			case 9:
+				msg.ChunkIndex = uint32(v)
+			case 11:
+				msg.RoundChangeReason = RoundChangeReason(v)
+			}
+			return n, nil
+		case typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			switch num {
+			case 2:
+				msg.From = NodeID(v)
+			case 3:
+				msg.Seal = copyBytes(v)
+			case 4:
+				msg.View = &View{}
+				return n, decodeProtoView(msg.View, v)
+			case 5:
+				msg.Hash = copyBytes(v)
+			case 6:
+				msg.Proposal = copyBytes(v)
+			case 10:
+				seal, err := decodeProtoSeal(v)
+				msg.CommittedSeals = append(msg.CommittedSeals, seal)
+				return n, err
+			}
+			return n, nil
+		}
+		// unknown fields are skipped and rejected as not canonical below
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message: %v", err)
+	}
+	encoded, err := c.Encode(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message: %v", err)
+	}
+	if !bytes.Equal(encoded, data) {
+		return nil, fmt.Errorf("failed to decode message: not canonical")
+	}
+	return msg, nil
+}
+
+func decodeProtoView(view *View, data []byte) error {
+	return consumeProto(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.VarintType {
+			return protowire.ConsumeFieldValue(num, typ, b), nil
+		}
+		v, n := protowire.ConsumeVarint(b)
+		switch num {
+		case 1:
+			view.Sequence = v
+		case 2:
+			view.Round = v
+		case 3:
+			view.Term = v
+		}
+		return n, nil
+	})
+}
+
+func decodeProtoSeal(data []byte) (CommittedSeal, error) {
+	seal := CommittedSeal{}
+	err := consumeProto(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.BytesType {
+			return protowire.ConsumeFieldValue(num, typ, b), nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		switch num {
+		case 1:
+			seal.Signer = NodeID(v)
+		case 2:
+			seal.Seal = copyBytes(v)
+		}
+		return n, nil
+	})
+	return seal, err
+}
+
+// consumeProto calls fn with the value of every field of the encoded message,
+// fn returns the length of the value or a negative protowire error code
+func consumeProto(data []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		n, err := fn(num, typ, data)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// appendProtoVarint appends the field unless it is zero, as proto3 does for the scalars
+func appendProtoVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendProtoBytes appends the field unless it is empty, as proto3 does for the scalars
+func appendProtoBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendProtoMessage(b, num, v)
+}
+
+// appendProtoMessage appends the encoded message, which is present even if empty
+func appendProtoMessage(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func copyBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
@@ -0,0 +1,294 @@
+package pbft
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	msg := &MessageReq{
+		Type:     MessageReq_Commit,
+		From:     "A",
+		Seal:     []byte{0x1, 0x2},
+		View:     &View{Sequence: 10, Round: 2, Term: 1},
+		Hash:     digest,
+		Proposal: mockProposal,
+		ChainID:  100,
+		CommittedSeals: []CommittedSeal{
+			{Signer: "B", Seal: []byte{0x3}},
+		},
+	}
+
+	for _, codec := range []Codec{JSONCodec{}, ProtoCodec{}} {
+		data, err := codec.Encode(msg)
+		assert.NoError(t, err, codec.Name())
+
+		decoded, err := codec.Decode(data)
+		assert.NoError(t, err, codec.Name())
+		assert.Equal(t, msg, decoded, codec.Name())
+	}
+}
+
+func TestCodec_RoundChangeReason(t *testing.T) {
+	msg := &MessageReq{
+		Type:              MessageReq_RoundChange,
+		From:              "A",
+		View:              ViewMsg(1, 2),
+		RoundChangeReason: RoundChangeInvalidProposal,
+	}
+	for _, codec := range []Codec{JSONCodec{}, ProtoCodec{}} {
+		data, err := codec.Encode(msg)
+		assert.NoError(t, err, codec.Name())
+
+		decoded, err := codec.Decode(data)
+		assert.NoError(t, err, codec.Name())
+		assert.Equal(t, msg, decoded, codec.Name())
+	}
+
+	// the encoding of the messages without a reason is unchanged
+	withReason, err := ProtoCodec{}.Encode(msg)
+	assert.NoError(t, err)
+	msg.RoundChangeReason = RoundChangeUnknown
+	withoutReason, err := ProtoCodec{}.Encode(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, withoutReason, withReason[:len(withReason)-2])
+}
+
+func TestCodec_ProtoInvalid(t *testing.T) {
+	codec := ProtoCodec{}
+
+	_, err := codec.Encode(&MessageReq{Type: MessageReq_Commit})
+	assert.ErrorIs(t, err, errViewMissing)
+
+	data, err := codec.Encode(&MessageReq{Type: MessageReq_Commit, From: "A", View: ViewMsg(1, 0)})
+	assert.NoError(t, err)
+
+	// truncated
+	_, err = codec.Decode(data[:len(data)-1])
+	assert.Error(t, err)
+
+	// trailing bytes
+	_, err = codec.Decode(append(data, 0x1))
+	assert.Error(t, err)
+
+	// unknown field
+	_, err = codec.Decode(append(data, 0x60, 0x1))
+	assert.Error(t, err)
+
+	// without view
+	_, err = codec.Decode([]byte{0x8, 0x1})
+	assert.Error(t, err)
+}
+
+func TestCodec_Negotiate(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+
+	// default preference is protobuf with fallback to json
+	assert.Equal(t, &Handshake{Version: ProtocolVersion, Codecs: []string{CodecProtobuf, CodecJSON}}, m.Handshake())
+
+	codec, err := m.NegotiateCodec(&Handshake{Version: ProtocolVersion, Codecs: []string{CodecJSON, CodecProtobuf}})
+	assert.NoError(t, err)
+	assert.Equal(t, CodecProtobuf, codec.Name())
+
+	// legacy peer only speaking json
+	codec, err = m.NegotiateCodec(&Handshake{Version: ProtocolVersion, Codecs: []string{CodecJSON}})
+	assert.NoError(t, err)
+	assert.Equal(t, CodecJSON, codec.Name())
+
+	_, err = m.NegotiateCodec(&Handshake{Version: ProtocolVersion, Codecs: []string{"cbor"}})
+	assert.Error(t, err)
+
+	_, err = m.NegotiateCodec(&Handshake{Version: ProtocolVersion + 1, Codecs: []string{CodecJSON}})
+	assert.Error(t, err)
+}
+
+type mockCodecTransport struct {
+	handshake *Handshake
+}
+
+func (m *mockCodecTransport) Gossip(msg *MessageReq) error {
+	return nil
+}
+
+func (m *mockCodecTransport) SetHandshake(h *Handshake) {
+	m.handshake = h
+}
+
+func TestCodec_TransportHandshake(t *testing.T) {
+	pool := newTesterAccountPool()
+	pool.add("A")
+
+	transport := &mockCodecTransport{}
+	New(pool.get("A"), transport, WithCodecs(JSONCodec{}))
+
+	assert.Equal(t, &Handshake{Version: ProtocolVersion, Codecs: []string{CodecJSON}}, transport.handshake)
+}
+
+func TestCodec_Canonical(t *testing.T) {
+	msg := &MessageReq{
+		Type:           MessageReq_Commit,
+		From:           "A",
+		View:           ViewMsg(1, 0),
+		Seal:           []byte{},
+		Hash:           []byte{},
+		CommittedSeals: []CommittedSeal{},
+	}
+	expected := &MessageReq{Type: MessageReq_Commit, From: "A", View: ViewMsg(1, 0)}
+	for _, codec := range []Codec{JSONCodec{}, ProtoCodec{}} {
+		data, err := codec.Encode(msg)
+		assert.NoError(t, err, codec.Name())
+
+		decoded, err := codec.Decode(data)
+		assert.NoError(t, err, codec.Name())
+		assert.Equal(t, expected, decoded, codec.Name())
+	}
+}
+
+func TestCodec_NonCanonicalProto(t *testing.T) {
+	codec := ProtoCodec{}
+
+	data, err := codec.Encode(&MessageReq{Type: MessageReq_RoundChange, From: "A", View: ViewMsg(1, 0)})
+	assert.NoError(t, err)
+	_, err = codec.Decode(data)
+	assert.NoError(t, err)
+
+	// explicit unknown reason
+	_, err = codec.Decode(append(data, 0x58, 0x0))
+	assert.Error(t, err)
+
+	// the view before the sender
+	_, err = codec.Decode([]byte{0x22, 0x2, 0x8, 0x1, 0x12, 0x1, 'A'})
+	assert.Error(t, err)
+
+	// sequence encoded with a non minimal varint
+	_, err = codec.Decode([]byte{0x12, 0x1, 'A', 0x22, 0x3, 0x8, 0x81, 0x0})
+	assert.Error(t, err)
+
+	// chunk index out of the range of uint32
+	_, err = codec.Decode(append(append([]byte{}, data...), 0x48, 0x80, 0x80, 0x80, 0x80, 0x10))
+	assert.Error(t, err)
+}
+
+func TestCodec_JSONInvalidUTF8(t *testing.T) {
+	_, err := JSONCodec{}.Encode(&MessageReq{Type: MessageReq_Prepare, From: "\xff", View: ViewMsg(1, 0)})
+	assert.Error(t, err)
+
+	_, err = JSONCodec{}.Encode(&MessageReq{Type: MessageReq_Committed, From: "A", View: ViewMsg(1, 0), CommittedSeals: []CommittedSeal{{Signer: "\xff"}}})
+	assert.Error(t, err)
+
+	// the protobuf codec keeps the bytes
+	msg := &MessageReq{Type: MessageReq_Prepare, From: "\xff", View: ViewMsg(1, 0)}
+	data, err := ProtoCodec{}.Encode(msg)
+	assert.NoError(t, err)
+	decoded, err := ProtoCodec{}.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, decoded)
+}
+
+// protoMessageDescriptor builds the descriptor of the MessageReq of messages.proto
+func protoMessageDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, message string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(num),
+			Type:     typ.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if message != "" {
+			f.TypeName = proto.String(".pbft." + message)
+		}
+		return f
+	}
+	const (
+		varint32  = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		uvarint   = descriptorpb.FieldDescriptorProto_TYPE_UINT64
+		uvarint32 = descriptorpb.FieldDescriptorProto_TYPE_UINT32
+		bytes     = descriptorpb.FieldDescriptorProto_TYPE_BYTES
+		message   = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	seals := field("committed_seals", 10, message, "CommittedSeal")
+	seals.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("messages.proto"),
+		Package: proto.String("pbft"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("MessageReq"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("type", 1, varint32, ""),
+					field("from", 2, bytes, ""),
+					field("seal", 3, bytes, ""),
+					field("view", 4, message, "View"),
+					field("hash", 5, bytes, ""),
+					field("proposal", 6, bytes, ""),
+					field("chain_id", 7, uvarint, ""),
+					field("chunk_count", 8, uvarint32, ""),
+					field("chunk_index", 9, uvarint32, ""),
+					seals,
+					field("round_change_reason", 11, uvarint, ""),
+				},
+			},
+			{
+				Name: proto.String("View"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("sequence", 1, uvarint, ""),
+					field("round", 2, uvarint, ""),
+					field("term", 3, uvarint, ""),
+				},
+			},
+			{
+				Name: proto.String("CommittedSeal"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("signer", 1, bytes, ""),
+					field("seal", 2, bytes, ""),
+				},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+	return file.Messages().ByName("MessageReq")
+}
+
+func TestCodec_ProtoInterop(t *testing.T) {
+	seed := time.Now().UnixNano()
+	g := &messageGenerator{r: rand.New(rand.NewSource(seed))}
+	desc := protoMessageDescriptor(t)
+	fields := desc.Fields()
+	view := fields.ByName("view").Message().Fields()
+
+	for i := 0; i < 1000; i++ {
+		msg := g.message(g.r.Intn(2) == 0)
+		data, err := ProtoCodec{}.Encode(msg)
+		require.NoError(t, err)
+
+		// the standard marshaler decodes the same values and encodes the same bytes
+		dynamic := dynamicpb.NewMessage(desc)
+		require.NoError(t, proto.Unmarshal(data, dynamic), "seed=%d, iteration=%d", seed, i)
+		assert.Equal(t, int64(msg.Type), dynamic.Get(fields.ByName("type")).Int())
+		assert.Equal(t, string(msg.From), string(dynamic.Get(fields.ByName("from")).Bytes()))
+		assert.Equal(t, msg.ChainID, dynamic.Get(fields.ByName("chain_id")).Uint())
+		assert.Equal(t, msg.View.Term, dynamic.Get(fields.ByName("view")).Message().Get(view.ByName("term")).Uint())
+		assert.Equal(t, len(msg.CommittedSeals), dynamic.Get(fields.ByName("committed_seals")).List().Len())
+
+		standard, err := proto.MarshalOptions{Deterministic: true}.Marshal(dynamic)
+		require.NoError(t, err)
+		if !assert.Equal(t, data, standard, "seed=%d, iteration=%d", seed, i) {
+			return
+		}
+		decoded, err := ProtoCodec{}.Decode(standard)
+		require.NoError(t, err)
+		assert.Equal(t, canonicalMessage(msg), decoded)
+	}
+}
@@ -3,11 +3,16 @@ package pbft
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/0xPolygon/pbft-consensus/validation"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -31,6 +36,120 @@ type Config struct {
 
 	// RoundTimeout is a function that calculates timeout based on a round number
 	RoundTimeout RoundTimeout
+
+	// ChainID is the identifier of the network. Messages with a different
+	// chain id are dropped
+	ChainID uint64
+
+	// RecordSink receives the state transitions and the processed messages
+	RecordSink RecordSink
+
+	// RoundStateExporter receives the round state every RoundStateInterval
+	RoundStateExporter RoundStateExporter
+
+	// RoundStateInterval is the interval to export the round state
+	RoundStateInterval time.Duration
+
+	// EventHandler receives the events emitted by the engine
+	EventHandler EventHandler
+
+	// ProtocolViolationHandler receives the messages rejected for structural reasons
+	ProtocolViolationHandler ProtocolViolationHandler
+
+	// MaxRound is the maximum round for a sequence. Once it is exceeded,
+	// the engine moves to the sync state. Zero means no limit
+	MaxRound uint64
+
+	// ProposalChunkSize is the maximum size of the proposal sent in the preprepare message.
+	// Bigger proposals are streamed in chunks of this size. Zero disables the chunking
+	ProposalChunkSize int
+
+	// CommitRelay enables the broadcast of the aggregated commit seals once the
+	// commit quorum is reached, so lagging nodes can finalize from a single message
+	CommitRelay bool
+
+	// DevMode enables the support for development networks with less than 4 validators.
+	// A single validator finalizes its proposals right away, while 2 and 3 validators
+	// require every validator to agree (see DevQuorumSize)
+	DevMode bool
+
+	// FastPath enables the optimistic path for round 0, where the prepare messages carry the commit
+	// seal and count as commit messages, so a validator finalizes as soon as it reaches the prepare
+	// quorum instead of waiting for the commit messages. Since its prepare may finalize the proposal,
+	// a validator locks when it sends it, which assumes a trusted proposer in round 0. Without a
+	// quorum of sealed prepares the standard flow is followed
+	FastPath bool
+
+	// Notifier is notified about the timeouts and can postpone them
+	Notifier StateNotifier
+
+	// CommitRetention is the number of finalized heights whose commit messages
+	// are kept in memory for the lagging peers. Zero disables the retention
+	CommitRetention int
+
+	// Codecs are the message codecs supported by the node in order of preference,
+	// negotiated with every peer through the handshake (see Pbft.NegotiateCodec)
+	Codecs []Codec
+
+	// StatusInterval is the interval to announce the view of the node to the peers,
+	// used to detect lagging nodes (see Pbft.SyncHint). Zero disables the announcements
+	StatusInterval time.Duration
+
+	// RoundRobinProposer makes the engine select the proposer itself (see WithRoundRobinProposer)
+	// instead of the CalcProposer of the validator set
+	RoundRobinProposer bool
+
+	// MemoryBudget is the maximum memory in bytes retained for the messages of the peers
+	// (see WithMemoryBudget). Zero disables the bound
+	MemoryBudget uint64
+
+	// LivenessWindow is the number of heights without signing after which a validator is
+	// deprioritized in the proposer selection (see WithLivenessRotation). Zero disables it
+	LivenessWindow uint64
+
+	// MaxValidators is the maximum size of the validator set accepted by SetBackend.
+	// Zero means no limit
+	MaxValidators int
+
+	// FinalityHistory is the number of finalized heights kept in memory with the rounds
+	// and the time it took to finalize them (see Pbft.FinalityHistory)
+	FinalityHistory int
+
+	// MessageStore keeps the inbound messages (see MessageStore). Nil (the default) does not store them
+	MessageStore MessageStore
+
+	// EvidenceStore persists the evidence pool (see WithEvidenceStore). Nil keeps it in memory only
+	EvidenceStore MessageStore
+
+	// ProposalRequestRetries is the number of requests for a missing preprepare per round
+	// (see WithProposalRequests). Zero disables the requests
+	ProposalRequestRetries int
+
+	// ProposalRequestInterval is the interval between the requests for a missing preprepare
+	ProposalRequestInterval time.Duration
+
+	// MinRoundDuration is the minimum time between the start of a height and the insertion
+	// of its proposal (see WithMinRoundDuration). Zero disables the pacing
+	MinRoundDuration time.Duration
+
+	// HeartbeatInterval is the interval of the HeartbeatEvent while a round is in progress.
+	// Zero disables the heartbeat
+	HeartbeatInterval time.Duration
+
+	// StrictMode is the handling of the messages that fail the MessageVerifier or
+	// miss required fields (see WithStrictMode)
+	StrictMode StrictMode
+
+	// MessageVerifier authenticates the messages of the peers in strict mode
+	MessageVerifier MessageVerifier
+
+	// DemotionPolicy is the behavior of the engine when the local node is removed
+	// from the validator set
+	DemotionPolicy DemotionPolicy
+
+	// FinalityGadget votes on the candidates submitted with SubmitCandidate instead of
+	// the proposals built by the backend
+	FinalityGadget bool
 }
 
 type ConfigOption func(*Config)
@@ -65,6 +184,36 @@ func WithRoundTimeout(roundTimeout RoundTimeout) ConfigOption {
 	}
 }
 
+func WithMaxRound(maxRound uint64) ConfigOption {
+	return func(c *Config) {
+		c.MaxRound = maxRound
+	}
+}
+
+func WithCommitRelay(enabled bool) ConfigOption {
+	return func(c *Config) {
+		c.CommitRelay = enabled
+	}
+}
+
+func WithDevMode(enabled bool) ConfigOption {
+	return func(c *Config) {
+		c.DevMode = enabled
+	}
+}
+
+func WithFastPath(enabled bool) ConfigOption {
+	return func(c *Config) {
+		c.FastPath = enabled
+	}
+}
+
+func WithChainID(chainID uint64) ConfigOption {
+	return func(c *Config) {
+		c.ChainID = chainID
+	}
+}
+
 const (
 	defaultTimeout     = 2 * time.Second
 	maxTimeout         = 300 * time.Second
@@ -78,6 +227,8 @@ func DefaultConfig() *Config {
 		Logger:          log.New(os.Stderr, "", log.LstdFlags),
 		Tracer:          trace.NewNoopTracerProvider().Tracer(""),
 		RoundTimeout:    exponentialTimeout,
+		Codecs:          []Codec{ProtoCodec{}, JSONCodec{}},
+		FinalityHistory: defaultFinalityHistory,
 	}
 }
 
@@ -92,6 +243,34 @@ type SealedProposal struct {
 	CommittedSeals [][]byte
 	Proposer       NodeID
 	Number         uint64
+
+	// Round is the round in which the proposal was finalized
+	Round uint64
+}
+
+// TermBackend is an optional interface implemented by the backends that identify
+// each validator set membership with a term (epoch) number
+type TermBackend interface {
+	// Term returns the term of the validator set for the current height
+	Term() uint64
+}
+
+// AsyncInsertBackend is an optional interface implemented by the backends that persist
+// the sealed proposals asynchronously. The engine moves to the next height right away,
+// but it does not sign anything for it until the insertion is acknowledged
+type AsyncInsertBackend interface {
+	// InsertAsync starts the insertion of the sealed proposal and returns a channel
+	// that receives the result of the insertion (nil on success)
+	InsertAsync(pp *SealedProposal) <-chan error
+}
+
+// ReproposalBackend is an optional interface implemented by the backends that need to
+// veto the re-proposal of a locked proposal in a later round (e.g. time-sensitive payloads)
+type ReproposalBackend interface {
+	// ShouldAcceptReproposal returns whether the locked proposal can be proposed again in the round.
+	// It is only called on the proposer of the round. If it returns false, the proposer builds a fresh
+	// proposal if a quorum of round change messages shows that no validator committed the locked one
+	ShouldAcceptReproposal(proposal *Proposal, round uint64) bool
 }
 
 type Backend interface {
@@ -125,6 +304,22 @@ type RoundInfo struct {
 	IsProposer bool
 	Proposer   NodeID
 	Locked     bool
+
+	// View is the view of the round
+	View *View
+
+	// Quorum is the number of messages required to reach the quorum, zero for the weighted
+	// validator sets (see VotingPowerSet)
+	Quorum int
+
+	// QuorumPower is the voting power required to reach the quorum with a weighted validator set
+	QuorumPower *big.Int
+
+	// LockedHash is the hash of the locked proposal, if any
+	LockedHash []byte
+
+	// StartTime is the time when the round started
+	StartTime time.Time
 }
 
 // Pbft represents the PBFT consensus mechanism object
@@ -138,6 +333,9 @@ type Pbft struct {
 	// inter is the interface with the runtime
 	backend Backend
 
+	// backendLock guards the updates of the backend for the readers outside of the state machine loop
+	backendLock sync.RWMutex
+
 	// state is the reference to the current state machine
 	state *currentState
 
@@ -163,6 +361,91 @@ type Pbft struct {
 	roundTimeout RoundTimeout
 
 	forceTimeoutCh bool
+
+	// stats collects the counters about the engine activity
+	stats *statsCollector
+
+	// evidence stores the proofs of equivocation of other validators
+	evidence *evidencePool
+
+	// quarantine is the set of validators whose messages are ignored
+	quarantine *quarantine
+
+	// health tracks the progress and the errors of the state machine
+	health *healthTracker
+
+	// running is set while the state machine loop is running or the engine is being reset
+	// (see runRunning and runResetting)
+	running uint64
+
+	// round is the trace of the current round
+	round *roundTrace
+
+	// recorder forwards the engine activity to the record sink
+	recorder *recorder
+
+	// roundState holds the last published snapshot of the round
+	roundState *roundStatePublisher
+
+	// chunks reassembles the proposals streamed in chunks
+	chunks *chunkBuffer
+
+	// commits keeps the commit messages of the last finalized heights
+	commits *commitStore
+
+	// status tracks the status announced by the validators
+	status *statusTracker
+
+	// built is the proposal built by this node in the current height, until its outcome is notified
+	built *builtProposal
+
+	// quorumSeals holds the commit seals of the last proposal that reached the quorum
+	quorumSeals *quorumSealsHolder
+
+	// syncFinality collects the commit quorums of the heights seen in the SyncState
+	syncFinality *syncFinality
+
+	// sessions caches the identities of the session keys (see SessionKeyBackend)
+	sessions *sessionKeys
+
+	// finality keeps the rounds and the duration of the last finalized heights
+	finality *finalityHistory
+
+	// timer is the timer of the state machine loop
+	timer *loopTimer
+
+	// proposalRequests serves and requests the preprepare of the current view
+	proposalRequests *proposalRequests
+
+	// candidates are the candidates submitted in the finality gadget mode
+	candidates *candidateTracker
+
+	// heartbeat ticks the HeartbeatEvent while the state machine loop runs, if enabled
+	heartbeat *time.Ticker
+
+	// storeLoaded is set once the stored messages are loaded in the queue
+	storeLoaded bool
+
+	// heightStart is the time the node started the current height
+	heightStart time.Time
+
+	// pendingInsert receives the result of the asynchronous insertion of the last
+	// finalized proposal, if it was not acknowledged yet
+	pendingInsert <-chan error
+
+	// isValidator is set while the local node is a member of the validator set,
+	// to detect its removal (see DemotionPolicy)
+	isValidator bool
+
+	// lastValidators are the sorted members of the last validator set, nil if not listable
+	lastValidators []NodeID
+
+	// lastFinalized is the sequence finalized by the last run, if finalizedRun is set
+	lastFinalized uint64
+	finalizedRun  bool
+
+	// signedView is the highest view of the messages signed by this node, nil if none
+	signedView *View
 }
 
 type SignKey interface {
@@ -184,28 +467,131 @@ func New(validator SignKey, transport Transport, opts ...ConfigOption) *Pbft {
 		config:       config,
 		logger:       config.Logger,
 		tracer:       config.Tracer,
-		roundTimeout: config.RoundTimeout,
+		roundTimeout: safeRoundTimeout(config.RoundTimeout),
+		stats:        newStatsCollector(),
+		evidence:     newEvidencePool(),
+		quarantine:   newQuarantine(),
+		health:       newHealthTracker(),
+		recorder:     newRecorder(config.RecordSink),
+		roundState:   &roundStatePublisher{},
+		chunks:       newChunkBuffer(),
+		commits:      newCommitStore(config.CommitRetention),
+		status:       newStatusTracker(),
+		quorumSeals:  &quorumSealsHolder{},
+		syncFinality: newSyncFinality(),
+		sessions:     newSessionKeys(),
+		finality:     newFinalityHistory(config.FinalityHistory),
+		timer:        &loopTimer{},
+
+		proposalRequests: newProposalRequests(),
+		candidates:       newCandidateTracker(),
+	}
+	p.state.devMode = config.DevMode
+	p.applyMemoryBudget(config.MemoryBudget)
+	p.loadEvidence()
+	if codecTransport, ok := transport.(CodecTransport); ok {
+		codecTransport.SetHandshake(p.Handshake())
 	}
 
 	p.logger.Printf("[INFO] validator key: addr=%s\n", p.validator.NodeID())
 	return p
 }
 
+// SetBackend sets the backend for the next height. It returns an OverlappingRunError if Run is
+// running, but it is safe to push messages and to call the getters concurrently
 func (p *Pbft) SetBackend(backend Backend) error {
+	if !atomic.CompareAndSwapUint64(&p.running, runIdle, runResetting) {
+		return &OverlappingRunError{Sequence: p.state.getView().Sequence, Reason: "backend set while running"}
+	}
+	defer atomic.StoreUint64(&p.running, runIdle)
+
+	return p.setBackend(backend)
+}
+
+// setBackend sets the backend and resets the engine at its height
+func (p *Pbft) setBackend(backend Backend) error {
+	p.backendLock.Lock()
 	p.backend = backend
+	p.backendLock.Unlock()
 
 	// set the next current sequence for this iteration
-	p.setSequence(p.backend.Height())
+	var height uint64
+	if err := p.guard("Height", func() { height = p.backend.Height() }); err != nil {
+		return err
+	}
+	p.setSequence(height)
 
 	// set the current set of validators
-	p.state.validators = p.backend.ValidatorSet()
+	var validators ValidatorSet
+	if err := p.guard("ValidatorSet", func() { validators = p.backend.ValidatorSet() }); err != nil {
+		return err
+	}
+	if _, ok := validators.(ValidatorLister); p.config.RoundRobinProposer && !ok {
+		return errRoundRobinNotListable
+	}
+	var indexed ValidatorSet
+	var size int
+	if err := p.guard("ValidatorSet", func() {
+		indexed = newIndexedValidatorSet(validators)
+		size = indexed.Len()
+	}); err != nil {
+		return err
+	}
+	if p.config.MaxValidators > 0 && size > p.config.MaxValidators {
+		return fmt.Errorf("%w: size=%d, max=%d", errTooManyValidators, size, p.config.MaxValidators)
+	}
+	// the validator set is called for every message, a panic faults the engine like the backend ones
+	p.state.setValidators(&guardedValidatorSet{ValidatorSet: indexed, p: p})
+	p.trackValidatorSet()
+	if size < 4 && !p.config.DevMode {
+		p.logger.Printf("[WARN] validator set of size %d cannot tolerate faults, consider the dev mode", size)
+	}
+	p.loadMessages()
 
 	return nil
 }
 
-// start starts the PBFT consensus state machine
+var errBackendNotSet = fmt.Errorf("backend is not set")
+
+// getBackend returns the backend, safe for concurrent use with SetBackend
+func (p *Pbft) getBackend() Backend {
+	p.backendLock.RLock()
+	defer p.backendLock.RUnlock()
+
+	return p.backend
+}
+
+// Run runs the PBFT consensus state machine for the current sequence. A run that overlaps with
+// another one for the same sequence is refused and the OverlappingRunError is reported in Health
 func (p *Pbft) Run(ctx context.Context) {
+	if err := p.startRun(); err != nil {
+		p.refuseRun(err)
+		return
+	}
+	defer p.endRun()
+
 	p.ctx = ctx
+	defer p.timer.stop()
+
+	if p.config.RoundStateExporter != nil && p.config.RoundStateInterval > 0 {
+		exportCtx, cancelFn := context.WithCancel(ctx)
+		defer cancelFn()
+
+		go p.runRoundStateExporter(exportCtx)
+	}
+	if p.config.StatusInterval > 0 {
+		statusCtx, cancelFn := context.WithCancel(ctx)
+		defer cancelFn()
+
+		go p.runStatusGossip(statusCtx)
+	}
+	if p.config.HeartbeatInterval > 0 {
+		p.heartbeat = time.NewTicker(p.config.HeartbeatInterval)
+		defer func() {
+			p.heartbeat.Stop()
+			p.heartbeat = nil
+		}()
+	}
 
 	// the iteration always starts with the AcceptState.
 	// AcceptState stages will reset the rest of the message queues.
@@ -214,15 +600,25 @@ func (p *Pbft) Run(ctx context.Context) {
 	// start the trace span
 	spanCtx, span := p.tracer.Start(context.Background(), fmt.Sprintf("Sequence-%d", p.state.view.Sequence))
 	defer span.End()
+	defer p.endRoundSpan()
 
 	// loop until we reach the a finish state
-	for p.getState() != DoneState && p.getState() != SyncState {
+	for p.getState() != DoneState && p.getState() != FaultedState && p.getState() != DemotedState {
 		select {
 		case <-ctx.Done():
 			return
 		default:
 		}
 
+		if p.getState() == SyncState {
+			// the backends syncing on their own let the engine resume, the others
+			// sync in the outer loop of the embedder
+			if !p.waitForSync(ctx) {
+				return
+			}
+			continue
+		}
+
 		// Start the state machine loop
 		p.runCycle(spanCtx)
 	}
@@ -235,6 +631,12 @@ func (p *Pbft) runCycle(ctx context.Context) {
 		p.logger.Printf("[DEBUG] cycle: state=%s, sequence=%d, round=%d", p.getState(), p.state.view.Sequence, p.state.view.Round)
 	}
 
+	// every round starts with the AcceptState
+	if p.getState() == AcceptState {
+		p.startRoundSpan(ctx)
+	}
+	ctx = p.roundContext(ctx)
+
 	// Based on the current state, execute the corresponding section
 	switch p.getState() {
 	case AcceptState:
@@ -255,10 +657,95 @@ func (p *Pbft) runCycle(ctx context.Context) {
 }
 
 func (p *Pbft) setSequence(sequence uint64) {
-	p.state.view = &View{
+	if current := p.state.getView(); current == nil || current.Sequence != sequence {
+		p.heightStart = time.Now()
+	}
+	view := &View{
 		Round:    0,
 		Sequence: sequence,
 	}
+	if backend, ok := p.backend.(TermBackend); ok {
+		// on panic the view keeps the zero term, the engine is faulted anyway
+		_ = p.guard("Term", func() { view.Term = backend.Term() })
+	}
+	p.state.setView(view)
+	p.syncFinality.prune(sequence)
+	p.pruneMessages(sequence)
+
+	// the height changed without finalizing the proposal built by this node (i.e. sync)
+	if p.built != nil && p.built.sequence != sequence {
+		p.releaseBuilt()
+	}
+}
+
+// awaitPendingInsert waits for the acknowledgment of the asynchronous insertion of the previous
+// height, if any. If the insertion failed the engine moves to the sync state, since the
+// previous height is not persisted. It returns false if the state machine has to stop the accept state
+func (p *Pbft) awaitPendingInsert() bool {
+	if p.pendingInsert == nil {
+		return true
+	}
+
+	var err error
+	select {
+	case err = <-p.pendingInsert:
+	case <-p.ctx.Done():
+		return false
+	}
+	p.pendingInsert = nil
+
+	if err != nil {
+		p.logger.Printf("[ERROR] failed to insert proposal asynchronously. Error message: %v", err)
+		p.health.setErr(err)
+		p.setState(SyncState)
+		return false
+	}
+	return true
+}
+
+// checkReproposal consults the backend (if it implements ReproposalBackend) before the proposer
+// proposes the locked proposal again. If the lock expired (see lockExpired) or the backend vetoes
+// the re-proposal, the proposer builds a
+// fresh proposal only if the round change messages of the round justify it (see justifyFreshProposal),
+// since a quorum may have committed the locked proposal otherwise. The validators keep their lock
+// until they verify the justification. It returns the error if the backend panicked
+func (p *Pbft) checkReproposal(roundChanges map[NodeID]*MessageReq) error {
+	if !p.state.locked || p.state.proposer != p.selfID() {
+		return nil
+	}
+	round := p.state.view.Round
+	reason := "expired"
+	if !p.lockExpired() {
+		vetoed, err := p.reproposalVetoed(round)
+		if err != nil || !vetoed {
+			return err
+		}
+		reason = "vetoed by the backend"
+	}
+	justified, err := p.justifyFreshProposal(roundChanges)
+	if err != nil {
+		return err
+	}
+	if !justified {
+		p.logger.Printf("[WARN] locked proposal %s without a round change justification, proposing it again: round=%d", reason, round)
+		return nil
+	}
+	p.logger.Printf("[INFO] locked proposal %s, building a fresh proposal: round=%d", reason, round)
+	return nil
+}
+
+// reproposalVetoed checks whether the backend (if it implements ReproposalBackend) vetoes the
+// re-proposal of the locked proposal in the round. It returns the error if the backend panicked
+func (p *Pbft) reproposalVetoed(round uint64) (bool, error) {
+	backend, ok := p.backend.(ReproposalBackend)
+	if !ok {
+		return false, nil
+	}
+	accept := false
+	if err := p.guard("ShouldAcceptReproposal", func() { accept = backend.ShouldAcceptReproposal(p.state.proposal, round) }); err != nil {
+		return false, err
+	}
+	return !accept, nil
 }
 
 // runAcceptState runs the Accept state loop
@@ -272,24 +759,49 @@ func (p *Pbft) runAcceptState(ctx context.Context) { // start new round
 
 	p.logger.Printf("[INFO] accept state: sequence %d", p.state.view.Sequence)
 
-	if !p.state.validators.Includes(p.validator.NodeID()) {
-		// we are not a validator anymore, move back to sync state
-		p.logger.Print("[INFO] we are not a validator anymore")
-		p.setState(SyncState)
+	if !p.awaitPendingInsert() {
+		return
+	}
+
+	self := p.selfID()
+	if !p.checkMembership(self) {
 		return
 	}
 
-	// reset round messages
+	// reset round messages, the round change messages of the round may justify a fresh proposal
+	roundChanges := p.state.roundMessages[p.state.view.Round]
 	p.state.resetRoundMsgs()
-	p.state.CalcProposer()
+	p.pruneRoundMessages(p.state.view)
+	p.chunks.prune(p.state.view)
+	if err := p.calcProposer(); err != nil {
+		return
+	}
+	if err := p.checkReproposal(roundChanges); err != nil {
+		return
+	}
+	p.msgQueue.setProposer(p.state.view, p.state.proposer)
 
-	isProposer := p.state.proposer == p.validator.NodeID()
+	isProposer := p.state.proposer == self
+	p.traceProposer(p.state.proposer)
 
-	p.backend.Init(&RoundInfo{
+	info := &RoundInfo{
 		Proposer:   p.state.proposer,
 		IsProposer: isProposer,
 		Locked:     p.state.locked,
-	})
+		View:       p.state.view.Copy(),
+		StartTime:  time.Now(),
+	}
+	info.Quorum, info.QuorumPower = p.state.quorumSize()
+	if p.round != nil {
+		info.StartTime = p.round.start
+	}
+	if p.state.locked {
+		info.LockedHash = append([]byte{}, p.state.proposal.Hash...)
+	}
+	err := p.guard("Init", func() { p.backend.Init(info) })
+	if err != nil {
+		return
+	}
 
 	// log the current state of this span
 	span.SetAttributes(
@@ -298,31 +810,46 @@ func (p *Pbft) runAcceptState(ctx context.Context) { // start new round
 		attribute.String("proposer", string(p.state.proposer)),
 	)
 
-	var err error
-
 	if isProposer {
 		p.logger.Printf("[INFO] we are the proposer")
 
-		if !p.state.locked {
+		if !p.state.locked && p.config.FinalityGadget {
+			// the proposal is a candidate of the external block producer
+			if !p.proposeCandidate(span) {
+				return
+			}
+		} else if !p.state.locked {
 			// since the state is not locked, we need to build a new proposal
-			p.state.proposal, err = p.backend.BuildProposal()
-			if err != nil {
+			if err := p.buildProposal(); err != nil {
+				if errors.Is(err, errBackendPanic) {
+					return
+				}
 				p.logger.Printf("[ERROR] failed to build proposal: %v", err)
-				p.setState(RoundChangeState)
+				p.health.setErr(err)
+				p.roundChange(RoundChangeBackendError)
 				return
 			}
 
 			// calculate how much time do we have to wait to gossip the proposal
 			delay := time.Until(p.state.proposal.Time)
 
+			delayCh := p.timer.reset("ProposalDelay", delay)
 			select {
-			case <-time.After(delay):
+			case <-delayCh:
+				p.timer.stop()
 			case <-p.ctx.Done():
+				p.timer.stop()
 				return
 			}
 
 		}
 
+		if p.config.DevMode && p.state.validators.Len() == 1 {
+			// single node network, there is nobody to exchange messages with
+			p.finalizeAlone()
+			return
+		}
+
 		// send the preprepare message
 		p.sendPreprepareMsg()
 
@@ -341,6 +868,11 @@ func (p *Pbft) runAcceptState(ctx context.Context) { // start new round
 
 	timeout := p.roundTimeout(p.state.view.Round)
 
+	// request the preprepare from the peers if it does not arrive while they vote for it
+	requestCtx, cancelRequests := context.WithCancel(p.ctx)
+	defer cancelRequests()
+	go p.runProposalRequests(requestCtx, p.state.view.Copy())
+
 	// We only need to wait here for one type of message, the Prepare message from the proposer.
 	// However, since we can receive bad Prepare messages we have to wait (or timeout) until
 	// we get the message from the correct proposer.
@@ -350,35 +882,77 @@ func (p *Pbft) runAcceptState(ctx context.Context) { // start new round
 			return
 		}
 		if msg == nil {
-			p.setState(RoundChangeState)
+			p.roundChange(p.timeoutReason())
 			continue
 		}
 
 		// TODO: Validate that the fields required for Preprepare are set (Proposal and Hash)
 		if msg.From != p.state.proposer {
 			p.logger.Printf("[ERROR] msg received from wrong proposer: expected=%s, found=%s", p.state.proposer, msg.From)
+			p.traceMessage(span, msg, msgDiscarded)
 			continue
 		}
 
+		if msg.ChunkCount != 0 {
+			// the proposal is streamed in chunks, wait for all of them
+			data, ok := p.waitForChunks(span, msg, timeout)
+			if !ok {
+				p.traceMessage(span, msg, msgDiscarded)
+				p.roundChange(RoundChangeTimeout)
+				return
+			}
+			msg.Proposal = data
+		}
+
 		// retrieve the proposal, the backend MUST validate that the hash belongs to the proposal
 		proposal := &Proposal{
 			Data: msg.Proposal,
 			Hash: msg.Hash,
 		}
-		if err := p.backend.Validate(proposal); err != nil {
+		var validateErr error
+		if p.config.FinalityGadget {
+			// only the candidates submitted to this node are voted
+			if _, ok := p.waitForCandidate(span, msg.Hash, timeout); !ok {
+				if p.ctx.Err() != nil {
+					return
+				}
+				validateErr = fmt.Errorf("unknown candidate %x", msg.Hash)
+			}
+		} else if err := p.guard("Validate", func() { validateErr = p.backend.Validate(proposal) }); err != nil {
+			return
+		}
+		if err := validateErr; err != nil {
 			p.logger.Printf("[ERROR] failed to validate proposal. Error message: %v", err)
-			p.setState(RoundChangeState)
+			p.traceMessage(span, msg, msgDiscarded)
+			p.health.setErr(err)
+			p.roundChange(RoundChangeInvalidProposal)
 			return
 		}
+		p.traceMessage(span, msg, msgAccepted)
+		p.proposalRequests.setPreprepare(msg, msg.Proposal)
+
+		if p.state.locked && !p.state.proposal.Equal(proposal) {
+			// a different proposal releases the lock only with a round change justification
+			unlocked, err := p.unlockJustified(msg)
+			if err != nil {
+				return
+			}
+			if !unlocked {
+				p.handleStateErr(RoundChangeInvalidProposal, errIncorrectLockedProposal)
+				continue
+			}
+		}
 
 		if p.state.locked {
 			// the state is locked, we need to receive the same proposal
 			if p.state.proposal.Equal(proposal) {
-				// fast-track and send a commit message and wait for validations
+				// fast-track and send a commit message and wait for validations. The prepare
+				// is sent too, the validators that are not locked need a quorum of them to commit
+				p.sendPrepareMsg()
 				p.sendCommitMsg()
 				p.setState(ValidateState)
 			} else {
-				p.handleStateErr(errIncorrectLockedProposal)
+				p.handleStateErr(RoundChangeInvalidProposal, errIncorrectLockedProposal)
 			}
 		} else {
 			p.state.proposal = proposal
@@ -410,6 +984,9 @@ func (p *Pbft) runValidateState(ctx context.Context) { // start new round
 		}
 	}
 
+	// relayed signals whether the commit quorum was reached with an aggregated committed message
+	relayed := false
+
 	timeout := p.roundTimeout(p.state.view.Round)
 
 	for p.getState() == ValidateState {
@@ -423,41 +1000,83 @@ func (p *Pbft) runValidateState(ctx context.Context) { // start new round
 		}
 		if msg == nil {
 			// timeout
-			p.setState(RoundChangeState)
+			p.roundChange(RoundChangeTimeout)
 			span.End()
 			return
 		}
 
+		if msg.Type != MessageReq_Committed && !p.state.validators.Includes(msg.From) {
+			p.traceMessage(span, msg, msgDiscarded)
+			p.countDiscard(msg, DiscardNotValidator)
+			continue
+		}
+
+		// check whether the sender already sent a different message for this view
+		if prev := p.state.conflictingMessage(msg); prev != nil {
+			p.reportEquivocation(prev, msg)
+		}
+
 		// the message must have our local hash
 		if !bytes.Equal(msg.Hash, p.state.proposal.Hash) {
 			p.logger.Print(fmt.Sprintf("[WARN]: incorrect hash in %s message", msg.Type.String()))
+			p.traceMessage(span, msg, msgDiscarded)
+			continue
+		}
+		if p.state.hasMessage(msg) {
+			p.traceMessage(span, msg, msgDiscarded)
+			p.countDiscard(msg, DiscardDuplicate)
 			continue
 		}
 
 		switch msg.Type {
 		case MessageReq_Prepare:
 			p.state.addPrepared(msg)
+			if err := p.addFastCommit(msg); err != nil {
+				span.End()
+				return
+			}
 
 		case MessageReq_Commit:
-			if err := p.backend.ValidateCommit(msg.From, msg.Seal); err != nil {
+			var commitErr error
+			if err := p.guard("ValidateCommit", func() { commitErr = p.backend.ValidateCommit(msg.From, msg.Seal) }); err != nil {
+				span.End()
+				return
+			}
+			if err := commitErr; err != nil {
 				p.logger.Printf("[ERROR]: failed to validate commit: %v", err)
+				p.traceMessage(span, msg, msgDiscarded)
 				continue
 			}
 			p.state.addCommitted(msg)
 
+		case MessageReq_Committed:
+			if err := p.addRelayedCommits(msg); err != nil {
+				span.End()
+				return
+			}
+			relayed = true
+
 		default:
 			panic(fmt.Errorf("BUG: Unexpected message type: %s in %s", msg.Type, p.getState()))
 		}
+		p.traceMessage(span, msg, msgAccepted)
 
-		if p.state.numPrepared() > p.state.NumValid() {
+		if p.state.hasQuorum(p.state.prepared) {
 			// we have received enough pre-prepare messages
+			p.traceQuorum("prepare")
 			sendCommit(span)
 		}
 
-		if p.state.numCommitted() > p.state.NumValid() {
+		if p.state.hasQuorum(p.state.committed) {
 			// we have received enough commit messages
+			p.traceQuorum("commit")
 			sendCommit(span)
 
+			if p.config.CommitRelay && !relayed {
+				// help the lagging nodes to finalize
+				p.sendCommittedMsg()
+			}
+
 			// change to commit state just to get out of the loop
 			p.setState(CommitState)
 		}
@@ -469,6 +1088,48 @@ func (p *Pbft) runValidateState(ctx context.Context) { // start new round
 	}
 }
 
+// addRelayedCommits adds the valid commit seals of an aggregated committed message as commit messages.
+// It returns the error if the backend panicked
+func (p *Pbft) addRelayedCommits(msg *MessageReq) error {
+	for _, seal := range msg.CommittedSeals {
+		var commitErr error
+		if err := p.guard("ValidateCommit", func() { commitErr = p.backend.ValidateCommit(seal.Signer, seal.Seal) }); err != nil {
+			return err
+		}
+		if err := commitErr; err != nil {
+			p.logger.Printf("[ERROR]: failed to validate relayed commit: from=%s, err=%v", seal.Signer, err)
+			continue
+		}
+		p.state.addCommitted(&MessageReq{
+			Type: MessageReq_Commit,
+			From: seal.Signer,
+			Seal: seal.Seal,
+			View: msg.View.Copy(),
+			Hash: msg.Hash,
+		})
+	}
+	return nil
+}
+
+// reportEquivocation stores the evidence of two conflicting messages sent by the same validator
+func (p *Pbft) reportEquivocation(first, second *MessageReq) {
+	evidence, err := NewEvidence(first, second)
+	if err != nil {
+		p.logger.Printf("[ERROR] failed to build evidence: %v", err)
+		return
+	}
+	p.logger.Printf("[WARN] equivocation detected: from=%s, type=%s, view=%s", evidence.Offender, evidence.Type, evidence.View)
+	p.storeEvidence(evidence)
+	if dropped := p.evidence.add(evidence); dropped != 0 {
+		p.logger.Printf("[WARN] evidence pool over the memory budget: dropped=%d", dropped)
+	}
+}
+
+// Evidence returns the equivocation evidence collected by the engine
+func (p *Pbft) Evidence() []*Evidence {
+	return p.evidence.list()
+}
+
 func spanAddEventMessage(typ string, span trace.Span, msg *MessageReq) {
 	span.AddEvent("Message", trace.WithAttributes(
 		// where was the message generated
@@ -508,7 +1169,15 @@ func (p *Pbft) runCommitState(ctx context.Context) {
 	_, span := p.tracer.Start(ctx, "CommitState")
 	defer span.End()
 
+	p.publishQuorumSeals()
+
+	// the quorum is reached, but the proposal is not inserted before the target block time
+	if !p.paceFinalization(p.ctx) {
+		return
+	}
+
 	committedSeals := p.state.getCommittedSeals()
+	committed := p.state.committed
 	proposal := p.state.proposal.Copy()
 
 	// at this point either if it works or not we need to unlock the state
@@ -520,13 +1189,33 @@ func (p *Pbft) runCommitState(ctx context.Context) {
 		CommittedSeals: committedSeals,
 		Proposer:       p.state.proposer,
 		Number:         p.state.view.Sequence,
+		Round:          p.state.view.Round,
+	}
+	if backend, ok := p.backend.(AsyncInsertBackend); ok {
+		// the insertion is acknowledged before signing anything for the next height
+		if err := p.guard("InsertAsync", func() { p.pendingInsert = backend.InsertAsync(pp) }); err != nil {
+			return
+		}
+		p.commits.add(pp.Number, committed)
+		p.notifyFinalized(proposal.Hash)
+		p.recordFinality()
+		p.setState(DoneState)
+		return
 	}
-	if err := p.backend.Insert(pp); err != nil {
+	var insertErr error
+	if err := p.guard("Insert", func() { insertErr = p.backend.Insert(pp) }); err != nil {
+		return
+	}
+	if err := insertErr; err != nil {
 		// start a new round with the state unlocked since we need to
 		// be able to propose/validate a different proposal
 		p.logger.Printf("[ERROR] failed to insert proposal. Error message: %v", err)
-		p.handleStateErr(errFailedToInsertProposal)
+		p.handleStateErr(RoundChangeBackendError, errFailedToInsertProposal)
 	} else {
+		p.commits.add(pp.Number, committed)
+		p.notifyFinalized(proposal.Hash)
+		p.recordFinality()
+
 		// move to done state to finish the current iteration of the state machine
 		p.setState(DoneState)
 	}
@@ -538,33 +1227,47 @@ var (
 	errFailedToInsertProposal  = fmt.Errorf("failed to insert proposal")
 )
 
-func (p *Pbft) handleStateErr(err error) {
+func (p *Pbft) handleStateErr(reason RoundChangeReason, err error) {
+	p.health.setErr(err)
 	p.state.err = err
-	p.setState(RoundChangeState)
+	p.roundChange(reason)
 }
 
 func (p *Pbft) runRoundChangeState(ctx context.Context) {
 	ctx, span := p.tracer.Start(ctx, "RoundChange")
 	defer span.End()
 
-	sendRoundChange := func(round uint64) {
-		p.logger.Printf("[DEBUG] local round change: round=%d", round)
+	sendRoundChange := func(round uint64, reason RoundChangeReason) {
+		if p.exceedsMaxRound(round) {
+			return
+		}
+		p.logger.Printf("[DEBUG] local round change: round=%d, reason=%s", round, reason)
 		// set the new round
-		p.state.view.Round = round
+		p.state.setRound(round)
 		// clean the round
 		p.state.cleanRound(round)
 		// send the round change message
-		p.sendRoundChange()
+		p.sendRoundChange(reason)
+		span.AddEvent("RoundChange", trace.WithAttributes(attribute.String("reason", reason.String())))
 	}
-	sendNextRoundChange := func() {
-		sendRoundChange(p.state.view.Round + 1)
+	sendNextRoundChange := func(reason RoundChangeReason) {
+		sendRoundChange(p.state.view.Round+1, reason)
 	}
 
-	checkTimeout := func() {
+	checkTimeout := func(reason RoundChangeReason) {
 		// At this point we might be stuck in the network if:
 		// - We have advanced the round but everyone else passed.
 		// - We are removing those messages since they are old now.
-		if bestHeight, stucked := p.backend.IsStuck(p.state.view.Sequence); stucked {
+		var bestHeight uint64
+		var stucked bool
+		if err := p.guard("IsStuck", func() { bestHeight, stucked = p.backend.IsStuck(p.state.view.Sequence) }); err != nil {
+			return
+		}
+		if !stucked {
+			// the peers may have announced a higher height
+			bestHeight, stucked = p.statusHint()
+		}
+		if stucked {
 			span.AddEvent("OutOfSync", trace.WithAttributes(
 				// our local height
 				attribute.Int64("local", int64(p.state.view.Sequence)),
@@ -577,72 +1280,148 @@ func (p *Pbft) runRoundChangeState(ctx context.Context) {
 
 		// otherwise, it seems that we are in sync
 		// and we should start a new round
-		sendNextRoundChange()
+		sendNextRoundChange(reason)
 	}
 
+	// the cause of the round change, the timeout if the state was set from the outside
+	reason := p.state.roundChangeReason
+	if reason == RoundChangeUnknown {
+		reason = RoundChangeTimeout
+	}
+	p.state.roundChangeReason = RoundChangeUnknown
+
 	// if the round was triggered due to an error, we send our own
 	// next round change
 	if err := p.state.getErr(); err != nil {
 		p.logger.Printf("[DEBUG] round change handle error. Error message: %v", err)
-		sendNextRoundChange()
+		sendNextRoundChange(reason)
 	} else {
 		// otherwise, it is due to a timeout in any stage
 		// First, we try to sync up with any max round already available
 		if maxRound, ok := p.state.maxRound(); ok {
 			p.logger.Printf("[DEBUG] round change, max round=%d", maxRound)
-			sendRoundChange(maxRound)
+			sendRoundChange(maxRound, RoundChangeCatchUp)
 		} else {
 			// otherwise, do your best to sync up
-			checkTimeout()
+			checkTimeout(reason)
 		}
 	}
 
 	// create a timer for the round change
 	timeout := p.roundTimeout(p.state.view.Round)
 
-	for p.getState() == RoundChangeState {
+	// the round in which the node, as a locked proposer, waits for the round change messages
+	// that justify a fresh proposal (see awaitsJustification), at most half of the round timeout
+	var justifying bool
+	var justifyRound uint64
+	var justifyDeadline time.Time
+
+	// readRoundChange handles the next message in its own span, it returns false once closing
+	readRoundChange := func() bool {
 		_, span := p.tracer.Start(ctx, "RoundChangeState")
+		defer span.End()
 
-		msg, ok := p.getNextMessage(span, timeout)
+		wait := timeout
+		if justifying {
+			wait = time.Until(justifyDeadline)
+		}
+		msg, ok := p.getNextMessage(span, wait)
 		if !ok {
 			// closing
-			span.End()
-			return
+			return false
 		}
 		if msg == nil {
+			// if the peers are ahead, checkTimeout syncs instead
+			_, behind := p.statusHint()
+			if justifying && !behind {
+				// move to the round anyway, the locked proposal is proposed again
+				p.logger.Printf("[WARN] round change justification timeout: round=%d", justifyRound)
+				p.state.setRound(justifyRound)
+				p.setState(AcceptState)
+				return true
+			}
 			p.logger.Print("[DEBUG] round change timeout")
-			checkTimeout()
+			checkTimeout(RoundChangeTimeout)
 			// update the timeout duration
 			timeout = p.roundTimeout(p.state.view.Round)
-			span.End()
-			continue
+			return true
 		}
 
 		// we only expect RoundChange messages right now
-		num := p.state.AddRoundMessage(msg)
-
-		if num == p.state.NumValid() {
+		p.state.AddRoundMessage(msg)
+		p.traceMessage(span, msg, msgAccepted)
+		p.countReceivedRoundChange(msg.RoundChangeReason)
+
+		round := msg.View.Round
+		roundChanges := p.state.roundMessages[round]
+		if p.state.hasRoundChangeQuorum(roundChanges) || (justifying && round == justifyRound) {
+			if p.exceedsMaxRound(round) {
+				return false
+			}
+			wait, err := p.awaitsJustification(round, roundChanges)
+			if err != nil {
+				return false
+			}
+			if wait {
+				if !justifying || justifyRound != round {
+					p.logger.Printf("[DEBUG] waiting for the round change justification: round=%d", round)
+					justifying, justifyRound = true, round
+					justifyDeadline = time.Now().Add(p.roundTimeout(round) / 2)
+				}
+				return true
+			}
 			// start a new round inmediatly
-			p.state.view.Round = msg.View.Round
+			p.state.setRound(round)
 			p.setState(AcceptState)
-		} else if num == p.state.MaxFaultyNodes()+1 {
+		} else if p.state.hasWeakQuorum(roundChanges) {
 			// weak certificate, try to catch up if our round number is smaller
-			if p.state.view.Round < msg.View.Round {
+			if p.state.view.Round < round {
 				// update timer
 				timeout = p.roundTimeout(p.state.view.Round)
-				sendRoundChange(msg.View.Round)
+				justifying = false
+				sendRoundChange(round, RoundChangeCatchUp)
 			}
 		}
 
 		p.setStateSpanAttributes(span)
-		span.End()
+		return true
+	}
+
+	for p.getState() == RoundChangeState {
+		if !readRoundChange() {
+			return
+		}
 	}
 }
 
+// exceedsMaxRound checks whether the round is beyond the configured maximum round.
+// In that case, it moves the engine to the sync state
+func (p *Pbft) exceedsMaxRound(round uint64) bool {
+	if p.config.MaxRound == 0 || round <= p.config.MaxRound {
+		return false
+	}
+	p.logger.Printf("[INFO] max round exceeded: round=%d, max=%d", round, p.config.MaxRound)
+	p.emit(&MaxRoundExceededEvent{
+		View:     ViewMsg(p.state.view.Sequence, round),
+		MaxRound: p.config.MaxRound,
+	})
+	p.setState(SyncState)
+	return true
+}
+
 // --- communication wrappers ---
 
-func (p *Pbft) sendRoundChange() {
+func (p *Pbft) sendRoundChange(reason RoundChangeReason) {
+	p.state.roundChangeReason = reason
 	p.gossip(MessageReq_RoundChange)
+
+	p.stats.update(func(s *Stats) {
+		if s.RoundChanges == nil {
+			s.RoundChanges = map[RoundChangeReason]uint64{}
+		}
+		s.RoundChanges[reason]++
+	})
+	p.emit(&RoundChangeEvent{View: p.state.view.Copy(), Reason: reason})
 }
 
 func (p *Pbft) sendPreprepareMsg() {
@@ -657,12 +1436,29 @@ func (p *Pbft) sendCommitMsg() {
 	p.gossip(MessageReq_Commit)
 }
 
+// sendCommittedMsg broadcasts the aggregated commit seals of the current round
+func (p *Pbft) sendCommittedMsg() {
+	msg := &MessageReq{
+		Type:           MessageReq_Committed,
+		From:           p.validator.NodeID(),
+		ChainID:        p.config.ChainID,
+		View:           p.state.view.Copy(),
+		Hash:           p.state.proposal.Hash,
+		CommittedSeals: p.state.getCommittedSealsWithSigners(),
+	}
+	// the message is not sent to ourselves since we already reached the quorum
+	p.transportGossip(msg)
+}
+
 func (p *Pbft) gossip(msgType MsgType) {
 	msg := &MessageReq{
-		Type: msgType,
-		From: p.validator.NodeID(),
+		Type:    msgType,
+		From:    p.validator.NodeID(),
+		ChainID: p.config.ChainID,
 	}
-	if msgType != MessageReq_RoundChange {
+	if msgType == MessageReq_RoundChange {
+		msg.RoundChangeReason = p.state.roundChangeReason
+	} else {
 		// Except for round change message in which we are deciding on the proposer,
 		// the rest of the consensus message require the hash:
 		// 1. Preprepare: notify the validators of the proposal + hash
@@ -672,10 +1468,28 @@ func (p *Pbft) gossip(msgType MsgType) {
 
 	// add View
 	msg.View = p.state.view.Copy()
+	p.recordSigned(msg.View)
+
+	switch msg.Type {
+	case MessageReq_Prepare:
+		p.sealPrepare(msg)
+	case MessageReq_RoundChange:
+		p.sealRoundChange(msg)
+	case MessageReq_Preprepare:
+		msg.CommittedSeals = p.state.justification
+	}
 
 	// if we are sending a preprepare message we need to include the proposal
+	// or, if it is too big, announce the chunks that will follow
+	var chunks [][]byte
 	if msg.Type == MessageReq_Preprepare {
-		msg.SetProposal(p.state.proposal.Data)
+		if size := p.config.ProposalChunkSize; size > 0 && len(p.state.proposal.Data) > size {
+			chunks = splitProposal(p.state.proposal.Data, size)
+			msg.ChunkCount = uint32(len(chunks))
+		} else {
+			msg.SetProposal(p.state.proposal.Data)
+		}
+		p.proposalRequests.setPreprepare(msg, p.state.proposal.Data)
 	}
 
 	// if the message is commit, we need to add the committed seal
@@ -693,9 +1507,24 @@ func (p *Pbft) gossip(msgType MsgType) {
 		// send a copy to ourselves so that we can process this message as well
 		msg2 := msg.Copy()
 		msg2.From = p.validator.NodeID()
-		p.PushMessage(msg2)
+		p.pushMessage(msg2, ingressLocal)
+	}
+	p.transportGossip(msg)
+	if len(chunks) != 0 {
+		p.gossipChunks(msg, chunks)
 	}
-	if err := p.transport.Gossip(msg); err != nil {
+}
+
+// transportGossip sends the message through the transport and accounts its payload
+func (p *Pbft) transportGossip(msg *MessageReq) {
+	if msg.IsDigestOnly() && msg.PayloadSize() != 0 {
+		panic(fmt.Errorf("BUG: proposal payload in digest-only message %s", msg.Type))
+	}
+	p.stats.update(func(s *Stats) {
+		s.GossipedMessages++
+		s.GossipedPayloadBytes += uint64(msg.PayloadSize())
+	})
+	if err := p.gossipWithHints(msg); err != nil {
 		p.logger.Printf("[ERROR] failed to gossip. Error message: %v", err)
 	}
 }
@@ -721,7 +1550,13 @@ func (p *Pbft) SetState(s PbftState) {
 // setState sets the PBFT state
 func (p *Pbft) setState(s PbftState) {
 	p.logger.Printf("[DEBUG] state change: '%s'", s)
+	from := p.state.getState()
 	p.state.setState(s)
+	p.recorder.recordStateTransition(from, s, p.state.getView())
+	if s == DoneState {
+		// only a finalized height is progress, a node looping over the rounds is not
+		p.health.progress()
+	}
 }
 
 // forceTimeout sets the forceTimeoutCh flag to true
@@ -731,14 +1566,22 @@ func (p *Pbft) forceTimeout() {
 
 // getNextMessage reads a new message from the message queue
 func (p *Pbft) getNextMessage(span trace.Span, timeout time.Duration) (*MessageReq, bool) {
-	timeoutCh := time.After(timeout)
+	timeoutCh := p.timer.reset(p.getState().String(), timeout)
+	defer p.timer.stop()
+
+	deadline := time.Now().Add(timeout)
 	for {
 		msg, discards := p.msgQueue.readMessageWithDiscards(p.getState(), p.state.view)
 		// send the discard messages
-		for _, msg := range discards {
-			spanAddEventMessage("dropMessage", span, msg)
+		for _, discard := range discards {
+			p.recorder.recordMessage(discard.msg)
+			spanAddEventMessage("dropMessage", span, discard.msg)
+			p.traceMessage(span, discard.msg, msgStale)
+			p.countDiscard(discard.msg, discard.reason)
 		}
 		if msg != nil {
+			p.recorder.recordMessage(msg)
+
 			// add the event to the span
 			spanAddEventMessage("message", span, msg)
 
@@ -750,14 +1593,33 @@ func (p *Pbft) getNextMessage(span trace.Span, timeout time.Duration) (*MessageR
 			return nil, true
 		}
 
+		// publish the round state before waiting for new messages
+		p.publishRoundState(deadline)
+
 		// wait until there is a new message or
 		// someone closes the stopCh (i.e. timeout for round change)
 		select {
 		case <-timeoutCh:
+			if postpone := p.handleTimeout(); postpone > 0 {
+				span.AddEvent("TimeoutPostponed")
+				timeoutCh = p.timer.reset(p.getState().String(), postpone)
+				deadline = time.Now().Add(postpone)
+				continue
+			}
 			span.AddEvent("Timeout")
 			return nil, true
 		case <-p.ctx.Done():
 			return nil, false
+		case <-p.heartbeatCh():
+			p.emitHeartbeat(deadline)
+		case <-p.status.behindCh:
+			// the peers finalized the sequence, the round change state does not wait for the timeout
+			if p.getState() == RoundChangeState {
+				if _, ok := p.statusHint(); ok {
+					span.AddEvent("Behind")
+					return nil, true
+				}
+			}
 		case <-p.updateCh:
 		}
 	}
@@ -765,19 +1627,133 @@ func (p *Pbft) getNextMessage(span trace.Span, timeout time.Duration) (*MessageR
 
 // PushMessage pushes a new message to the message queue
 func (p *Pbft) PushMessage(msg *MessageReq) {
+	p.pushMessage(msg, ingressUnverified)
+}
+
+// pushMessage pushes the message received through the ingress to the message queue
+func (p *Pbft) pushMessage(msg *MessageReq, ingress ingress) {
+	p.countIngress(ingress)
 	if err := msg.Validate(); err != nil {
 		p.logger.Printf("[ERROR]: failed to validate msg: %v", err)
+		p.reportViolation(msg, err)
+		return
+	}
+	// the status of the lagging nodes is far from the local view by definition
+	if msg.Type != MessageReq_Status {
+		if err := validateView(msg.View, p.state.getView()); err != nil {
+			p.logger.Printf("[ERROR]: invalid view: from=%s, err=%v", msg.From, err)
+			p.countDiscard(msg, DiscardInvalidView)
+			return
+		}
+	}
+	if msg.ChainID != p.config.ChainID {
+		// the message belongs to a different network sharing the transport
+		p.logger.Printf("[DEBUG] drop message from a different chain: from=%s, chain=%d", msg.From, msg.ChainID)
+		p.stats.update(func(s *Stats) { s.CrossChainDrops++ })
+		return
+	}
+	if ingress != ingressLocal && !p.strictAllows(msg, ingress == ingressUnverified) {
+		return
+	}
+	resolved, err := p.resolveSender(msg)
+	if err != nil {
+		p.logger.Printf("[ERROR]: failed to resolve the sender: %v", err)
+		p.countDiscard(msg, DiscardUnknownSessionKey)
+		return
+	}
+	msg = resolved
+	if p.quarantine.contains(msg.From) {
+		p.stats.update(func(s *Stats) { s.QuarantineDrops++ })
+		return
+	}
+	if msg.Type == MessageReq_Status {
+		p.handleStatus(msg)
+		return
+	}
+	if msg.Type == MessageReq_ProposalRequest {
+		p.handleProposalRequest(msg)
+		return
+	}
+	if msg.Type == MessageReq_ProposalChunk {
+		// chunks are not part of the consensus flow, store them for the reassembly
+		if err := p.chunks.add(msg); err != nil {
+			p.logger.Printf("[ERROR]: failed to add proposal chunk: %v", err)
+			if errors.Is(err, errInvalidChunk) {
+				p.reportViolation(msg, err)
+			}
+			return
+		}
+		p.notifyUpdate()
 		return
 	}
 
-	p.msgQueue.pushMessage(msg)
+	p.collectFinality(msg)
+	p.proposalRequests.observe(msg, p.state.getView())
+
+	p.storeMessage(msg)
+	p.enqueue(msg)
+	p.notifyUpdate()
+}
 
+// notifyUpdate notifies the state machine loop that new data has arrived
+func (p *Pbft) notifyUpdate() {
 	select {
 	case p.updateCh <- struct{}{}:
 	default:
 	}
 }
 
+// Quarantine ignores every message from the given validator for the duration
+// (i.e. after evidence of equivocation or flooding). Quarantining an already
+// quarantined validator overwrites its expiry time
+func (p *Pbft) Quarantine(id NodeID, duration time.Duration) {
+	p.logger.Printf("[INFO] quarantine validator: id=%s, duration=%s", id, duration)
+	p.quarantine.add(id, duration)
+}
+
+// Release lifts the quarantine of the given validator before it expires
+func (p *Pbft) Release(id NodeID) {
+	p.quarantine.remove(id)
+}
+
+// Quarantined returns the currently quarantined validators along with the expiry time
+func (p *Pbft) Quarantined() map[NodeID]time.Time {
+	return p.quarantine.list()
+}
+
+// Term returns the term of the current view
+func (p *Pbft) Term() uint64 {
+	if view := p.state.getView(); view != nil {
+		return view.Term
+	}
+	return 0
+}
+
+// Stats returns a snapshot of the engine counters
+func (p *Pbft) Stats() Stats {
+	return p.stats.snapshot()
+}
+
+// finalizeAlone seals the proposal and moves to the commit state without exchanging messages.
+// It is only used in dev mode when the node is the only validator
+func (p *Pbft) finalizeAlone() {
+	seal, err := p.validator.Sign(p.state.proposal.Hash)
+	if err != nil {
+		p.logger.Printf("[ERROR] failed to commit seal. Error message: %v", err)
+		p.handleStateErr(RoundChangeBackendError, err)
+		return
+	}
+	p.state.lock()
+	p.state.addCommitted(&MessageReq{
+		Type: MessageReq_Commit,
+		From: p.validator.NodeID(),
+		Seal: seal,
+		View: p.state.view.Copy(),
+		Hash: p.state.proposal.Hash,
+	})
+	p.setState(CommitState)
+}
+
 // exponentialTimeout calculates the timeout duration depending on the current round.
 // Round acts as an exponent when determining timeout (2^round).
 func exponentialTimeout(round uint64) time.Duration {
@@ -805,15 +1781,19 @@ func exponentialTimeout(round uint64) time.Duration {
 // 7 = 3 * 2 + 1
 // It should always take the floor of the result
 func MaxFaultyNodes(nodesCount int) int {
-	if nodesCount <= 0 {
-		return 0
-	}
-	return (nodesCount - 1) / 3
+	return validation.MaxFaulty(nodesCount)
 }
 
 // Calculates quorum size (namely the number of required messages of some type in order to proceed to the next state in PolyBFT state machine).
 // It is calculated by formula:
 // 2 * F + 1, where F denotes maximum count of faulty nodes in order to have Byzantine fault tollerant property satisfied.
 func QuorumSize(nodesCount int) int {
-	return 2*MaxFaultyNodes(nodesCount) + 1
+	return validation.QuorumSize(nodesCount)
+}
+
+// DevQuorumSize calculates the quorum size used in dev mode.
+// Validator sets smaller than 4 cannot tolerate any faulty node, hence all the validators are required
+// to guarantee that two quorums always intersect. Otherwise, it is the same as QuorumSize.
+func DevQuorumSize(nodesCount int) int {
+	return validation.DevQuorumSize(nodesCount)
 }
@@ -1,6 +1,7 @@
 package pbft
 
 import (
+	"bytes"
 	"container/heap"
 	"context"
 	"crypto/sha1"
@@ -64,6 +65,257 @@ func TestTransition_AcceptState_Proposer_Propose(t *testing.T) {
 	})
 }
 
+func TestTransition_AcceptState_Proposer_DevModeSingleNode(t *testing.T) {
+	// we are the only validator in dev mode, the proposal is
+	// sealed right away without exchanging any message
+	i := newMockPbft(t, []string{"A"}, "A")
+	i.config.DevMode = true
+	i.setState(AcceptState)
+
+	i.setProposal(&Proposal{
+		Data: mockProposal,
+		Time: time.Now(),
+	})
+
+	i.runCycle(context.Background())
+
+	i.expect(expectResult{
+		sequence:   1,
+		state:      CommitState,
+		commitMsgs: 1,
+		locked:     true,
+	})
+
+	i.runCycle(context.Background())
+	assert.True(t, i.IsState(DoneState))
+}
+
+func TestTransition_RoundChangeState_DevModeSingleNode(t *testing.T) {
+	// we are the only validator in dev mode, our own round change
+	// message is enough to start the next round
+	m := newMockPbft(t, []string{"A"}, "A")
+	m.config.DevMode = true
+	m.state.devMode = true
+	m.state.err = errFailedToInsertProposal
+
+	m.setState(RoundChangeState)
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence: 1,
+		round:    1,
+		state:    AcceptState,
+		outgoing: 1,
+	})
+}
+
+func TestTransition_ValidateState_DevModeQuorum(t *testing.T) {
+	// in dev mode with 3 validators, all of them are required
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.config.DevMode = true
+	m.state.devMode = true
+	m.setState(ValidateState)
+
+	for _, from := range []NodeID{"A", "B"} {
+		m.emitMsg(&MessageReq{
+			From: from,
+			Type: MessageReq_Commit,
+			View: ViewMsg(1, 0),
+		})
+	}
+	m.runCycle(context.Background())
+
+	// it times out waiting for the commit of C
+	m.expect(expectResult{
+		sequence:   1,
+		state:      RoundChangeState,
+		commitMsgs: 2,
+	})
+}
+
+// prepareSealer signs the digests with the digests themselves, as accepted by mockBackend.VerifyCommitSeal
+func prepareSealer(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+func TestTransition_AcceptState_Proposer_FastPath(t *testing.T) {
+	// with the fast path in round 0 the proposer seals its prepare
+	// message with the commit seal and locks on the proposal
+	i := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	i.config.FastPath = true
+	i.pool.get("A").signFn = prepareSealer
+	i.setState(AcceptState)
+
+	i.setProposal(&Proposal{
+		Data: mockProposal,
+		Time: time.Now(),
+	})
+
+	i.runCycle(context.Background())
+
+	i.expect(expectResult{
+		sequence: 1,
+		outgoing: 2, // preprepare and prepare
+		locked:   true,
+		state:    ValidateState,
+	})
+	assert.Equal(t, i.state.proposal.Hash, i.respMsg[1].Seal)
+}
+
+func TestTransition_ValidateState_FastPath(t *testing.T) {
+	preprepare := func(i *mockPbft, round uint64) {
+		i.config.FastPath = true
+		i.pool.get("B").signFn = prepareSealer
+		i.state.view = ViewMsg(1, round)
+		i.setState(AcceptState)
+
+		i.emitMsg(&MessageReq{
+			From:     i.state.validators.CalcProposer(round),
+			Type:     MessageReq_Preprepare,
+			Proposal: mockProposal,
+			View:     ViewMsg(1, round),
+		})
+		i.runCycle(context.Background())
+		require.Equal(t, ValidateState, i.getState())
+	}
+	prepare := func(from NodeID, round uint64, seal []byte) *MessageReq {
+		return &MessageReq{
+			From: from,
+			Type: MessageReq_Prepare,
+			Seal: seal,
+			View: ViewMsg(1, round),
+		}
+	}
+
+	t.Run("PrepareQuorum", func(t *testing.T) {
+		// the sealed prepares reach the commit quorum without any commit message
+		i := newMockPbft(t, []string{"A", "B", "C", "D"}, "B")
+		preprepare(i, 0)
+		assert.True(t, i.state.locked)
+
+		i.emitMsg(prepare("A", 0, digest))
+		i.emitMsg(prepare("C", 0, digest))
+		i.runCycle(context.Background())
+
+		i.expect(expectResult{
+			sequence:    1,
+			outgoing:    2, // prepare and commit
+			prepareMsgs: 3,
+			commitMsgs:  3,
+			locked:      true,
+			state:       CommitState,
+		})
+		assert.Equal(t, [][]byte{digest, digest, digest}, i.state.getCommittedSeals())
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		// without a quorum of sealed prepares the standard flow is followed
+		i := newMockPbft(t, []string{"A", "B", "C", "D"}, "B")
+		preprepare(i, 0)
+
+		i.emitMsg(prepare("A", 0, digest))
+		i.emitMsg(prepare("C", 0, nil))
+		i.emitMsg(&MessageReq{From: "C", Type: MessageReq_Commit, Seal: digest, View: ViewMsg(1, 0)})
+		i.runCycle(context.Background())
+
+		i.expect(expectResult{
+			sequence:    1,
+			outgoing:    2, // prepare and commit
+			prepareMsgs: 3,
+			commitMsgs:  3,
+			locked:      true,
+			state:       CommitState,
+		})
+	})
+
+	t.Run("Round", func(t *testing.T) {
+		// the prepare seals are ignored after round 0
+		i := newMockPbft(t, []string{"A", "B", "C", "D"}, "B")
+		preprepare(i, 2)
+		assert.False(t, i.state.locked)
+		assert.Empty(t, i.respMsg[0].Seal)
+
+		i.emitMsg(prepare("A", 2, digest))
+		i.emitMsg(prepare("C", 2, digest))
+		i.Close()
+		i.runCycle(context.Background())
+
+		i.expect(expectResult{
+			sequence:    1,
+			round:       2,
+			outgoing:    2, // prepare and commit
+			prepareMsgs: 3,
+			commitMsgs:  1,
+			locked:      true,
+			state:       ValidateState,
+		})
+	})
+}
+
+// messageRounds runs a network of engines in synchronous message rounds: in every round each engine
+// handles the messages sent in the previous round until it waits for more. It returns the number of
+// message rounds until every engine finalized the proposal
+func messageRounds(t *testing.T, fastPath bool) int {
+	accounts := []string{"A", "B", "C", "D"}
+	nodes := []*mockPbft{}
+	for _, id := range accounts {
+		m := newMockPbft(t, accounts, id)
+		m.config.FastPath = fastPath
+		m.pool.get(id).signFn = prepareSealer
+		// the rounds do not time out while the engines wait for the messages of the next round
+		m.roundTimeout = func(uint64) time.Duration { return time.Hour }
+		m.setState(AcceptState)
+		nodes = append(nodes, m)
+	}
+	// the proposer sends the preprepare before the engines wait for messages,
+	// the delay of the proposal is not interrupted by the cancelled context
+	nodes[0].setProposal(&Proposal{Data: mockProposal, Time: time.Now()})
+	nodes[0].runCycle(context.Background())
+
+	outgoing := make([]int, len(nodes))
+	for rounds := 0; rounds < 10; rounds++ {
+		sent := []*MessageReq{}
+		done := true
+		for i, m := range nodes {
+			// the cancelled context returns the control once the queue is empty
+			ctx, cancel := context.WithCancel(context.Background())
+			m.ctx = ctx
+			cancel()
+
+			for m.getState() != DoneState {
+				state := m.getState()
+				m.runCycle(context.Background())
+				if m.getState() == state {
+					break
+				}
+			}
+			sent = append(sent, m.respMsg[outgoing[i]:]...)
+			outgoing[i] = len(m.respMsg)
+			done = done && m.getState() == DoneState
+		}
+		if done {
+			return rounds
+		}
+		for _, msg := range sent {
+			for _, m := range nodes {
+				if NodeID(m.validator.NodeID()) != msg.From {
+					m.emitMsg(msg.Copy())
+				}
+			}
+		}
+	}
+	t.Fatal("the proposal is not finalized")
+	return 0
+}
+
+func TestFastPath_MessageRounds(t *testing.T) {
+	// preprepare, prepare and commit
+	assert.Equal(t, 3, messageRounds(t, false))
+
+	// preprepare and the sealed prepare
+	assert.Equal(t, 2, messageRounds(t, true))
+}
+
 func TestTransition_AcceptState_Proposer_Locked(t *testing.T) {
 	// we are in AcceptState, we are the proposer but the value is locked.
 	// it needs to send the locked proposal again
@@ -87,6 +339,380 @@ func TestTransition_AcceptState_Proposer_Locked(t *testing.T) {
 	assert.Equal(t, i.state.proposal.Data, mockProposal)
 }
 
+type mockInitBackend struct {
+	*mockBackend
+	info *RoundInfo
+}
+
+func (m *mockInitBackend) Init(info *RoundInfo) {
+	m.info = info
+}
+
+func TestTransition_AcceptState_RoundInfo(t *testing.T) {
+	i := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	backend := &mockInitBackend{mockBackend: i.backend.(*mockBackend)}
+	i.backend = backend
+	i.setState(AcceptState)
+
+	i.state.locked = true
+	i.state.proposal = &Proposal{
+		Data: mockProposal,
+		Hash: digest,
+	}
+
+	before := time.Now()
+	i.runCycle(context.Background())
+
+	info := backend.info
+	assert.NotNil(t, info)
+	assert.True(t, info.IsProposer)
+	assert.Equal(t, NodeID("A"), info.Proposer)
+	assert.True(t, info.Locked)
+	assert.Equal(t, digest, info.LockedHash)
+	assert.Equal(t, uint64(1), info.View.Sequence)
+	assert.Equal(t, 3, info.Quorum)
+	assert.False(t, info.StartTime.Before(before))
+}
+
+type mockReproposalBackend struct {
+	*mockBackend
+	accept bool
+	rounds []uint64
+}
+
+func (m *mockReproposalBackend) ShouldAcceptReproposal(proposal *Proposal, round uint64) bool {
+	m.rounds = append(m.rounds, round)
+	return m.accept
+}
+
+// roundChangeJustification returns the round change seals of the senders for the view
+func roundChangeJustification(view *View, senders ...NodeID) []CommittedSeal {
+	seals := []CommittedSeal{}
+	for _, from := range senders {
+		seals = append(seals, CommittedSeal{Signer: from, Seal: RoundChangeDigest(view)})
+	}
+	return seals
+}
+
+func TestTransition_AcceptState_Proposer_Locked_ReproposalVetoed(t *testing.T) {
+	// we are in AcceptState, we are the proposer and the value is locked,
+	// but the backend vetoes the re-proposal (A is the proposer of round 1)
+	setup := func(t *testing.T) (*mockPbft, *mockReproposalBackend) {
+		i := newMockPbft(t, []string{"B", "A", "C", "D"}, "A")
+		backend := &mockReproposalBackend{mockBackend: i.backend.(*mockBackend)}
+		backend.HookBuildProposalHandler(func() (*Proposal, error) {
+			return &Proposal{
+				Data: mockProposal1,
+				Hash: digest1,
+			}, nil
+		})
+		i.backend = backend
+		i.setState(AcceptState)
+		i.state.view.Round = 1
+
+		i.state.locked = true
+		i.state.proposal = &Proposal{
+			Data: mockProposal,
+			Hash: digest,
+		}
+		return i, backend
+	}
+
+	t.Run("Justified", func(t *testing.T) {
+		// a quorum of validators moved to the round without a lock, a fresh proposal is built
+		i, backend := setup(t)
+		for _, seal := range roundChangeJustification(ViewMsg(1, 1), "B", "C", "D") {
+			i.state.AddRoundMessage(&MessageReq{
+				Type: MessageReq_RoundChange,
+				From: seal.Signer,
+				Seal: seal.Seal,
+				View: ViewMsg(1, 1),
+			})
+		}
+
+		i.runCycle(context.Background())
+
+		i.expect(expectResult{
+			sequence: 1,
+			round:    1,
+			state:    ValidateState,
+			outgoing: 2, // preprepare and prepare
+		})
+		assert.Equal(t, mockProposal1, i.state.proposal.Data)
+		assert.Equal(t, []uint64{1}, backend.rounds)
+
+		// the preprepare carries the justification
+		assert.Equal(t, MessageReq_Preprepare, i.respMsg[0].Type)
+		assert.Equal(t, roundChangeJustification(ViewMsg(1, 1), "B", "C", "D"), i.respMsg[0].CommittedSeals)
+	})
+
+	t.Run("NotJustified", func(t *testing.T) {
+		// one of the validators is locked, the locked proposal is proposed again
+		i, backend := setup(t)
+		for _, seal := range roundChangeJustification(ViewMsg(1, 1), "B", "C") {
+			i.state.AddRoundMessage(&MessageReq{
+				Type: MessageReq_RoundChange,
+				From: seal.Signer,
+				Seal: seal.Seal,
+				View: ViewMsg(1, 1),
+			})
+		}
+		i.state.AddRoundMessage(&MessageReq{
+			Type: MessageReq_RoundChange,
+			From: "D",
+			View: ViewMsg(1, 1),
+		})
+
+		i.runCycle(context.Background())
+
+		i.expect(expectResult{
+			sequence: 1,
+			round:    1,
+			state:    ValidateState,
+			locked:   true,
+			outgoing: 2, // preprepare and prepare
+		})
+		assert.Equal(t, mockProposal, i.state.proposal.Data)
+		assert.Equal(t, []uint64{1}, backend.rounds)
+		assert.Empty(t, i.respMsg[0].CommittedSeals)
+	})
+}
+
+// sentRoundChange runs the engine of the sender through the round change state from round 0
+// and returns the round change message it sends for round 1, sealed if it is not locked
+func sentRoundChange(t *testing.T, accounts []string, from string, locked bool) *MessageReq {
+	t.Helper()
+
+	m := newMockPbft(t, accounts, from)
+	m.pool.get(from).signFn = prepareSealer
+	if locked {
+		m.state.proposal = &Proposal{Data: mockProposal, Hash: digest}
+		m.state.lock()
+	}
+	m.setState(RoundChangeState)
+	m.Close()
+	m.runCycle(context.Background())
+
+	require.Equal(t, MessageReq_RoundChange, m.respMsg[0].Type)
+	require.Equal(t, uint64(1), m.respMsg[0].View.Round)
+	return m.respMsg[0]
+}
+
+func TestTransition_RoundChangeState_Proposer_Locked_ReproposalVetoed(t *testing.T) {
+	// A is locked and the proposer of round 1, the backend vetoes the re-proposal. The round
+	// change quorum includes the unsealed message of A, hence A waits in the round change state
+	// for the round changes of the validators that justify a fresh proposal
+	accounts := []string{"B", "A", "C", "D"}
+	setup := func(t *testing.T) (*mockPbft, *mockReproposalBackend) {
+		i := newMockPbft(t, accounts, "A")
+		backend := &mockReproposalBackend{mockBackend: i.backend.(*mockBackend)}
+		backend.HookBuildProposalHandler(func() (*Proposal, error) {
+			return &Proposal{
+				Data: mockProposal1,
+				Hash: digest1,
+			}, nil
+		})
+		i.backend = backend
+		i.state.proposal = &Proposal{Data: mockProposal, Hash: digest}
+		i.state.lock()
+		i.setState(RoundChangeState)
+		return i, backend
+	}
+
+	t.Run("Justified", func(t *testing.T) {
+		i, backend := setup(t)
+		for _, from := range []string{"B", "C", "D"} {
+			i.emitMsg(sentRoundChange(t, accounts, from, false))
+		}
+
+		i.runCycle(context.Background())
+		assert.Equal(t, AcceptState, i.getState())
+		assert.Equal(t, uint64(1), i.state.view.Round)
+
+		i.runCycle(context.Background())
+		i.expect(expectResult{
+			sequence: 1,
+			round:    1,
+			state:    ValidateState,
+			outgoing: 3, // round change, preprepare and prepare
+		})
+		assert.Equal(t, mockProposal1, i.state.proposal.Data)
+		assert.NotEmpty(t, backend.rounds)
+		for _, round := range backend.rounds {
+			assert.Equal(t, uint64(1), round)
+		}
+
+		// the preprepare carries the justification
+		assert.Equal(t, MessageReq_Preprepare, i.respMsg[1].Type)
+		assert.Equal(t, roundChangeJustification(ViewMsg(1, 1), "B", "C", "D"), i.respMsg[1].CommittedSeals)
+	})
+
+	t.Run("NotJustified", func(t *testing.T) {
+		// D is locked too, A moves to the round once the wait times out and proposes the locked proposal
+		i, _ := setup(t)
+		i.emitMsg(sentRoundChange(t, accounts, "B", false))
+		i.emitMsg(sentRoundChange(t, accounts, "C", false))
+		i.emitMsg(sentRoundChange(t, accounts, "D", true))
+		i.forceTimeout()
+
+		i.runCycle(context.Background())
+		assert.Equal(t, AcceptState, i.getState())
+		assert.Equal(t, uint64(1), i.state.view.Round)
+
+		i.runCycle(context.Background())
+		i.expect(expectResult{
+			sequence: 1,
+			round:    1,
+			state:    ValidateState,
+			locked:   true,
+			outgoing: 3, // round change, preprepare and prepare
+		})
+		assert.Equal(t, mockProposal, i.state.proposal.Data)
+		assert.Empty(t, i.respMsg[1].CommittedSeals)
+	})
+
+	t.Run("Accepted", func(t *testing.T) {
+		// the backend accepts the re-proposal, A moves to the round with the round change quorum
+		i, backend := setup(t)
+		backend.accept = true
+		i.emitMsg(sentRoundChange(t, accounts, "B", false))
+		i.Close()
+
+		i.runCycle(context.Background())
+		assert.Equal(t, AcceptState, i.getState())
+		assert.Equal(t, uint64(1), i.state.view.Round)
+	})
+}
+
+func TestTransition_AcceptState_Validator_Locked_ReproposalVetoed(t *testing.T) {
+	// the backend of a validator is not consulted, it keeps its lock
+	// (B is the proposer of round 1)
+	i := newMockPbft(t, []string{"A", "B", "C", "D"}, "C")
+	backend := &mockReproposalBackend{mockBackend: i.backend.(*mockBackend)}
+	i.backend = backend
+	i.state.view = ViewMsg(1, 1)
+	i.setState(AcceptState)
+
+	i.state.proposal = &Proposal{
+		Data: mockProposal,
+		Hash: digest,
+	}
+	i.state.lock()
+
+	i.emitMsg(&MessageReq{
+		From:     "B",
+		Type:     MessageReq_Preprepare,
+		Proposal: mockProposal,
+		View:     ViewMsg(1, 1),
+	})
+
+	i.runCycle(context.Background())
+
+	i.expect(expectResult{
+		sequence: 1,
+		round:    1,
+		state:    ValidateState,
+		locked:   true,
+		outgoing: 2, // prepare and commit
+	})
+	assert.Empty(t, backend.rounds)
+}
+
+func TestTransition_AcceptState_Validator_Locked_Justified(t *testing.T) {
+	// a fresh proposal releases the lock of the validator only if it is justified
+	// by a quorum of valid round change seals (B is the proposer of round 1)
+	cases := []struct {
+		name   string
+		seals  []CommittedSeal
+		locked bool
+	}{
+		{"Justified", roundChangeJustification(ViewMsg(1, 1), "A", "B", "D"), false},
+		{"NoQuorum", roundChangeJustification(ViewMsg(1, 1), "A", "B"), true},
+		{"OtherRound", roundChangeJustification(ViewMsg(1, 0), "A", "B", "D"), true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			i := newMockPbft(t, []string{"A", "B", "C", "D"}, "C")
+			i.state.view = ViewMsg(1, 1)
+			i.setState(AcceptState)
+
+			i.state.proposal = &Proposal{
+				Data: mockProposal,
+				Hash: digest,
+			}
+			i.state.lock()
+
+			i.emitMsg(&MessageReq{
+				From:           "B",
+				Type:           MessageReq_Preprepare,
+				Proposal:       mockProposal1,
+				Hash:           digest1,
+				View:           ViewMsg(1, 1),
+				CommittedSeals: c.seals,
+			})
+
+			i.runCycle(context.Background())
+
+			if c.locked {
+				i.expect(expectResult{
+					sequence: 1,
+					round:    1,
+					state:    RoundChangeState,
+					locked:   true,
+					err:      errIncorrectLockedProposal,
+				})
+				assert.Equal(t, mockProposal, i.state.proposal.Data)
+				return
+			}
+			i.expect(expectResult{
+				sequence: 1,
+				round:    1,
+				state:    ValidateState,
+				outgoing: 1, // prepare
+			})
+			assert.Equal(t, mockProposal1, i.state.proposal.Data)
+		})
+	}
+}
+
+func TestRoundChange_Seal(t *testing.T) {
+	// only the nodes that are not locked seal their round change messages
+	i := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	i.pool.get("A").signFn = prepareSealer
+	i.state.view = ViewMsg(1, 1)
+
+	i.sendRoundChange(RoundChangeTimeout)
+	assert.Equal(t, RoundChangeDigest(ViewMsg(1, 1)), i.respMsg[0].Seal)
+
+	i.state.proposal = &Proposal{Data: mockProposal, Hash: digest}
+	i.state.lock()
+	i.sendRoundChange(RoundChangeTimeout)
+	assert.Empty(t, i.respMsg[1].Seal)
+}
+
+func TestTransition_AcceptState_Proposer_Locked_ReproposalAccepted(t *testing.T) {
+	i := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	backend := &mockReproposalBackend{mockBackend: i.backend.(*mockBackend), accept: true}
+	i.backend = backend
+	i.setState(AcceptState)
+
+	i.state.locked = true
+	i.state.proposal = &Proposal{
+		Data: mockProposal,
+		Hash: digest,
+	}
+
+	i.runCycle(context.Background())
+
+	i.expect(expectResult{
+		sequence: 1,
+		state:    ValidateState,
+		locked:   true,
+		outgoing: 2, // preprepare and prepare
+	})
+	assert.Equal(t, mockProposal, i.state.proposal.Data)
+}
+
 func TestTransition_AcceptState_Validator_VerifyCorrect(t *testing.T) {
 	i := newMockPbft(t, []string{"A", "B", "C"}, "B")
 	i.state.view = ViewMsg(1, 0)
@@ -282,7 +908,7 @@ func TestTransition_AcceptState_Validator_LockCorrect(t *testing.T) {
 		sequence: 1,
 		state:    ValidateState,
 		locked:   true,
-		outgoing: 1, // prepare message
+		outgoing: 2, // prepare and commit messages
 	})
 }
 
@@ -461,6 +1087,30 @@ func TestTransition_RoundChangeState_StartNewRound(t *testing.T) {
 	})
 }
 
+func TestTransition_RoundChangeState_MaxRoundExceeded(t *testing.T) {
+	// if the next round is beyond the configured max round
+	// we move to the sync state and emit an event
+	m := newMockPbft(t, []string{"A", "B"}, "A")
+	m.Close()
+
+	events := []Event{}
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	m.config.MaxRound = 2
+	m.state.view = ViewMsg(1, 2)
+
+	m.setState(RoundChangeState)
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence: 1,
+		round:    2,
+		state:    SyncState,
+	})
+	assert.Equal(t, []Event{&MaxRoundExceededEvent{View: ViewMsg(1, 3), MaxRound: 2}}, events)
+}
+
 func TestTransition_RoundChangeState_MaxRound(t *testing.T) {
 	// if we start round change due to a state timeout we try to catch up
 	// with the highest round seen.
@@ -556,6 +1206,115 @@ func TestTransition_ValidateState_MoveToCommitState(t *testing.T) {
 	})
 }
 
+func TestTransition_ValidateState_DiscardReasons(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.setState(ValidateState)
+	m.state.view = ViewMsg(1, 1)
+
+	// stale round
+	m.emitMsg(&MessageReq{
+		From: "B",
+		Type: MessageReq_Prepare,
+		View: ViewMsg(1, 0),
+	})
+	// not a validator
+	m.emitMsg(&MessageReq{
+		From: "E",
+		Type: MessageReq_Prepare,
+		View: ViewMsg(1, 1),
+	})
+	// duplicate
+	m.emitMsg(&MessageReq{
+		From: "B",
+		Type: MessageReq_Prepare,
+		View: ViewMsg(1, 1),
+	})
+	m.emitMsg(&MessageReq{
+		From: "B",
+		Type: MessageReq_Prepare,
+		View: ViewMsg(1, 1),
+	})
+	m.emitMsg(&MessageReq{
+		From: "C",
+		Type: MessageReq_Prepare,
+		View: ViewMsg(1, 1),
+	})
+	m.emitMsg(&MessageReq{
+		From: "D",
+		Type: MessageReq_Prepare,
+		View: ViewMsg(1, 1),
+	})
+
+	m.runCycle(context.Background())
+
+	discards := m.Stats().Discards
+	assert.Equal(t, uint64(1), discards[DiscardStaleRound])
+	assert.Equal(t, uint64(1), discards[DiscardNotValidator])
+	assert.Equal(t, uint64(1), discards[DiscardDuplicate])
+	assert.Equal(t, "NotValidator", DiscardNotValidator.String())
+}
+
+func TestTransition_ValidateState_CommitRelay(t *testing.T) {
+	// once we reach the commit quorum, we broadcast the aggregated commit seals
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.CommitRelay = true
+	m.setState(ValidateState)
+
+	for _, from := range []NodeID{"A", "B", "C"} {
+		m.emitMsg(&MessageReq{
+			From: from,
+			Type: MessageReq_Commit,
+			View: ViewMsg(1, 0),
+			Seal: []byte(from),
+		})
+	}
+
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence:   1,
+		state:      CommitState,
+		commitMsgs: 3,
+		locked:     true,
+		outgoing:   2, // A commit message and the aggregated committed message
+	})
+	committed := m.respMsg[1]
+	assert.Equal(t, MessageReq_Committed, committed.Type)
+	assert.Equal(t, []CommittedSeal{
+		{Signer: "A", Seal: []byte("A")},
+		{Signer: "B", Seal: []byte("B")},
+		{Signer: "C", Seal: []byte("C")},
+	}, committed.CommittedSeals)
+}
+
+func TestTransition_ValidateState_RelayedCommits(t *testing.T) {
+	// a single committed message with enough seals is enough to finalize
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "D")
+	m.config.CommitRelay = true
+	m.setState(ValidateState)
+
+	m.emitMsg(&MessageReq{
+		From: "A",
+		Type: MessageReq_Committed,
+		View: ViewMsg(1, 0),
+		CommittedSeals: []CommittedSeal{
+			{Signer: "A", Seal: []byte("A")},
+			{Signer: "B", Seal: []byte("B")},
+			{Signer: "C", Seal: []byte("C")},
+		},
+	})
+
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence:   1,
+		state:      CommitState,
+		commitMsgs: 3,
+		locked:     true,
+		outgoing:   1, // D commit message, the seals are not relayed again
+	})
+}
+
 // No messages are sent, so ensure that destination state is RoundChangeState and that state machine jumps out of the loop.
 func TestTransition_ValidateState_MoveToRoundChangeState(t *testing.T) {
 	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
@@ -643,6 +1402,53 @@ func TestTransition_CommitState_RoundChange(t *testing.T) {
 	assert.True(t, m.IsState(RoundChangeState))
 }
 
+type mockAsyncInsertBackend struct {
+	*mockBackend
+	inserted []*SealedProposal
+	resultCh chan error
+}
+
+func (m *mockAsyncInsertBackend) InsertAsync(pp *SealedProposal) <-chan error {
+	m.inserted = append(m.inserted, pp)
+	return m.resultCh
+}
+
+func TestTransition_CommitState_AsyncInsert(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		state PbftState
+	}{
+		{"acknowledged", nil, ValidateState},
+		{"failed", errFailedToInsertProposal, SyncState},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+			backend := &mockAsyncInsertBackend{mockBackend: m.backend.(*mockBackend), resultCh: make(chan error, 1)}
+			m.backend = backend
+			m.state.view = ViewMsg(1, 0)
+			m.state.proposer = "A"
+			m.setState(CommitState)
+
+			// the engine does not wait for the insertion to finish the height
+			m.runCycle(context.Background())
+			assert.True(t, m.IsState(DoneState))
+			assert.Len(t, backend.inserted, 1)
+
+			// the next height waits for the acknowledgment before proposing
+			backend.resultCh <- c.err
+			m.state.view = ViewMsg(2, 0)
+			m.setProposal(&Proposal{Data: mockProposal, Hash: digest})
+			m.setState(AcceptState)
+			m.runCycle(context.Background())
+
+			assert.True(t, m.IsState(c.state))
+			assert.Nil(t, m.pendingInsert)
+		})
+	}
+}
+
 // Test exponential timeout for various rounds.
 func TestExponentialTimeout(t *testing.T) {
 	testCases := []struct {
@@ -757,6 +1563,56 @@ func TestGossip_SignProposalFailed(t *testing.T) {
 	assert.Empty(t, m.msgQueue.validateStateQueue)
 }
 
+// Messages generated for a different chain are dropped and accounted in the stats.
+func TestPushMessage_ChainIDMismatch(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.config.ChainID = 10
+
+	m.emitMsg(&MessageReq{
+		From:    "B",
+		Type:    MessageReq_RoundChange,
+		View:    ViewMsg(1, 0),
+		ChainID: 20,
+	})
+	assert.Empty(t, m.msgQueue.roundChangeStateQueue)
+	assert.Equal(t, uint64(1), m.Stats().CrossChainDrops)
+
+	m.emitMsg(&MessageReq{
+		From:    "B",
+		Type:    MessageReq_RoundChange,
+		View:    ViewMsg(1, 0),
+		ChainID: 10,
+	})
+	assert.Len(t, m.msgQueue.roundChangeStateQueue, 1)
+	assert.Equal(t, uint64(1), m.Stats().CrossChainDrops)
+
+	// outgoing messages are tagged with the local chain id
+	m.sendRoundChange(RoundChangeTimeout)
+	assert.Equal(t, uint64(10), m.respMsg[0].ChainID)
+}
+
+// Only the preprepare message carries the proposal payload.
+func TestGossip_DigestOnlyPayload(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+
+	m.sendPreprepareMsg()
+	m.sendPrepareMsg()
+	m.sendCommitMsg()
+	m.sendRoundChange(RoundChangeTimeout)
+
+	for _, msg := range m.respMsg {
+		if msg.Type == MessageReq_Preprepare {
+			assert.Equal(t, mockProposal, msg.Proposal)
+		} else {
+			assert.Zero(t, msg.PayloadSize())
+		}
+	}
+
+	stats := m.Stats()
+	assert.Equal(t, uint64(4), stats.GossipedMessages)
+	assert.Equal(t, uint64(len(mockProposal)), stats.GossipedPayloadBytes)
+}
+
 type gossipDelegate func(*MessageReq) error
 
 type mockPbft struct {
@@ -953,6 +1809,14 @@ func (m *mockBackend) ValidateCommit(from NodeID, seal []byte) error {
 	return nil
 }
 
+// VerifyCommitSeal accepts the seals equal to the hash they seal
+func (m *mockBackend) VerifyCommitSeal(from NodeID, hash, seal []byte) error {
+	if !bytes.Equal(hash, seal) {
+		return errors.New("seal of another hash")
+	}
+	return nil
+}
+
 func (m *mockBackend) Hash(p []byte) []byte {
 	h := sha1.New()
 	h.Write(p)
@@ -1002,3 +1866,25 @@ func (m *mockBackend) ValidatorSet() ValidatorSet {
 
 func (m *mockBackend) Init(*RoundInfo) {
 }
+
+type mockTermBackend struct {
+	*mockBackend
+	term uint64
+}
+
+func (m *mockTermBackend) Term() uint64 {
+	return m.term
+}
+
+func TestPbft_Term(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	assert.Equal(t, uint64(0), m.Term())
+
+	backend := &mockTermBackend{mockBackend: newMockBackend([]string{"A", "B", "C"}, m), term: 3}
+	assert.NoError(t, m.SetBackend(backend))
+	assert.Equal(t, uint64(3), m.Term())
+
+	// outgoing messages carry the term
+	m.sendRoundChange(RoundChangeTimeout)
+	assert.Equal(t, uint64(3), m.respMsg[0].View.Term)
+}
@@ -0,0 +1,78 @@
+package pbft
+
+import "fmt"
+
+// DemotionPolicy is the behavior of the engine when the local node is removed from the validator set
+type DemotionPolicy int
+
+const (
+	// DemotionObserve moves the engine to the sync state, the node keeps following the
+	// network as an observer and becomes a validator again if it is added back (the default)
+	DemotionObserve DemotionPolicy = iota
+
+	// DemotionStop moves the engine to the DemotedState and Run returns
+	DemotionStop
+)
+
+func (d DemotionPolicy) String() string {
+	switch d {
+	case DemotionObserve:
+		return "Observe"
+	case DemotionStop:
+		return "Stop"
+	default:
+		return fmt.Sprintf("DemotionPolicy(%d)", int(d))
+	}
+}
+
+// WithDemotionPolicy sets the behavior of the engine when the validator set of the
+// next height no longer includes the local node
+func WithDemotionPolicy(policy DemotionPolicy) ConfigOption {
+	return func(c *Config) {
+		c.DemotionPolicy = policy
+	}
+}
+
+// DemotedEvent is emitted when the local node is removed from the validator set.
+// It is emitted once, at the first height the node is not a validator anymore
+type DemotedEvent struct {
+	// View is the view of the first height without the node
+	View *View
+
+	// NodeID is the identity of the local node
+	NodeID NodeID
+
+	// Policy is the behavior of the engine from now on
+	Policy DemotionPolicy
+}
+
+func (e *DemotedEvent) EventName() string {
+	return "Demoted"
+}
+
+// checkMembership checks whether the local node is a member of the validator set of the current
+// height and applies the demotion policy if it was removed. It returns false if the node must not
+// take part in the consensus, in that case the state is changed already
+func (p *Pbft) checkMembership(self NodeID) bool {
+	if p.state.validators.Includes(self) {
+		p.isValidator = true
+		return true
+	}
+	if p.isValidator {
+		p.isValidator = false
+		p.logger.Printf("[INFO] local node removed from the validator set: sequence=%d, policy=%s", p.state.view.Sequence, p.config.DemotionPolicy)
+		p.emit(&DemotedEvent{
+			View:   p.state.view.Copy(),
+			NodeID: self,
+			Policy: p.config.DemotionPolicy,
+		})
+		if p.config.DemotionPolicy == DemotionStop {
+			p.setState(DemotedState)
+			return false
+		}
+	}
+	// we are not a validator, move back to sync state
+	p.logger.Print("[INFO] we are not a validator anymore")
+	p.setState(SyncState)
+	return false
+}
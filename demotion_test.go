@@ -0,0 +1,76 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemotion_Observe(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var events []*DemotedEvent
+	m.config.EventHandler = func(e Event) {
+		if demoted, ok := e.(*DemotedEvent); ok {
+			events = append(events, demoted)
+		}
+	}
+	m.setProposal(&Proposal{Data: mockProposal})
+	m.Close()
+
+	m.setState(AcceptState)
+	m.runCycle(m.ctx)
+	assert.True(t, m.isValidator)
+
+	// the next height does not include the node
+	m.sequence = 2
+	assert.NoError(t, m.SetBackend(newMockBackend([]string{"B", "C", "D", "E"}, m)))
+	m.setState(AcceptState)
+	m.runCycle(m.ctx)
+
+	assert.True(t, m.IsState(SyncState))
+	assert.Len(t, events, 1)
+	assert.Equal(t, &DemotedEvent{View: ViewMsg(2, 0), NodeID: "A", Policy: DemotionObserve}, events[0])
+
+	// the event is emitted only once
+	m.setState(AcceptState)
+	m.runCycle(m.ctx)
+	assert.True(t, m.IsState(SyncState))
+	assert.Len(t, events, 1)
+}
+
+func TestDemotion_Stop(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.DemotionPolicy = DemotionStop
+	m.setProposal(&Proposal{Data: mockProposal})
+	m.Close()
+
+	m.setState(AcceptState)
+	m.runCycle(m.ctx)
+
+	m.sequence = 2
+	assert.NoError(t, m.SetBackend(newMockBackend([]string{"B", "C", "D", "E"}, m)))
+	sent := len(m.respMsg)
+	m.Run(context.Background())
+	assert.True(t, m.IsState(DemotedState))
+
+	// the node does not vote anymore
+	assert.Len(t, m.respMsg, sent)
+}
+
+func TestDemotion_NeverValidator(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "")
+	m.config.DemotionPolicy = DemotionStop
+	demoted := false
+	m.config.EventHandler = func(e Event) {
+		if _, ok := e.(*DemotedEvent); ok {
+			demoted = true
+		}
+	}
+
+	// a node that never was a validator keeps syncing
+	m.setState(AcceptState)
+	m.runCycle(m.ctx)
+	assert.True(t, m.IsState(SyncState))
+	assert.False(t, demoted)
+}
@@ -0,0 +1,72 @@
+package pbft
+
+import "fmt"
+
+// DiscardReason is the reason a message was discarded by the engine
+type DiscardReason uint8
+
+const (
+	// DiscardStaleRound is used for messages of a past round of the current sequence
+	DiscardStaleRound DiscardReason = iota
+
+	// DiscardWrongSequence is used for messages of a past sequence
+	DiscardWrongSequence
+
+	// DiscardDifferentTerm is used for messages of a different validator set membership
+	DiscardDifferentTerm
+
+	// DiscardDuplicate is used for messages already received from the same sender
+	DiscardDuplicate
+
+	// DiscardNotValidator is used for messages sent by nodes outside of the validator set
+	DiscardNotValidator
+
+	// DiscardInvalidView is used for messages with a view too far ahead of the current one
+	DiscardInvalidView
+
+	// DiscardUnknownSessionKey is used for messages signed with a session key not registered in the current term
+	DiscardUnknownSessionKey
+
+	// DiscardStrictViolation is used for messages that fail the authenticator or miss required fields in strict mode
+	DiscardStrictViolation
+
+	// DiscardMemoryBudget is used for messages dropped from the message queue over the memory budget
+	DiscardMemoryBudget
+)
+
+var discardReasonNames = map[DiscardReason]string{
+	DiscardStaleRound:    "StaleRound",
+	DiscardWrongSequence: "WrongSequence",
+	DiscardDifferentTerm: "DifferentTerm",
+	DiscardDuplicate:     "Duplicate",
+	DiscardNotValidator:  "NotValidator",
+	DiscardInvalidView:   "InvalidView",
+
+	DiscardUnknownSessionKey: "UnknownSessionKey",
+	DiscardStrictViolation:   "StrictViolation",
+	DiscardMemoryBudget:      "MemoryBudget",
+}
+
+func (d DiscardReason) String() string {
+	if name, ok := discardReasonNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("DiscardReason(%d)", d)
+}
+
+// discardedMsg is a message discarded by the engine along with the reason
+type discardedMsg struct {
+	msg    *MessageReq
+	reason DiscardReason
+}
+
+// countDiscard logs the discarded message and counts it by reason in the stats
+func (p *Pbft) countDiscard(msg *MessageReq, reason DiscardReason) {
+	p.logger.Printf("[DEBUG] discard message: from=%s, type=%s, view=%s, reason=%s", msg.From, msg.Type, msg.View, reason)
+	p.stats.update(func(s *Stats) {
+		if s.Discards == nil {
+			s.Discards = map[DiscardReason]uint64{}
+		}
+		s.Discards[reason]++
+	})
+}
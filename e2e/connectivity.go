@@ -0,0 +1,100 @@
+package e2e
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// components returns the groups of running nodes connected in both directions by the transport hooks
+func (c *cluster) components() [][]string {
+	running := []string{}
+	for _, name := range sortedStrings(c.resolveNodes()) {
+		if c.nodes[name].IsRunning() {
+			running = append(running, name)
+		}
+	}
+	connected := func(a, b string) bool {
+		return c.hook.Connects(pbft.NodeID(a), pbft.NodeID(b)) && c.hook.Connects(pbft.NodeID(b), pbft.NodeID(a))
+	}
+
+	visited := map[string]bool{}
+	components := [][]string{}
+	for _, name := range running {
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		component := []string{name}
+		for i := 0; i < len(component); i++ {
+			for _, other := range running {
+				if !visited[other] && connected(component[i], other) {
+					visited[other] = true
+					component = append(component, other)
+				}
+			}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// hasQuorum returns true if the nodes include a quorum of the validators of the next height of the node
+func (c *cluster) hasQuorum(n *node, nodes []string) bool {
+	validators := c.validatorsAt(n.getNodeHeight()+1, n.nodes)
+	num := 0
+	for _, name := range nodes {
+		if containsString(validators, name) {
+			num++
+		}
+	}
+	return num >= pbft.QuorumSize(len(validators))
+}
+
+// quorumConnectivity checks that the nodes outside of a quorum-connected component do not
+// finalize new heights. When a node is first seen without a quorum it can only reach the height
+// of its own component, by syncing, until it is connected to a quorum again
+type quorumConnectivity struct {
+	lock sync.Mutex
+
+	// isolated is the highest height reachable by every node without a quorum
+	isolated map[string]uint64
+}
+
+func newQuorumConnectivityInvariant() invariant {
+	q := &quorumConnectivity{isolated: map[string]uint64{}}
+	return q.check
+}
+
+func (q *quorumConnectivity) check(c *cluster) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	isolated := map[string]uint64{}
+	for _, component := range c.components() {
+		height := uint64(0)
+		for _, name := range component {
+			if h := c.nodes[name].getNodeHeight(); h > height {
+				height = h
+			}
+		}
+		for _, name := range component {
+			n := c.nodes[name]
+			if c.hasQuorum(n, component) {
+				continue
+			}
+			limit, ok := q.isolated[name]
+			if !ok {
+				limit = height
+			}
+			if h := n.getNodeHeight(); h > limit {
+				return fmt.Errorf("node %s without quorum connectivity finalized height %d above %d, component %v", name, h, limit, component)
+			}
+			isolated[name] = limit
+		}
+	}
+	// the nodes connected to a quorum again, or stopped, are not tracked anymore
+	q.isolated = isolated
+	return nil
+}
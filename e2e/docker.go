@@ -0,0 +1,159 @@
+package e2e
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const (
+	// dockerNodePort is the port the nodes listen on inside the containers
+	dockerNodePort = 8545
+
+	// dockerDefaultImage is the image used to run the nodes, it must provide tc and iptables
+	dockerDefaultImage = "nicolaka/netshoot"
+)
+
+// dockerLauncher runs every node in its own container. The test binary is mounted in the
+// containers, so it has to be built for the container platform (i.e. CGO_ENABLED=0 on linux)
+type dockerLauncher struct {
+	network string
+	image   string
+	binary  string
+
+	// hostAddrs are the addresses of the nodes published on the local host
+	hostAddrs map[string]string
+}
+
+func (d *dockerLauncher) container(name string) string {
+	return d.network + "-" + name
+}
+
+func (d *dockerLauncher) command(name string, env []string) *exec.Cmd {
+	args := []string{
+		"run", "--rm",
+		"--name", d.container(name),
+		"--hostname", name,
+		"--network", d.network,
+		"--cap-add", "NET_ADMIN",
+		"-p", d.hostAddrs[name] + ":" + strconv.Itoa(dockerNodePort),
+		"-v", d.binary + ":/e2e.test:ro",
+	}
+	for _, e := range env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, d.image, "/e2e.test", "-test.run=^$")
+	return exec.Command("docker", args...)
+}
+
+func (d *dockerLauncher) signal(name string, cmd *exec.Cmd, sig syscall.Signal) error {
+	return exec.Command("docker", "kill", "--signal", strconv.Itoa(int(sig)), d.container(name)).Run()
+}
+
+// dockerCluster is a process cluster where the nodes run in containers, the network
+// conditions are shaped with tc (latency and loss) and iptables (partitions)
+type dockerCluster struct {
+	*processCluster
+
+	launcher *dockerLauncher
+}
+
+// newDockerCluster creates the docker cluster. The test is skipped
+// unless E2E_DOCKER is set and docker is available
+func newDockerCluster(t *testing.T, name, prefix string, count int) *dockerCluster {
+	if os.Getenv("E2E_DOCKER") != "true" {
+		t.Skip("Docker tests are disabled.")
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not available")
+	}
+
+	binary, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := os.Getenv("E2E_DOCKER_IMAGE")
+	if image == "" {
+		image = dockerDefaultImage
+	}
+
+	launcher := &dockerLauncher{
+		network:   fmt.Sprintf("e2e-%s-%d", name, time.Now().UnixNano()),
+		image:     image,
+		binary:    binary,
+		hostAddrs: map[string]string{},
+	}
+	if out, err := exec.Command("docker", "network", "create", launcher.network).CombinedOutput(); err != nil {
+		t.Fatalf("failed to create the docker network: %v %s", err, out)
+	}
+	t.Cleanup(func() {
+		exec.Command("docker", "network", "rm", launcher.network).Run()
+	})
+
+	c := &dockerCluster{
+		processCluster: newProcessClusterWithLauncher(t, name, prefix, count, launcher),
+		launcher:       launcher,
+	}
+	for _, nodeName := range c.names {
+		addr := freeLocalAddr(t)
+		launcher.hostAddrs[nodeName] = addr
+		c.statusAddrs[nodeName] = addr
+		c.peers[nodeName] = nodeName + ":" + strconv.Itoa(dockerNodePort)
+	}
+	return c
+}
+
+func (c *dockerCluster) exec(name string, args ...string) error {
+	args = append([]string{"exec", c.launcher.container(name)}, args...)
+	if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+// SetNetem sets the latency and the loss percentage of the outgoing traffic of the node
+func (c *dockerCluster) SetNetem(name string, latency time.Duration, loss float64) error {
+	return c.exec(name, "tc", "qdisc", "replace", "dev", "eth0", "root", "netem",
+		"delay", fmt.Sprintf("%dms", latency.Milliseconds()),
+		"loss", fmt.Sprintf("%.2f%%", loss))
+}
+
+// Partition splits the network in the given subsets, the traffic between subsets is dropped
+func (c *dockerCluster) Partition(subsets ...[]string) error {
+	subsetOf := map[string]int{}
+	for indx, subset := range subsets {
+		for _, name := range subset {
+			subsetOf[name] = indx
+		}
+	}
+	for _, name := range c.names {
+		for _, peer := range c.names {
+			fromSubset, ok1 := subsetOf[name]
+			toSubset, ok2 := subsetOf[peer]
+			if !ok1 || !ok2 || fromSubset == toSubset {
+				continue
+			}
+			if err := c.exec(name, "iptables", "-A", "INPUT", "-s", peer, "-j", "DROP"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Heal removes the partitions and the traffic shaping of every node
+func (c *dockerCluster) Heal() error {
+	for _, name := range c.names {
+		if err := c.exec(name, "iptables", "-F", "INPUT"); err != nil {
+			return err
+		}
+		// fails if no qdisc is set
+		_ = c.exec(name, "tc", "qdisc", "del", "dev", "eth0", "root")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// DuplicateIdentity starts a second node with the key of the node, as an operator running two
+// processes with the same key by mistake. The clone builds proposals different from the ones
+// of the node if divergent is set, so that the two processes equivocate when they propose.
+// It returns the name of the clone in the cluster. It must be called from the test goroutine
+func (c *cluster) DuplicateIdentity(name string, divergent bool) string {
+	original, ok := c.nodes[name]
+	if !ok {
+		c.t.Fatalf("node %s not found", name)
+	}
+	clone := name + "_dup"
+
+	logFile, err := os.Create(filepath.Join(c.outputDir, clone+".log"))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var replay *replayNotifier
+	if isReplayEnabled() {
+		if replay, err = newReplayNotifier(c.outputDir, clone); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	// the node is registered in the transport with the same id, both receive every message
+	n, _ := newPBFTNode(name, original.nodes, c.tracer.Tracer(clone), c.transport, logFile, replay)
+	n.c = c
+	n.divergent = divergent
+
+	c.lock.Lock()
+	c.nodes[clone] = n
+	c.lock.Unlock()
+
+	n.Start()
+	return clone
+}
+
+// Offenders returns the validators reported in the equivocation evidence of any node
+func (c *cluster) Offenders() []pbft.NodeID {
+	found := map[pbft.NodeID]struct{}{}
+	for _, n := range c.Nodes() {
+		for _, evidence := range n.pbft.Evidence() {
+			found[evidence.Offender] = struct{}{}
+		}
+	}
+	offenders := make([]pbft.NodeID, 0, len(found))
+	for id := range found {
+		offenders = append(offenders, id)
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		return offenders[i] < offenders[j]
+	})
+	return offenders
+}
+
+// WaitForOffender waits until the validator is reported in the equivocation evidence of any node
+func (c *cluster) WaitForOffender(id pbft.NodeID, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		for _, offender := range c.Offenders() {
+			if offender == id {
+				return true
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+// QuarantineOffenders quarantines the offenders found in the evidence on every other node
+// for the duration and returns them
+func (c *cluster) QuarantineOffenders(duration time.Duration) []pbft.NodeID {
+	offenders := c.Offenders()
+	for _, n := range c.Nodes() {
+		for _, offender := range offenders {
+			if pbft.NodeID(n.name) != offender {
+				n.pbft.Quarantine(offender, duration)
+			}
+		}
+	}
+	return offenders
+}
@@ -0,0 +1,39 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_QuorumConnectivity(t *testing.T) {
+	c := newPBFTCluster(t, "quorum_connectivity", "qc", 5)
+	c.Start()
+	defer c.Stop()
+
+	assert.NoError(t, c.WaitForHeight(2, 1*time.Minute))
+
+	check := newQuorumConnectivityInvariant()
+
+	// no component has a quorum of 3
+	c.Scenario().Partition([]string{"qc_0", "qc_1"}, []string{"qc_2", "qc_3"}, []string{"qc_4"})
+	assert.Len(t, c.components(), 3)
+	for i := 0; i < 15; i++ {
+		assert.NoError(t, check(c))
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	// a node finalizing without a quorum is caught
+	n := c.nodes["qc_4"]
+	index := n.getSyncIndex()
+	n.setSyncIndex(index + 5)
+	assert.Error(t, check(c))
+	n.setSyncIndex(index)
+
+	// the nodes are not tracked once they are connected to a quorum again
+	c.Scenario().Heal()
+	assert.Len(t, c.components(), 1)
+	assert.NoError(t, c.WaitForHeight(c.maxHeight()+2, 1*time.Minute))
+	assert.NoError(t, check(c))
+}
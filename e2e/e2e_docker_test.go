@@ -0,0 +1,31 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Docker_NetworkShaping(t *testing.T) {
+	c := newDockerCluster(t, "docker", "docker", 4)
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(3, 2*time.Minute)
+	assert.NoError(t, err)
+
+	for _, name := range c.names {
+		assert.NoError(t, c.SetNetem(name, 100*time.Millisecond, 5))
+	}
+	err = c.WaitForHeight(6, 2*time.Minute)
+	assert.NoError(t, err)
+
+	// no subset has the quorum
+	assert.NoError(t, c.Partition([]string{"docker_0", "docker_1"}, []string{"docker_2", "docker_3"}))
+	time.Sleep(5 * time.Second)
+
+	assert.NoError(t, c.Heal())
+	err = c.WaitForHeight(9, 2*time.Minute)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,42 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_DuplicateIdentity(t *testing.T) {
+	t.Run("Identical", func(t *testing.T) {
+		c := newPBFTCluster(t, "duplicate_identical", "dup", 4)
+		c.Start()
+		defer c.Stop()
+
+		assert.NoError(t, c.WaitForHeight(2, 1*time.Minute))
+
+		// both processes propose and vote the same, the network tolerates them
+		c.DuplicateIdentity("dup_1", false)
+		assert.NoError(t, c.WaitForHeight(10, 1*time.Minute))
+		assert.Empty(t, c.Offenders())
+	})
+
+	t.Run("Divergent", func(t *testing.T) {
+		c := newPBFTCluster(t, "duplicate_divergent", "dup", 4)
+		c.Start()
+		defer c.Stop()
+
+		assert.NoError(t, c.WaitForHeight(2, 1*time.Minute))
+
+		// the processes propose different proposals, the honest nodes collect the evidence
+		c.DuplicateIdentity("dup_1", true)
+		assert.True(t, c.WaitForOffender("dup_1", 1*time.Minute))
+		assert.Equal(t, []pbft.NodeID{"dup_1"}, c.Offenders())
+
+		// the rest of the validators keep the quorum without the offender
+		c.QuarantineOffenders(10 * time.Minute)
+		height := c.Nodes()[0].currentHeight()
+		assert.NoError(t, c.WaitForHeight(height+5, 1*time.Minute, []string{"dup_0", "dup_2", "dup_3"}))
+	})
+}
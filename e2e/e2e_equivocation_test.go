@@ -0,0 +1,26 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_ProposerEquivocation(t *testing.T) {
+	c := newPBFTCluster(t, "proposer_equivocation", "equiv", 4)
+	proposer := c.EquivocateProposer(3)
+	c.Start()
+	defer c.Stop()
+
+	// the cluster panics if two nodes finalize different proposals at the same height
+	assert.NoError(t, c.WaitForHeight(8, 2*time.Minute))
+	assert.NoError(t, agreementInvariant(c))
+
+	// the proposer voted for both proposals, the honest nodes collect the evidence
+	assert.NotEmpty(t, proposer())
+	assert.True(t, c.WaitForOffender(proposer(), 1*time.Minute))
+	for _, offender := range c.Offenders() {
+		assert.Equal(t, proposer(), offender)
+	}
+}
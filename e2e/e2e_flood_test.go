@@ -0,0 +1,22 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Flood(t *testing.T) {
+	hook := newFloodTransport([]string{"flood_0"}, 50, 10*time.Millisecond)
+	c := newPBFTCluster(t, "flood", "flood", 5, hook)
+	c.Start()
+	defer c.Stop()
+
+	hook.Start(c.transport)
+	defer hook.Stop()
+
+	err := c.WaitForHeight(10, 1*time.Minute)
+	assert.NoError(t, err)
+	assert.NotZero(t, hook.Flooded())
+}
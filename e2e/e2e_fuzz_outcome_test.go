@@ -0,0 +1,52 @@
+package e2e
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_FuzzOutcome_Classify(t *testing.T) {
+	c := &cluster{t: t, nodes: map[string]*node{"a": {}, "b": {}}}
+	n := &nemesis{c: c}
+
+	assert.Equal(t, outcomeClean, n.classify(nil))
+
+	n.stalls = []string{"partition [a] from [b]"}
+	assert.Equal(t, outcomeStallRecovered, n.classify(nil))
+	assert.Equal(t, outcomeStallUnrecovered, n.classify(fmt.Errorf("no progress after healing")))
+
+	atomic.StoreUint64(&c.nodes["a"].crashed, 1)
+	assert.Equal(t, outcomeNodeCrash, n.classify(fmt.Errorf("no progress after healing")))
+
+	// safety violations are the most severe
+	assert.Equal(t, outcomeSafetyViolation, n.classify(fmt.Errorf("%w: fork", errInvariantViolation)))
+}
+
+func TestE2E_FuzzOutcome_Rates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outcomes.jsonl")
+	outcomes := map[string][]fuzzOutcome{
+		"base":   {outcomeClean, outcomeClean, outcomeStallRecovered, outcomeSafetyViolation},
+		"change": {outcomeClean, outcomeNodeCrash},
+	}
+	for label, list := range outcomes {
+		for _, outcome := range list {
+			assert.NoError(t, appendOutcome(path, &outcomeRecord{Label: label, Outcome: outcome}))
+		}
+	}
+
+	records, err := readOutcomes(path)
+	assert.NoError(t, err)
+	assert.Len(t, records, 6)
+
+	rates := outcomeRates(records)
+	assert.Equal(t, 0.5, rates["base"][outcomeClean])
+	assert.Equal(t, 0.25, rates["base"][outcomeStallRecovered])
+	assert.Equal(t, 0.25, rates["base"][outcomeSafetyViolation])
+	assert.Equal(t, 0.0, rates["base"][outcomeNodeCrash])
+	assert.Equal(t, 0.5, rates["change"][outcomeNodeCrash])
+	assert.Contains(t, formatOutcomeRates(rates), "change: safety-violation=0.00 node-crash=0.50")
+}
@@ -0,0 +1,18 @@
+package e2e
+
+// Generated by scenariogen from testdata/scenarios/minority_partition_heal.json, edit it to refine the scenario.
+
+import (
+	"testing"
+	"time"
+)
+
+// TestE2E_Generated_MinorityPartitionHeal runs the scenario: a minority of the cluster is partitioned away and healed, every node must catch up
+func TestE2E_Generated_MinorityPartitionHeal(t *testing.T) {
+	newScenarioBuilder(t, "Generated_MinorityPartitionHeal", 5).
+		Latency(50*time.Millisecond).
+		Partition(3, []int{0, 1}, []int{2, 3, 4}).
+		Heal(6).
+		ExpectHeight(9, 1*time.Minute).
+		Run()
+}
@@ -0,0 +1,27 @@
+package e2e
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Golden_HappyPath(t *testing.T) {
+	for _, nodes := range []int{4, 7, 100} {
+		t.Run(fmt.Sprintf("%d", nodes), func(t *testing.T) {
+			if isGoldenUpdate() {
+				if err := recordGolden(t, nodes); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected, replayed, err := replayGolden(goldenPath(nodes), nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.NotEmpty(t, expected)
+			assert.Equal(t, expected, replayed)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_StartAtHeight(t *testing.T) {
+	const height = 1000
+
+	c := newPBFTCluster(t, "start_at_height", "sah", 4)
+	c.UseLedger()
+	assert.NoError(t, c.StartAtHeight(height))
+	defer c.Stop()
+
+	assert.NoError(t, c.WaitForHeight(height+3, 1*time.Minute))
+	assert.NoError(t, c.VerifyLedger())
+
+	for _, n := range c.Nodes() {
+		assert.Greater(t, n.pbft.Health().View.Sequence, uint64(height))
+	}
+}
@@ -0,0 +1,65 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+type orderHook struct {
+	name  string
+	send  bool
+	calls *[]string
+}
+
+func (o *orderHook) Connects(from, to pbft.NodeID) bool {
+	return o.send
+}
+
+func (o *orderHook) Gossip(from, to pbft.NodeID, msg *pbft.MessageReq) bool {
+	*o.calls = append(*o.calls, o.name)
+	return o.send
+}
+
+func TestE2E_HookChain_Order(t *testing.T) {
+	calls := []string{}
+
+	chain := &hookChain{}
+	chain.add(&orderHook{name: "a", send: true, calls: &calls})
+	chain.add(&orderHook{name: "b", send: false, calls: &calls})
+	chain.add(&orderHook{name: "c", send: true, calls: &calls})
+
+	msg := &pbft.MessageReq{View: &pbft.View{}}
+	assert.False(t, chain.Gossip("A", "B", msg))
+	assert.False(t, chain.Connects("A", "B"))
+
+	// hooks run in order and the pipeline stops at the first drop
+	assert.Equal(t, []string{"a", "b"}, calls)
+
+	// an empty chain lets everything through
+	assert.True(t, (&hookChain{}).Gossip("A", "B", msg))
+	assert.True(t, (&hookChain{}).Connects("A", "B"))
+}
+
+func TestE2E_HookChain_PartitionLatencyLoss(t *testing.T) {
+	partition := newPartitionTransport(50 * time.Millisecond)
+	loss := newRuleTransport(dropRule(pbft.MessageReq_Prepare).From("chain_0"))
+
+	c := newPBFTCluster(t, "hook_chain", "chain", 5, partition, newRandomTransport(50*time.Millisecond), loss)
+	c.Start()
+	defer c.Stop()
+
+	// isolate one node while the prepares of another one are lost
+	partition.Partition([]string{"chain_0", "chain_1", "chain_2", "chain_3"}, []string{"chain_4"})
+
+	majority := []string{"chain_0", "chain_1", "chain_2", "chain_3"}
+	err := c.WaitForHeight(5, 1*time.Minute, majority)
+	assert.NoError(t, err)
+
+	partition.Reset()
+
+	err = c.WaitForHeight(8, 1*time.Minute)
+	assert.NoError(t, err)
+}
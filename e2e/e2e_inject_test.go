@@ -0,0 +1,68 @@
+package e2e
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_InjectMessage(t *testing.T) {
+	c := newPBFTCluster(t, "inject", "inj", 4)
+	c.Start()
+	defer c.Stop()
+
+	assert.NoError(t, c.WaitForHeight(2, 1*time.Minute))
+
+	target := c.nodes["inj_0"].pbft
+	view := target.Health().View
+	before := target.Stats()
+
+	// the injected messages are told apart from the organic traffic by the handler of the target
+	var lock sync.Mutex
+	injected := map[string]int{}
+	c.transport.Register("inj_0", func(msg *pbft.MessageReq) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case msg.View.Round >= view.Round+1000:
+			injected["future"]++
+		case msg.From == "forged":
+			injected["forged"]++
+		case len(msg.Hash) == 2:
+			injected["digest"]++
+		}
+	})
+
+	// future view
+	assert.NoError(t, c.InjectMessage("inj_1", "inj_0", &pbft.MessageReq{
+		Type: pbft.MessageReq_RoundChange,
+		View: &pbft.View{Sequence: view.Sequence, Round: view.Round + 1000},
+	}))
+	// forged sender
+	assert.NoError(t, c.InjectMessage("forged", "inj_0", &pbft.MessageReq{
+		Type: pbft.MessageReq_Status,
+		View: view,
+	}))
+	// bad digest
+	assert.NoError(t, c.InjectMessage("inj_1", "inj_0", &pbft.MessageReq{
+		Type: pbft.MessageReq_Prepare,
+		View: view,
+		Hash: []byte{0x1, 0x2},
+	}))
+	assert.Error(t, c.InjectMessage("inj_1", "unknown", &pbft.MessageReq{Type: pbft.MessageReq_RoundChange, View: view}))
+
+	lock.Lock()
+	assert.Equal(t, map[string]int{"future": 1, "forged": 1, "digest": 1}, injected)
+	lock.Unlock()
+
+	// the organic traffic may be discarded for the same reasons, only the injected ones are certain
+	after := target.Stats()
+	assert.GreaterOrEqual(t, after.Discards[pbft.DiscardInvalidView], before.Discards[pbft.DiscardInvalidView]+1)
+	assert.GreaterOrEqual(t, after.Discards[pbft.DiscardNotValidator], before.Discards[pbft.DiscardNotValidator]+1)
+
+	// the crafted messages do not disrupt the cluster
+	assert.NoError(t, c.WaitForHeight(view.Sequence+3, 1*time.Minute))
+}
@@ -0,0 +1,41 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Ledger(t *testing.T) {
+	c := newPBFTCluster(t, "ledger", "ledger", 4)
+	c.UseLedger()
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(5, 1*time.Minute)
+	assert.NoError(t, err)
+
+	// restarting a node must not break the chain
+	c.StopNode("ledger_0")
+	err = c.WaitForHeight(8, 1*time.Minute, []string{"ledger_1", "ledger_2", "ledger_3"})
+	assert.NoError(t, err)
+	c.StartNode("ledger_0")
+	err = c.WaitForHeight(10, 1*time.Minute)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.VerifyLedger())
+
+	// a missed insert shows up as a break of the hash chain
+	c.lock.Lock()
+	missed := append(append([]*pbft.SealedProposal{}, c.sealedProposals[:3]...), c.sealedProposals[4:]...)
+	c.lock.Unlock()
+	assert.Error(t, verifyLedger(missed))
+
+	// every node builds on its own head, the node that missed an insert breaks the chain
+	head := &chainHead{}
+	head.set(3, hash([]byte{3}))
+	assert.Equal(t, hash([]byte{3}), head.parentHash(4))
+	assert.Nil(t, head.parentHash(5))
+}
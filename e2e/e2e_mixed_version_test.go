@@ -0,0 +1,20 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_MixedVersion(t *testing.T) {
+	// two nodes of the previous version, the cluster needs both versions for the quorum
+	c := newMixedVersionCluster(t, "mixed_version", "mixed", 4, 2)
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(8, 2*time.Minute)
+	assert.NoError(t, err)
+
+	c.AssertInteroperability(8)
+}
@@ -0,0 +1,31 @@
+package e2e
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_MessageComplexity(t *testing.T) {
+	for _, nodes := range []int{4, 7} {
+		nodes := nodes
+		t.Run(fmt.Sprintf("Nodes%d", nodes), func(t *testing.T) {
+			c := newPBFTCluster(t, fmt.Sprintf("msg_complexity_%d", nodes), "complexity", nodes, newRandomTransport(50*time.Millisecond))
+			c.Start()
+			defer c.Stop()
+
+			err := c.WaitForHeight(8, 1*time.Minute)
+			assert.NoError(t, err)
+
+			for height := uint64(2); height <= 6; height++ {
+				msgs := c.MessagesAt(height)
+				t.Logf("height=%d gossiped=%d delivered=%d consensus=%d types=%s", height, msgs.Gossiped, msgs.Delivered, msgs.Consensus(), formatTypes(msgs.Types))
+			}
+			// per height the proposer broadcasts a preprepare and every node broadcasts a prepare
+			// and a commit, that is 2N²+N consensus messages delivered
+			c.AssertMessageComplexity(2, 6, 3)
+		})
+	}
+}
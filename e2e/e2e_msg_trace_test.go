@@ -0,0 +1,54 @@
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_MsgTrace(t *testing.T) {
+	t.Setenv("E2E_MSG_TRACE", "true")
+
+	hook := newRuleTransport(dropRule(pbft.MessageReq_Commit).To("trace_0").InRound(0))
+	c := newPBFTCluster(t, "trace", "trace", 4, hook)
+	c.Start()
+
+	err := c.WaitForHeight(2, 1*time.Minute)
+	assert.NoError(t, err)
+	c.Stop()
+
+	entries, err := readMsgTrace(filepath.Join(c.outputDir, msgTraceFile))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, entries)
+
+	graphs := communicationGraphs(entries)
+	assert.NotEmpty(t, graphs)
+
+	dropped := 0
+	for _, graph := range graphs {
+		for from := range graph.Dropped {
+			dropped += graph.Dropped[from]["trace_0"]
+		}
+	}
+	assert.NotZero(t, dropped)
+	t.Log(graphs[0])
+}
+
+// TestE2E_MsgTraceView prints the communication graphs of the trace file set in E2E_MSG_TRACE_VIEW
+func TestE2E_MsgTraceView(t *testing.T) {
+	path := os.Getenv("E2E_MSG_TRACE_VIEW")
+	if path == "" {
+		t.Skip("E2E_MSG_TRACE_VIEW is not set")
+	}
+	entries, err := readMsgTrace(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, graph := range communicationGraphs(entries) {
+		t.Log(graph)
+	}
+}
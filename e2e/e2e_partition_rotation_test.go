@@ -0,0 +1,59 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_PartitionRotation(t *testing.T) {
+	const epochSize = 4
+
+	// the validator set changes at height 5: pr_0 leaves and pr_4 joins
+	prev := []string{"pr_0", "pr_1", "pr_2", "pr_3"}
+	next := []string{"pr_1", "pr_2", "pr_3", "pr_4"}
+
+	t.Run("MajorityAcrossChange", func(t *testing.T) {
+		c := newPBFTCluster(t, "partition_rotation_majority", "pr", 5, newRandomTransport(50*time.Millisecond))
+		c.Rotate(epochSize, prev, next)
+		c.Start()
+		defer c.Stop()
+
+		assert.NoError(t, c.WaitForHeight(2, 1*time.Minute))
+
+		// the joining validator is cut off, the rest keeps a quorum of both sets
+		majority := []string{"pr_0", "pr_1", "pr_2", "pr_3"}
+		c.Scenario().Partition(majority, []string{"pr_4"})
+		assert.NoError(t, c.WaitForHeight(2*epochSize, 1*time.Minute, next[:3]))
+
+		// the joining validator catches up on the chain finalized without it
+		c.Scenario().Heal()
+		assert.NoError(t, c.WaitForHeight(3*epochSize, 1*time.Minute))
+		assert.NoError(t, agreementInvariant(c))
+		assertSetChanged(t, c, prev, next, epochSize+1)
+	})
+
+	t.Run("NoQuorumAfterChange", func(t *testing.T) {
+		c := newPBFTCluster(t, "partition_rotation_stall", "pr", 5, newRandomTransport(50*time.Millisecond))
+		c.Rotate(epochSize, prev, next)
+		c.Start()
+		defer c.Stop()
+
+		assert.NoError(t, c.WaitForHeight(2, 1*time.Minute))
+
+		// the side with a quorum of the old set finalizes up to the change, then neither
+		// side has a quorum of the new set
+		left, right := []string{"pr_0", "pr_1", "pr_2"}, []string{"pr_3", "pr_4"}
+		c.Scenario().Partition(left, right)
+		assert.NoError(t, c.WaitForHeight(epochSize, 1*time.Minute, left))
+		c.IsStuck(10*time.Second, left)
+		c.IsStuck(10*time.Second, right)
+
+		// once healed every node converges on a single chain through the change
+		c.Scenario().Heal()
+		assert.NoError(t, c.WaitForHeight(3*epochSize, 1*time.Minute))
+		assert.NoError(t, agreementInvariant(c))
+		assertSetChanged(t, c, prev, next, epochSize+1)
+	})
+}
@@ -11,9 +11,9 @@ import (
 
 func TestE2E_Partition_OneMajority(t *testing.T) {
 	const nodesCnt = 5
-	hook := newPartitionTransport(300 * time.Millisecond)
 
-	c := newPBFTCluster(t, "majority_partition", "prt", nodesCnt, hook)
+	c := newPBFTCluster(t, "majority_partition", "prt", nodesCnt)
+	c.Scenario().SetLatency(300 * time.Millisecond)
 	c.Start()
 	defer c.Stop()
 
@@ -23,7 +23,7 @@ func TestE2E_Partition_OneMajority(t *testing.T) {
 	// create two partitions.
 	majorityPartition := []string{"prt_0", "prt_1", "prt_2"}
 	minorityPartition := []string{"prt_3", "prt_4"}
-	hook.Partition(majorityPartition, minorityPartition)
+	c.Scenario().Partition(majorityPartition, minorityPartition)
 
 	// only the majority partition will be able to sync
 	err = c.WaitForHeight(10, 1*time.Minute, majorityPartition)
@@ -33,7 +33,7 @@ func TestE2E_Partition_OneMajority(t *testing.T) {
 	c.IsStuck(10*time.Second, minorityPartition)
 
 	// reset all partitions
-	hook.Reset()
+	c.Scenario().Heal()
 
 	allNodes := make([]string, len(c.nodes))
 	for i, node := range c.Nodes() {
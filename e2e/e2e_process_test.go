@@ -0,0 +1,74 @@
+package e2e
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(processNodeEnv) != "" {
+		// the test binary was started as a node of a process cluster
+		if err := runProcessNode(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	code := m.Run()
+	if leaked := leakedTransports(); leaked != 0 && code == 0 {
+		fmt.Fprintf(os.Stderr, "%d transports leaked after the clusters stopped\n", leaked)
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func TestE2E_Process_KillNode(t *testing.T) {
+	c := newProcessCluster(t, "process", "proc", 4)
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(3, 1*time.Minute)
+	assert.NoError(t, err)
+
+	// crash one node, the rest keeps the quorum
+	c.KillNode("proc_0")
+	err = c.WaitForHeight(6, 1*time.Minute, []string{"proc_1", "proc_2", "proc_3"})
+	assert.NoError(t, err)
+
+	// the restarted node syncs and joins again
+	c.StartNode("proc_0")
+	err = c.WaitForHeight(9, 1*time.Minute)
+	assert.NoError(t, err)
+}
+
+func TestE2E_Process_Partition(t *testing.T) {
+	c := newProcessCluster(t, "process_partition", "proc", 5)
+	c.Scenario().SetLatency(50 * time.Millisecond)
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(3, 1*time.Minute)
+	assert.NoError(t, err)
+
+	// only the majority partition finalizes heights, the messages go through the relay
+	majority := []string{"proc_0", "proc_1", "proc_2"}
+	minority := []string{"proc_3", "proc_4"}
+	c.Scenario().Partition(majority, minority)
+	err = c.WaitForHeight(6, 1*time.Minute, majority)
+	assert.NoError(t, err)
+
+	for _, name := range minority {
+		height, ok := c.getHeight(name)
+		assert.True(t, ok)
+		assert.Less(t, height, uint64(6))
+	}
+
+	// the minority syncs once healed
+	c.Scenario().Heal()
+	err = c.WaitForHeight(9, 1*time.Minute)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,33 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_ProposerDoS(t *testing.T) {
+	const attackedRounds = 1
+
+	var c *cluster
+	hook := newProposerDoSTransport(attackedRounds, func(view *pbft.View) pbft.NodeID {
+		return c.calcProposer(view)
+	})
+	c = newPBFTCluster(t, "proposer_dos", "dos", 5, hook)
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(5, 2*time.Minute)
+	assert.NoError(t, err)
+
+	// the predictable proposer is always isolated, so every
+	// height needs at least one extra round to be finalized
+	for height := uint64(1); height <= 5; height++ {
+		round, ok := hook.commitRound(height)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, round, uint64(attackedRounds))
+		t.Logf("height %d committed in round %d", height, round)
+	}
+}
@@ -0,0 +1,20 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_ProposerFairness(t *testing.T) {
+	c := newPBFTCluster(t, "proposer_fairness", "fair", 5)
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(10, 1*time.Minute)
+	assert.NoError(t, err)
+
+	// without faults the proposer rotates on every height
+	c.AssertProposerFairness(1, 10, 0.5)
+}
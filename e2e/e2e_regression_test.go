@@ -0,0 +1,107 @@
+package e2e
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func commitRecord(node string, seq uint64, hash byte) *replayRecord {
+	return &replayRecord{Node: node, Msg: &pbft.MessageReq{Type: pbft.MessageReq_Commit, View: pbft.ViewMsg(seq, 0), Hash: []byte{hash}}}
+}
+
+func doneRecord(node string, seq uint64) *replayRecord {
+	return &replayRecord{Node: node, From: pbft.CommitState.String(), To: pbft.DoneState.String(), View: pbft.ViewMsg(seq, 0)}
+}
+
+func TestRegression_FlowAgreement(t *testing.T) {
+	records := []*replayRecord{
+		commitRecord("A", 1, 0x1), commitRecord("A", 1, 0x1), doneRecord("A", 1),
+		commitRecord("B", 1, 0x1), commitRecord("B", 1, 0x1), doneRecord("B", 1),
+		commitRecord("A", 2, 0x2), commitRecord("A", 2, 0x2), doneRecord("A", 2),
+		commitRecord("B", 2, 0x3), commitRecord("B", 2, 0x3), commitRecord("B", 2, 0x2), doneRecord("B", 2),
+		commitRecord("A", 3, 0x4), doneRecord("A", 3),
+	}
+	height, err := flowAgreement(records[:6])
+	assert.NoError(t, err)
+	assert.Zero(t, height)
+
+	height, err = flowAgreement(records)
+	assert.Error(t, err)
+	assert.Equal(t, uint64(2), height)
+
+	// heights 1 and 2 are kept
+	assert.Len(t, minimizeFlow(records, 2), 13)
+	assert.Len(t, minimizeFlow(records, 3), 9)
+}
+
+func TestRegression_Emit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "regression")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	replay, err := newReplayNotifier(dir, "A")
+	assert.NoError(t, err)
+	replay.RecordMessage(1, commitRecord("A", 1, 0x1).Msg)
+	replay.RecordStateTransition(2, pbft.CommitState, pbft.DoneState, pbft.ViewMsg(1, 0))
+	assert.NoError(t, replay.Close())
+	_, err = mergeReplayFiles(dir)
+	assert.NoError(t, err)
+
+	c := &cluster{nodes: map[string]*node{}, outputDir: dir}
+	n := &nemesis{c: c, seed: 42, interval: time.Second, schedule: []string{"stop [A]"}}
+
+	path, err := emitRegression(dir, c, n, errors.New("invariant violation: boom"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "regression_nemesis_42_test.go"), path)
+
+	test, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(test), `runRegression(t, "Nemesis_42")`))
+
+	records, err := readReplayFile(filepath.Join(dir, regressionDir, "Nemesis_42", regressionFlowFile))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	_, err = os.Stat(filepath.Join(dir, regressionDir, "Nemesis_42", regressionScheduleFile))
+	assert.NoError(t, err)
+}
+
+func TestRegression_Replay(t *testing.T) {
+	t.Setenv("E2E_REPLAY", "true")
+
+	c := newPBFTCluster(t, "regression_replay", regressionPrefix, 4)
+	c.Start()
+	err := c.WaitForHeight(4, 1*time.Minute)
+	c.Stop()
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "regression")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	n := &nemesis{c: c, seed: 7, interval: time.Second}
+	_, err = emitRegression(dir, c, n, errors.New("invariant violation: none"))
+	assert.NoError(t, err)
+
+	// the recorded flow of the healthy run is finalized again by the replayed nodes
+	checkRegression(t, filepath.Join(dir, regressionDir, "Nemesis_7"))
+}
+
+func TestRegression_FlowLastProposer(t *testing.T) {
+	nodes := regressionNodes(4)
+	preprepare := func(from string, round uint64) *replayRecord {
+		return &replayRecord{Node: "nemesis_0", Msg: &pbft.MessageReq{Type: pbft.MessageReq_Preprepare, From: pbft.NodeID(from), View: pbft.ViewMsg(3, round)}}
+	}
+
+	assert.Equal(t, pbft.NodeID(""), flowLastProposer(nil, nodes, 3))
+	assert.Equal(t, pbft.NodeID("nemesis_1"), flowLastProposer([]*replayRecord{preprepare("nemesis_2", 0)}, nodes, 3))
+	// the lowest round is used
+	assert.Equal(t, pbft.NodeID("nemesis_3"), flowLastProposer([]*replayRecord{preprepare("nemesis_3", 2), preprepare("nemesis_0", 0)}, nodes, 3))
+}
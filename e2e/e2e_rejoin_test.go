@@ -0,0 +1,26 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Rejoin(t *testing.T) {
+	c := newPBFTCluster(t, "rejoin", "rj", 5)
+	c.Start()
+	defer c.Stop()
+
+	assert.NoError(t, c.WaitForHeight(3, 1*time.Minute))
+
+	// the stopped node misses several heights
+	c.StopNode("rj_0")
+	assert.NoError(t, c.WaitForHeight(8, 1*time.Minute, generateNodeNames(1, 4, "rj_")))
+
+	c.AssertRejoin("rj_0", 2, 4, 1*time.Minute)
+
+	// a node restarted right away rejoins as well
+	c.StopNode("rj_1")
+	c.AssertRejoin("rj_1", 2, 4, 1*time.Minute)
+}
@@ -0,0 +1,77 @@
+package e2e
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayPlayer_Speed(t *testing.T) {
+	start := time.Now()
+	records := []*replayRecord{}
+	for i := 0; i < 5; i++ {
+		records = append(records, &replayRecord{Seq: uint64(i), Node: "A", Time: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	cases := []struct {
+		speed   string
+		elapsed time.Duration
+	}{
+		{"max", 0},
+		{"recorded", 4 * time.Second},
+		{"2x", 2 * time.Second},
+		{"0.5x", 8 * time.Second},
+	}
+	for _, c := range cases {
+		speed, err := parseReplaySpeed(c.speed)
+		assert.NoError(t, err)
+
+		clock := newVirtualClock(start)
+		played := []uint64{}
+		err = newReplayPlayer(speed, clock).Play(records, func(r *replayRecord) error {
+			played = append(played, r.Seq)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []uint64{0, 1, 2, 3, 4}, played, c.speed)
+		assert.Equal(t, c.elapsed, clock.Now().Sub(start), c.speed)
+	}
+
+	_, err := parseReplaySpeed("-1x")
+	assert.Error(t, err)
+	_, err = parseReplaySpeed("fast")
+	assert.Error(t, err)
+}
+
+func TestReplayPlayer_RealClock(t *testing.T) {
+	start := time.Now()
+	records := []*replayRecord{{Time: start}, {Time: start.Add(200 * time.Millisecond)}}
+
+	// 4x compresses the 200ms of the recording
+	now := time.Now()
+	err := newReplayPlayer(replaySpeed(4), realClock{}).Play(records, func(*replayRecord) error { return nil })
+	assert.NoError(t, err)
+	elapsed := time.Since(now)
+	assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+	assert.Less(t, elapsed, 200*time.Millisecond)
+}
+
+func TestReplayPlayer_SpeedFromEnv(t *testing.T) {
+	t.Setenv("E2E_REPLAY_SPEED", "2x")
+	speed, err := replaySpeedFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, replaySpeed(2), speed)
+
+	// the takeover rejects an invalid speed before replaying anything
+	t.Setenv("E2E_REPLAY", "true")
+	recorded := newPBFTCluster(t, "replay_speed_recorded", "replay_speed", 3)
+	recorded.Start()
+	assert.NoError(t, recorded.WaitForHeight(2, 1*time.Minute))
+	recorded.Stop()
+
+	t.Setenv("E2E_REPLAY_SPEED", "fast")
+	c := newPBFTCluster(t, "replay_speed_live", "replay_speed", 3)
+	assert.Error(t, c.Takeover(filepath.Join(recorded.outputDir, replayMergedFile), 2, 0))
+}
@@ -0,0 +1,95 @@
+package e2e
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Replay_PerNode(t *testing.T) {
+	t.Setenv("E2E_REPLAY", "true")
+
+	c := newPBFTCluster(t, "replay", "replay", 3)
+	c.Start()
+	err := c.WaitForHeight(3, 1*time.Minute)
+	assert.NoError(t, err)
+	c.Stop()
+
+	total := 0
+	for _, n := range c.Nodes() {
+		records, err := readReplayFile(filepath.Join(c.outputDir, n.name+replayFileExt))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, records)
+		for _, r := range records {
+			// every file only has the activity of its own node
+			assert.Equal(t, n.name, r.Node)
+		}
+		total += len(records)
+	}
+
+	merged, err := readReplayFile(filepath.Join(c.outputDir, replayMergedFile))
+	assert.NoError(t, err)
+	assert.Len(t, merged, total)
+	for i := 1; i < len(merged); i++ {
+		assert.False(t, merged[i].Time.Before(merged[i-1].Time))
+	}
+}
+
+func TestReplay_Integrity(t *testing.T) {
+	dir := t.TempDir()
+	replay, err := newReplayNotifier(dir, "A")
+	assert.NoError(t, err)
+	for i := uint64(1); i <= 3; i++ {
+		replay.RecordStateTransition(i, pbft.AcceptState, pbft.ValidateState, &pbft.View{Sequence: i})
+		replay.RecordMessage(i, &pbft.MessageReq{Type: pbft.MessageReq_Commit, From: "B", Hash: []byte{byte(i)}, Seal: []byte{0x1}, View: &pbft.View{Sequence: i}})
+	}
+	assert.NoError(t, replay.Close())
+
+	path := filepath.Join(dir, "A"+replayFileExt)
+	records, err := readReplayFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, records, 6)
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	assert.Len(t, lines, 7)
+
+	load := func(content string) error {
+		corrupted := filepath.Join(t.TempDir(), "A"+replayFileExt)
+		assert.NoError(t, ioutil.WriteFile(corrupted, []byte(content), 0644))
+		_, err := readReplayFile(corrupted)
+		return err
+	}
+
+	// truncated, the digest is missing
+	err = load(strings.Join(lines[:4], ""))
+	assert.True(t, errors.Is(err, errFlowTruncated))
+
+	// edited record
+	err = load(strings.Replace(string(data), `"From":"B"`, `"From":"C"`, 1))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2: checksum mismatch")
+
+	// a removed record keeps the other checksums but not the digest
+	err = load(strings.Join(append(append([]string{}, lines[:2]...), lines[3:]...), ""))
+	assert.True(t, errors.Is(err, errFlowDigest))
+
+	// records after the digest
+	err = load(string(data) + lines[0])
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "records after the digest")
+
+	// the merged file has its own digest
+	merged, err := mergeReplayFiles(dir)
+	assert.NoError(t, err)
+	records, err = readReplayFile(merged)
+	assert.NoError(t, err)
+	assert.Len(t, records, 6)
+}
@@ -0,0 +1,145 @@
+package e2e
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Rotation(t *testing.T) {
+	const epochSize = 3
+
+	pool := func(prefix string, count int) []string {
+		names := []string{}
+		for i := 0; i < count; i++ {
+			names = append(names, fmt.Sprintf("%s_%d", prefix, i))
+		}
+		return names
+	}
+
+	cases := []struct {
+		name   string
+		nodes  int
+		epochs func(pool []string) [][]string
+	}{
+		{
+			name:  "SwapOnePerEpoch",
+			nodes: 6,
+			epochs: func(pool []string) [][]string {
+				return swapOnePerEpoch(pool, 4, 3)
+			},
+		},
+		{
+			name:  "SwapQuorum",
+			nodes: 7,
+			epochs: func(pool []string) [][]string {
+				return swapQuorum(pool, 4)
+			},
+		},
+		{
+			name:   "Halve",
+			nodes:  8,
+			epochs: halve,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			prefix := strings.ToLower(tc.name)
+			epochs := tc.epochs(pool(prefix, tc.nodes))
+
+			c := newPBFTCluster(t, "rotation_"+prefix, prefix, tc.nodes, newRandomTransport(50*time.Millisecond))
+			c.Rotate(epochSize, epochs...)
+			c.Start()
+			defer c.Stop()
+
+			// the consensus goes on through every change of the validator set
+			last := uint64(len(epochs)*epochSize + 2)
+			err := c.WaitForHeight(last, 1*time.Minute)
+			assert.NoError(t, err)
+
+			// the validators of both epochs see the last change of the validator set
+			current := epochs[len(epochs)-1]
+			assertSetChanged(t, c, epochs[len(epochs)-2], current, uint64(len(epochs)-1)*epochSize+1)
+
+			// the validators removed by the last change are rejected by the active ones
+			for _, removed := range epochs[len(epochs)-2] {
+				if c.isValidatorAt(removed, last+1) {
+					continue
+				}
+				for _, name := range current {
+					assertRejected(t, c.nodes[name], removed)
+				}
+			}
+		})
+	}
+}
+
+// assertSetChanged checks that a node in both epochs saw the change of the validator set
+// from prev to next, starting at the height
+func assertSetChanged(t *testing.T, c *cluster, prev, next []string, height uint64) {
+	t.Helper()
+
+	members := func(names []string) map[string]bool {
+		set := map[string]bool{}
+		for _, name := range names {
+			set[name] = true
+		}
+		return set
+	}
+	prevSet, nextSet := members(prev), members(next)
+
+	var added, removed []pbft.NodeID
+	for _, name := range next {
+		if !prevSet[name] {
+			added = append(added, pbft.NodeID(name))
+		}
+	}
+	for _, name := range prev {
+		if !nextSet[name] {
+			removed = append(removed, pbft.NodeID(name))
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i] < added[j] })
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+
+	for _, name := range next {
+		if !prevSet[name] {
+			continue
+		}
+		for _, e := range c.nodes[name].setChanges.list() {
+			if e.Sequence >= height && assert.Equal(t, added, e.Added) && assert.Equal(t, removed, e.Removed) {
+				assert.Equal(t, pbft.QuorumSize(len(next)), e.Quorum)
+				return
+			}
+		}
+		t.Errorf("%s did not see the change of the validator set at height %d", name, height)
+		return
+	}
+}
+
+// assertRejected checks that the node rejects the prepare messages of the sender in its current view
+func assertRejected(t *testing.T, n *node, sender string) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		view := n.pbft.RoundState().View
+		if view == nil {
+			return false
+		}
+		msg := &pbft.MessageReq{
+			Type: pbft.MessageReq_Prepare,
+			From: pbft.NodeID(sender),
+			Hash: []byte{0x1},
+			View: &pbft.View{Sequence: view.Sequence, Round: view.Round + 1, Term: view.Term},
+		}
+		err := n.pbft.Preflight(msg)
+		return err != nil && strings.Contains(err.Error(), pbft.DiscardNotValidator.String())
+	}, 5*time.Second, 50*time.Millisecond, "%s accepts the messages of %s", n.name, sender)
+}
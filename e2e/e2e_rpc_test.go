@@ -0,0 +1,37 @@
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_RPC_Status(t *testing.T) {
+	c := newPBFTCluster(t, "rpc", "rpc", 4)
+	c.Start()
+	defer c.Stop()
+
+	addrs, err := c.ServeRPC()
+	assert.NoError(t, err)
+	assert.Len(t, addrs, 4)
+
+	err = c.WaitForHeight(3, 1*time.Minute)
+	assert.NoError(t, err)
+
+	for name, addr := range addrs {
+		resp, err := http.Get("http://" + addr + "/status")
+		assert.NoError(t, err)
+
+		status := &nodeStatus{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(status))
+		resp.Body.Close()
+
+		assert.Equal(t, name, status.Name)
+		assert.True(t, status.Running)
+		assert.GreaterOrEqual(t, status.Height, uint64(3))
+		assert.NotNil(t, status.View)
+	}
+}
@@ -0,0 +1,32 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Rules_String(t *testing.T) {
+	assert.Equal(t, "drop Commit where round<=1", dropRule(pbft.MessageReq_Commit).UpToRound(1).String())
+	assert.Equal(t, "delay RoundChange from [A_2] by 2s where round=3",
+		delayRule(2*time.Second, pbft.MessageReq_RoundChange).From("A_2").InRound(3).String())
+}
+
+func TestE2E_Rules_DropCommit(t *testing.T) {
+	dropCommit := dropRule(pbft.MessageReq_Commit).UpToRound(0)
+	hook := newRuleTransport(
+		dropCommit,
+		delayRule(10*time.Millisecond, pbft.MessageReq_Prepare).From("rules_1"),
+	)
+	c := newPBFTCluster(t, "rules", "rules", 5, hook)
+	c.Start()
+	defer c.Stop()
+
+	// commits are never delivered in round 0, every height is committed in a later round
+	err := c.WaitForHeight(3, 2*time.Minute)
+	assert.NoError(t, err)
+	assert.NotZero(t, hook.Hits(dropCommit))
+	t.Log(hook)
+}
@@ -0,0 +1,44 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Scenario_DropType(t *testing.T) {
+	c := newPBFTCluster(t, "scenario", "scenario", 4)
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(3, 1*time.Minute)
+	assert.NoError(t, err)
+
+	// without commits no height can be finalized
+	c.Scenario().DropType(pbft.MessageReq_Commit)
+	c.IsStuck(5 * time.Second)
+
+	c.Scenario().Heal()
+	err = c.WaitForHeight(6, 1*time.Minute)
+	assert.NoError(t, err)
+}
+
+func TestE2E_Scenario_SnapshotOnTimeout(t *testing.T) {
+	c := newPBFTCluster(t, "snapshot", "snapshot", 4)
+	c.Scenario().Partition([]string{"snapshot_0", "snapshot_1"}, []string{"snapshot_2", "snapshot_3"})
+	c.Start()
+	defer c.Stop()
+
+	// no partition has the quorum
+	err := c.WaitForHeight(2, 5*time.Second)
+	assert.Error(t, err)
+
+	s := c.snapshot("test")
+	assert.Len(t, s.Nodes, 4)
+	for _, n := range s.Nodes {
+		assert.Equal(t, uint64(0), n.Height)
+	}
+	assert.Contains(t, s.Hooks[0], "partitions=")
+}
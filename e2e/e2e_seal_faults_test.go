@@ -0,0 +1,33 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_SealFaults(t *testing.T) {
+	c := newPBFTCluster(t, "seal_faults", "seal", 7)
+
+	// every node rejects the seals of seal_6 and seal_0 rejects the seals of seal_5 too,
+	// the quorum is still achievable with the rest of the seals
+	for _, n := range c.Nodes() {
+		n.rejectSealsFrom("seal_6")
+	}
+	c.nodes["seal_0"].rejectSeals(func(from pbft.NodeID, seal []byte) bool {
+		return from == "seal_5"
+	})
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(5, 1*time.Minute)
+	assert.NoError(t, err)
+
+	for _, n := range c.Nodes() {
+		if n.name != "seal_6" {
+			assert.NotZero(t, n.rejectedSeals(), n.name)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package e2e
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Soak(t *testing.T) {
+	duration, err := time.ParseDuration(os.Getenv("E2E_SOAK"))
+	if err != nil {
+		t.Skip("Soak test is disabled, set E2E_SOAK to the duration of the run.")
+	}
+
+	c := newPBFTCluster(t, "soak", "soak", 5, newRandomTransport(50*time.Millisecond))
+	c.Start()
+	defer c.Stop()
+
+	// let the cluster warm up before the first checkpoint
+	err = c.WaitForHeight(3, 1*time.Minute)
+	assert.NoError(t, err)
+
+	err = c.runSoak(&soakConfig{
+		Duration:           duration,
+		CheckpointInterval: 10 * time.Second,
+		MaxStall:           1 * time.Minute,
+		MaxGoroutineGrowth: 200,
+		MaxHeapGrowth:      64 * 1024 * 1024,
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,42 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Starvation(t *testing.T) {
+	c := newPBFTCluster(t, "starvation", "starve", 5, newRandomTransport(50*time.Millisecond))
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(2, 1*time.Minute)
+	assert.NoError(t, err)
+
+	// no node lags behind while the network is healthy
+	d := c.WatchStarvation(2, 2*time.Second)
+	err = c.WaitForHeight(6, 1*time.Minute)
+	assert.NoError(t, err)
+	d.AssertNoStarvation()
+
+	// starve_0 is cut off (it cannot sync either) while the rest keeps the quorum
+	d = c.WatchStarvation(2, 2*time.Second)
+	c.Scenario().Partition([]string{"starve_0"}, []string{"starve_1", "starve_2", "starve_3", "starve_4"})
+	err = c.WaitForHeight(12, 1*time.Minute, []string{"starve_1", "starve_2", "starve_3", "starve_4"})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return len(d.Starved()) != 0
+	}, 10*time.Second, 100*time.Millisecond)
+
+	starved := d.Starved()
+	assert.Len(t, starved, 1)
+	assert.Equal(t, "starve_0", starved[0].Node)
+	assert.Error(t, d.Stop())
+
+	// once healed, the node catches up
+	c.Scenario().Heal()
+	err = c.WaitForHeight(14, 1*time.Minute)
+	assert.NoError(t, err)
+}
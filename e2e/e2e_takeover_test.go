@@ -0,0 +1,35 @@
+package e2e
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Replay_Takeover(t *testing.T) {
+	t.Setenv("E2E_REPLAY", "true")
+
+	recorded := newPBFTCluster(t, "takeover_recorded", "takeover", 4)
+	recorded.Start()
+	err := recorded.WaitForHeight(4, 1*time.Minute)
+	assert.NoError(t, err)
+	recorded.Stop()
+
+	// what if the run had continued from the first round of the third height
+	c := newPBFTCluster(t, "takeover_live", "takeover", 4)
+	err = c.Takeover(filepath.Join(recorded.outputDir, replayMergedFile), 3, 0)
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, recorded.sealedProposals[i].Proposal.Hash, c.sealedProposals[i].Proposal.Hash)
+		assert.Equal(t, recorded.sealedProposals[i].Proposer, c.sealedProposals[i].Proposer)
+	}
+	assert.Len(t, c.sealedProposals, 2)
+
+	c.Start()
+	defer c.Stop()
+
+	err = c.WaitForHeight(6, 1*time.Minute)
+	assert.NoError(t, err)
+}
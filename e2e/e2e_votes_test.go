@@ -0,0 +1,68 @@
+package e2e
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_VotesAt(t *testing.T) {
+	// commits are lost in round 0, every node locks the proposal without finalizing it
+	hook := newRuleTransport(dropRule(pbft.MessageReq_Commit).InRound(0))
+	c := newPBFTCluster(t, "votes", "votes", 4, hook)
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(1, 1*time.Minute)
+	assert.NoError(t, err)
+
+	all := []string{"votes_0", "votes_1", "votes_2", "votes_3"}
+
+	votes := c.VotesAt(1, 0)
+	assert.Len(t, votes.Proposals, 1)
+	for _, digest := range votes.Proposals {
+		raw, err := hex.DecodeString(digest)
+		assert.NoError(t, err)
+		assert.Equal(t, all, votes.Prepares[digest])
+		assert.Equal(t, all, votes.Locked(raw))
+	}
+
+	// everyone moved to round 1 and re-proposed the locked proposal
+	locked := votes.Proposals
+	votes = c.VotesAt(1, 1)
+	assert.Equal(t, all, votes.RoundChanges)
+	assert.Len(t, votes.Commits, 1)
+
+	// the locks of round 0 are still held in round 1
+	for _, digest := range locked {
+		raw, _ := hex.DecodeString(digest)
+		assert.Equal(t, all, votes.Locked(raw))
+	}
+}
+
+func TestVoteLog_LockAt(t *testing.T) {
+	v := newVoteLog()
+	vote := func(typ pbft.MsgType, round uint64, digest byte) {
+		v.add(&pbft.MessageReq{Type: typ, View: &pbft.View{Sequence: 1, Round: round}, Hash: []byte{digest}})
+	}
+	vote(pbft.MessageReq_Prepare, 0, 1)
+	_, ok := v.lockAt(1, 0)
+	assert.False(t, ok)
+
+	// the commit of round 0 locks the node in the next rounds, even without a vote in them
+	vote(pbft.MessageReq_Commit, 0, 1)
+	vote(pbft.MessageReq_RoundChange, 1, 0)
+	locked, ok := v.lockAt(1, 2)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{1}, locked)
+
+	// the lock is released once the node prepares another digest
+	vote(pbft.MessageReq_Prepare, 3, 2)
+	_, ok = v.lockAt(1, 3)
+	assert.False(t, ok)
+	locked, _ = v.lockAt(1, 2)
+	assert.Equal(t, []byte{1}, locked)
+}
@@ -0,0 +1,107 @@
+package e2e
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// proposerEquivocation is a byzantine proposer: in the first round of the height it sends its
+// proposal to half of the validators and a conflicting proposal to the other half, and it
+// votes (prepare and commit) for both proposals to every validator
+type proposerEquivocation struct {
+	c      *cluster
+	height uint64
+
+	lock     sync.Mutex
+	proposer pbft.NodeID
+	split    map[pbft.NodeID]bool
+	forged   *pbft.Proposal
+}
+
+// EquivocateProposer makes the proposer of the first round of the height equivocate (see
+// proposerEquivocation) and returns the function that returns the proposer once it proposed,
+// empty before. The conflicting proposal is only sent to the validators after the first half
+// sorted by name, excluding the proposer. It must be called before Start
+func (c *cluster) EquivocateProposer(height uint64) func() pbft.NodeID {
+	hook := &proposerEquivocation{c: c, height: height}
+	c.transport.addHook(hook)
+	return hook.getProposer
+}
+
+func (p *proposerEquivocation) Connects(from, to pbft.NodeID) bool {
+	return true
+}
+
+func (p *proposerEquivocation) Gossip(from, to pbft.NodeID, msg *pbft.MessageReq) bool {
+	if msg.View == nil || msg.View.Sequence != p.height || msg.View.Round != 0 || from == to {
+		return true
+	}
+	if from != p.c.calcProposer(pbft.ViewMsg(p.height, 0)) {
+		return true
+	}
+	switch msg.Type {
+	case pbft.MessageReq_Preprepare:
+		forged := p.forge(from, msg)
+		if !p.inSplit(to) {
+			return true
+		}
+		preprepare := msg.Copy()
+		preprepare.Proposal = forged.Data
+		preprepare.Hash = forged.Hash
+		_ = p.c.InjectMessage(string(from), string(to), preprepare)
+		return false
+
+	case pbft.MessageReq_Prepare, pbft.MessageReq_Commit:
+		forged := p.forge(from, msg)
+		vote := msg.Copy()
+		vote.Hash = forged.Hash
+		if msg.Type == pbft.MessageReq_Commit {
+			vote.Seal, _ = key(from).Sign(forged.Hash)
+		}
+		_ = p.c.InjectMessage(string(from), string(to), vote)
+	}
+	return true
+}
+
+// forge returns the conflicting proposal, built out of the first message of the proposer
+func (p *proposerEquivocation) forge(proposer pbft.NodeID, msg *pbft.MessageReq) *pbft.Proposal {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.forged != nil {
+		return p.forged
+	}
+	p.proposer = proposer
+	data := append([]byte("equivocation "), msg.Hash...)
+	p.forged = &pbft.Proposal{Data: data, Hash: hash(data)}
+
+	others := []string{}
+	for _, name := range p.c.validatorsAt(p.height, p.c.resolveNodes()) {
+		if pbft.NodeID(name) != proposer {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	p.split = map[pbft.NodeID]bool{}
+	for _, name := range others[len(others)/2:] {
+		p.split[pbft.NodeID(name)] = true
+	}
+	return p.forged
+}
+
+// inSplit returns true if the node receives the conflicting proposal
+func (p *proposerEquivocation) inSplit(id pbft.NodeID) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.split[id]
+}
+
+func (p *proposerEquivocation) getProposer() pbft.NodeID {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.proposer
+}
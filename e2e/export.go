@@ -0,0 +1,38 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// The regression packages under e2e/ build their scenarios with the rules and the cluster of this package
+
+// MsgRule is a declarative rule applied to the gossiped messages (see msgRule)
+type MsgRule = msgRule
+
+// DropRule drops the messages of the given types (or every message if none is set)
+func DropRule(types ...pbft.MsgType) *MsgRule {
+	return dropRule(types...)
+}
+
+// DelayRule delays the messages of the given types (or every message if none is set)
+func DelayRule(delay time.Duration, types ...pbft.MsgType) *MsgRule {
+	return delayRule(delay, types...)
+}
+
+// RunRules runs a cluster of count nodes (<prefix>_0 ... <prefix>_<count-1>) whose network is
+// shaped by the rules until it reaches the height. The nodes use the ledger, so that the proposals
+// of different proposers differ. It returns the error of WaitForHeight
+func RunRules(t *testing.T, name, prefix string, count int, height uint64, timeout time.Duration, rules ...*MsgRule) error {
+	hook := newRuleTransport(rules...)
+	c := newPBFTCluster(t, name, prefix, count, hook)
+	c.UseLedger()
+	c.Start()
+	defer c.Stop()
+
+	err := c.WaitForHeight(height, timeout)
+	t.Log(hook)
+	return err
+}
@@ -1,13 +1,17 @@
 package e2e
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
+	"math"
+	"net/http"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -23,6 +27,9 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// statusInterval is the interval of the status announcements used by the nodes to detect they are lagging
+const statusInterval = 500 * time.Millisecond
+
 func initTracer(name string) *sdktrace.TracerProvider {
 	ctx := context.Background()
 
@@ -66,7 +73,21 @@ type cluster struct {
 	nodes           map[string]*node
 	tracer          *sdktrace.TracerProvider
 	hook            transportHook
+	transport       *transport
+	scenario        *ScenarioController
 	sealedProposals []*pbft.SealedProposal
+
+	// outputDir is the directory with the artifacts of the cluster (logs, replay files and snapshots)
+	outputDir string
+
+	// baseGoroutines is the number of goroutines before the cluster was created
+	baseGoroutines int
+
+	// ledger is set when the nodes use hash-chained proposals (see UseLedger)
+	ledger uint32
+
+	// rotation is the validator set of every epoch, if it changes (see Rotate)
+	rotation *validatorRotation
 }
 
 func newPBFTCluster(t *testing.T, name, prefix string, count int, hook ...transportHook) *cluster {
@@ -75,21 +96,49 @@ func newPBFTCluster(t *testing.T, name, prefix string, count int, hook ...transp
 		names[i] = fmt.Sprintf("%s_%d", prefix, i)
 	}
 
-	tt := &transport{}
-	if len(hook) == 1 {
-		tt.addHook(hook[0])
+	baseGoroutines := runtime.NumGoroutine()
+	scenario := newScenarioController()
+
+	tt := &transport{complexity: newMsgComplexity()}
+	trackTransport(tt)
+	tt.addHook(scenario)
+	for _, h := range hook {
+		tt.addHook(h)
 	}
 
 	c := &cluster{
 		t:               t,
 		nodes:           map[string]*node{},
 		tracer:          initTracer("fuzzy_" + name),
-		hook:            tt.hook,
+		hook:            &tt.hooks,
+		transport:       tt,
+		scenario:        scenario,
 		sealedProposals: []*pbft.SealedProposal{},
+		outputDir:       newOutputDir(t, name),
+		baseGoroutines:  baseGoroutines,
+	}
+	if isMsgTraceEnabled() {
+		trace, err := newMsgTrace(c.outputDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.trace = trace
 	}
 	for _, name := range names {
 		trace := c.tracer.Tracer(name)
-		n, _ := newPBFTNode(name, names, trace, tt)
+
+		logFile, err := os.Create(filepath.Join(c.outputDir, name+".log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var replay *replayNotifier
+		if isReplayEnabled() {
+			// every node records its own activity to avoid sharing a single notifier
+			if replay, err = newReplayNotifier(c.outputDir, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		n, _ := newPBFTNode(name, names, trace, tt, logFile, replay)
 		n.c = c
 		c.nodes[name] = n
 	}
@@ -192,6 +241,7 @@ func (c *cluster) WaitForHeight(num uint64, timeout time.Duration, nodes ...[]st
 				return nil
 			}
 		case <-timer.C:
+			c.dumpSnapshot(fmt.Sprintf("timeout waiting for height %d", num))
 			return fmt.Errorf("timeout")
 		}
 	}
@@ -214,12 +264,10 @@ func (c *cluster) syncWithNetwork(nodeID string) (uint64, int64) {
 		if n.name == nodeID {
 			continue
 		}
-		if c.hook != nil {
-			// we need to see if this transport does allow those two nodes to be connected
-			// Otherwise, that node should not be eligible to sync
-			if !c.hook.Connects(pbft.NodeID(nodeID), pbft.NodeID(n.name)) {
-				continue
-			}
+		// we need to see if this transport does allow those two nodes to be connected
+		// Otherwise, that node should not be eligible to sync
+		if !c.hook.Connects(pbft.NodeID(nodeID), pbft.NodeID(n.name)) {
+			continue
 		}
 		localHeight := n.getNodeHeight()
 		if localHeight > height {
@@ -261,6 +309,11 @@ func (c *cluster) Nodes() []*node {
 	return list
 }
 
+// Scenario returns the controller of the network conditions of the cluster
+func (c *cluster) Scenario() *ScenarioController {
+	return c.scenario
+}
+
 func (c *cluster) Start() {
 	for _, n := range c.nodes {
 		n.Start()
@@ -277,11 +330,33 @@ func (c *cluster) StopNode(name string) {
 
 func (c *cluster) Stop() {
 	for _, n := range c.nodes {
-		n.Stop()
+		if n.IsRunning() || !n.isCrashed() {
+			n.Stop()
+		}
+		n.stopRPC()
+		if err := n.logFile.Close(); err != nil {
+			c.t.Logf("[ERROR] failed to close log file of %s: %v", n.name, err)
+		}
+	}
+	if isReplayEnabled() {
+		for _, n := range c.nodes {
+			if err := n.replay.Close(); err != nil {
+				c.t.Logf("[ERROR] failed to close replay file of %s: %v", n.name, err)
+			}
+		}
+		if _, err := mergeReplayFiles(c.outputDir); err != nil {
+			c.t.Logf("[ERROR] failed to merge replay files: %v", err)
+		}
+	}
+	if c.transport.trace != nil {
+		if err := c.transport.trace.Close(); err != nil {
+			c.t.Logf("[ERROR] failed to close message trace: %v", err)
+		}
 	}
 	if err := c.tracer.Shutdown(context.Background()); err != nil {
 		panic("failed to shutdown TracerProvider")
 	}
+	c.checkGoroutineLeaks()
 }
 
 type node struct {
@@ -300,18 +375,57 @@ type node struct {
 
 	// indicate if the node is faulty
 	faulty uint64
+
+	// sealFaults are the commit seals rejected by the node
+	sealFaults sealFaults
+
+	// replay records the activity of the node, if enabled
+	replay *replayNotifier
+
+	// rpc is the status endpoint of the node, if enabled
+	rpc *http.Server
+
+	// logFile stores the logs of the node in the output directory of the cluster
+	logFile *os.File
+
+	// votes records the consensus messages sent by the node
+	votes *voteLog
+
+	// divergent makes the node build proposals different from the ones of the other nodes
+	// (see DuplicateIdentity)
+	divergent bool
+
+	// crashed is set when the engine stopped by itself, i.e. in the faulted state
+	crashed uint64
+
+	// setChanges records the changes of the validator set seen by the engine
+	setChanges *validatorSetChanges
+
+	// head is the last sealed proposal of the node, the parent of its next ledger block
+	head chainHead
 }
 
-func newPBFTNode(name string, nodes []string, trace trace.Tracer, tt *transport) (*node, error) {
+func newPBFTNode(name string, nodes []string, trace trace.Tracer, tt *transport, logFile *os.File, replay *replayNotifier) (*node, error) {
 	var loggerOutput io.Writer
 	if os.Getenv("SILENT") == "true" {
-		loggerOutput = ioutil.Discard
+		loggerOutput = logFile
 	} else {
-		loggerOutput = os.Stdout
+		loggerOutput = io.MultiWriter(os.Stdout, logFile)
 	}
 
 	kk := key(name)
-	con := pbft.New(kk, tt, pbft.WithTracer(trace), pbft.WithLogger(log.New(loggerOutput, "", log.LstdFlags)))
+	setChanges := newValidatorSetChanges()
+	opts := []pbft.ConfigOption{
+		pbft.WithTracer(trace),
+		pbft.WithLogger(log.New(loggerOutput, "", log.LstdFlags)),
+		pbft.WithStatusInterval(statusInterval),
+		pbft.WithEventHandler(setChanges.handle),
+	}
+	if replay != nil {
+		opts = append(opts, pbft.WithRecordSink(replay))
+	}
+	votes := newVoteLog()
+	con := pbft.New(kk, &votingTransport{transport: tt, votes: votes}, opts...)
 
 	tt.Register(pbft.NodeID(name), func(msg *pbft.MessageReq) {
 		// pipe messages from mock transport to pbft
@@ -323,6 +437,11 @@ func newPBFTNode(name string, nodes []string, trace trace.Tracer, tt *transport)
 		name:    name,
 		pbft:    con,
 		running: 0,
+		replay:  replay,
+		logFile: logFile,
+		votes:   votes,
+
+		setChanges: setChanges,
 		// set to init index -1 so that zero value is not the same as first index
 		localSyncIndex: -1,
 	}
@@ -338,16 +457,17 @@ func (n *node) setSyncIndex(idx int64) {
 }
 
 func (n *node) isStuck(num uint64) (uint64, bool) {
-	// get max height in the network
-	height, _ := n.c.syncWithNetwork(n.name)
+	// get max height announced by the peers
+	_, height, ok := n.pbft.SyncHint()
 
-	if height > num {
+	if ok && height > num {
 		return height, true
 	}
 	return 0, false
 }
 
 func (n *node) Insert(pp *pbft.SealedProposal) error {
+	n.head.set(pp.Number, pp.Proposal.Hash)
 	n.c.insertFinalProposal(pp)
 	return nil
 }
@@ -368,6 +488,56 @@ func (n *node) isFaulty() bool {
 	return atomic.LoadUint64(&n.faulty) != 0
 }
 
+// sealFaults makes ValidateCommit fail for the seals of some senders, or for the seals
+// matching a predicate, to exercise the engine with invalid commit seals
+type sealFaults struct {
+	lock     sync.Mutex
+	senders  map[pbft.NodeID]struct{}
+	match    func(from pbft.NodeID, seal []byte) bool
+	rejected uint64
+}
+
+// rejectSealsFrom makes the node reject every commit seal of the senders
+func (n *node) rejectSealsFrom(senders ...string) {
+	n.sealFaults.lock.Lock()
+	defer n.sealFaults.lock.Unlock()
+
+	if n.sealFaults.senders == nil {
+		n.sealFaults.senders = map[pbft.NodeID]struct{}{}
+	}
+	for _, sender := range senders {
+		n.sealFaults.senders[pbft.NodeID(sender)] = struct{}{}
+	}
+}
+
+// rejectSeals makes the node reject the commit seals matching the predicate
+func (n *node) rejectSeals(match func(from pbft.NodeID, seal []byte) bool) {
+	n.sealFaults.lock.Lock()
+	defer n.sealFaults.lock.Unlock()
+
+	n.sealFaults.match = match
+}
+
+// rejectedSeals returns the number of commit seals rejected by the node
+func (n *node) rejectedSeals() uint64 {
+	return atomic.LoadUint64(&n.sealFaults.rejected)
+}
+
+func (n *node) validateCommit(from pbft.NodeID, seal []byte) error {
+	n.sealFaults.lock.Lock()
+	_, rejected := n.sealFaults.senders[from]
+	if !rejected && n.sealFaults.match != nil {
+		rejected = n.sealFaults.match(from, seal)
+	}
+	n.sealFaults.lock.Unlock()
+
+	if rejected {
+		atomic.AddUint64(&n.sealFaults.rejected, 1)
+		return fmt.Errorf("invalid commit seal from %s", from)
+	}
+	return nil
+}
+
 func (n *node) Start() {
 	if n.IsRunning() {
 		panic("already started")
@@ -384,14 +554,15 @@ func (n *node) Start() {
 	SYNC:
 		_, syncIndex := n.c.syncWithNetwork(n.name)
 		n.setSyncIndex(syncIndex)
+		n.syncHead(syncIndex)
 		for {
+			// important: in this iteration of the fsm we have increased our height
+			height := n.getNodeHeight() + 1
 			fsm := &fsm{
-				n:            n,
-				nodes:        n.nodes,
-				lastProposer: n.c.getProposer(n.getSyncIndex()),
-
-				// important: in this iteration of the fsm we have increased our height
-				height:          n.getNodeHeight() + 1,
+				n:               n,
+				nodes:           n.c.validatorsAt(height, n.nodes),
+				lastProposer:    n.c.getProposer(n.getSyncIndex()),
+				height:          height,
 				validationFails: n.isFaulty(),
 			}
 			if err := n.pbft.SetBackend(fsm); err != nil {
@@ -403,6 +574,14 @@ func (n *node) Start() {
 
 			switch n.pbft.GetState() {
 			case pbft.SyncState:
+				if !n.c.isValidatorAt(n.name, height) {
+					// the nodes outside of the validator set follow the chain by syncing
+					select {
+					case <-time.After(syncFollowInterval):
+					case <-ctx.Done():
+						return
+					}
+				}
 				// we need to go back to sync
 				goto SYNC
 			case pbft.DoneState:
@@ -411,6 +590,9 @@ func (n *node) Start() {
 				n.setSyncIndex(currentSyncIndex + 1)
 			default:
 				// stopped
+				if ctx.Err() == nil {
+					atomic.StoreUint64(&n.crashed, 1)
+				}
 				return
 			}
 		}
@@ -431,6 +613,11 @@ func (n *node) IsRunning() bool {
 	return atomic.LoadUint64(&n.running) != 0
 }
 
+// isCrashed returns true if the engine of the node stopped without being stopped by the cluster
+func (n *node) isCrashed() bool {
+	return atomic.LoadUint64(&n.crashed) != 0
+}
+
 func (n *node) Restart() {
 	n.Stop()
 	n.Start()
@@ -469,6 +656,11 @@ func (f *fsm) BuildProposal() (*pbft.Proposal, error) {
 		Data: []byte{byte(f.Height())},
 		Time: time.Now().Add(1 * time.Second),
 	}
+	if f.n.c.isLedger() {
+		proposal.Data = f.buildLedgerBlock()
+	} else if f.n.divergent {
+		proposal.Data = append(proposal.Data, 0xff)
+	}
 	proposal.Hash = hash(proposal.Data)
 	return proposal, nil
 }
@@ -481,6 +673,9 @@ func (f *fsm) Validate(proposal *pbft.Proposal) error {
 	if f.validationFails {
 		return fmt.Errorf("validation error")
 	}
+	if f.n.c.isLedger() {
+		return f.validateLedgerBlock(proposal)
+	}
 	return nil
 }
 
@@ -488,6 +683,11 @@ func (f *fsm) Insert(pp *pbft.SealedProposal) error {
 	return f.n.Insert(pp)
 }
 
+// Term is the epoch of the height, the validator set changes with it (see Rotate)
+func (f *fsm) Term() uint64 {
+	return f.n.c.epochOf(f.height)
+}
+
 func (f *fsm) ValidatorSet() pbft.ValidatorSet {
 	valsAsNode := []pbft.NodeID{}
 	for _, i := range f.nodes {
@@ -510,7 +710,15 @@ func (f *fsm) Init(*pbft.RoundInfo) {
 }
 
 func (f *fsm) ValidateCommit(node pbft.NodeID, seal []byte) error {
-	return nil
+	return f.n.validateCommit(node, seal)
+}
+
+// VerifyCommitSeal checks that the seal signs the hash, the keys of the cluster sign with the data itself
+func (f *fsm) VerifyCommitSeal(node pbft.NodeID, hash, seal []byte) error {
+	if !bytes.Equal(seal, hash) {
+		return fmt.Errorf("commit seal of another hash from %s", node)
+	}
+	return f.n.validateCommit(node, seal)
 }
 
 type valString struct {
@@ -555,3 +763,55 @@ func (v *valString) Includes(id pbft.NodeID) bool {
 func (v *valString) Len() int {
 	return len(v.nodes)
 }
+
+// Validators lets the engine index the validator set
+func (v *valString) Validators() []pbft.NodeID {
+	return v.nodes
+}
+
+// proposerCounts returns the number of sealed proposals of each proposer in the heights [from, to]
+func (c *cluster) proposerCounts(from, to uint64) map[pbft.NodeID]int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	counts := map[pbft.NodeID]int{}
+	for _, p := range c.sealedProposals {
+		if p.Number >= from && p.Number <= to {
+			counts[p.Proposer]++
+		}
+	}
+	return counts
+}
+
+// AssertProposerFairness checks that over the heights [from, to] every node proposed
+// within the given tolerance (as a ratio of the expected share) of (to-from+1)/N proposals
+func (c *cluster) AssertProposerFairness(from, to uint64, tolerance float64) {
+	c.t.Helper()
+
+	counts := c.proposerCounts(from, to)
+	expected := float64(to-from+1) / float64(len(c.nodes))
+	for _, n := range c.nodes {
+		count := counts[pbft.NodeID(n.name)]
+		if math.Abs(float64(count)-expected) > expected*tolerance {
+			c.t.Fatalf("unfair proposer selection: node=%s, proposals=%d, expected=%.2f, counts=%v", n.name, count, expected, counts)
+		}
+	}
+}
+
+// calcProposer returns the proposer of the given view, based on the proposer of the previous sealed height
+func (c *cluster) calcProposer(view *pbft.View) pbft.NodeID {
+	var nodes []string
+	for _, n := range c.nodes {
+		nodes = c.validatorsAt(view.Sequence, n.nodes)
+		break
+	}
+	valsAsNode := []pbft.NodeID{}
+	for _, i := range nodes {
+		valsAsNode = append(valsAsNode, pbft.NodeID(i))
+	}
+	vv := valString{
+		nodes:        valsAsNode,
+		lastProposer: c.getProposer(int64(view.Sequence) - 2),
+	}
+	return vv.CalcProposer(view.Round)
+}
@@ -0,0 +1,35 @@
+package e2e
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzz_Nemesis(t *testing.T) {
+	isFuzzEnabled(t)
+	if isRegressionEmitEnabled() {
+		// the flow of the cluster is the base of the regression bundle
+		t.Setenv("E2E_REPLAY", "true")
+	}
+
+	c := newPBFTCluster(t, "nemesis", "nemesis", 7, newRandomTransport(100*time.Millisecond))
+	c.Start()
+
+	err := c.WaitForHeight(3, 1*time.Minute)
+	assert.NoError(t, err)
+
+	n := newNemesis(c, time.Now().UnixNano())
+	err = n.Run(2*time.Minute, 10*time.Second)
+	c.Stop()
+	n.trackOutcome(err)
+
+	if errors.Is(err, errInvariantViolation) && isRegressionEmitEnabled() {
+		path, emitErr := emitRegression(".", c, n, err)
+		assert.NoError(t, emitErr)
+		t.Logf("regression test written to %s", path)
+	}
+	assert.NoError(t, err)
+}
@@ -0,0 +1,232 @@
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+const (
+	// goldenDir is the directory with the committed golden traces
+	goldenDir = "testdata/golden"
+
+	// goldenHeights is the number of heights of a golden trace
+	goldenHeights = 3
+
+	// goldenPrefix is the prefix of the names of the nodes of the golden clusters
+	goldenPrefix = "golden"
+)
+
+// isGoldenUpdate returns whether the golden traces are recorded again instead of replayed
+func isGoldenUpdate() bool {
+	return os.Getenv("E2E_GOLDEN_UPDATE") == "true"
+}
+
+func goldenPath(nodes int) string {
+	return filepath.Join(goldenDir, fmt.Sprintf("happy_path_%d%s", nodes, replayFileExt))
+}
+
+// goldenObserver is the node whose flow is stored in the golden trace, the proposer of the first height
+func goldenObserver() string {
+	return goldenPrefix + "_0"
+}
+
+func goldenNodes(nodes int) []string {
+	names := make([]string, nodes)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s_%d", goldenPrefix, i)
+	}
+	return names
+}
+
+// isGoldenRecord returns true if the record belongs to the heights of a golden trace
+func isGoldenRecord(record *replayRecord) bool {
+	view := record.View
+	if record.Msg != nil {
+		view = record.Msg.View
+	}
+	return view != nil && view.Sequence >= 1 && view.Sequence <= goldenHeights
+}
+
+// recordGolden runs a happy path cluster and stores the flow of the observer in the golden trace.
+// The run is rejected if the observer went through a state outside of the happy path
+func recordGolden(t *testing.T, nodes int) error {
+	t.Setenv("E2E_REPLAY", "true")
+
+	c := newPBFTCluster(t, fmt.Sprintf("golden_%d", nodes), goldenPrefix, nodes)
+	c.Start()
+	err := c.WaitForHeight(goldenHeights+1, 5*time.Minute)
+	c.Stop()
+	if err != nil {
+		return err
+	}
+
+	records, err := readReplayFile(filepath.Join(c.outputDir, goldenObserver()+replayFileExt))
+	if err != nil {
+		return err
+	}
+	golden := []*replayRecord{}
+	for _, record := range records {
+		if !isGoldenRecord(record) {
+			continue
+		}
+		if record.View != nil {
+			if record.View.Round != 0 {
+				return fmt.Errorf("not a happy path run, round %d at height %d", record.View.Round, record.View.Sequence)
+			}
+			switch record.To {
+			case pbft.AcceptState.String(), pbft.ValidateState.String(), pbft.CommitState.String(), pbft.DoneState.String():
+			default:
+				return fmt.Errorf("not a happy path run, %s at height %d", record.To, record.View.Sequence)
+			}
+		}
+		golden = append(golden, record)
+	}
+
+	if err := os.MkdirAll(goldenDir, 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(goldenPath(nodes))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buf := bufio.NewWriter(file)
+	if err := writeFlow(buf, golden); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// goldenTransitions returns the state transitions of the records as comparable lines
+func goldenTransitions(records []*replayRecord) []string {
+	transitions := []string{}
+	for _, record := range records {
+		if record.View != nil {
+			transitions = append(transitions, fmt.Sprintf("%s -> %s (%d/%d)", record.From, record.To, record.View.Sequence, record.View.Round))
+		}
+	}
+	return transitions
+}
+
+// replayGolden replays the messages of the golden trace received by the observer against a new engine,
+// one height at a time, and returns the recorded and the replayed state transitions
+func replayGolden(path string, nodes int) ([]string, []string, error) {
+	records, err := readReplayFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sink := &goldenSink{}
+	engine := pbft.New(key(goldenObserver()), &goldenTransport{},
+		pbft.WithLogger(log.New(ioutil.Discard, "", 0)),
+		pbft.WithRecordSink(sink),
+	)
+
+	lastProposer := pbft.NodeID("")
+	for height := uint64(1); height <= goldenHeights; height++ {
+		backend := &goldenBackend{height: height, nodes: goldenNodes(nodes), lastProposer: lastProposer}
+		if err := engine.SetBackend(backend); err != nil {
+			return nil, nil, err
+		}
+		for _, record := range records {
+			msg := record.Msg
+			if msg == nil || msg.View.Sequence != height {
+				continue
+			}
+			if msg.Type == pbft.MessageReq_Preprepare {
+				lastProposer = msg.From
+			}
+			if msg.From != pbft.NodeID(goldenObserver()) {
+				// the messages of the observer are built again by the engine
+				engine.PushMessage(msg.Copy())
+			}
+		}
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), 10*time.Second)
+		engine.Run(ctx)
+		cancelFn()
+		if state := engine.GetState(); state != pbft.DoneState {
+			return nil, nil, fmt.Errorf("height %d not finalized, state %s", height, state)
+		}
+	}
+	return goldenTransitions(records), goldenTransitions(sink.records), nil
+}
+
+// goldenSink collects the state transitions of the replayed engine
+type goldenSink struct {
+	records []*replayRecord
+}
+
+func (g *goldenSink) RecordStateTransition(seq uint64, from, to pbft.PbftState, view *pbft.View) {
+	record := &replayRecord{Seq: seq, From: from.String(), To: to.String(), View: view}
+	if isGoldenRecord(record) {
+		g.records = append(g.records, record)
+	}
+}
+
+func (g *goldenSink) RecordMessage(seq uint64, msg *pbft.MessageReq) {
+}
+
+// goldenTransport drops the messages of the replayed engine, the other nodes are only recorded
+type goldenTransport struct{}
+
+func (goldenTransport) Gossip(msg *pbft.MessageReq) error {
+	return nil
+}
+
+// goldenBackend builds the same proposals as the fsm of the cluster
+type goldenBackend struct {
+	height       uint64
+	nodes        []string
+	lastProposer pbft.NodeID
+}
+
+func (g *goldenBackend) BuildProposal() (*pbft.Proposal, error) {
+	proposal := &pbft.Proposal{
+		Data: []byte{byte(g.height)},
+		Time: time.Now(),
+	}
+	proposal.Hash = hash(proposal.Data)
+	return proposal, nil
+}
+
+func (g *goldenBackend) Validate(proposal *pbft.Proposal) error {
+	return nil
+}
+
+func (g *goldenBackend) Insert(p *pbft.SealedProposal) error {
+	return nil
+}
+
+func (g *goldenBackend) Height() uint64 {
+	return g.height
+}
+
+func (g *goldenBackend) ValidatorSet() pbft.ValidatorSet {
+	validators := &valString{lastProposer: g.lastProposer}
+	for _, name := range g.nodes {
+		validators.nodes = append(validators.nodes, pbft.NodeID(name))
+	}
+	return validators
+}
+
+func (g *goldenBackend) Init(*pbft.RoundInfo) {
+}
+
+func (g *goldenBackend) IsStuck(num uint64) (uint64, bool) {
+	return 0, false
+}
+
+func (g *goldenBackend) ValidateCommit(from pbft.NodeID, seal []byte) error {
+	return nil
+}
@@ -52,3 +52,8 @@ func isFuzzEnabled(t *testing.T) {
 		t.Skip("Fuzz tests are disabled.")
 	}
 }
+
+// isReplayEnabled returns whether the nodes record their activity in replay files
+func isReplayEnabled() bool {
+	return os.Getenv("E2E_REPLAY") == "true"
+}
@@ -0,0 +1,94 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// StartAtHeight starts the nodes pre-loaded at the given height with a synthetic history, so that
+// the tests of high sequence numbers do not need to finalize every height first. The heights below
+// are sealed by a quorum of the validators of their epoch and the proof of the last one is imported
+// by every node with CatchUp. It must be called instead of Start
+func (c *cluster) StartAtHeight(height uint64) error {
+	if height == 0 {
+		c.Start()
+		return nil
+	}
+	var proof *pbft.FinalityProof
+	for h := uint64(1); h <= height; h++ {
+		proof = c.syntheticProof(h)
+		if h < height {
+			c.insertFinalProposal(proof.SealedProposal())
+		}
+	}
+	for _, n := range c.Nodes() {
+		if err := n.catchUp(proof); err != nil {
+			return fmt.Errorf("node %s failed to catch up at height %d: %v", n.name, height, err)
+		}
+	}
+	c.Start()
+	return nil
+}
+
+// syntheticProof returns the finality proof of the proposal of round 0 at the given height,
+// it must be called after the proposals of the previous heights are inserted
+func (c *cluster) syntheticProof(height uint64) *pbft.FinalityProof {
+	view := &pbft.View{Sequence: height}
+	proposer := c.calcProposer(view)
+
+	proposal := &pbft.Proposal{
+		Data: []byte{byte(height)},
+		Time: time.Now(),
+	}
+	if c.isLedger() {
+		data, err := json.Marshal(&ledgerBlock{
+			Number:     height,
+			ParentHash: c.parentHash(height),
+			Proposer:   proposer,
+		})
+		if err != nil {
+			panic(err)
+		}
+		proposal.Data = data
+	}
+	proposal.Hash = hash(proposal.Data)
+
+	var validators []string
+	for _, n := range c.nodes {
+		validators = c.validatorsAt(height, n.nodes)
+		break
+	}
+	proof := &pbft.FinalityProof{
+		Number:   height,
+		Proposal: proposal,
+		Proposer: proposer,
+	}
+	for _, name := range validators[:pbft.QuorumSize(len(validators))] {
+		seal, _ := key(name).Sign(proposal.Hash)
+		proof.Seals = append(proof.Seals, pbft.CommittedSeal{Signer: pbft.NodeID(name), Seal: seal})
+	}
+	return proof
+}
+
+// catchUp imports the finality proof in the engine of a stopped node and moves it to the next height
+func (n *node) catchUp(proof *pbft.FinalityProof) error {
+	fsm := &fsm{
+		n:            n,
+		nodes:        n.c.validatorsAt(proof.Number, n.nodes),
+		lastProposer: n.c.getProposer(int64(proof.Number) - 2),
+		height:       proof.Number,
+	}
+	if err := n.pbft.SetBackend(fsm); err != nil {
+		return err
+	}
+	// the node holds the synthetic history up to the previous height
+	n.syncHead(int64(proof.Number) - 2)
+	if err := n.pbft.CatchUp(proof); err != nil {
+		return err
+	}
+	n.setSyncIndex(int64(proof.Number) - 1)
+	return nil
+}
@@ -0,0 +1,21 @@
+package e2e
+
+import (
+	"fmt"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// InjectMessage delivers a crafted message to the node as if it was sent by from, so that the
+// tests can send malicious or edge-case messages (bad digest, future view, forged sender).
+// Unlike the transport hooks, which only filter or mutate the organic traffic, the message
+// bypasses the hooks (partitions, drops and latency) and is pushed before InjectMessage returns.
+// The sender does not need to be a node of the cluster
+func (c *cluster) InjectMessage(from, to string, msg *pbft.MessageReq) error {
+	msg = msg.Copy()
+	msg.From = pbft.NodeID(from)
+	if !c.transport.inject(pbft.NodeID(to), msg) {
+		return fmt.Errorf("node %s not found", to)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package e2e
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// leakCheckTimeout is how long the leak check waits for the goroutines of a stopped cluster to exit
+const leakCheckTimeout = 5 * time.Second
+
+// liveTransports is the number of transports not collected yet. Every transport
+// holds a token with a finalizer, so a transport retained after its cluster stopped is detected as a leak
+var liveTransports int64
+
+// leakToken is only referenced by its transport. The finalizer can not be set on the transport
+// itself since it is part of a cycle (transport -> handlers -> pbft -> transport) and
+// finalizers are not guaranteed to run for objects in cycles
+type leakToken struct {
+	// big enough to avoid the tiny allocator, which batches small objects
+	_ [32]byte
+}
+
+func trackTransport(tt *transport) {
+	atomic.AddInt64(&liveTransports, 1)
+	tt.leakToken = &leakToken{}
+	runtime.SetFinalizer(tt.leakToken, func(*leakToken) {
+		atomic.AddInt64(&liveTransports, -1)
+	})
+}
+
+// leakedTransports forces the collection of the unreachable transports and returns the number of live ones
+func leakedTransports() int64 {
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		if atomic.LoadInt64(&liveTransports) == 0 {
+			break
+		}
+		// finalizers run in their own goroutine
+		time.Sleep(100 * time.Millisecond)
+	}
+	return atomic.LoadInt64(&liveTransports)
+}
+
+// checkGoroutineLeaks waits for the goroutines to go back to the baseline and
+// fails the test with the dump of the goroutines if they do not
+func (c *cluster) checkGoroutineLeaks() {
+	deadline := time.Now().Add(leakCheckTimeout)
+	for {
+		current := runtime.NumGoroutine()
+		if current <= c.baseGoroutines {
+			return
+		}
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			buf = buf[:runtime.Stack(buf, true)]
+			c.t.Errorf("goroutines leaked after the cluster stopped: %d before start, %d after stop\n%s", c.baseGoroutines, current, buf)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
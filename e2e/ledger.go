@@ -0,0 +1,140 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// ledgerBlock is the proposal of the stateful fsm, it references the hash of the
+// previous sealed proposal so that forks and missed inserts break the hash chain
+type ledgerBlock struct {
+	Number     uint64
+	ParentHash []byte
+	Proposer   pbft.NodeID
+}
+
+func decodeLedgerBlock(data []byte) (*ledgerBlock, error) {
+	block := &ledgerBlock{}
+	if err := json.Unmarshal(data, block); err != nil {
+		return nil, fmt.Errorf("proposal is not a ledger block: %v", err)
+	}
+	return block, nil
+}
+
+// UseLedger makes the nodes build and validate hash-chained proposals. It must be called before Start
+func (c *cluster) UseLedger() {
+	atomic.StoreUint32(&c.ledger, 1)
+}
+
+func (c *cluster) isLedger() bool {
+	return atomic.LoadUint32(&c.ledger) == 1
+}
+
+// chainHead is the last sealed proposal of a node. Every node tracks its own head, so that a
+// missed insert breaks the hash chain of the blocks it builds and validates afterwards
+type chainHead struct {
+	lock   sync.Mutex
+	number uint64
+	hash   []byte
+}
+
+func (h *chainHead) set(number uint64, hash []byte) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.number, h.hash = number, hash
+}
+
+// parentHash returns the hash of the head if it precedes the given height, nil otherwise
+func (h *chainHead) parentHash(height uint64) []byte {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if height < 2 || h.number != height-1 {
+		return nil
+	}
+	return h.hash
+}
+
+// syncHead moves the head of the node to the sealed proposal it synced to
+func (n *node) syncHead(syncIndex int64) {
+	if syncIndex < 0 {
+		return
+	}
+	n.c.lock.Lock()
+	defer n.c.lock.Unlock()
+
+	if syncIndex < int64(len(n.c.sealedProposals)) {
+		pp := n.c.sealedProposals[syncIndex]
+		n.head.set(pp.Number, pp.Proposal.Hash)
+	}
+}
+
+// parentHash returns the hash of the sealed proposal of the cluster preceding the given height,
+// used to build the synthetic history (see StartAtHeight)
+func (c *cluster) parentHash(height uint64) []byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if height < 2 || uint64(len(c.sealedProposals)) < height-1 {
+		return nil
+	}
+	return c.sealedProposals[height-2].Proposal.Hash
+}
+
+// VerifyLedger checks the hash chain of the sealed proposals of a cluster using the ledger
+func (c *cluster) VerifyLedger() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return verifyLedger(c.sealedProposals)
+}
+
+func verifyLedger(sealedProposals []*pbft.SealedProposal) error {
+	var parent []byte
+	for i, pp := range sealedProposals {
+		block, err := decodeLedgerBlock(pp.Proposal.Data)
+		if err != nil {
+			return fmt.Errorf("height %d: %v", i+1, err)
+		}
+		if block.Number != uint64(i+1) || pp.Number != block.Number {
+			return fmt.Errorf("height %d: hash chain break, sealed proposal of height %d", i+1, block.Number)
+		}
+		if !bytes.Equal(block.ParentHash, parent) {
+			return fmt.Errorf("height %d: hash chain break, parent=%x, expected=%x", i+1, block.ParentHash, parent)
+		}
+		parent = pp.Proposal.Hash
+	}
+	return nil
+}
+
+func (f *fsm) buildLedgerBlock() []byte {
+	data, err := json.Marshal(&ledgerBlock{
+		Number:     f.height,
+		ParentHash: f.n.head.parentHash(f.height),
+		Proposer:   pbft.NodeID(f.n.name),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (f *fsm) validateLedgerBlock(proposal *pbft.Proposal) error {
+	block, err := decodeLedgerBlock(proposal.Data)
+	if err != nil {
+		return err
+	}
+	if block.Number != f.height {
+		return fmt.Errorf("ledger block of height %d, expected %d", block.Number, f.height)
+	}
+	if parent := f.n.head.parentHash(f.height); !bytes.Equal(block.ParentHash, parent) {
+		return fmt.Errorf("hash chain break: parent=%x, expected=%x", block.ParentHash, parent)
+	}
+	return nil
+}
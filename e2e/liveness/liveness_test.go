@@ -0,0 +1,84 @@
+// Package liveness is the regression suite of the liveness of the round change, built on the
+// cluster of the e2e package
+package liveness
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/0xPolygon/pbft-consensus/e2e"
+	"github.com/stretchr/testify/assert"
+)
+
+// livenessScenario is a regression case for the liveness of the round change. The rules
+// shape the network of a cluster of 4 nodes (lv_0 ... lv_3) in the first rounds and the
+// cluster must recover and keep finalizing heights
+type livenessScenario struct {
+	name  string
+	rules func() []*e2e.MsgRule
+}
+
+var livenessScenarios = []livenessScenario{
+	{
+		name: "CommitLoss",
+		rules: func() []*e2e.MsgRule {
+			// every node locks the proposal of round 0 but nobody commits
+			return []*e2e.MsgRule{e2e.DropRule(pbft.MessageReq_Commit).InRound(0)}
+		},
+	},
+	{
+		name: "SingleLock",
+		rules: func() []*e2e.MsgRule {
+			// only lv_0 gathers the prepares of round 0 and locks the proposal
+			return []*e2e.MsgRule{
+				e2e.DropRule(pbft.MessageReq_Prepare).To("lv_1", "lv_2", "lv_3").InRound(0),
+				e2e.DropRule(pbft.MessageReq_Commit).InRound(0),
+			}
+		},
+	},
+	{
+		name: "DelayedRoundChange",
+		rules: func() []*e2e.MsgRule {
+			// the round change messages of one node arrive after the others moved on
+			return []*e2e.MsgRule{
+				e2e.DropRule(pbft.MessageReq_Commit).InRound(0),
+				e2e.DelayRule(2*time.Second, pbft.MessageReq_RoundChange).From("lv_3").UpToRound(1),
+			}
+		},
+	},
+	{
+		name: "SplitLock",
+		rules: func() []*e2e.MsgRule {
+			// lv_0 locks the proposal of round 0 and lv_1 a different proposal of round 1,
+			// neither of them accepts the proposal of the other one
+			return []*e2e.MsgRule{
+				e2e.DropRule(pbft.MessageReq_Prepare).To("lv_1", "lv_2", "lv_3").InRound(0),
+				e2e.DropRule(pbft.MessageReq_Prepare).To("lv_0", "lv_2", "lv_3").InRound(1),
+				e2e.DropRule(pbft.MessageReq_Commit).UpToRound(1),
+			}
+		},
+	},
+	{
+		name: "SplitLockWithPartition",
+		rules: func() []*e2e.MsgRule {
+			// same as SplitLock but lv_3 is also isolated while lv_0 takes its lock
+			return []*e2e.MsgRule{
+				e2e.DropRule().To("lv_3").InRound(0),
+				e2e.DropRule(pbft.MessageReq_Prepare).To("lv_1", "lv_2").InRound(0),
+				e2e.DropRule(pbft.MessageReq_Prepare).To("lv_0", "lv_2").InRound(1),
+				e2e.DropRule(pbft.MessageReq_Commit).UpToRound(1),
+			}
+		},
+	},
+}
+
+func TestE2E_Liveness(t *testing.T) {
+	for _, scenario := range livenessScenarios {
+		scenario := scenario
+		t.Run(scenario.name, func(t *testing.T) {
+			err := e2e.RunRules(t, "liveness", "lv", 4, 3, 3*time.Minute, scenario.rules()...)
+			assert.NoError(t, err)
+		})
+	}
+}
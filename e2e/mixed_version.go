@@ -0,0 +1,169 @@
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+const (
+	// previousBinaryEnv is the path of the e2e test binary built from the previous version
+	previousBinaryEnv = "E2E_PREVIOUS_BINARY"
+
+	// previousRefEnv is the git ref of the previous version, its e2e test binary is built
+	// from a worktree of the repository when previousBinaryEnv is not set
+	previousRefEnv = "E2E_PREVIOUS_REF"
+)
+
+// previousVersionBinary returns the e2e test binary of the previous version of the engine.
+// It returns an empty path if no previous version is configured
+func previousVersionBinary(t *testing.T) (string, error) {
+	if binary := os.Getenv(previousBinaryEnv); binary != "" {
+		return binary, nil
+	}
+	ref := os.Getenv(previousRefEnv)
+	if ref == "" {
+		return "", nil
+	}
+	return buildPreviousVersion(t, ref)
+}
+
+// buildPreviousVersion checks out the ref in a temporary worktree and builds the e2e test
+// binary there, so that its nodes run the engine of that version
+func buildPreviousVersion(t *testing.T, ref string) (string, error) {
+	dir, err := ioutil.TempDir("", "e2e-previous")
+	if err != nil {
+		return "", err
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	worktree := filepath.Join(dir, "src")
+	if out, err := exec.Command("git", "worktree", "add", "--detach", worktree, ref).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to check out %s: %v: %s", ref, err, out)
+	}
+	defer func() {
+		if out, err := exec.Command("git", "worktree", "remove", "--force", worktree).CombinedOutput(); err != nil {
+			t.Logf("[ERROR] failed to remove the worktree %s: %v: %s", worktree, err, out)
+		}
+	}()
+
+	binary := filepath.Join(dir, "e2e.test")
+	cmd := exec.Command("go", "test", "-c", "-o", binary, ".")
+	cmd.Dir = filepath.Join(worktree, "e2e")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to build %s: %v: %s", ref, err, out)
+	}
+	return binary, nil
+}
+
+// mixedLauncher runs the nodes of the previous version with the binary of that version
+// and the rest with the test binary of the working tree
+type mixedLauncher struct {
+	localLauncher
+
+	binary   string
+	previous map[string]bool
+}
+
+func (m *mixedLauncher) command(name string, env []string) *exec.Cmd {
+	if !m.previous[name] {
+		return m.localLauncher.command(name, env)
+	}
+	cmd := exec.Command(m.binary, "-test.run=^$")
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+func (m *mixedLauncher) signal(name string, cmd *exec.Cmd, sig syscall.Signal) error {
+	return m.localLauncher.signal(name, cmd, sig)
+}
+
+// mixedVersionCluster is a process cluster where some nodes run the previous version of the engine
+type mixedVersionCluster struct {
+	*processCluster
+
+	// previous are the nodes running the previous version
+	previous []string
+
+	// current are the nodes running the working tree
+	current []string
+}
+
+// newMixedVersionCluster creates a process cluster where the first previous nodes run the
+// engine of the previous version (see previousVersionBinary). The test is skipped if no
+// previous version is configured
+func newMixedVersionCluster(t *testing.T, name, prefix string, count, previous int) *mixedVersionCluster {
+	binary, err := previousVersionBinary(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binary == "" {
+		t.Skipf("set %s or %s to run the mixed version tests", previousBinaryEnv, previousRefEnv)
+	}
+
+	launcher := &mixedLauncher{binary: binary, previous: map[string]bool{}}
+	c := &mixedVersionCluster{
+		processCluster: newProcessClusterWithLauncher(t, name, prefix, count, launcher),
+	}
+	c.useLocalAddrs()
+	for i, nodeName := range c.names {
+		if i < previous {
+			launcher.previous[nodeName] = true
+			c.previous = append(c.previous, nodeName)
+		} else {
+			c.current = append(c.current, nodeName)
+		}
+	}
+	return c
+}
+
+// AssertInteroperability checks that every node has the same chain up to the height and that
+// the proposals of both versions were sealed by the cluster
+func (c *mixedVersionCluster) AssertInteroperability(height uint64) {
+	var reference []*pbft.SealedProposal
+	for _, name := range c.names {
+		chain, err := c.getChain(name)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if uint64(len(chain)) < height {
+			c.t.Fatalf("node %s is at height %d, expected %d", name, len(chain), height)
+		}
+		chain = chain[:height]
+		if reference == nil {
+			reference = chain
+			continue
+		}
+		for i, p := range chain {
+			if !p.Proposal.Equal(reference[i].Proposal) {
+				c.t.Fatalf("node %s diverges at height %d", name, p.Number)
+			}
+		}
+	}
+
+	proposers := map[string]bool{}
+	names := []string{}
+	for _, p := range reference {
+		if !proposers[string(p.Proposer)] {
+			names = append(names, string(p.Proposer))
+		}
+		proposers[string(p.Proposer)] = true
+	}
+	for version, nodes := range map[string][]string{"previous": c.previous, "current": c.current} {
+		sealed := false
+		for _, name := range nodes {
+			sealed = sealed || proposers[name]
+		}
+		if !sealed {
+			c.t.Fatalf("no proposal of the %s version sealed, proposers: %s", version, strings.Join(sortedStrings(names), ","))
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package e2e
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// heightMessages are the messages of a height exchanged in the cluster
+type heightMessages struct {
+	// Gossiped is the number of messages gossiped by the nodes
+	Gossiped uint64
+
+	// Delivered is the number of messages delivered to the nodes
+	Delivered uint64
+
+	// Types is the number of delivered messages per type
+	Types map[pbft.MsgType]uint64
+}
+
+// Consensus returns the number of delivered messages of the consensus flow, without the status
+// and proposal request messages whose rate depends on the timers instead of the height
+func (h heightMessages) Consensus() uint64 {
+	return h.Delivered - h.Types[pbft.MessageReq_Status] - h.Types[pbft.MessageReq_ProposalRequest]
+}
+
+// msgComplexity counts the messages gossiped and delivered by the transport per height
+type msgComplexity struct {
+	lock    sync.Mutex
+	heights map[uint64]*heightMessages
+}
+
+func newMsgComplexity() *msgComplexity {
+	return &msgComplexity{heights: map[uint64]*heightMessages{}}
+}
+
+func (m *msgComplexity) get(msg *pbft.MessageReq) *heightMessages {
+	res, ok := m.heights[msg.View.Sequence]
+	if !ok {
+		res = &heightMessages{Types: map[pbft.MsgType]uint64{}}
+		m.heights[msg.View.Sequence] = res
+	}
+	return res
+}
+
+func (m *msgComplexity) gossiped(msg *pbft.MessageReq) {
+	if msg.View == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.get(msg).Gossiped++
+}
+
+func (m *msgComplexity) delivered(msg *pbft.MessageReq) {
+	if msg.View == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	res := m.get(msg)
+	res.Delivered++
+	res.Types[msg.Type]++
+}
+
+// at returns a copy of the messages of the height
+func (m *msgComplexity) at(height uint64) heightMessages {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	res := heightMessages{Types: map[pbft.MsgType]uint64{}}
+	if msgs, ok := m.heights[height]; ok {
+		res.Gossiped, res.Delivered = msgs.Gossiped, msgs.Delivered
+		for typ, num := range msgs.Types {
+			res.Types[typ] = num
+		}
+	}
+	return res
+}
+
+// MessagesAt returns the messages exchanged in the cluster for the height
+func (c *cluster) MessagesAt(height uint64) heightMessages {
+	return c.transport.complexity.at(height)
+}
+
+// AssertMessageComplexity checks that the consensus messages delivered for every height in [from, to]
+// (see heightMessages.Consensus) stay within factor*N² (N being the number of nodes), the bound of
+// the happy path where every node broadcasts a constant number of messages per height
+func (c *cluster) AssertMessageComplexity(from, to uint64, factor float64) {
+	n := float64(len(c.nodes))
+	bound := uint64(factor * n * n)
+
+	exceeded := []string{}
+	for height := from; height <= to; height++ {
+		msgs := c.MessagesAt(height)
+		if msgs.Consensus() == 0 {
+			c.t.Fatalf("no messages recorded for height %d", height)
+		}
+		if msgs.Consensus() > bound {
+			exceeded = append(exceeded, fmt.Sprintf("height=%d consensus=%d types=%s", height, msgs.Consensus(), formatTypes(msgs.Types)))
+		}
+	}
+	if len(exceeded) != 0 {
+		c.t.Fatalf("message complexity over %d messages per height: %v", bound, exceeded)
+	}
+}
+
+func formatTypes(types map[pbft.MsgType]uint64) string {
+	keys := []pbft.MsgType{}
+	for typ := range types {
+		keys = append(keys, typ)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	res := ""
+	for i, typ := range keys {
+		if i != 0 {
+			res += ","
+		}
+		res += fmt.Sprintf("%s:%d", typ, types[typ])
+	}
+	return res
+}
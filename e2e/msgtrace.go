@@ -0,0 +1,226 @@
+package e2e
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// msgTraceFile is the name of the message trace of the cluster in the output directory
+const msgTraceFile = "messages.trace"
+
+// isMsgTraceEnabled returns whether the transport records every message in a trace file
+func isMsgTraceEnabled() bool {
+	return os.Getenv("E2E_MSG_TRACE") == "true"
+}
+
+// msgTraceEntry is a message delivered or dropped by the transport. The trace has one
+// tab separated line per entry: time (unix nanos), from, to, type, height, round,
+// delivered (1 or 0) and the first bytes of the digest
+type msgTraceEntry struct {
+	Time      time.Time
+	From      pbft.NodeID
+	To        pbft.NodeID
+	Type      pbft.MsgType
+	Height    uint64
+	Round     uint64
+	Delivered bool
+	Digest    string
+}
+
+func (e *msgTraceEntry) marshal() string {
+	delivered := 0
+	if e.Delivered {
+		delivered = 1
+	}
+	return fmt.Sprintf("%d\t%s\t%s\t%d\t%d\t%d\t%d\t%s", e.Time.UnixNano(), e.From, e.To, e.Type, e.Height, e.Round, delivered, e.Digest)
+}
+
+func parseMsgTraceEntry(line string) (*msgTraceEntry, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 8 {
+		return nil, fmt.Errorf("expected 8 fields, found %d", len(fields))
+	}
+	var nums [5]uint64
+	for i, field := range []string{fields[0], fields[3], fields[4], fields[5], fields[6]} {
+		num, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = num
+	}
+	return &msgTraceEntry{
+		Time:      time.Unix(0, int64(nums[0])),
+		From:      pbft.NodeID(fields[1]),
+		To:        pbft.NodeID(fields[2]),
+		Type:      pbft.MsgType(nums[1]),
+		Height:    nums[2],
+		Round:     nums[3],
+		Delivered: nums[4] == 1,
+		Digest:    fields[7],
+	}, nil
+}
+
+// msgTrace records the messages of the transport in the trace file
+type msgTrace struct {
+	lock sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+}
+
+func newMsgTrace(dir string) (*msgTrace, error) {
+	file, err := os.Create(filepath.Join(dir, msgTraceFile))
+	if err != nil {
+		return nil, err
+	}
+	return &msgTrace{file: file, buf: bufio.NewWriter(file)}, nil
+}
+
+func (m *msgTrace) record(to pbft.NodeID, msg *pbft.MessageReq, delivered bool) {
+	digest := hex.EncodeToString(msg.Hash)
+	if len(digest) > 8 {
+		digest = digest[:8]
+	}
+	entry := &msgTraceEntry{
+		Time:      time.Now(),
+		From:      msg.From,
+		To:        to,
+		Type:      msg.Type,
+		Height:    msg.View.Sequence,
+		Round:     msg.View.Round,
+		Delivered: delivered,
+		Digest:    digest,
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.file == nil {
+		// already closed, the message was in flight when the cluster stopped
+		return
+	}
+	m.buf.WriteString(entry.marshal())
+	m.buf.WriteByte('\n')
+}
+
+func (m *msgTrace) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.file == nil {
+		return nil
+	}
+	if err := m.buf.Flush(); err != nil {
+		return err
+	}
+	err := m.file.Close()
+	m.file = nil
+	return err
+}
+
+func readMsgTrace(path string) ([]*msgTraceEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []*msgTraceEntry{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry, err := parseMsgTraceEntry(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// roundGraph is the communication graph of a round, the number of messages
+// delivered and dropped between every pair of nodes
+type roundGraph struct {
+	Height    uint64
+	Round     uint64
+	Nodes     []pbft.NodeID
+	Delivered map[pbft.NodeID]map[pbft.NodeID]int
+	Dropped   map[pbft.NodeID]map[pbft.NodeID]int
+}
+
+// communicationGraphs reconstructs the communication graph of every round of the trace
+func communicationGraphs(entries []*msgTraceEntry) []*roundGraph {
+	graphs := map[viewKey]*roundGraph{}
+	for _, entry := range entries {
+		key := viewKey{height: entry.Height, round: entry.Round}
+		graph, ok := graphs[key]
+		if !ok {
+			graph = &roundGraph{
+				Height:    entry.Height,
+				Round:     entry.Round,
+				Delivered: map[pbft.NodeID]map[pbft.NodeID]int{},
+				Dropped:   map[pbft.NodeID]map[pbft.NodeID]int{},
+			}
+			graphs[key] = graph
+		}
+		edges := graph.Dropped
+		if entry.Delivered {
+			edges = graph.Delivered
+		}
+		if edges[entry.From] == nil {
+			edges[entry.From] = map[pbft.NodeID]int{}
+		}
+		edges[entry.From][entry.To]++
+		graph.addNode(entry.From)
+		graph.addNode(entry.To)
+	}
+
+	list := make([]*roundGraph, 0, len(graphs))
+	for _, graph := range graphs {
+		sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i] < graph.Nodes[j] })
+		list = append(list, graph)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Height != list[j].Height {
+			return list[i].Height < list[j].Height
+		}
+		return list[i].Round < list[j].Round
+	})
+	return list
+}
+
+func (g *roundGraph) addNode(node pbft.NodeID) {
+	for _, n := range g.Nodes {
+		if n == node {
+			return
+		}
+	}
+	g.Nodes = append(g.Nodes, node)
+}
+
+// String renders the graph as a matrix of delivered/dropped messages, rows are the senders
+func (g *roundGraph) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "height=%d round=%d (delivered/dropped, rows are senders)\n", g.Height, g.Round)
+	b.WriteString("from\\to")
+	for _, to := range g.Nodes {
+		fmt.Fprintf(&b, "\t%s", to)
+	}
+	b.WriteByte('\n')
+	for _, from := range g.Nodes {
+		b.WriteString(string(from))
+		for _, to := range g.Nodes {
+			fmt.Fprintf(&b, "\t%d/%d", g.Delivered[from][to], g.Dropped[from][to])
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
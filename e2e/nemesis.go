@@ -0,0 +1,229 @@
+package e2e
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// errInvariantViolation is returned by the nemesis when an invariant does not hold
+var errInvariantViolation = errors.New("invariant violation")
+
+// nemesisFault is a fault the nemesis can inject in the cluster. At most f nodes are affected
+type nemesisFault interface {
+	// apply injects the fault and returns its description
+	apply(c *cluster, r *rand.Rand, f int) string
+
+	// heal removes the fault
+	heal(c *cluster)
+}
+
+// churnFault stops up to f random nodes
+type churnFault struct {
+	stopped []string
+}
+
+func (ch *churnFault) apply(c *cluster, r *rand.Rand, f int) string {
+	ch.stopped = pickNodes(c, r, 1+r.Intn(f))
+	for _, name := range ch.stopped {
+		c.StopNode(name)
+	}
+	return fmt.Sprintf("stop %v", ch.stopped)
+}
+
+func (ch *churnFault) heal(c *cluster) {
+	for _, name := range ch.stopped {
+		c.StartNode(name)
+	}
+}
+
+// partitionFault isolates a minority of up to f random nodes
+type partitionFault struct{}
+
+func (partitionFault) apply(c *cluster, r *rand.Rand, f int) string {
+	minority := pickNodes(c, r, 1+r.Intn(f))
+	majority := []string{}
+	for _, name := range c.resolveNodes() {
+		if !containsString(minority, name) {
+			majority = append(majority, name)
+		}
+	}
+	c.Scenario().Partition(minority, majority)
+	return fmt.Sprintf("partition %v from %v", minority, majority)
+}
+
+func (partitionFault) heal(c *cluster) {
+	c.Scenario().Heal()
+}
+
+// byzantineFault makes up to f random nodes fail the validation of every proposal
+type byzantineFault struct {
+	faulty []string
+}
+
+func (b *byzantineFault) apply(c *cluster, r *rand.Rand, f int) string {
+	b.faulty = pickNodes(c, r, 1+r.Intn(f))
+	for _, name := range b.faulty {
+		c.nodes[name].setFaultyNode(true)
+	}
+	return fmt.Sprintf("byzantine %v", b.faulty)
+}
+
+func (b *byzantineFault) heal(c *cluster) {
+	for _, name := range b.faulty {
+		c.nodes[name].setFaultyNode(false)
+	}
+}
+
+// invariant is a property of the cluster that must hold while the faults are injected
+type invariant func(c *cluster) error
+
+// agreementInvariant checks that the sealed proposals form a single chain and
+// that no node is ahead of it
+func agreementInvariant(c *cluster) error {
+	c.lock.Lock()
+	sealed := append(c.sealedProposals[:0:0], c.sealedProposals...)
+	c.lock.Unlock()
+
+	for indx, p := range sealed {
+		if p.Number != uint64(indx)+1 {
+			return fmt.Errorf("sealed proposal at index %d has number %d", indx, p.Number)
+		}
+	}
+	for _, n := range c.Nodes() {
+		if height := n.getNodeHeight(); height > uint64(len(sealed)) {
+			return fmt.Errorf("node %s at height %d is ahead of the chain %d", n.name, height, len(sealed))
+		}
+	}
+	return nil
+}
+
+// nemesis injects random faults (churn, partitions and byzantine nodes) in the cluster,
+// one at a time, while the invariants are checked continuously. After every fault the
+// cluster must make progress again within the recovery timeout
+type nemesis struct {
+	c          *cluster
+	seed       int64
+	rand       *rand.Rand
+	faults     []nemesisFault
+	invariants []invariant
+
+	// recoveryTimeout is the time the cluster has to make progress once a fault is healed
+	recoveryTimeout time.Duration
+
+	// stallTimeout is the recovery time above which the fault counts as a liveness stall
+	stallTimeout time.Duration
+
+	// stalls are the descriptions of the faults the cluster was slow to recover from
+	stalls []string
+
+	// schedule are the descriptions of the injected faults
+	schedule []string
+
+	// maxFaults limits the number of injected faults, zero means no limit
+	maxFaults int
+
+	// interval is the time every fault is active
+	interval time.Duration
+}
+
+func newNemesis(c *cluster, seed int64) *nemesis {
+	c.t.Logf("nemesis seed %d", seed)
+	return &nemesis{
+		c:               c,
+		seed:            seed,
+		rand:            rand.New(rand.NewSource(seed)),
+		faults:          []nemesisFault{&churnFault{}, partitionFault{}, &byzantineFault{}},
+		invariants:      []invariant{agreementInvariant, newQuorumConnectivityInvariant()},
+		recoveryTimeout: 1 * time.Minute,
+		stallTimeout:    10 * time.Second,
+	}
+}
+
+func (n *nemesis) checkInvariants() error {
+	for _, inv := range n.invariants {
+		if err := inv(n.c); err != nil {
+			return fmt.Errorf("%w: %v", errInvariantViolation, err)
+		}
+	}
+	return nil
+}
+
+// Run injects a random fault every interval until the duration elapses
+func (n *nemesis) Run(duration, interval time.Duration) error {
+	f := (len(n.c.nodes) - 1) / 3
+	if f == 0 {
+		return fmt.Errorf("the cluster does not tolerate faults")
+	}
+
+	n.interval = interval
+	end := time.Now().Add(duration)
+	for time.Now().Before(end) && (n.maxFaults == 0 || len(n.schedule) < n.maxFaults) {
+		fault := n.faults[n.rand.Intn(len(n.faults))]
+		desc := fault.apply(n.c, n.rand, f)
+		n.schedule = append(n.schedule, desc)
+		n.c.t.Logf("nemesis: %s", desc)
+
+		// check the invariants while the fault is active
+		faultEnd := time.After(interval)
+	ACTIVE:
+		for {
+			select {
+			case <-faultEnd:
+				break ACTIVE
+			case <-time.After(200 * time.Millisecond):
+				if err := n.checkInvariants(); err != nil {
+					return err
+				}
+			}
+		}
+
+		fault.heal(n.c)
+		n.c.t.Log("nemesis: healed")
+
+		height := n.c.maxHeight()
+		healed := time.Now()
+		if err := n.c.WaitForHeight(height+1, n.recoveryTimeout); err != nil {
+			return fmt.Errorf("no progress after healing at height %d: %v", height, err)
+		}
+		if recovery := time.Since(healed); recovery > n.stallTimeout {
+			n.c.t.Logf("nemesis: stall of %s after %s", recovery, desc)
+			n.stalls = append(n.stalls, desc)
+		}
+		if err := n.checkInvariants(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxHeight returns the highest height of the nodes
+func (c *cluster) maxHeight() uint64 {
+	height := uint64(0)
+	for _, n := range c.Nodes() {
+		if h := n.getNodeHeight(); h > height {
+			height = h
+		}
+	}
+	return height
+}
+
+// pickNodes returns num random nodes of the cluster
+func pickNodes(c *cluster, r *rand.Rand, num int) []string {
+	// sorted so that the picks only depend on the seed
+	names := sortedStrings(c.resolveNodes())
+	r.Shuffle(len(names), func(i, j int) {
+		names[i], names[j] = names[j], names[i]
+	})
+	return names[:num]
+}
+
+func containsString(list []string, s string) bool {
+	for _, i := range list {
+		if i == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package e2e
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fuzzOutcome is the bucket of a finished fuzz run
+type fuzzOutcome string
+
+const (
+	// outcomeClean is a run where every fault was recovered in time
+	outcomeClean fuzzOutcome = "clean"
+
+	// outcomeStallRecovered is a run where the cluster was slow to make progress after a fault
+	outcomeStallRecovered fuzzOutcome = "liveness-stall-recovered"
+
+	// outcomeStallUnrecovered is a run where the cluster did not make progress after a fault
+	outcomeStallUnrecovered fuzzOutcome = "liveness-stall-unrecovered"
+
+	// outcomeSafetyViolation is a run where an invariant did not hold
+	outcomeSafetyViolation fuzzOutcome = "safety-violation"
+
+	// outcomeNodeCrash is a run where the engine of a node stopped by itself
+	outcomeNodeCrash fuzzOutcome = "node-crash"
+)
+
+// fuzzOutcomes are the buckets from the most to the least severe
+var fuzzOutcomes = []fuzzOutcome{
+	outcomeSafetyViolation,
+	outcomeNodeCrash,
+	outcomeStallUnrecovered,
+	outcomeStallRecovered,
+	outcomeClean,
+}
+
+// classify returns the bucket of the run of the nemesis that returned the error, the most
+// severe one when several apply
+func (n *nemesis) classify(err error) fuzzOutcome {
+	if errors.Is(err, errInvariantViolation) {
+		return outcomeSafetyViolation
+	}
+	for _, node := range n.c.Nodes() {
+		if node.isCrashed() {
+			return outcomeNodeCrash
+		}
+	}
+	if err != nil {
+		return outcomeStallUnrecovered
+	}
+	if len(n.stalls) != 0 {
+		return outcomeStallRecovered
+	}
+	return outcomeClean
+}
+
+// outcomesFile returns the file tracking the outcomes of the fuzz runs, if any
+func outcomesFile() string {
+	return os.Getenv("FUZZ_OUTCOMES")
+}
+
+// outcomeRecord is the outcome of a fuzz run, stored as a json line in the outcomes file
+type outcomeRecord struct {
+	Time    time.Time
+	Test    string
+	Label   string
+	Seed    int64
+	Outcome fuzzOutcome
+	Detail  string
+}
+
+func newOutcomeRecord(n *nemesis, err error) *outcomeRecord {
+	r := &outcomeRecord{
+		Time:    time.Now(),
+		Test:    n.c.t.Name(),
+		Label:   os.Getenv("FUZZ_OUTCOMES_LABEL"),
+		Seed:    n.seed,
+		Outcome: n.classify(err),
+	}
+	if err != nil {
+		r.Detail = err.Error()
+	} else if len(n.stalls) != 0 {
+		r.Detail = "stalls: " + strings.Join(n.stalls, ", ")
+	}
+	return r
+}
+
+// appendOutcome appends the record to the outcomes file
+func appendOutcome(path string, r *outcomeRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readOutcomes(path string) ([]*outcomeRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := []*outcomeRecord{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		r := &outcomeRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// outcomeRates returns the rate of every bucket over the runs of every label, so that the
+// runs of two versions of the protocol can be compared
+func outcomeRates(records []*outcomeRecord) map[string]map[fuzzOutcome]float64 {
+	counts := map[string]map[fuzzOutcome]int{}
+	totals := map[string]int{}
+	for _, r := range records {
+		if counts[r.Label] == nil {
+			counts[r.Label] = map[fuzzOutcome]int{}
+		}
+		counts[r.Label][r.Outcome]++
+		totals[r.Label]++
+	}
+	rates := map[string]map[fuzzOutcome]float64{}
+	for label, count := range counts {
+		rates[label] = map[fuzzOutcome]float64{}
+		for _, outcome := range fuzzOutcomes {
+			rates[label][outcome] = float64(count[outcome]) / float64(totals[label])
+		}
+	}
+	return rates
+}
+
+// formatOutcomeRates returns a line with the rates of the buckets of every label
+func formatOutcomeRates(rates map[string]map[fuzzOutcome]float64) string {
+	labels := []string{}
+	for label := range rates {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var b strings.Builder
+	for _, label := range labels {
+		name := label
+		if name == "" {
+			name = "(no label)"
+		}
+		fmt.Fprintf(&b, "%s:", name)
+		for _, outcome := range fuzzOutcomes {
+			fmt.Fprintf(&b, " %s=%.2f", outcome, rates[label][outcome])
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// trackOutcome classifies the run of the nemesis and, if FUZZ_OUTCOMES is set, appends it
+// to the outcomes file and logs the rates of the buckets of all the tracked runs
+func (n *nemesis) trackOutcome(err error) {
+	r := newOutcomeRecord(n, err)
+	n.c.t.Logf("fuzz outcome: %s", r.Outcome)
+
+	path := outcomesFile()
+	if path == "" {
+		return
+	}
+	if err := appendOutcome(path, r); err != nil {
+		n.c.t.Logf("[ERROR] failed to record the fuzz outcome: %v", err)
+		return
+	}
+	records, err := readOutcomes(path)
+	if err != nil {
+		n.c.t.Logf("[ERROR] failed to read the fuzz outcomes: %v", err)
+		return
+	}
+	n.c.t.Logf("fuzz outcome rates over %d runs:\n%s", len(records), formatOutcomeRates(outcomeRates(records)))
+}
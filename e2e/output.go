@@ -0,0 +1,39 @@
+package e2e
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newOutputDir creates the directory for the artifacts (logs, replay files, snapshots) of the cluster.
+// If E2E_OUTPUT_DIR is set the artifacts are stored in <E2E_OUTPUT_DIR>/<test name>/<cluster name>
+// and kept. Otherwise a temporary directory is used, which is removed at the end of the test unless it failed
+func newOutputDir(t *testing.T, name string) string {
+	if base := os.Getenv("E2E_OUTPUT_DIR"); base != "" {
+		dir := filepath.Join(base, sanitizeTestName(t.Name()), name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	dir, err := ioutil.TempDir("", "e2e-"+sanitizeTestName(t.Name())+"-"+name+"-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("artifacts of the failed test kept in %s", dir)
+			return
+		}
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func sanitizeTestName(name string) string {
+	return strings.NewReplacer("/", "_", " ", "_").Replace(name)
+}
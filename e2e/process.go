@@ -0,0 +1,629 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+const (
+	// processNodeEnv is the name of the node run by the child process
+	processNodeEnv = "E2E_PROCESS_NODE"
+
+	// processPeersEnv is the list of nodes of the cluster as comma separated name=addr pairs
+	processPeersEnv = "E2E_PROCESS_PEERS"
+
+	// processRelayEnv is the address of the relay of the test process, the nodes reach
+	// each other through it when it is set
+	processRelayEnv = "E2E_PROCESS_RELAY"
+)
+
+// processNode is a node running in its own OS process. The nodes communicate
+// over HTTP on localhost and every node keeps its own copy of the chain
+type processNode struct {
+	name   string
+	peers  map[string]string
+	relay  string
+	pbft   *pbft.Pbft
+	client *http.Client
+
+	lock  sync.Mutex
+	chain []*pbft.SealedProposal
+}
+
+// runProcessNode runs the node described by the environment until the process is terminated.
+// It is invoked by the test binary when it is started as a child of a process cluster
+func runProcessNode() error {
+	name := os.Getenv(processNodeEnv)
+	peers, err := parsePeers(os.Getenv(processPeersEnv))
+	if err != nil {
+		return err
+	}
+	addr, ok := peers[name]
+	if !ok {
+		return fmt.Errorf("node %s is not in the peers list", name)
+	}
+
+	n := &processNode{
+		name:   name,
+		peers:  peers,
+		relay:  os.Getenv(processRelayEnv),
+		client: &http.Client{Timeout: 2 * time.Second},
+		chain:  []*pbft.SealedProposal{},
+	}
+	n.pbft = pbft.New(key(name), &httpTransport{n: n}, pbft.WithLogger(log.New(os.Stdout, "", log.LstdFlags)))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/message", n.handleMessage)
+	mux.HandleFunc("/status", n.handleStatus)
+	mux.HandleFunc("/chain", n.handleChain)
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go http.Serve(lis, mux)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-sigCh
+		cancelFn()
+	}()
+
+	n.run(ctx)
+	return nil
+}
+
+func (n *processNode) run(ctx context.Context) {
+	for {
+		n.sync()
+
+		height, lastProposer := n.head()
+		backend := &processBackend{
+			n:            n,
+			height:       height + 1,
+			lastProposer: lastProposer,
+		}
+		if err := n.pbft.SetBackend(backend); err != nil {
+			panic(err)
+		}
+		n.pbft.Run(ctx)
+
+		switch n.pbft.GetState() {
+		case pbft.SyncState, pbft.DoneState:
+			// sync (if needed) and move to the next height
+		default:
+			// stopped
+			return
+		}
+	}
+}
+
+// head returns the height of the chain and the proposer of the last block
+func (n *processNode) head() (uint64, pbft.NodeID) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if len(n.chain) == 0 {
+		return 0, pbft.NodeID("")
+	}
+	return uint64(len(n.chain)), n.chain[len(n.chain)-1].Proposer
+}
+
+func (n *processNode) insert(p *pbft.SealedProposal) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if p.Number == uint64(len(n.chain))+1 {
+		n.chain = append(n.chain, p)
+	}
+}
+
+// sync downloads the blocks the node is missing from the peers
+func (n *processNode) sync() {
+	for name := range n.peers {
+		if name == n.name {
+			continue
+		}
+		height, _ := n.head()
+		proposals := []*pbft.SealedProposal{}
+		if err := n.get(name, "/chain?from="+strconv.FormatUint(height+1, 10), &proposals); err != nil {
+			continue
+		}
+		for _, p := range proposals {
+			n.insert(p)
+		}
+	}
+}
+
+// maxPeerHeight returns the highest height of the peers
+func (n *processNode) maxPeerHeight() uint64 {
+	height := uint64(0)
+	for name := range n.peers {
+		if name == n.name {
+			continue
+		}
+		status := &processStatus{}
+		if err := n.get(name, "/status", status); err != nil {
+			continue
+		}
+		if status.Height > height {
+			height = status.Height
+		}
+	}
+	return height
+}
+
+// url returns the url of the path on the peer, through the relay of the cluster if it is set
+func (n *processNode) url(peer, path string) string {
+	if n.relay == "" {
+		return "http://" + n.peers[peer] + path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return "http://" + n.relay + path + sep + "src=" + n.name + "&dst=" + peer
+}
+
+func (n *processNode) get(peer, path string, obj interface{}) error {
+	resp, err := n.client.Get(n.url(peer, path))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer %s unreachable: status=%d", peer, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(obj)
+}
+
+func (n *processNode) handleMessage(w http.ResponseWriter, r *http.Request) {
+	msg := &pbft.MessageReq{}
+	if err := json.NewDecoder(r.Body).Decode(msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	n.pbft.PushMessage(msg)
+}
+
+// processStatus is the response of the status endpoint of a process node
+type processStatus struct {
+	Name   string
+	Height uint64
+}
+
+func (n *processNode) handleStatus(w http.ResponseWriter, r *http.Request) {
+	height, _ := n.head()
+	json.NewEncoder(w).Encode(&processStatus{Name: n.name, Height: height})
+}
+
+func (n *processNode) handleChain(w http.ResponseWriter, r *http.Request) {
+	from, err := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+	if err != nil || from == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	n.lock.Lock()
+	proposals := []*pbft.SealedProposal{}
+	if from <= uint64(len(n.chain)) {
+		proposals = append(proposals, n.chain[from-1:]...)
+	}
+	n.lock.Unlock()
+
+	json.NewEncoder(w).Encode(proposals)
+}
+
+// httpTransport gossips the messages to every node of the cluster (including itself) over HTTP
+type httpTransport struct {
+	n *processNode
+}
+
+func (h *httpTransport) Gossip(msg *pbft.MessageReq) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	for name := range h.n.peers {
+		go func(name string) {
+			resp, err := h.n.client.Post(h.n.url(name, "/message"), "application/json", bytes.NewReader(data))
+			if err != nil {
+				// the peer is down
+				return
+			}
+			resp.Body.Close()
+		}(name)
+	}
+	return nil
+}
+
+// processBackend is the backend of a process node, it behaves as the in-process fsm
+type processBackend struct {
+	n            *processNode
+	height       uint64
+	lastProposer pbft.NodeID
+}
+
+func (p *processBackend) Height() uint64 {
+	return p.height
+}
+
+func (p *processBackend) IsStuck(num uint64) (uint64, bool) {
+	if height := p.n.maxPeerHeight(); height > num {
+		return height, true
+	}
+	return 0, false
+}
+
+func (p *processBackend) BuildProposal() (*pbft.Proposal, error) {
+	proposal := &pbft.Proposal{
+		Data: []byte{byte(p.height)},
+		Time: time.Now().Add(1 * time.Second),
+	}
+	proposal.Hash = hash(proposal.Data)
+	return proposal, nil
+}
+
+func (p *processBackend) Validate(proposal *pbft.Proposal) error {
+	return nil
+}
+
+func (p *processBackend) Insert(pp *pbft.SealedProposal) error {
+	p.n.insert(pp)
+	return nil
+}
+
+func (p *processBackend) ValidatorSet() pbft.ValidatorSet {
+	names := make([]string, 0, len(p.n.peers))
+	for name := range p.n.peers {
+		names = append(names, name)
+	}
+	return &valString{
+		nodes:        toNodeIDs(sortedStrings(names)),
+		lastProposer: p.lastProposer,
+	}
+}
+
+func (p *processBackend) Init(*pbft.RoundInfo) {
+}
+
+func (p *processBackend) ValidateCommit(node pbft.NodeID, seal []byte) error {
+	return nil
+}
+
+// nodeLauncher starts and signals the processes of the nodes of a process cluster
+type nodeLauncher interface {
+	// command returns the command that runs the node with the given environment
+	command(name string, env []string) *exec.Cmd
+
+	// signal sends the signal to the running node
+	signal(name string, cmd *exec.Cmd, sig syscall.Signal) error
+}
+
+// localLauncher runs the nodes as processes of the local host
+type localLauncher struct{}
+
+func (localLauncher) command(name string, env []string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+func (localLauncher) signal(name string, cmd *exec.Cmd, sig syscall.Signal) error {
+	return cmd.Process.Signal(sig)
+}
+
+// processCluster is a cluster where every node runs in its own OS process (the test binary
+// started again in node mode), which allows true crash testing with SIGKILL
+type processCluster struct {
+	t         *testing.T
+	names     []string
+	outputDir string
+	client    *http.Client
+	launcher  nodeLauncher
+
+	// peers are the addresses the nodes use to reach each other
+	peers map[string]string
+
+	// statusAddrs are the addresses the test uses to reach the nodes
+	statusAddrs map[string]string
+
+	// scenario drives the network conditions of the traffic going through the relay
+	scenario *ScenarioController
+	relay    *http.Server
+	relayLis net.Listener
+
+	lock  sync.Mutex
+	procs map[string]*exec.Cmd
+}
+
+func newProcessCluster(t *testing.T, name, prefix string, count int) *processCluster {
+	c := newProcessClusterWithLauncher(t, name, prefix, count, localLauncher{})
+	c.useLocalAddrs()
+	c.useRelay()
+	return c
+}
+
+// useRelay routes the traffic between the nodes (messages, status and sync) through a relay
+// in the test process, where the scenario controller applies the partitions, the drop rules
+// and the latency as in the in-process clusters
+func (c *processCluster) useRelay() {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", c.handleRelay)
+
+	c.scenario = newScenarioController()
+	c.relayLis = lis
+	c.relay = &http.Server{Handler: mux}
+	go c.relay.Serve(lis)
+}
+
+// Scenario returns the controller of the network conditions of the cluster
+func (c *processCluster) Scenario() *ScenarioController {
+	if c.scenario == nil {
+		c.t.Fatal("the cluster does not relay the traffic of the nodes")
+	}
+	return c.scenario
+}
+
+// handleRelay forwards the request of the src node to the dst node if the scenario connects them
+func (c *processCluster) handleRelay(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	src, dst := pbft.NodeID(query.Get("src")), pbft.NodeID(query.Get("dst"))
+	addr, ok := c.peers[string(dst)]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !c.scenario.Connects(src, dst) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	query.Del("src")
+	query.Del("dst")
+	target := "http://" + addr + r.URL.Path
+	if len(query) != 0 {
+		target += "?" + query.Encode()
+	}
+
+	var resp *http.Response
+	var err error
+	if r.Method == http.MethodPost {
+		msg := &pbft.MessageReq{}
+		if err := json.NewDecoder(r.Body).Decode(msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !c.scenario.Gossip(src, dst, msg) {
+			// dropped
+			return
+		}
+		data, _ := json.Marshal(msg)
+		resp, err = c.client.Post(target, "application/json", bytes.NewReader(data))
+	} else {
+		resp, err = c.client.Get(target)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// useLocalAddrs assigns a free localhost address to every node
+func (c *processCluster) useLocalAddrs() {
+	for _, nodeName := range c.names {
+		addr := freeLocalAddr(c.t)
+		c.peers[nodeName] = addr
+		c.statusAddrs[nodeName] = addr
+	}
+}
+
+func newProcessClusterWithLauncher(t *testing.T, name, prefix string, count int, launcher nodeLauncher) *processCluster {
+	c := &processCluster{
+		t:           t,
+		names:       []string{},
+		outputDir:   newOutputDir(t, name),
+		client:      &http.Client{Timeout: 1 * time.Second},
+		launcher:    launcher,
+		peers:       map[string]string{},
+		statusAddrs: map[string]string{},
+		procs:       map[string]*exec.Cmd{},
+	}
+	for i := 0; i < count; i++ {
+		c.names = append(c.names, fmt.Sprintf("%s_%d", prefix, i))
+	}
+	return c
+}
+
+func (c *processCluster) Start() {
+	for _, name := range c.names {
+		c.StartNode(name)
+	}
+}
+
+// StartNode starts the process of the node, the node syncs with the peers on start
+func (c *processCluster) StartNode(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	logFile, err := os.OpenFile(filepath.Join(c.outputDir, name+".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	peers := []string{}
+	for _, n := range c.names {
+		peers = append(peers, n+"="+c.peers[n])
+	}
+
+	env := []string{processNodeEnv + "=" + name, processPeersEnv + "=" + strings.Join(peers, ",")}
+	if c.relayLis != nil {
+		env = append(env, processRelayEnv+"="+c.relayLis.Addr().String())
+	}
+	cmd := c.launcher.command(name, env)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		c.t.Fatal(err)
+	}
+	c.procs[name] = cmd
+}
+
+// KillNode kills the process of the node with SIGKILL
+func (c *processCluster) KillNode(name string) {
+	c.signalNode(name, syscall.SIGKILL)
+}
+
+// StopNode gracefully stops the process of the node
+func (c *processCluster) StopNode(name string) {
+	c.signalNode(name, syscall.SIGTERM)
+}
+
+func (c *processCluster) signalNode(name string, sig syscall.Signal) {
+	c.lock.Lock()
+	cmd, ok := c.procs[name]
+	delete(c.procs, name)
+	c.lock.Unlock()
+
+	if !ok {
+		return
+	}
+	if err := c.launcher.signal(name, cmd, sig); err != nil {
+		c.t.Logf("[ERROR] failed to signal node %s: %v", name, err)
+	}
+	// the exit status is not relevant, the process was signaled
+	_ = cmd.Wait()
+}
+
+func (c *processCluster) Stop() {
+	for _, name := range c.names {
+		c.StopNode(name)
+	}
+	if c.relay != nil {
+		_ = c.relay.Close()
+	}
+}
+
+// getHeight returns the height of the node, or false if it is not reachable
+func (c *processCluster) getHeight(name string) (uint64, bool) {
+	resp, err := c.client.Get("http://" + c.statusAddrs[name] + "/status")
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+
+	status := &processStatus{}
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return 0, false
+	}
+	return status.Height, true
+}
+
+// getChain returns the sealed proposals of the node
+func (c *processCluster) getChain(name string) ([]*pbft.SealedProposal, error) {
+	resp, err := c.client.Get("http://" + c.statusAddrs[name] + "/chain?from=1")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	proposals := []*pbft.SealedProposal{}
+	if err := json.NewDecoder(resp.Body).Decode(&proposals); err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+func (c *processCluster) WaitForHeight(num uint64, timeout time.Duration, nodes ...[]string) error {
+	queryNodes := c.names
+	if len(nodes) == 1 {
+		queryNodes = nodes[0]
+	}
+
+	enough := func() bool {
+		for _, name := range queryNodes {
+			if height, ok := c.getHeight(name); !ok || height < num {
+				return false
+			}
+		}
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if enough() {
+				return nil
+			}
+		case <-timer.C:
+			for _, name := range queryNodes {
+				height, ok := c.getHeight(name)
+				c.t.Logf("node %s: height=%d reachable=%v", name, height, ok)
+			}
+			return fmt.Errorf("timeout")
+		}
+	}
+}
+
+// freeLocalAddr returns a localhost address with a free port
+func freeLocalAddr(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	return lis.Addr().String()
+}
+
+func parsePeers(str string) (map[string]string, error) {
+	peers := map[string]string{}
+	for _, pair := range strings.Split(str, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid peer %q", pair)
+		}
+		peers[parts[0]] = parts[1]
+	}
+	return peers, nil
+}
+
+func sortedStrings(s []string) []string {
+	res := append([]string{}, s...)
+	sort.Strings(res)
+	return res
+}
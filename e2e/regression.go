@@ -0,0 +1,341 @@
+package e2e
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// regressionDir is the directory of the regression bundles, relative to the e2e package
+	regressionDir = "testdata/regressions"
+
+	// regressionFlowFile is the minimized flow of the cluster in a regression bundle
+	regressionFlowFile = "cluster.flow"
+
+	// regressionScheduleFile describes the nemesis run of a regression bundle
+	regressionScheduleFile = "schedule.json"
+
+	// regressionPrefix is the prefix of the names of the nodes of the nemesis clusters
+	regressionPrefix = "nemesis"
+
+	// regressionHeightTimeout bounds the replay of a height on the engine of a node
+	regressionHeightTimeout = 5 * time.Second
+)
+
+// isRegressionEmitEnabled returns whether the nemesis findings are turned into regression tests
+func isRegressionEmitEnabled() bool {
+	return os.Getenv("FUZZ_EMIT_REGRESSIONS") == "true"
+}
+
+// regressionSchedule is the nemesis run that violated an invariant
+type regressionSchedule struct {
+	Name     string
+	Seed     int64
+	Nodes    int
+	Interval time.Duration
+	Faults   []string
+	Error    string
+}
+
+// finalizedDigests returns the digest finalized by every node per height, derived from the
+// commit messages processed in the view in which the node reached the done state
+func finalizedDigests(records []*replayRecord) map[uint64]map[string]string {
+	type nodeView struct {
+		node     string
+		sequence uint64
+		round    uint64
+	}
+	commits := map[nodeView]map[string]int{}
+	finalized := map[uint64]map[string]string{}
+	for _, record := range records {
+		if record.Msg != nil && record.Msg.Type == pbft.MessageReq_Commit && record.Msg.View != nil {
+			key := nodeView{record.Node, record.Msg.View.Sequence, record.Msg.View.Round}
+			if commits[key] == nil {
+				commits[key] = map[string]int{}
+			}
+			commits[key][hex.EncodeToString(record.Msg.Hash)]++
+			continue
+		}
+		if record.To != pbft.DoneState.String() || record.View == nil {
+			continue
+		}
+		best, votes := "", 0
+		for digest, num := range commits[nodeView{record.Node, record.View.Sequence, record.View.Round}] {
+			if num > votes || (num == votes && digest < best) {
+				best, votes = digest, num
+			}
+		}
+		if finalized[record.View.Sequence] == nil {
+			finalized[record.View.Sequence] = map[string]string{}
+		}
+		finalized[record.View.Sequence][record.Node] = best
+	}
+	return finalized
+}
+
+// flowAgreement checks that the nodes of the flow finalized the same digest at every height.
+// It returns the first height where they disagree
+func flowAgreement(records []*replayRecord) (uint64, error) {
+	finalized := finalizedDigests(records)
+	heights := make([]uint64, 0, len(finalized))
+	for height := range finalized {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	for _, height := range heights {
+		digests := map[string][]string{}
+		for node, digest := range finalized[height] {
+			digests[digest] = append(digests[digest], node)
+		}
+		if len(digests) > 1 {
+			return height, fmt.Errorf("nodes disagree at height %d: %v", height, digests)
+		}
+	}
+	return 0, nil
+}
+
+// minimizeFlow keeps the records of the given height and the previous one
+func minimizeFlow(records []*replayRecord, height uint64) []*replayRecord {
+	from := height
+	if from > 0 {
+		from--
+	}
+	minimized := []*replayRecord{}
+	for _, record := range records {
+		view := record.View
+		if record.Msg != nil {
+			view = record.Msg.View
+		}
+		if view != nil && view.Sequence >= from && view.Sequence <= height {
+			minimized = append(minimized, record)
+		}
+	}
+	return minimized
+}
+
+var regressionTestTemplate = template.Must(template.New("regression").Parse(`// Code generated by the nemesis from a fuzz finding. DO NOT EDIT.
+
+package e2e
+
+import "testing"
+
+// TestRegression_{{.Name}} replays the flow of the finding: {{.Error}}
+func TestRegression_{{.Name}}(t *testing.T) {
+	runRegression(t, "{{.Name}}")
+}
+`))
+
+// emitRegression turns the invariant violation found by the nemesis into a regression test.
+// It writes the bundle (the minimized merged flow and the fault schedule) in testdata/regressions
+// and the test file that runs it, both in the directory of the e2e package. The replay files
+// of the cluster must be closed (see cluster.Stop)
+func emitRegression(dir string, c *cluster, n *nemesis, violation error) (string, error) {
+	records, err := readReplayFile(filepath.Join(c.outputDir, replayMergedFile))
+	if err != nil {
+		return "", err
+	}
+	if height, err := flowAgreement(records); err != nil {
+		records = minimizeFlow(records, height)
+	} else if height := c.maxHeight(); height > 0 {
+		// the violation is not visible in the flow, keep the last heights
+		records = minimizeFlow(records, height)
+	}
+
+	name := fmt.Sprintf("Nemesis_%d", n.seed)
+	if n.seed < 0 {
+		name = fmt.Sprintf("Nemesis_m%d", -n.seed)
+	}
+	bundle := filepath.Join(dir, regressionDir, name)
+	if err := os.MkdirAll(bundle, 0755); err != nil {
+		return "", err
+	}
+
+	var flow strings.Builder
+	if err := writeFlow(&flow, records); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(bundle, regressionFlowFile), []byte(flow.String()), 0644); err != nil {
+		return "", err
+	}
+
+	schedule := &regressionSchedule{
+		Name:     name,
+		Seed:     n.seed,
+		Nodes:    len(c.nodes),
+		Interval: n.interval,
+		Faults:   n.schedule,
+		Error:    strings.ReplaceAll(violation.Error(), "\n", " "),
+	}
+	data, err := json.MarshalIndent(schedule, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(bundle, regressionScheduleFile), data, 0644); err != nil {
+		return "", err
+	}
+
+	var test strings.Builder
+	if err := regressionTestTemplate.Execute(&test, schedule); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "regression_"+strings.ToLower(name)+"_test.go")
+	return path, ioutil.WriteFile(path, []byte(test.String()), 0644)
+}
+
+// runRegression loads the bundle of a fuzz finding and replays its flow against new engines
+func runRegression(t *testing.T, name string) {
+	checkRegression(t, filepath.Join(regressionDir, name))
+}
+
+// checkRegression replays the recorded flow of the bundle deterministically (see replayRegression)
+// and asserts that the replayed nodes finalize and agree at every height
+func checkRegression(t *testing.T, bundle string) {
+	data, err := ioutil.ReadFile(filepath.Join(bundle, regressionScheduleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule := &regressionSchedule{}
+	if err := json.Unmarshal(data, schedule); err != nil {
+		t.Fatal(err)
+	}
+	records, err := readReplayFile(filepath.Join(bundle, regressionFlowFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := flowAgreement(records); err != nil {
+		t.Logf("recorded finding: %v", err)
+	}
+
+	replayed, err := replayRegression(records, regressionNodes(schedule.Nodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, finalizedDigests(replayed))
+	_, err = flowAgreement(replayed)
+	assert.NoError(t, err)
+}
+
+// regressionNodes returns the names of the nodes of a nemesis cluster
+func regressionNodes(nodes int) []string {
+	names := make([]string, nodes)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s_%d", regressionPrefix, i)
+	}
+	return names
+}
+
+// flowHeights returns the lowest and the highest height of the flow
+func flowHeights(records []*replayRecord) (uint64, uint64) {
+	var from, to uint64
+	for _, record := range records {
+		view := record.View
+		if record.Msg != nil {
+			view = record.Msg.View
+		}
+		if view == nil || view.Sequence == 0 {
+			continue
+		}
+		if from == 0 || view.Sequence < from {
+			from = view.Sequence
+		}
+		if view.Sequence > to {
+			to = view.Sequence
+		}
+	}
+	return from, to
+}
+
+// flowLastProposer returns the proposer of the height preceding the given one as seen by the
+// validator set of the cluster, derived from the preprepare of the lowest recorded round
+func flowLastProposer(records []*replayRecord, nodes []string, height uint64) pbft.NodeID {
+	var preprepare *pbft.MessageReq
+	for _, record := range records {
+		msg := record.Msg
+		if msg == nil || msg.Type != pbft.MessageReq_Preprepare || msg.View == nil || msg.View.Sequence != height {
+			continue
+		}
+		if preprepare == nil || msg.View.Round < preprepare.View.Round {
+			preprepare = msg
+		}
+	}
+	if preprepare == nil {
+		return ""
+	}
+	for i, name := range nodes {
+		if pbft.NodeID(name) == preprepare.From {
+			// the proposer of the round follows the last proposer by round+1 positions
+			last := (i - int((preprepare.View.Round+1)%uint64(len(nodes))) + len(nodes)) % len(nodes)
+			return pbft.NodeID(nodes[last])
+		}
+	}
+	return ""
+}
+
+// replayRegression replays the messages received by every node of the flow against a new engine,
+// one height at a time like the golden traces, and returns the flow of the replayed engines.
+// The replay of a node stops at the first height it does not finalize
+func replayRegression(records []*replayRecord, nodes []string) ([]*replayRecord, error) {
+	from, to := flowHeights(records)
+	replayed := []*replayRecord{}
+	for _, name := range nodes {
+		sink := &regressionSink{node: name}
+		engine := pbft.New(key(name), &goldenTransport{},
+			pbft.WithLogger(log.New(ioutil.Discard, "", 0)),
+			pbft.WithRecordSink(sink),
+		)
+		for height := from; height <= to && height > 0; height++ {
+			backend := &goldenBackend{height: height, nodes: nodes, lastProposer: flowLastProposer(records, nodes, height)}
+			if err := engine.SetBackend(backend); err != nil {
+				return nil, err
+			}
+			for _, record := range records {
+				msg := record.Msg
+				if record.Node != name || msg == nil || msg.View == nil || msg.View.Sequence != height {
+					continue
+				}
+				if msg.From != pbft.NodeID(name) {
+					// the messages of the node are built again by the engine
+					engine.PushMessage(msg.Copy())
+				}
+			}
+
+			ctx, cancelFn := context.WithTimeout(context.Background(), regressionHeightTimeout)
+			engine.Run(ctx)
+			cancelFn()
+			if engine.GetState() != pbft.DoneState {
+				break
+			}
+		}
+		replayed = append(replayed, sink.records...)
+	}
+	return replayed, nil
+}
+
+// regressionSink collects the flow of a replayed engine
+type regressionSink struct {
+	node    string
+	records []*replayRecord
+}
+
+func (r *regressionSink) RecordStateTransition(seq uint64, from, to pbft.PbftState, view *pbft.View) {
+	r.records = append(r.records, &replayRecord{Node: r.node, Seq: seq, From: from.String(), To: to.String(), View: view.Copy()})
+}
+
+func (r *regressionSink) RecordMessage(seq uint64, msg *pbft.MessageReq) {
+	r.records = append(r.records, &replayRecord{Node: r.node, Seq: seq, Msg: msg})
+}
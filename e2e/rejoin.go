@@ -0,0 +1,100 @@
+package e2e
+
+import (
+	"fmt"
+	"time"
+)
+
+// rejoinPollInterval is the interval between two checks of the votes of the restarted node
+const rejoinPollInterval = 50 * time.Millisecond
+
+// rejoin is the time a restarted node needed to take part in the consensus again,
+// that is to send a commit after syncing with the cluster
+type rejoin struct {
+	Node string
+
+	// StartHeight is the highest height of the cluster when the node was restarted
+	StartHeight uint64
+
+	// Height is the first height the node committed after the restart
+	Height uint64
+
+	// Heights is the number of heights finalized without the node after the restart
+	Heights uint64
+
+	// Rounds is the number of rounds run by the cluster after the restart until the commit of the node
+	Rounds uint64
+
+	// Duration is the time from the restart to the commit of the node
+	Duration time.Duration
+}
+
+func (r *rejoin) String() string {
+	return fmt.Sprintf("node %s rejoined at height %d after %d heights and %d rounds (%s)", r.Node, r.Height, r.Heights, r.Rounds, r.Duration.Round(time.Millisecond))
+}
+
+// MeasureRejoin starts the stopped node and waits until it commits a height again
+func (c *cluster) MeasureRejoin(name string, timeout time.Duration) (*rejoin, error) {
+	n, ok := c.nodes[name]
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", name)
+	}
+	startHeight := uint64(0)
+	for _, other := range c.Nodes() {
+		if other.IsRunning() && other.currentHeight() > startHeight {
+			startHeight = other.currentHeight()
+		}
+	}
+	start := time.Now()
+	n.Start()
+
+	for time.Since(start) < timeout {
+		view, at, ok := n.votes.firstCommitSince(start)
+		if !ok {
+			time.Sleep(rejoinPollInterval)
+			continue
+		}
+		r := &rejoin{
+			Node:        name,
+			StartHeight: startHeight,
+			Height:      view.height,
+			Rounds:      view.round + 1,
+			Duration:    at.Sub(start),
+		}
+		for height := startHeight + 1; height < view.height; height++ {
+			r.Heights++
+			r.Rounds += c.roundsAt(height)
+		}
+		return r, nil
+	}
+	return nil, fmt.Errorf("node %s did not rejoin in %s", name, timeout)
+}
+
+// roundsAt returns the number of rounds the cluster ran at the height
+func (c *cluster) roundsAt(height uint64) uint64 {
+	rounds := uint64(0)
+	for _, n := range c.Nodes() {
+		if r := n.votes.rounds(height); r > rounds {
+			rounds = r
+		}
+	}
+	return rounds
+}
+
+// AssertRejoin starts the stopped node and fails the test unless it takes part in the
+// consensus again within maxHeights heights and maxRounds rounds
+func (c *cluster) AssertRejoin(name string, maxHeights, maxRounds uint64, timeout time.Duration) *rejoin {
+	r, err := c.MeasureRejoin(name, timeout)
+	if err != nil {
+		c.t.Error(err)
+		return nil
+	}
+	c.t.Log(r)
+	if r.Heights > maxHeights {
+		c.t.Errorf("node %s rejoined after %d heights, max %d", name, r.Heights, maxHeights)
+	}
+	if r.Rounds > maxRounds {
+		c.t.Errorf("node %s rejoined after %d rounds, max %d", name, r.Rounds, maxRounds)
+	}
+	return r
+}
@@ -0,0 +1,261 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	gohash "hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+const (
+	// replayFileExt is the extension of the per node replay files
+	replayFileExt = ".flow"
+
+	// replayMergedFile is the name of the file with the merged activity of every node
+	replayMergedFile = "cluster.merged"
+)
+
+// replayRecord is a single line of a replay file
+type replayRecord struct {
+	Time time.Time
+	Node string
+	Seq  uint64
+
+	// set for state transitions
+	From string     `json:",omitempty"`
+	To   string     `json:",omitempty"`
+	View *pbft.View `json:",omitempty"`
+
+	// set for processed messages
+	Msg *pbft.MessageReq `json:",omitempty"`
+
+	// Checksum is the checksum of the record without the checksum itself
+	Checksum string `json:",omitempty"`
+
+	// Digest is only set in the last line of the file, it covers the checksums of every record
+	Digest string `json:",omitempty"`
+}
+
+// flowTrailer is the last line of a replay file
+type flowTrailer struct {
+	Digest string
+}
+
+var (
+	errFlowTruncated = fmt.Errorf("replay file is truncated, the digest is missing")
+	errFlowDigest    = fmt.Errorf("replay file digest mismatch")
+)
+
+// recordChecksum returns the checksum of the record, ignoring the checksum field
+func recordChecksum(record *replayRecord) ([]byte, error) {
+	r := *record
+	r.Checksum = ""
+	data, err := json.Marshal(&r)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// flowEncoder writes the records of a replay file, each one with its own checksum,
+// followed by the digest of the whole file on Close
+type flowEncoder struct {
+	enc    *json.Encoder
+	digest gohash.Hash
+}
+
+func newFlowEncoder(w io.Writer) *flowEncoder {
+	return &flowEncoder{enc: json.NewEncoder(w), digest: sha256.New()}
+}
+
+func (f *flowEncoder) Encode(record *replayRecord) error {
+	sum, err := recordChecksum(record)
+	if err != nil {
+		return err
+	}
+	f.digest.Write(sum)
+
+	r := *record
+	r.Checksum = hex.EncodeToString(sum)
+	return f.enc.Encode(&r)
+}
+
+// Close writes the digest, a file without it is detected as truncated on load
+func (f *flowEncoder) Close() error {
+	return f.enc.Encode(&flowTrailer{Digest: hex.EncodeToString(f.digest.Sum(nil))})
+}
+
+// replayNotifier records the activity of a single node in its own file,
+// so that the run can be replayed from the perspective of that node
+type replayNotifier struct {
+	node string
+
+	lock sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	enc  *flowEncoder
+}
+
+func newReplayNotifier(dir, node string) (*replayNotifier, error) {
+	file, err := os.Create(filepath.Join(dir, node+replayFileExt))
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+	return &replayNotifier{
+		node: node,
+		file: file,
+		buf:  buf,
+		enc:  newFlowEncoder(buf),
+	}, nil
+}
+
+func (r *replayNotifier) RecordStateTransition(seq uint64, from, to pbft.PbftState, view *pbft.View) {
+	r.write(&replayRecord{Seq: seq, From: from.String(), To: to.String(), View: view})
+}
+
+func (r *replayNotifier) RecordMessage(seq uint64, msg *pbft.MessageReq) {
+	r.write(&replayRecord{Seq: seq, Msg: msg})
+}
+
+func (r *replayNotifier) write(record *replayRecord) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		// already closed
+		return
+	}
+	record.Time = time.Now()
+	record.Node = r.node
+	// the replay is best effort, a failed write must not affect the node
+	_ = r.enc.Encode(record)
+}
+
+// Close writes the digest, flushes the pending records and closes the file
+func (r *replayNotifier) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.enc.Close()
+	if ferr := r.buf.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+	return err
+}
+
+// readReplayFile decodes the records of a replay file. It verifies the checksum of every
+// record and the digest of the file, so that truncated or edited files are not replayed
+func readReplayFile(path string) ([]*replayRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return decodeFlow(bufio.NewReader(file))
+}
+
+func decodeFlow(r io.Reader) ([]*replayRecord, error) {
+	records := []*replayRecord{}
+	digest := sha256.New()
+	dec := json.NewDecoder(r)
+	for line := 1; dec.More(); line++ {
+		record := &replayRecord{}
+		if err := dec.Decode(record); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if record.Digest != "" {
+			if dec.More() {
+				return nil, fmt.Errorf("line %d: records after the digest", line)
+			}
+			if record.Digest != hex.EncodeToString(digest.Sum(nil)) {
+				return nil, errFlowDigest
+			}
+			return records, nil
+		}
+
+		sum, err := recordChecksum(record)
+		if err != nil {
+			return nil, err
+		}
+		if expected, err := hex.DecodeString(record.Checksum); err != nil || !bytes.Equal(expected, sum) {
+			return nil, fmt.Errorf("line %d: checksum mismatch of the record of %s", line, record.Node)
+		}
+		digest.Write(sum)
+
+		record.Checksum = ""
+		records = append(records, record)
+	}
+	return nil, errFlowTruncated
+}
+
+// writeFlow writes the records in the replay file format
+func writeFlow(w io.Writer, records []*replayRecord) error {
+	enc := newFlowEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// mergeReplayFiles merges the per node replay files of the directory into a single
+// file ordered by time, so that the run can be replayed from the cluster perspective
+func mergeReplayFiles(dir string) (string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+replayFileExt))
+	if err != nil {
+		return "", err
+	}
+	records := []*replayRecord{}
+	for _, path := range paths {
+		nodeRecords, err := readReplayFile(path)
+		if err != nil {
+			return "", err
+		}
+		records = append(records, nodeRecords...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].Time.Equal(records[j].Time) {
+			return records[i].Time.Before(records[j].Time)
+		}
+		if records[i].Node != records[j].Node {
+			return strings.Compare(records[i].Node, records[j].Node) < 0
+		}
+		return records[i].Seq < records[j].Seq
+	})
+
+	out := filepath.Join(dir, replayMergedFile)
+	file, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buf := bufio.NewWriter(file)
+	if err := writeFlow(buf, records); err != nil {
+		return "", err
+	}
+	return out, buf.Flush()
+}
@@ -0,0 +1,115 @@
+package e2e
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clock is the time source of the replay player
+type clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// realClock waits for the actual time to pass
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// virtualClock advances instantly on Sleep, the replay keeps the recorded
+// timing in virtual time without waiting for it
+type virtualClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newVirtualClock(start time.Time) *virtualClock {
+	return &virtualClock{now: start}
+}
+
+func (v *virtualClock) Now() time.Time {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return v.now
+}
+
+func (v *virtualClock) Sleep(d time.Duration) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.now = v.now.Add(d)
+}
+
+// replaySpeed is the speed of the replay relative to the recording.
+// Zero replays as fast as possible, 1 at the recorded speed
+type replaySpeed float64
+
+const (
+	replayAsFastAsPossible replaySpeed = 0
+	replayRecordedSpeed    replaySpeed = 1
+)
+
+// parseReplaySpeed parses "max" (as fast as possible), "recorded" or a multiplier such as "2x" or "0.5x"
+func parseReplaySpeed(s string) (replaySpeed, error) {
+	switch s {
+	case "", "max":
+		return replayAsFastAsPossible, nil
+	case "recorded":
+		return replayRecordedSpeed, nil
+	}
+	multiplier, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || multiplier <= 0 {
+		return 0, fmt.Errorf("invalid replay speed '%s'", s)
+	}
+	return replaySpeed(multiplier), nil
+}
+
+// replaySpeedFromEnv returns the replay speed set in E2E_REPLAY_SPEED, as fast as possible by default
+func replaySpeedFromEnv() (replaySpeed, error) {
+	return parseReplaySpeed(os.Getenv("E2E_REPLAY_SPEED"))
+}
+
+// scale returns the delay to wait for a recorded delay
+func (s replaySpeed) scale(recorded time.Duration) time.Duration {
+	if s == replayAsFastAsPossible || recorded <= 0 {
+		return 0
+	}
+	return time.Duration(float64(recorded) / float64(s))
+}
+
+// replayPlayer plays the records of a flow, waiting between two records
+// the recorded delay scaled by the speed
+type replayPlayer struct {
+	speed replaySpeed
+	clock clock
+}
+
+func newReplayPlayer(speed replaySpeed, clock clock) *replayPlayer {
+	return &replayPlayer{speed: speed, clock: clock}
+}
+
+// Play calls the handler for every record in order. It stops at the first error of the handler
+func (p *replayPlayer) Play(records []*replayRecord, handler func(*replayRecord) error) error {
+	for i, record := range records {
+		if i > 0 {
+			if delay := p.speed.scale(record.Time.Sub(records[i-1].Time)); delay > 0 {
+				p.clock.Sleep(delay)
+			}
+		}
+		if err := handler(record); err != nil {
+			return fmt.Errorf("record %d of %s: %v", record.Seq, record.Node, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package e2e
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// syncFollowInterval is the interval the nodes outside of the validator set sync with the network
+const syncFollowInterval = 100 * time.Millisecond
+
+// validatorRotation is the validator set of every epoch of the cluster
+type validatorRotation struct {
+	epochSize uint64
+	epochs    [][]string
+}
+
+// Rotate changes the validator set every epochSize heights, epochs[i] being the validators of
+// the epoch i (the heights from i*epochSize+1 to (i+1)*epochSize). The last set is kept for the
+// following epochs. The nodes outside of the validator set follow the chain by syncing.
+// It must be called before Start
+func (c *cluster) Rotate(epochSize uint64, epochs ...[]string) {
+	if epochSize == 0 || len(epochs) == 0 {
+		panic("rotation without epochs")
+	}
+	for _, epoch := range epochs {
+		for _, name := range epoch {
+			if _, ok := c.nodes[name]; !ok {
+				panic("node not found in rotation")
+			}
+		}
+	}
+	c.rotation = &validatorRotation{epochSize: epochSize, epochs: epochs}
+}
+
+// epochOf returns the epoch of the height, always zero without rotation
+func (c *cluster) epochOf(height uint64) uint64 {
+	if c.rotation == nil || height == 0 {
+		return 0
+	}
+	return (height - 1) / c.rotation.epochSize
+}
+
+// validatorsAt returns the validators of the height, all the nodes without rotation
+func (c *cluster) validatorsAt(height uint64, all []string) []string {
+	if c.rotation == nil {
+		return all
+	}
+	epoch := c.epochOf(height)
+	if last := uint64(len(c.rotation.epochs) - 1); epoch > last {
+		epoch = last
+	}
+	return c.rotation.epochs[epoch]
+}
+
+// isValidatorAt checks whether the node is a validator of the height
+func (c *cluster) isValidatorAt(name string, height uint64) bool {
+	for _, validator := range c.validatorsAt(height, []string{name}) {
+		if validator == name {
+			return true
+		}
+	}
+	return false
+}
+
+// swapOnePerEpoch returns the validators of count epochs, the first one being the first size
+// nodes of the pool and every following epoch replacing its oldest validator by the next node
+func swapOnePerEpoch(pool []string, size, count int) [][]string {
+	epochs := [][]string{}
+	for i := 0; i < count; i++ {
+		epoch := []string{}
+		for j := 0; j < size; j++ {
+			epoch = append(epoch, pool[(i+j)%len(pool)])
+		}
+		epochs = append(epochs, epoch)
+	}
+	return epochs
+}
+
+// swapQuorum returns two epochs of size validators where the second one replaces a quorum
+// of the validators of the first one at once
+func swapQuorum(pool []string, size int) [][]string {
+	first := append([]string{}, pool[:size]...)
+	swapped := pbft.QuorumSize(size)
+	second := append([]string{}, first[:size-swapped]...)
+	second = append(second, pool[size:size+swapped]...)
+	return [][]string{first, second}
+}
+
+// halve returns two epochs, every node of the pool and then its first half
+func halve(pool []string) [][]string {
+	return [][]string{pool, pool[:len(pool)/2]}
+}
+
+// validatorSetChanges records the changes of the validator set seen by the engine of a node
+type validatorSetChanges struct {
+	lock   sync.Mutex
+	events []*pbft.ValidatorSetChangedEvent
+}
+
+func newValidatorSetChanges() *validatorSetChanges {
+	return &validatorSetChanges{events: []*pbft.ValidatorSetChangedEvent{}}
+}
+
+// handle is the event handler of the engine
+func (v *validatorSetChanges) handle(e pbft.Event) {
+	if changed, ok := e.(*pbft.ValidatorSetChangedEvent); ok {
+		v.lock.Lock()
+		defer v.lock.Unlock()
+
+		v.events = append(v.events, changed)
+	}
+}
+
+// list returns the changes seen so far
+func (v *validatorSetChanges) list() []*pbft.ValidatorSetChangedEvent {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return append([]*pbft.ValidatorSetChangedEvent{}, v.events...)
+}
@@ -0,0 +1,103 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// nodeStatus is the response of the node RPC status endpoint
+type nodeStatus struct {
+	Name    string
+	Running bool
+	Height  uint64
+
+	State         string
+	View          *pbft.View
+	SinceProgress time.Duration
+	LastError     string `json:",omitempty"`
+
+	// Round has the lock status and the votes of the current round
+	Round *pbft.RoundStateView `json:",omitempty"`
+
+	AcceptQueueLen      int
+	ValidateQueueLen    int
+	RoundChangeQueueLen int
+
+	Stats pbft.Stats
+}
+
+func (n *node) status() *nodeStatus {
+	health := n.pbft.Health()
+	status := &nodeStatus{
+		Name:                n.name,
+		Running:             n.IsRunning(),
+		Height:              n.getNodeHeight(),
+		State:               health.State.String(),
+		View:                health.View,
+		SinceProgress:       health.SinceProgress,
+		Round:               n.pbft.RoundState(),
+		AcceptQueueLen:      health.AcceptQueueLen,
+		ValidateQueueLen:    health.ValidateQueueLen,
+		RoundChangeQueueLen: health.RoundChangeQueueLen,
+		Stats:               n.pbft.Stats(),
+	}
+	if health.LastError != nil {
+		status.LastError = health.LastError.Error()
+	}
+	return status
+}
+
+// ServeRPC exposes the status of the node as JSON on GET /status, so that
+// external tooling can query the node without being inside the test process.
+// It returns the address the endpoint listens on (use port 0 for a random port)
+func (n *node) ServeRPC(addr string) (string, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(n.status()); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	n.rpc = &http.Server{Handler: mux}
+	go n.rpc.Serve(lis)
+
+	return lis.Addr().String(), nil
+}
+
+// stopRPC stops the RPC endpoint of the node, if any
+func (n *node) stopRPC() {
+	if n.rpc == nil {
+		return
+	}
+	n.rpc.Shutdown(context.Background())
+	n.rpc = nil
+}
+
+// ServeRPC starts the RPC endpoint of every node on a random localhost port
+// and returns the addresses by node name
+func (c *cluster) ServeRPC() (map[string]string, error) {
+	addrs := map[string]string{}
+	for name, n := range c.nodes {
+		addr, err := n.ServeRPC("127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		addrs[name] = addr
+	}
+	return addrs, nil
+}
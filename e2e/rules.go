@@ -0,0 +1,208 @@
+package e2e
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+type ruleAction int
+
+const (
+	ruleDrop ruleAction = iota
+	ruleDelay
+)
+
+// msgRule is a declarative rule applied to the gossiped messages, e.g.
+// "drop Commit where round<=1" or "delay RoundChange from A_2 by 2s where round=3".
+// Empty filters match every message
+type msgRule struct {
+	action ruleAction
+	delay  time.Duration
+
+	types []pbft.MsgType
+	from  []pbft.NodeID
+	to    []pbft.NodeID
+
+	roundDesc string
+	roundFn   func(round uint64) bool
+}
+
+// dropRule drops the messages of the given types (or every message if none is set)
+func dropRule(types ...pbft.MsgType) *msgRule {
+	return &msgRule{action: ruleDrop, types: types}
+}
+
+// delayRule delays the messages of the given types (or every message if none is set)
+func delayRule(delay time.Duration, types ...pbft.MsgType) *msgRule {
+	return &msgRule{action: ruleDelay, delay: delay, types: types}
+}
+
+// From restricts the rule to the messages sent by the nodes
+func (r *msgRule) From(nodes ...string) *msgRule {
+	r.from = append(r.from, toNodeIDs(nodes)...)
+	return r
+}
+
+// To restricts the rule to the messages received by the nodes
+func (r *msgRule) To(nodes ...string) *msgRule {
+	r.to = append(r.to, toNodeIDs(nodes)...)
+	return r
+}
+
+// InRound restricts the rule to the messages of the round
+func (r *msgRule) InRound(round uint64) *msgRule {
+	r.roundDesc = fmt.Sprintf("round=%d", round)
+	r.roundFn = func(i uint64) bool { return i == round }
+	return r
+}
+
+// UpToRound restricts the rule to the messages with a round lower or equal than the given one
+func (r *msgRule) UpToRound(round uint64) *msgRule {
+	r.roundDesc = fmt.Sprintf("round<=%d", round)
+	r.roundFn = func(i uint64) bool { return i <= round }
+	return r
+}
+
+func (r *msgRule) match(from, to pbft.NodeID, msg *pbft.MessageReq) bool {
+	if len(r.types) != 0 && !containsType(r.types, msg.Type) {
+		return false
+	}
+	if len(r.from) != 0 && !containsNode(r.from, from) {
+		return false
+	}
+	if len(r.to) != 0 && !containsNode(r.to, to) {
+		return false
+	}
+	if r.roundFn != nil && !r.roundFn(msg.View.Round) {
+		return false
+	}
+	return true
+}
+
+func (r *msgRule) String() string {
+	var b strings.Builder
+	if r.action == ruleDrop {
+		b.WriteString("drop")
+	} else {
+		b.WriteString("delay")
+	}
+	if len(r.types) == 0 {
+		b.WriteString(" any")
+	}
+	for _, typ := range r.types {
+		b.WriteString(" " + typ.String())
+	}
+	if len(r.from) != 0 {
+		fmt.Fprintf(&b, " from %v", r.from)
+	}
+	if len(r.to) != 0 {
+		fmt.Fprintf(&b, " to %v", r.to)
+	}
+	if r.action == ruleDelay {
+		fmt.Fprintf(&b, " by %s", r.delay)
+	}
+	if r.roundFn != nil {
+		b.WriteString(" where " + r.roundDesc)
+	}
+	return b.String()
+}
+
+// ruleTransport applies a set of rules to the gossiped messages. Rules are evaluated in order,
+// the first matching drop rule discards the message and every matching delay rule adds its delay
+type ruleTransport struct {
+	rules []*msgRule
+
+	lock sync.Mutex
+	hits map[int]uint64
+}
+
+func newRuleTransport(rules ...*msgRule) *ruleTransport {
+	return &ruleTransport{
+		rules: rules,
+		hits:  map[int]uint64{},
+	}
+}
+
+func (r *ruleTransport) Connects(from, to pbft.NodeID) bool {
+	return true
+}
+
+func (r *ruleTransport) Gossip(from, to pbft.NodeID, msg *pbft.MessageReq) bool {
+	delay := time.Duration(0)
+	for indx, rule := range r.rules {
+		if !rule.match(from, to, msg) {
+			continue
+		}
+		r.hit(indx)
+		if rule.action == ruleDrop {
+			return false
+		}
+		delay += rule.delay
+	}
+	if delay != 0 {
+		time.Sleep(delay)
+	}
+	return true
+}
+
+func (r *ruleTransport) hit(indx int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.hits[indx]++
+}
+
+// Hits returns the number of messages matched by the rule
+func (r *ruleTransport) Hits(rule *msgRule) uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for indx, i := range r.rules {
+		if i == rule {
+			return r.hits[indx]
+		}
+	}
+	return 0
+}
+
+// String returns a summary of the rules and the number of messages they matched
+func (r *ruleTransport) String() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	lines := []string{}
+	for indx, rule := range r.rules {
+		lines = append(lines, fmt.Sprintf("%s (%d hits)", rule, r.hits[indx]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func toNodeIDs(nodes []string) []pbft.NodeID {
+	res := []pbft.NodeID{}
+	for _, n := range nodes {
+		res = append(res, pbft.NodeID(n))
+	}
+	return res
+}
+
+func containsNode(nodes []pbft.NodeID, node pbft.NodeID) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+func containsType(types []pbft.MsgType, typ pbft.MsgType) bool {
+	for _, t := range types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
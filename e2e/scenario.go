@@ -0,0 +1,103 @@
+package e2e
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// ScenarioController drives the network conditions of the cluster with typed methods,
+// so that tests do not need to keep (and type assert) the hooks used to build the cluster.
+// It is the first hook of the transport pipeline of every cluster
+type ScenarioController struct {
+	partition *partitionTransport
+
+	lock    sync.RWMutex
+	latency time.Duration
+	rules   []*msgRule
+}
+
+func newScenarioController() *ScenarioController {
+	return &ScenarioController{
+		partition: newPartitionTransport(0),
+	}
+}
+
+// Partition splits the network in the given subsets, nodes are only connected
+// to the nodes of the same subset
+func (s *ScenarioController) Partition(subsets ...[]string) {
+	s.partition.Partition(subsets...)
+}
+
+// Heal removes the partitions and the drop rules
+func (s *ScenarioController) Heal() {
+	s.partition.Reset()
+
+	s.lock.Lock()
+	s.rules = nil
+	s.lock.Unlock()
+}
+
+// SetLatency sets a random latency (up to max) on every message, zero disables it
+func (s *ScenarioController) SetLatency(max time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.latency = max
+}
+
+// DropType drops every message of the given types
+func (s *ScenarioController) DropType(types ...pbft.MsgType) *msgRule {
+	return s.Drop(dropRule(types...))
+}
+
+// Drop applies the drop rule to the gossiped messages. The rule must not be modified afterwards
+func (s *ScenarioController) Drop(rule *msgRule) *msgRule {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rules = append(s.rules, rule)
+	return rule
+}
+
+func (s *ScenarioController) Connects(from, to pbft.NodeID) bool {
+	return s.partition.Connects(from, to)
+}
+
+func (s *ScenarioController) Gossip(from, to pbft.NodeID, msg *pbft.MessageReq) bool {
+	if !s.partition.Connects(from, to) {
+		return false
+	}
+
+	s.lock.RLock()
+	latency := s.latency
+	for _, rule := range s.rules {
+		if rule.match(from, to, msg) {
+			s.lock.RUnlock()
+			return false
+		}
+	}
+	s.lock.RUnlock()
+
+	if latency != 0 {
+		time.Sleep(timeJitter(latency))
+	}
+	return true
+}
+
+func (s *ScenarioController) String() string {
+	s.partition.lock.Lock()
+	subsets := fmt.Sprintf("%v", s.partition.subsets)
+	s.partition.lock.Unlock()
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	rules := []string{}
+	for _, rule := range s.rules {
+		rules = append(rules, rule.String())
+	}
+	return fmt.Sprintf("partitions=%s latency=%s rules=%v", subsets, s.latency, rules)
+}
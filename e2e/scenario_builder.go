@@ -0,0 +1,185 @@
+package e2e
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+//go:generate go run ./scenariogen -in testdata/scenarios -out .
+
+// scenarioStep is a fault applied to the cluster once the active nodes reach the height
+type scenarioStep struct {
+	height uint64
+	desc   string
+	apply  func(b *scenarioBuilder)
+}
+
+// scenarioBuilder describes an e2e scenario (the size of the cluster, the faults applied
+// at given heights and the expected outcome) and runs it. The nodes are referred to by index
+type scenarioBuilder struct {
+	t       *testing.T
+	name    string
+	nodes   int
+	latency time.Duration
+	steps   []*scenarioStep
+
+	expectHeight  uint64
+	expectTimeout time.Duration
+	expectStuck   time.Duration
+
+	// set while the scenario runs
+	c       *cluster
+	stopped map[string]bool
+	active  []string
+}
+
+// newScenarioBuilder creates a scenario of a cluster with the given number of nodes
+func newScenarioBuilder(t *testing.T, name string, nodes int) *scenarioBuilder {
+	return &scenarioBuilder{
+		t:             t,
+		name:          name,
+		nodes:         nodes,
+		expectTimeout: 1 * time.Minute,
+	}
+}
+
+// Latency sets a random latency (up to max) on every message
+func (b *scenarioBuilder) Latency(max time.Duration) *scenarioBuilder {
+	b.latency = max
+	return b
+}
+
+func (b *scenarioBuilder) at(height uint64, desc string, apply func(b *scenarioBuilder)) *scenarioBuilder {
+	b.steps = append(b.steps, &scenarioStep{height: height, desc: desc, apply: apply})
+	return b
+}
+
+// Partition splits the network in the groups of nodes at the height, the largest group
+// is expected to make progress
+func (b *scenarioBuilder) Partition(height uint64, groups ...[]int) *scenarioBuilder {
+	return b.at(height, fmt.Sprintf("partition %v", groups), func(b *scenarioBuilder) {
+		subsets := [][]string{}
+		largest := []string{}
+		for _, group := range groups {
+			subset := b.names(group...)
+			subsets = append(subsets, subset)
+			if len(subset) > len(largest) {
+				largest = subset
+			}
+		}
+		b.c.Scenario().Partition(subsets...)
+		b.active = b.running(largest)
+	})
+}
+
+// DropType drops every message of the types from the height
+func (b *scenarioBuilder) DropType(height uint64, types ...pbft.MsgType) *scenarioBuilder {
+	return b.at(height, fmt.Sprintf("drop %v", types), func(b *scenarioBuilder) {
+		b.c.Scenario().DropType(types...)
+	})
+}
+
+// Heal removes the partitions and the drop rules at the height
+func (b *scenarioBuilder) Heal(height uint64) *scenarioBuilder {
+	return b.at(height, "heal", func(b *scenarioBuilder) {
+		b.c.Scenario().Heal()
+		b.active = b.running(b.names())
+	})
+}
+
+// StopNodes stops the nodes at the height
+func (b *scenarioBuilder) StopNodes(height uint64, nodes ...int) *scenarioBuilder {
+	return b.at(height, fmt.Sprintf("stop %v", nodes), func(b *scenarioBuilder) {
+		for _, name := range b.names(nodes...) {
+			b.c.StopNode(name)
+			b.stopped[name] = true
+		}
+		b.active = b.running(b.active)
+	})
+}
+
+// StartNodes starts again the nodes at the height
+func (b *scenarioBuilder) StartNodes(height uint64, nodes ...int) *scenarioBuilder {
+	return b.at(height, fmt.Sprintf("start %v", nodes), func(b *scenarioBuilder) {
+		for _, name := range b.names(nodes...) {
+			b.c.StartNode(name)
+			delete(b.stopped, name)
+			b.active = append(b.active, name)
+		}
+	})
+}
+
+// ExpectHeight expects the active nodes to reach the height within the timeout
+func (b *scenarioBuilder) ExpectHeight(height uint64, timeout time.Duration) *scenarioBuilder {
+	b.expectHeight, b.expectTimeout = height, timeout
+	return b
+}
+
+// ExpectStuck expects the cluster not to make any progress for the duration after the last step
+func (b *scenarioBuilder) ExpectStuck(duration time.Duration) *scenarioBuilder {
+	b.expectStuck = duration
+	return b
+}
+
+// names returns the names of the nodes with the given indexes, or every node
+func (b *scenarioBuilder) names(nodes ...int) []string {
+	prefix := strings.ToLower(b.name)
+	res := []string{}
+	if len(nodes) == 0 {
+		for i := 0; i < b.nodes; i++ {
+			nodes = append(nodes, i)
+		}
+	}
+	for _, i := range nodes {
+		if i < 0 || i >= b.nodes {
+			b.t.Fatalf("node %d out of the cluster of %d nodes", i, b.nodes)
+		}
+		res = append(res, fmt.Sprintf("%s_%d", prefix, i))
+	}
+	return res
+}
+
+// running filters out the stopped nodes
+func (b *scenarioBuilder) running(nodes []string) []string {
+	res := []string{}
+	for _, name := range nodes {
+		if !b.stopped[name] {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// Run runs the scenario: every step is applied once the active nodes reach its height,
+// then the outcome is checked
+func (b *scenarioBuilder) Run() {
+	prefix := strings.ToLower(b.name)
+	b.c = newPBFTCluster(b.t, prefix, prefix, b.nodes)
+	b.c.Scenario().SetLatency(b.latency)
+	b.stopped = map[string]bool{}
+	b.active = b.names()
+
+	b.c.Start()
+	defer b.c.Stop()
+
+	for _, step := range b.steps {
+		if err := b.c.WaitForHeight(step.height, b.expectTimeout, b.active); err != nil {
+			b.t.Fatalf("height %d not reached before %s: %v", step.height, step.desc, err)
+		}
+		b.t.Logf("height %d: %s", step.height, step.desc)
+		step.apply(b)
+	}
+
+	if b.expectStuck != 0 {
+		b.c.IsStuck(b.expectStuck, b.active)
+	}
+	if b.expectHeight != 0 {
+		err := b.c.WaitForHeight(b.expectHeight, b.expectTimeout, b.active)
+		assert.NoError(b.t, err)
+	}
+}
@@ -0,0 +1,292 @@
+// Command scenariogen generates skeleton e2e tests from scenario descriptions. Every
+// testdata/scenarios/<name>.json file describes the cluster, the faults and the expected
+// outcome, and becomes an e2e_<name>_test.go file using the scenario builder of the e2e
+// package. Existing tests are not overwritten (unless -force), they are meant to be edited
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// msgTypes are the message types a scenario can drop
+var msgTypes = map[string]bool{
+	"RoundChange":   true,
+	"Preprepare":    true,
+	"Commit":        true,
+	"Prepare":       true,
+	"ProposalChunk": true,
+	"Committed":     true,
+	"Status":        true,
+}
+
+var namePattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9_]*$`)
+
+// duration is a time.Duration encoded as a string (e.g. "50ms")
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// Go returns the duration as a Go expression
+func (d duration) Go() string {
+	v := time.Duration(d)
+	for _, unit := range []struct {
+		d    time.Duration
+		name string
+	}{{time.Minute, "time.Minute"}, {time.Second, "time.Second"}, {time.Millisecond, "time.Millisecond"}} {
+		if v%unit.d == 0 {
+			return fmt.Sprintf("%d*%s", v/unit.d, unit.name)
+		}
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+// fault is a fault applied once the active nodes reach the height
+type fault struct {
+	Height uint64
+	// Kind is one of partition, drop, heal, stop and start
+	Kind string
+	// Groups are the indexes of the nodes of every subset of a partition
+	Groups [][]int
+	// Nodes are the indexes of the nodes to stop or start
+	Nodes []int
+	// Types are the message types to drop
+	Types []string
+}
+
+// Go returns the call of the scenario builder applying the fault
+func (f *fault) Go() string {
+	switch f.Kind {
+	case "partition":
+		groups := []string{}
+		for _, group := range f.Groups {
+			groups = append(groups, "[]int{"+joinInts(group)+"}")
+		}
+		return fmt.Sprintf("Partition(%d, %s)", f.Height, strings.Join(groups, ", "))
+	case "drop":
+		types := []string{}
+		for _, typ := range f.Types {
+			types = append(types, "pbft.MessageReq_"+typ)
+		}
+		return fmt.Sprintf("DropType(%d, %s)", f.Height, strings.Join(types, ", "))
+	case "heal":
+		return fmt.Sprintf("Heal(%d)", f.Height)
+	case "stop":
+		return fmt.Sprintf("StopNodes(%d, %s)", f.Height, joinInts(f.Nodes))
+	default:
+		return fmt.Sprintf("StartNodes(%d, %s)", f.Height, joinInts(f.Nodes))
+	}
+}
+
+// expect is the expected outcome of the scenario
+type expect struct {
+	// Height is the height the active nodes must reach
+	Height uint64
+	// Timeout is the time to reach every height
+	Timeout duration
+	// Stuck is the time the cluster must not make progress after the last fault
+	Stuck duration
+}
+
+// scenario is the description of an e2e scenario
+type scenario struct {
+	Name        string
+	Description string
+	Nodes       int
+	Latency     duration
+	Faults      []*fault
+	Expect      expect
+
+	// File is the path of the description
+	File string `json:"-"`
+}
+
+// UsesPbft returns whether the generated test references the pbft package
+func (s *scenario) UsesPbft() bool {
+	for _, f := range s.Faults {
+		if f.Kind == "drop" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scenario) validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid name %q", s.Name)
+	}
+	if s.Nodes <= 0 {
+		return fmt.Errorf("no nodes")
+	}
+	if s.Expect.Height == 0 && s.Expect.Stuck == 0 {
+		return fmt.Errorf("no expected outcome")
+	}
+	checkNodes := func(nodes []int) error {
+		for _, i := range nodes {
+			if i < 0 || i >= s.Nodes {
+				return fmt.Errorf("node %d out of the cluster of %d nodes", i, s.Nodes)
+			}
+		}
+		return nil
+	}
+	for _, f := range s.Faults {
+		switch f.Kind {
+		case "partition":
+			if len(f.Groups) < 2 {
+				return fmt.Errorf("partition at height %d with less than two groups", f.Height)
+			}
+			for _, group := range f.Groups {
+				if err := checkNodes(group); err != nil {
+					return err
+				}
+			}
+		case "drop":
+			if len(f.Types) == 0 {
+				return fmt.Errorf("drop at height %d without message types", f.Height)
+			}
+			for _, typ := range f.Types {
+				if !msgTypes[typ] {
+					return fmt.Errorf("unknown message type %q", typ)
+				}
+			}
+		case "stop", "start":
+			if len(f.Nodes) == 0 {
+				return fmt.Errorf("%s at height %d without nodes", f.Kind, f.Height)
+			}
+			if err := checkNodes(f.Nodes); err != nil {
+				return err
+			}
+		case "heal":
+		default:
+			return fmt.Errorf("unknown fault %q", f.Kind)
+		}
+	}
+	return nil
+}
+
+var testTemplate = template.Must(template.New("scenario").Parse(`package e2e
+
+// Generated by scenariogen from {{.File}}, edit it to refine the scenario.
+
+import (
+	"testing"
+	"time"
+{{if .UsesPbft}}
+	"github.com/0xPolygon/pbft-consensus"
+{{end}}
+)
+
+{{if .Description}}// TestE2E_{{.Name}} runs the scenario: {{.Description}}
+{{end}}func TestE2E_{{.Name}}(t *testing.T) {
+	newScenarioBuilder(t, "{{.Name}}", {{.Nodes}}).
+{{- if .Latency}}
+		Latency({{.Latency.Go}}).
+{{- end}}
+{{- range .Faults}}
+		{{.Go}}.
+{{- end}}
+{{- if .Expect.Stuck}}
+		ExpectStuck({{.Expect.Stuck.Go}}).
+{{- end}}
+{{- if .Expect.Height}}
+		ExpectHeight({{.Expect.Height}}, {{if .Expect.Timeout}}{{.Expect.Timeout.Go}}{{else}}1*time.Minute{{end}}).
+{{- end}}
+		Run()
+}
+`))
+
+func joinInts(nums []int) string {
+	strs := []string{}
+	for _, num := range nums {
+		strs = append(strs, fmt.Sprintf("%d", num))
+	}
+	return strings.Join(strs, ", ")
+}
+
+// testFileName returns the name of the test file of the scenario (e.g. NodeDrop_Heal -> e2e_node_drop_heal_test.go)
+func testFileName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i != 0 && name[i-1] != '_' {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return "e2e_" + b.String() + "_test.go"
+}
+
+func generate(path, out string, force bool) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	s := &scenario{File: filepath.ToSlash(path)}
+	if err := json.Unmarshal(data, s); err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+	if err := s.validate(); err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+
+	target := filepath.Join(out, testFileName(s.Name))
+	if _, err := os.Stat(target); err == nil && !force {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := testTemplate.Execute(&buf, s); err != nil {
+		return "", err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+	return target, ioutil.WriteFile(target, src, 0644)
+}
+
+func main() {
+	in := flag.String("in", "testdata/scenarios", "directory of the scenario descriptions")
+	out := flag.String("out", ".", "directory of the generated tests")
+	force := flag.Bool("force", false, "overwrite the existing tests")
+	flag.Parse()
+
+	paths, err := filepath.Glob(filepath.Join(*in, "*.json"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, path := range paths {
+		target, err := generate(path, *out, *force)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if target != "" {
+			fmt.Println(target)
+		}
+	}
+}
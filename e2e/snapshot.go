@@ -0,0 +1,58 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// snapshotFile is the name of the file with the failure snapshot of the cluster
+const snapshotFile = "snapshot.json"
+
+// clusterSnapshot is the state of every node and of the transport hooks at a given time
+type clusterSnapshot struct {
+	Time   time.Time
+	Reason string
+	Nodes  []*nodeStatus
+	Hooks  []string
+}
+
+func (c *cluster) snapshot(reason string) *clusterSnapshot {
+	s := &clusterSnapshot{
+		Time:   time.Now(),
+		Reason: reason,
+		Nodes:  []*nodeStatus{},
+		Hooks:  []string{},
+	}
+	for _, n := range c.Nodes() {
+		s.Nodes = append(s.Nodes, n.status())
+	}
+	sort.Slice(s.Nodes, func(i, j int) bool {
+		return s.Nodes[i].Name < s.Nodes[j].Name
+	})
+	for _, hook := range c.transport.hooks.getHooks() {
+		desc := fmt.Sprintf("%T", hook)
+		if stringer, ok := hook.(fmt.Stringer); ok {
+			desc += ": " + stringer.String()
+		}
+		s.Hooks = append(s.Hooks, desc)
+	}
+	return s
+}
+
+// dumpSnapshot logs the snapshot of the cluster and stores it in the output directory
+func (c *cluster) dumpSnapshot(reason string) {
+	data, err := json.MarshalIndent(c.snapshot(reason), "", "  ")
+	if err != nil {
+		c.t.Logf("[ERROR] failed to encode the cluster snapshot: %v", err)
+		return
+	}
+	c.t.Logf("cluster snapshot (%s):\n%s", reason, data)
+
+	if err := ioutil.WriteFile(filepath.Join(c.outputDir, snapshotFile), data, 0644); err != nil {
+		c.t.Logf("[ERROR] failed to store the cluster snapshot: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// soakCheckpointsFile is the name of the file with the checkpoints of the soak run
+const soakCheckpointsFile = "soak.jsonl"
+
+// soakConfig configures a long running soak test
+type soakConfig struct {
+	// Duration is the total duration of the run
+	Duration time.Duration
+
+	// CheckpointInterval is the interval between checkpoints
+	CheckpointInterval time.Duration
+
+	// MaxStall is the maximum time without any new height in the cluster
+	MaxStall time.Duration
+
+	// MaxGoroutineGrowth is the maximum growth of the goroutines over the first checkpoint
+	MaxGoroutineGrowth int
+
+	// MaxHeapGrowth is the maximum growth (in bytes) of the heap over the first checkpoint
+	MaxHeapGrowth uint64
+}
+
+// soakCheckpoint is the state of the cluster and of the process at a given time
+type soakCheckpoint struct {
+	Time       time.Time
+	Heights    map[string]uint64
+	MaxHeight  uint64
+	Goroutines int
+	HeapAlloc  uint64
+}
+
+func (c *cluster) checkpoint() *soakCheckpoint {
+	// collect first to measure the live heap only
+	runtime.GC()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	cp := &soakCheckpoint{
+		Time:       time.Now(),
+		Heights:    map[string]uint64{},
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  mem.HeapAlloc,
+	}
+	for _, n := range c.Nodes() {
+		height := n.getNodeHeight()
+		cp.Heights[n.name] = height
+		if height > cp.MaxHeight {
+			cp.MaxHeight = height
+		}
+	}
+	return cp
+}
+
+// runSoak keeps the (already started) cluster running for the configured duration.
+// It checkpoints the heights, the goroutines and the heap at every interval and
+// fails on a prolonged stall or when the goroutines or the heap grow over the limits
+func (c *cluster) runSoak(config *soakConfig) error {
+	file, err := os.Create(filepath.Join(c.outputDir, soakCheckpointsFile))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	enc := json.NewEncoder(file)
+
+	first := c.checkpoint()
+	lastProgress := first
+	if err := enc.Encode(first); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(config.CheckpointInterval)
+	defer ticker.Stop()
+
+	end := time.After(config.Duration)
+	for {
+		select {
+		case <-end:
+			return nil
+		case <-ticker.C:
+		}
+
+		cp := c.checkpoint()
+		if err := enc.Encode(cp); err != nil {
+			return err
+		}
+		c.t.Logf("soak checkpoint: height=%d goroutines=%d heap=%d", cp.MaxHeight, cp.Goroutines, cp.HeapAlloc)
+
+		if cp.MaxHeight > lastProgress.MaxHeight {
+			lastProgress = cp
+		} else if stall := cp.Time.Sub(lastProgress.Time); stall > config.MaxStall {
+			c.dumpSnapshot("soak stall")
+			return fmt.Errorf("no progress for %s at height %d", stall, cp.MaxHeight)
+		}
+		if growth := cp.Goroutines - first.Goroutines; growth > config.MaxGoroutineGrowth {
+			return fmt.Errorf("goroutines grew by %d (from %d to %d)", growth, first.Goroutines, cp.Goroutines)
+		}
+		if cp.HeapAlloc > first.HeapAlloc && cp.HeapAlloc-first.HeapAlloc > config.MaxHeapGrowth {
+			return fmt.Errorf("heap grew by %d bytes (from %d to %d)", cp.HeapAlloc-first.HeapAlloc, first.HeapAlloc, cp.HeapAlloc)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package e2e
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// starvationSampleInterval is the interval between two samples of the starvation detector
+const starvationSampleInterval = 100 * time.Millisecond
+
+// starvation is a node lagging behind the cluster for longer than allowed
+type starvation struct {
+	Node string
+
+	// Lag is the highest number of heights the node was behind the cluster maximum
+	Lag uint64
+
+	// Since is the time the node started lagging
+	Since time.Time
+
+	// Duration is the time the node lagged
+	Duration time.Duration
+}
+
+func (s *starvation) String() string {
+	return fmt.Sprintf("node %s lagged up to %d heights for %s", s.Node, s.Lag, s.Duration.Round(time.Millisecond))
+}
+
+// starvationDetector samples the heights of the running nodes and flags every node that lags
+// more than maxLag heights behind the cluster maximum for longer than maxDuration, even if the
+// rest of the cluster keeps finalizing heights. The stopped nodes are not considered
+type starvationDetector struct {
+	c           *cluster
+	nodes       []string
+	maxLag      uint64
+	maxDuration time.Duration
+
+	lock sync.Mutex
+	// lagging is the ongoing lag of every node behind the cluster
+	lagging map[string]*starvation
+	// starved are the nodes that lagged for longer than maxDuration
+	starved map[string]*starvation
+
+	closeCh chan struct{}
+	doneCh  chan struct{}
+}
+
+// WatchStarvation starts a starvation detector on the given nodes (all the nodes by default),
+// it runs until it is stopped (see AssertNoStarvation)
+func (c *cluster) WatchStarvation(maxLag uint64, maxDuration time.Duration, nodes ...[]string) *starvationDetector {
+	d := &starvationDetector{
+		c:           c,
+		nodes:       c.resolveNodes(nodes...),
+		maxLag:      maxLag,
+		maxDuration: maxDuration,
+		lagging:     map[string]*starvation{},
+		starved:     map[string]*starvation{},
+		closeCh:     make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+func (d *starvationDetector) run() {
+	defer close(d.doneCh)
+
+	ticker := time.NewTicker(starvationSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.sample(now)
+		case <-d.closeCh:
+			return
+		}
+	}
+}
+
+// sample compares the height of every watched node with the highest height of the cluster
+func (d *starvationDetector) sample(now time.Time) {
+	maxHeight := uint64(0)
+	heights := map[string]uint64{}
+	for _, n := range d.c.Nodes() {
+		if !n.IsRunning() {
+			continue
+		}
+		height := n.getNodeHeight()
+		heights[n.name] = height
+		if height > maxHeight {
+			maxHeight = height
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, name := range d.nodes {
+		height, running := heights[name]
+		if !running || maxHeight-height <= d.maxLag {
+			delete(d.lagging, name)
+			continue
+		}
+		lag := maxHeight - height
+		current, ok := d.lagging[name]
+		if !ok {
+			current = &starvation{Node: name, Since: now}
+			d.lagging[name] = current
+		}
+		if lag > current.Lag {
+			current.Lag = lag
+		}
+		current.Duration = now.Sub(current.Since)
+		if current.Duration <= d.maxDuration {
+			continue
+		}
+		// keep the longest starvation of the node
+		if starved, ok := d.starved[name]; !ok || starved.Since == current.Since || starved.Duration < current.Duration {
+			snapshot := *current
+			d.starved[name] = &snapshot
+		}
+	}
+}
+
+// Starved returns the nodes that lagged for longer than allowed, sorted by name
+func (d *starvationDetector) Starved() []*starvation {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	res := []*starvation{}
+	for _, s := range d.starved {
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Node < res[j].Node })
+	return res
+}
+
+// Stop stops the detector, it returns an error that describes the starved nodes if any
+func (d *starvationDetector) Stop() error {
+	select {
+	case <-d.closeCh:
+	default:
+		close(d.closeCh)
+	}
+	<-d.doneCh
+
+	starved := d.Starved()
+	if len(starved) == 0 {
+		return nil
+	}
+	descs := []string{}
+	for _, s := range starved {
+		descs = append(descs, s.String())
+	}
+	return fmt.Errorf("starved nodes: %s", strings.Join(descs, ", "))
+}
+
+// AssertNoStarvation stops the detector and fails the test if a node starved
+func (d *starvationDetector) AssertNoStarvation() {
+	if err := d.Stop(); err != nil {
+		d.c.t.Fatal(err)
+	}
+}
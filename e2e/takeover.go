@@ -0,0 +1,103 @@
+package e2e
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+// replayedProposals rebuilds the proposals finalized in the flow below the height,
+// with the proposal of the preprepare and the commit seals received by the nodes
+func replayedProposals(records []*replayRecord, height uint64) ([]*pbft.SealedProposal, error) {
+	if disagreement, err := flowAgreement(records); err != nil && disagreement < height {
+		return nil, err
+	}
+	finalized := finalizedDigests(records)
+
+	sealed := []*pbft.SealedProposal{}
+	for number := uint64(1); number < height; number++ {
+		digest := ""
+		for _, d := range finalized[number] {
+			digest = d
+			break
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("height %d is not finalized in the flow", number)
+		}
+
+		proposal := &pbft.SealedProposal{Number: number}
+		signers := map[pbft.NodeID]struct{}{}
+		for _, record := range records {
+			msg := record.Msg
+			if msg == nil || msg.View == nil || msg.View.Sequence != number || hex.EncodeToString(msg.Hash) != digest {
+				continue
+			}
+			switch msg.Type {
+			case pbft.MessageReq_Preprepare:
+				if proposal.Proposal == nil && msg.Proposal != nil {
+					proposal.Proposal = &pbft.Proposal{
+						Data: append([]byte{}, msg.Proposal...),
+						Hash: append([]byte{}, msg.Hash...),
+					}
+					proposal.Proposer = msg.From
+				}
+			case pbft.MessageReq_Commit:
+				if _, ok := signers[msg.From]; !ok {
+					signers[msg.From] = struct{}{}
+					proposal.CommittedSeals = append(proposal.CommittedSeals, msg.Seal)
+				}
+			}
+		}
+		if proposal.Proposal == nil {
+			return nil, fmt.Errorf("proposal of height %d is not in the flow", number)
+		}
+		sealed = append(sealed, proposal)
+	}
+	return sealed, nil
+}
+
+// Takeover replays the flow of a recorded run up to the height and round and then hands the
+// cluster over to the live transport and timers. The proposals below the height are loaded
+// as already finalized and every node receives the messages it processed at the height up
+// to the round, so the run continues from the recorded state. It must be called before Start
+func (c *cluster) Takeover(path string, height, round uint64) error {
+	speed, err := replaySpeedFromEnv()
+	if err != nil {
+		return err
+	}
+	records, err := readReplayFile(path)
+	if err != nil {
+		return err
+	}
+	sealed, err := replayedProposals(records, height)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.sealedProposals = sealed
+	c.lock.Unlock()
+	for _, n := range c.nodes {
+		if n.IsRunning() {
+			return fmt.Errorf("node %s is already running", n.name)
+		}
+		n.setSyncIndex(int64(len(sealed)) - 1)
+		n.syncHead(int64(len(sealed)) - 1)
+	}
+
+	// the recorded state is rebuilt at the speed of E2E_REPLAY_SPEED, the live run keeps the real timers
+	player := newReplayPlayer(speed, realClock{})
+	return player.Play(records, func(record *replayRecord) error {
+		msg := record.Msg
+		if msg == nil || msg.View == nil || msg.View.Sequence != height || msg.View.Round > round {
+			return nil
+		}
+		n, ok := c.nodes[record.Node]
+		if !ok {
+			return fmt.Errorf("node %s is not in the cluster", record.Node)
+		}
+		n.pbft.PushMessage(msg.Copy())
+		return nil
+	})
+}
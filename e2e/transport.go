@@ -9,43 +9,134 @@ import (
 )
 
 type transport struct {
-	nodes map[pbft.NodeID]transportHandler
-	hook  transportHook
+	lock  sync.RWMutex
+	nodes map[pbft.NodeID][]transportHandler
+	hooks hookChain
+
+	// leakToken detects the transports retained after the cluster stopped
+	leakToken *leakToken
+
+	// trace records every delivered and dropped message, if enabled
+	trace *msgTrace
+
+	// complexity counts the messages per height
+	complexity *msgComplexity
 }
 
+// addHook appends the hook to the transport pipeline
 func (t *transport) addHook(hook transportHook) {
-	t.hook = hook
+	t.hooks.add(hook)
 }
 
 type transportHandler func(*pbft.MessageReq)
 
+// Register adds the handler of the node. Several handlers can be registered
+// for the same id, i.e. two processes sharing the same key
 func (t *transport) Register(name pbft.NodeID, handler transportHandler) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	if t.nodes == nil {
-		t.nodes = map[pbft.NodeID]transportHandler{}
+		t.nodes = map[pbft.NodeID][]transportHandler{}
+	}
+	t.nodes[name] = append(t.nodes[name], handler)
+}
+
+func (t *transport) handlers() map[pbft.NodeID][]transportHandler {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	handlers := make(map[pbft.NodeID][]transportHandler, len(t.nodes))
+	for to, h := range t.nodes {
+		handlers[to] = h
 	}
-	t.nodes[name] = handler
+	return handlers
 }
 
 func (t *transport) Gossip(msg *pbft.MessageReq) error {
-	for to, handler := range t.nodes {
-		go func(to pbft.NodeID, handler transportHandler) {
-			send := true
-			if t.hook != nil {
-				send = t.hook.Gossip(msg.From, to, msg)
-			}
-			if send {
-				handler(msg)
-			}
-		}(to, handler)
+	if t.complexity != nil {
+		t.complexity.gossiped(msg)
+	}
+	for to, handlers := range t.handlers() {
+		for _, handler := range handlers {
+			go func(to pbft.NodeID, handler transportHandler) {
+				delivered := t.hooks.Gossip(msg.From, to, msg)
+				if t.trace != nil {
+					t.trace.record(to, msg, delivered)
+				}
+				if delivered {
+					if t.complexity != nil {
+						t.complexity.delivered(msg)
+					}
+					handler(msg)
+				}
+			}(to, handler)
+		}
 	}
 	return nil
 }
 
+// inject delivers the message to the handlers of the node right away, bypassing the hooks.
+// It returns false if the node is not registered
+func (t *transport) inject(to pbft.NodeID, msg *pbft.MessageReq) bool {
+	handlers, ok := t.handlers()[to]
+	if !ok {
+		return false
+	}
+	if t.trace != nil {
+		t.trace.record(to, msg, true)
+	}
+	for _, handler := range handlers {
+		handler(msg.Copy())
+	}
+	return true
+}
+
 type transportHook interface {
 	Connects(from, to pbft.NodeID) bool
 	Gossip(from, to pbft.NodeID, msg *pbft.MessageReq) bool
 }
 
+// hookChain composes several hooks as a pipeline (e.g. partition + latency + loss).
+// Hooks are applied in the order they were added, a message is delivered only if every
+// hook lets it through and two nodes are connected only if every hook connects them
+type hookChain struct {
+	lock  sync.RWMutex
+	hooks []transportHook
+}
+
+func (h *hookChain) add(hook transportHook) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.hooks = append(h.hooks, hook)
+}
+
+func (h *hookChain) getHooks() []transportHook {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return h.hooks
+}
+
+func (h *hookChain) Connects(from, to pbft.NodeID) bool {
+	for _, hook := range h.getHooks() {
+		if !hook.Connects(from, to) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *hookChain) Gossip(from, to pbft.NodeID, msg *pbft.MessageReq) bool {
+	for _, hook := range h.getHooks() {
+		if !hook.Gossip(from, to, msg) {
+			return false
+		}
+	}
+	return true
+}
+
 // latency transport
 type randomTransport struct {
 	jitterMax time.Duration
@@ -145,3 +236,169 @@ func (p *partitionTransport) Gossip(from, to pbft.NodeID, msg *pbft.MessageReq)
 func timeJitter(jitterMax time.Duration) time.Duration {
 	return time.Duration(uint64(rand.Int63()) % uint64(jitterMax))
 }
+
+// proposerDoSTransport simulates a targeted attack against the proposer of every view.
+// For the first attackedRounds rounds of each height, the proposer of the view is isolated
+// from the rest of the network. It records the round in which each height was committed
+type proposerDoSTransport struct {
+	proposerFn     func(view *pbft.View) pbft.NodeID
+	attackedRounds uint64
+
+	lock         sync.Mutex
+	commitRounds map[uint64]uint64
+}
+
+func newProposerDoSTransport(attackedRounds uint64, proposerFn func(view *pbft.View) pbft.NodeID) *proposerDoSTransport {
+	return &proposerDoSTransport{
+		proposerFn:     proposerFn,
+		attackedRounds: attackedRounds,
+		commitRounds:   map[uint64]uint64{},
+	}
+}
+
+func (p *proposerDoSTransport) Connects(from, to pbft.NodeID) bool {
+	return true
+}
+
+func (p *proposerDoSTransport) Gossip(from, to pbft.NodeID, msg *pbft.MessageReq) bool {
+	if msg.View.Round < p.attackedRounds {
+		proposer := p.proposerFn(msg.View)
+		if from == proposer || to == proposer {
+			return false
+		}
+	}
+	if msg.Type == pbft.MessageReq_Commit {
+		p.lock.Lock()
+		if round, ok := p.commitRounds[msg.View.Sequence]; !ok || round < msg.View.Round {
+			p.commitRounds[msg.View.Sequence] = msg.View.Round
+		}
+		p.lock.Unlock()
+	}
+	return true
+}
+
+// commitRound returns the round in which the commit messages of the height were exchanged
+func (p *proposerDoSTransport) commitRound(height uint64) (uint64, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	round, ok := p.commitRounds[height]
+	return round, ok
+}
+
+// floodTransport simulates attacker nodes that flood the network with syntactically
+// valid but useless messages (stale views and random digests). It tracks the highest
+// view gossiped in the network so that the flooded messages look plausible to the peers
+type floodTransport struct {
+	attackers []pbft.NodeID
+	burst     int
+	interval  time.Duration
+
+	lock    sync.Mutex
+	view    *pbft.View
+	flooded uint64
+	closeCh chan struct{}
+}
+
+func newFloodTransport(attackers []string, burst int, interval time.Duration) *floodTransport {
+	f := &floodTransport{
+		attackers: []pbft.NodeID{},
+		burst:     burst,
+		interval:  interval,
+		closeCh:   make(chan struct{}),
+	}
+	for _, a := range attackers {
+		f.attackers = append(f.attackers, pbft.NodeID(a))
+	}
+	return f
+}
+
+func (f *floodTransport) Connects(from, to pbft.NodeID) bool {
+	return true
+}
+
+func (f *floodTransport) Gossip(from, to pbft.NodeID, msg *pbft.MessageReq) bool {
+	f.lock.Lock()
+	if f.view == nil || msg.View.Sequence > f.view.Sequence ||
+		(msg.View.Sequence == f.view.Sequence && msg.View.Round > f.view.Round) {
+		f.view = msg.View.Copy()
+	}
+	f.lock.Unlock()
+	return true
+}
+
+// Start sends a burst of messages from every attacker each interval until Stop is called
+func (f *floodTransport) Start(tt *transport) {
+	go func() {
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				for _, msg := range f.buildBurst() {
+					tt.Gossip(msg)
+				}
+			case <-f.closeCh:
+				return
+			}
+		}
+	}()
+}
+
+func (f *floodTransport) Stop() {
+	close(f.closeCh)
+}
+
+// Flooded returns the number of messages sent by the attackers
+func (f *floodTransport) Flooded() uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.flooded
+}
+
+func (f *floodTransport) buildBurst() []*pbft.MessageReq {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.view == nil {
+		// nothing gossiped yet
+		return nil
+	}
+
+	msgs := []*pbft.MessageReq{}
+	for _, attacker := range f.attackers {
+		for i := 0; i < f.burst; i++ {
+			view := f.view.Copy()
+			if i%2 == 0 && view.Sequence > 1 {
+				// stale view
+				view.Sequence--
+			}
+			msg := &pbft.MessageReq{
+				From: attacker,
+				View: view,
+				Hash: randomDigest(),
+			}
+			switch rand.Intn(3) {
+			case 0:
+				msg.Type = pbft.MessageReq_Prepare
+			case 1:
+				msg.Type = pbft.MessageReq_Commit
+				msg.Seal = randomDigest()
+			default:
+				msg.Type = pbft.MessageReq_RoundChange
+				msg.Hash = nil
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+	f.flooded += uint64(len(msgs))
+	return msgs
+}
+
+func randomDigest() []byte {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return b
+}
@@ -0,0 +1,199 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/pbft-consensus"
+)
+
+type viewKey struct {
+	height uint64
+	round  uint64
+}
+
+// voteLog records the votes of a node (the consensus messages it sent) per view
+type voteLog struct {
+	lock  sync.Mutex
+	votes map[viewKey][]*pbft.MessageReq
+
+	// commits is the time of the first commit sent in every view
+	commits map[viewKey]time.Time
+}
+
+func newVoteLog() *voteLog {
+	return &voteLog{
+		votes:   map[viewKey][]*pbft.MessageReq{},
+		commits: map[viewKey]time.Time{},
+	}
+}
+
+func (v *voteLog) add(msg *pbft.MessageReq) {
+	switch msg.Type {
+	case pbft.MessageReq_Preprepare, pbft.MessageReq_Prepare, pbft.MessageReq_Commit, pbft.MessageReq_RoundChange:
+	default:
+		return
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	key := viewKey{height: msg.View.Sequence, round: msg.View.Round}
+	v.votes[key] = append(v.votes[key], msg.Copy())
+	if _, ok := v.commits[key]; !ok && msg.Type == pbft.MessageReq_Commit {
+		v.commits[key] = time.Now()
+	}
+}
+
+// firstCommitSince returns the lowest view with a commit sent after the given time
+func (v *voteLog) firstCommitSince(since time.Time) (viewKey, time.Time, bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	var first viewKey
+	var at time.Time
+	found := false
+	for key, sent := range v.commits {
+		if sent.Before(since) {
+			continue
+		}
+		if !found || key.height < first.height || (key.height == first.height && key.round < first.round) {
+			first, at, found = key, sent, true
+		}
+	}
+	return first, at, found
+}
+
+// rounds returns the number of rounds the node voted in at the height
+func (v *voteLog) rounds(height uint64) uint64 {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	rounds := uint64(0)
+	for key := range v.votes {
+		if key.height == height && key.round+1 > rounds {
+			rounds = key.round + 1
+		}
+	}
+	return rounds
+}
+
+func (v *voteLog) at(height, round uint64) []*pbft.MessageReq {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return append([]*pbft.MessageReq{}, v.votes[viewKey{height: height, round: round}]...)
+}
+
+// votingTransport records the votes of the node before gossiping them
+type votingTransport struct {
+	*transport
+	votes *voteLog
+}
+
+func (v *votingTransport) Gossip(msg *pbft.MessageReq) error {
+	v.votes.add(msg)
+	return v.transport.Gossip(msg)
+}
+
+// Votes are the votes of the nodes in a view, the digests are hex encoded
+type Votes struct {
+	Height uint64
+	Round  uint64
+
+	// Proposals are the digests proposed per proposer
+	Proposals map[string]string
+
+	// Prepares are the nodes that sent a prepare per digest
+	Prepares map[string][]string
+
+	// Commits are the nodes that sent a commit per digest in the round
+	Commits map[string][]string
+
+	// Locks are the nodes locked per digest in the round. A node is locked on the digest of its
+	// commit in the latest round up to this one, unless it prepared another digest afterwards
+	// (the lock was released)
+	Locks map[string][]string
+
+	// RoundChanges are the nodes that voted to move to the round
+	RoundChanges []string
+}
+
+// Locked returns the nodes locked on the digest in the view
+func (v *Votes) Locked(digest []byte) []string {
+	return v.Locks[hex.EncodeToString(digest)]
+}
+
+// lockAt returns the digest the node is locked on in the round, derived from its votes at the height
+func (v *voteLog) lockAt(height, round uint64) ([]byte, bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	var locked []byte
+	for i := uint64(0); i <= round; i++ {
+		for _, msg := range v.votes[viewKey{height: height, round: i}] {
+			switch msg.Type {
+			case pbft.MessageReq_Commit:
+				locked = msg.Hash
+			case pbft.MessageReq_Prepare:
+				if locked != nil && !bytes.Equal(locked, msg.Hash) {
+					// a locked node only prepares another proposal once its lock is released
+					locked = nil
+				}
+			}
+		}
+	}
+	return locked, locked != nil
+}
+
+// VotesAt aggregates the votes of every node of the cluster in the given view
+func (c *cluster) VotesAt(height, round uint64) *Votes {
+	votes := &Votes{
+		Height:       height,
+		Round:        round,
+		Proposals:    map[string]string{},
+		Prepares:     map[string][]string{},
+		Commits:      map[string][]string{},
+		Locks:        map[string][]string{},
+		RoundChanges: []string{},
+	}
+	for _, n := range c.Nodes() {
+		for _, msg := range n.votes.at(height, round) {
+			digest := hex.EncodeToString(msg.Hash)
+			switch msg.Type {
+			case pbft.MessageReq_Preprepare:
+				votes.Proposals[n.name] = digest
+			case pbft.MessageReq_Prepare:
+				votes.Prepares[digest] = appendUnique(votes.Prepares[digest], n.name)
+			case pbft.MessageReq_Commit:
+				votes.Commits[digest] = appendUnique(votes.Commits[digest], n.name)
+			case pbft.MessageReq_RoundChange:
+				votes.RoundChanges = appendUnique(votes.RoundChanges, n.name)
+			}
+		}
+		if locked, ok := n.votes.lockAt(height, round); ok {
+			digest := hex.EncodeToString(locked)
+			votes.Locks[digest] = appendUnique(votes.Locks[digest], n.name)
+		}
+	}
+	for _, nodes := range votes.Prepares {
+		sort.Strings(nodes)
+	}
+	for _, nodes := range votes.Commits {
+		sort.Strings(nodes)
+	}
+	for _, nodes := range votes.Locks {
+		sort.Strings(nodes)
+	}
+	sort.Strings(votes.RoundChanges)
+	return votes
+}
+
+func appendUnique(nodes []string, node string) []string {
+	if containsString(nodes, node) {
+		return nodes
+	}
+	return append(nodes, node)
+}
@@ -0,0 +1,39 @@
+package pbft
+
+// Event is a notification about a notable condition of the state machine
+type Event interface {
+	// EventName returns the name of the event
+	EventName() string
+}
+
+// EventHandler receives the events emitted by the engine. It is called
+// synchronously from the state machine loop and must not block
+type EventHandler func(Event)
+
+func WithEventHandler(handler EventHandler) ConfigOption {
+	return func(c *Config) {
+		c.EventHandler = handler
+	}
+}
+
+// MaxRoundExceededEvent is emitted when the round goes beyond the configured
+// maximum round and the engine moves to the sync state
+type MaxRoundExceededEvent struct {
+	// View is the view with the round that exceeded the maximum
+	View *View
+
+	// MaxRound is the configured maximum round
+	MaxRound uint64
+}
+
+func (e *MaxRoundExceededEvent) EventName() string {
+	return "MaxRoundExceeded"
+}
+
+// emit sends the event to the event handler, if any
+func (p *Pbft) emit(event Event) {
+	p.logger.Printf("[DEBUG] event: %s", event.EventName())
+	if p.config.EventHandler != nil {
+		p.config.EventHandler(event)
+	}
+}
@@ -0,0 +1,184 @@
+package pbft
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Evidence is the proof that a validator sent two conflicting messages
+// (same type and view but different proposal hash). It can be serialized
+// and verified without a running instance of the engine.
+type Evidence struct {
+	// Offender is the validator that sent the conflicting messages
+	Offender NodeID
+
+	// ChainID is the identifier of the network where the conflict happened
+	ChainID uint64
+
+	// Type is the type of the conflicting messages
+	Type MsgType
+
+	// View is the view of the conflicting messages
+	View *View
+
+	// First is the message received first
+	First *MessageReq
+
+	// Second is the message that conflicts with the first one
+	Second *MessageReq
+}
+
+// MessageVerifier authenticates a message (i.e. checks that it was signed by its sender)
+type MessageVerifier func(msg *MessageReq) error
+
+// NewEvidence builds the evidence out of two conflicting messages
+func NewEvidence(first, second *MessageReq) (*Evidence, error) {
+	e := &Evidence{
+		Offender: first.From,
+		ChainID:  first.ChainID,
+		Type:     first.Type,
+		First:    first.Copy(),
+		Second:   second.Copy(),
+	}
+	if first.View != nil {
+		e.View = first.View.Copy()
+	}
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// UnmarshalEvidence decodes the evidence encoded with Marshal
+func UnmarshalEvidence(data []byte) (*Evidence, error) {
+	e := &Evidence{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Marshal encodes the evidence
+func (e *Evidence) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Verify checks that the evidence is consistent and that both messages
+// are authenticated by the verifier
+func (e *Evidence) Verify(verifier MessageVerifier) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	if verifier == nil {
+		return fmt.Errorf("message verifier not set")
+	}
+	if err := verifier(e.First); err != nil {
+		return fmt.Errorf("failed to verify first message: %v", err)
+	}
+	if err := verifier(e.Second); err != nil {
+		return fmt.Errorf("failed to verify second message: %v", err)
+	}
+	return nil
+}
+
+// validate checks that both messages are from the offender, match the
+// evidence metadata and conflict with each other
+func (e *Evidence) validate() error {
+	if e.First == nil || e.Second == nil || e.View == nil {
+		return fmt.Errorf("evidence is incomplete")
+	}
+	for _, msg := range []*MessageReq{e.First, e.Second} {
+		if msg.From != e.Offender {
+			return fmt.Errorf("message from %s does not belong to the offender %s", msg.From, e.Offender)
+		}
+		if msg.Type != e.Type {
+			return fmt.Errorf("message type %s does not match evidence type %s", msg.Type, e.Type)
+		}
+		if msg.ChainID != e.ChainID {
+			return fmt.Errorf("message chain %d does not match evidence chain %d", msg.ChainID, e.ChainID)
+		}
+		if msg.View == nil || cmpView(msg.View, e.View) != 0 || msg.View.Term != e.View.Term {
+			return fmt.Errorf("message view does not match evidence view %s", e.View)
+		}
+	}
+	if bytes.Equal(e.First.Hash, e.Second.Hash) {
+		return fmt.Errorf("messages do not conflict")
+	}
+	return nil
+}
+
+// evidencePool stores the evidence collected by the engine
+type evidencePool struct {
+	lock     sync.Mutex
+	evidence []*Evidence
+
+	// size is the estimated memory of the evidence
+	size uint64
+
+	// limit is the maximum size of the evidence, zero if unbounded (see WithMemoryBudget)
+	limit uint64
+}
+
+func newEvidencePool() *evidencePool {
+	return &evidencePool{
+		evidence: []*Evidence{},
+	}
+}
+
+// add adds a new evidence to the pool. If the pool is over its limit,
+// it drops the oldest evidence and returns the number of dropped ones
+func (e *evidencePool) add(evidence *Evidence) int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.evidence = append(e.evidence, evidence)
+	e.size += evidenceSize(evidence)
+
+	dropped := 0
+	for e.limit != 0 && e.size > e.limit && len(e.evidence) > 0 {
+		e.size -= evidenceSize(e.evidence[0])
+		e.evidence[0] = nil
+		e.evidence = e.evidence[1:]
+		dropped++
+	}
+	return dropped
+}
+
+// prune removes the evidence of the heights below the sequence
+func (e *evidencePool) prune(sequence uint64) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	kept := []*Evidence{}
+	for _, evidence := range e.evidence {
+		if evidence.View.Sequence < sequence {
+			e.size -= evidenceSize(evidence)
+			continue
+		}
+		kept = append(kept, evidence)
+	}
+	e.evidence = kept
+}
+
+// evidenceSize returns the estimated memory retained by the evidence
+func evidenceSize(evidence *Evidence) uint64 {
+	return msgSize(evidence.First) + msgSize(evidence.Second)
+}
+
+// memory returns the estimated memory of the evidence
+func (e *evidencePool) memory() uint64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.size
+}
+
+// list returns the evidence stored in the pool
+func (e *evidencePool) list() []*Evidence {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return append([]*Evidence{}, e.evidence...)
+}
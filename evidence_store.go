@@ -0,0 +1,88 @@
+package pbft
+
+import "fmt"
+
+// WithEvidenceStore backs the evidence pool with a store, so that the evidence collected right
+// before a crash is not lost before the chain includes it. The store has the same interface as
+// the store of the inbound messages (see MessageStore): the engine puts the two conflicting
+// messages of every evidence and rebuilds the pool out of them on start. It must not be the
+// store of WithMessageStore. The evidence is pruned with PruneEvidence once reported on-chain
+func WithEvidenceStore(store MessageStore) ConfigOption {
+	return func(c *Config) {
+		c.EvidenceStore = store
+	}
+}
+
+// storeEvidence puts the conflicting messages of the evidence in the evidence store, if any.
+// A failure of the store does not affect the consensus
+func (p *Pbft) storeEvidence(evidence *Evidence) {
+	store := p.config.EvidenceStore
+	if store == nil {
+		return
+	}
+	for _, msg := range []*MessageReq{evidence.First, evidence.Second} {
+		if err := store.Put(msg); err != nil {
+			p.logger.Printf("[ERROR] failed to store evidence: offender=%s, view=%s, err=%v", evidence.Offender, evidence.View, err)
+			return
+		}
+	}
+}
+
+// loadEvidence rebuilds the evidence pool out of the messages of the evidence store, if any
+func (p *Pbft) loadEvidence() {
+	store := p.config.EvidenceStore
+	if store == nil {
+		return
+	}
+	msgs, err := store.Messages()
+	if err != nil {
+		p.logger.Printf("[ERROR] failed to load stored evidence: %v", err)
+		return
+	}
+	evidence := evidenceFromMessages(msgs)
+	for _, e := range evidence {
+		p.evidence.add(e)
+	}
+	if len(evidence) != 0 {
+		p.logger.Printf("[INFO] loaded stored evidence: evidence=%d", len(evidence))
+	}
+}
+
+// evidenceFromMessages pairs the conflicting messages in the order they were stored: the first
+// message of every sender, type and view conflicts with each of the following different ones
+func evidenceFromMessages(msgs []*MessageReq) []*Evidence {
+	firsts := map[string]*MessageReq{}
+	seen := map[string]struct{}{}
+	evidence := []*Evidence{}
+	for _, msg := range msgs {
+		if msg.View == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s/%d/%d/%d/%d", msg.From, msg.Type, msg.View.Sequence, msg.View.Round, msg.View.Term)
+		hashKey := fmt.Sprintf("%s/%x", key, msg.Hash)
+		if _, ok := seen[hashKey]; ok {
+			continue
+		}
+		seen[hashKey] = struct{}{}
+
+		first, ok := firsts[key]
+		if !ok {
+			firsts[key] = msg
+			continue
+		}
+		if e, err := NewEvidence(first, msg); err == nil {
+			evidence = append(evidence, e)
+		}
+	}
+	return evidence
+}
+
+// PruneEvidence removes the evidence of the heights below the sequence from the pool and
+// the evidence store, i.e. once the chain included it
+func (p *Pbft) PruneEvidence(sequence uint64) error {
+	p.evidence.prune(sequence)
+	if store := p.config.EvidenceStore; store != nil {
+		return store.Prune(sequence)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvidenceStore_ResumeAfterRestart(t *testing.T) {
+	store := NewMemoryMessageStore()
+
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.EvidenceStore = store
+	m.setState(ValidateState)
+
+	first, second := conflictingCommits("B")
+	m.emitMsg(first)
+	m.emitMsg(second)
+	m.runCycle(context.Background())
+	require.Len(t, m.Evidence(), 1)
+
+	// the node restarts with the same store
+	restarted := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	restarted.config.EvidenceStore = store
+	restarted.loadEvidence()
+
+	evidence := restarted.Evidence()
+	require.Len(t, evidence, 1)
+	assert.Equal(t, m.Evidence(), evidence)
+	assert.NoError(t, evidence[0].Verify(sealVerifier))
+
+	// the evidence reported on-chain is pruned
+	require.NoError(t, restarted.PruneEvidence(2))
+	assert.Empty(t, restarted.Evidence())
+	msgs, err := store.Messages()
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+}
+
+func TestEvidenceFromMessages(t *testing.T) {
+	first, second := conflictingCommits("B")
+	third := second.Copy()
+	third.Hash = []byte{0x3}
+	other := first.Copy()
+	other.From = "C"
+
+	// the duplicates are ignored and the first message conflicts with each of the following ones
+	evidence := evidenceFromMessages([]*MessageReq{first, other, second, first, second, third})
+	require.Len(t, evidence, 2)
+	assert.Equal(t, first, evidence[0].First)
+	assert.Equal(t, second, evidence[0].Second)
+	assert.Equal(t, first, evidence[1].First)
+	assert.Equal(t, third, evidence[1].Second)
+}
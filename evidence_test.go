@@ -0,0 +1,89 @@
+package pbft
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func conflictingCommits(from string) (*MessageReq, *MessageReq) {
+	first := &MessageReq{
+		From: NodeID(from),
+		Type: MessageReq_Commit,
+		View: ViewMsg(1, 0),
+		Hash: digest,
+		Seal: digest,
+	}
+	second := first.Copy()
+	second.Hash = digest1
+	second.Seal = digest1
+	return first, second
+}
+
+// commits are verified by checking that the seal signs the hash (see testerAccount.Sign)
+func sealVerifier(msg *MessageReq) error {
+	if !bytes.Equal(msg.Seal, msg.Hash) {
+		return errors.New("invalid seal")
+	}
+	return nil
+}
+
+func TestEvidence_MarshalVerify(t *testing.T) {
+	first, second := conflictingCommits("A")
+
+	evidence, err := NewEvidence(first, second)
+	require.NoError(t, err)
+
+	data, err := evidence.Marshal()
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalEvidence(data)
+	require.NoError(t, err)
+	assert.Equal(t, evidence, decoded)
+	assert.NoError(t, decoded.Verify(sealVerifier))
+
+	// a tampered seal does not pass the verification
+	decoded.Second.Seal = []byte{0x9}
+	assert.Error(t, decoded.Verify(sealVerifier))
+	assert.Error(t, decoded.Verify(nil))
+}
+
+func TestEvidence_NotConflicting(t *testing.T) {
+	first, second := conflictingCommits("A")
+
+	// same hash
+	_, err := NewEvidence(first, first.Copy())
+	assert.Error(t, err)
+
+	// different senders
+	second.From = "B"
+	_, err = NewEvidence(first, second)
+	assert.Error(t, err)
+
+	// different views
+	second.From = "A"
+	second.View = ViewMsg(1, 1)
+	_, err = NewEvidence(first, second)
+	assert.Error(t, err)
+}
+
+func TestTransition_ValidateState_Equivocation(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.state.view = ViewMsg(1, 0)
+	m.setState(ValidateState)
+
+	first, second := conflictingCommits("B")
+	m.emitMsg(first)
+	m.emitMsg(second)
+
+	m.runCycle(context.Background())
+
+	evidence := m.Evidence()
+	require.Len(t, evidence, 1)
+	assert.Equal(t, NodeID("B"), evidence[0].Offender)
+	assert.NoError(t, evidence[0].Verify(sealVerifier))
+}
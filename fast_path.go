@@ -0,0 +1,45 @@
+package pbft
+
+// fastPath checks whether the fast path applies to the round
+func (p *Pbft) fastPath() bool {
+	return p.config.FastPath && p.state.view.Round == 0
+}
+
+// sealPrepare adds the commit seal to the prepare message on the fast path. The prepare counts as
+// a commit message for the validators, hence the node locks on the proposal like when it commits
+func (p *Pbft) sealPrepare(msg *MessageReq) {
+	if !p.fastPath() {
+		return
+	}
+	seal, err := p.validator.Sign(msg.Hash)
+	if err != nil {
+		p.logger.Printf("[ERROR] failed to seal the prepare message. Error message: %v", err)
+		return
+	}
+	msg.Seal = seal
+	p.state.lock()
+}
+
+// addFastCommit adds the sealed prepare message of the fast path as a commit message, so that
+// the prepare quorum also reaches the commit quorum. It returns the error if the backend panicked
+func (p *Pbft) addFastCommit(msg *MessageReq) error {
+	if !p.fastPath() || len(msg.Seal) == 0 {
+		return nil
+	}
+	var commitErr error
+	if err := p.guard("ValidateCommit", func() { commitErr = p.backend.ValidateCommit(msg.From, msg.Seal) }); err != nil {
+		return err
+	}
+	if commitErr != nil {
+		p.logger.Printf("[ERROR]: failed to validate prepare seal: from=%s, err=%v", msg.From, commitErr)
+		return nil
+	}
+	p.state.addCommitted(&MessageReq{
+		Type: MessageReq_Commit,
+		From: msg.From,
+		Seal: msg.Seal,
+		View: msg.View.Copy(),
+		Hash: msg.Hash,
+	})
+	return nil
+}
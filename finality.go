@@ -0,0 +1,161 @@
+package pbft
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/0xPolygon/pbft-consensus/validation"
+)
+
+// CommittedSeal is the commit seal of a proposal along with its signer
+type CommittedSeal struct {
+	// Signer is the validator that sealed the proposal
+	Signer NodeID
+
+	// Seal is the signature of the proposal hash
+	Seal []byte
+}
+
+// FinalityProof proves that a proposal has been finalized at a given height
+// by a quorum of commit seals
+type FinalityProof struct {
+	// Number is the height of the finalized proposal
+	Number uint64
+
+	// Proposal is the finalized proposal
+	Proposal *Proposal
+
+	// Proposer is the validator that proposed the proposal
+	Proposer NodeID
+
+	// Seals are the commit seals of the validators
+	Seals []CommittedSeal
+}
+
+// CommitSealBackend is an optional interface implemented by the backends that verify a commit
+// seal against the hash it signs. ValidateCommit does not receive the hash, it relies on the
+// proposal of the current round, so the finality proofs are only accepted by these backends
+type CommitSealBackend interface {
+	// VerifyCommitSeal verifies that the seal is the commit seal of the hash by the validator
+	VerifyCommitSeal(from NodeID, hash, seal []byte) error
+}
+
+var (
+	errUnboundSeals         = fmt.Errorf("the backend does not verify the commit seals against the hash")
+	errFinalityProofMissing = fmt.Errorf("finality proof is missing")
+)
+
+// Verify checks that the proof has a quorum of valid commit seals of the proposal hash from
+// distinct members of the validator set. verifySeal checks a single seal against the hash
+func (f *FinalityProof) Verify(validators ValidatorSet, verifySeal func(from NodeID, hash, seal []byte) error) error {
+	return f.verify(validators, signersQuorum(validators, QuorumSize(validators.Len())), verifySeal)
+}
+
+func (f *FinalityProof) verify(validators ValidatorSet, quorum func(signers []NodeID) error, verifySeal func(from NodeID, hash, seal []byte) error) error {
+	if f.Proposal == nil || f.Proposal.Hash == nil {
+		return fmt.Errorf("proof without proposal")
+	}
+	isValidator := func(signer string) bool {
+		return validators.Includes(NodeID(signer))
+	}
+	verify := func(digest []byte, signer string, seal []byte) error {
+		return verifySeal(NodeID(signer), digest, seal)
+	}
+	signers, err := validation.VerifySeals(f.Proposal.Hash, f.Certificate().Seals, isValidator, verify)
+	if err != nil {
+		return err
+	}
+	ids := make([]NodeID, len(signers))
+	for i, signer := range signers {
+		ids[i] = NodeID(signer)
+	}
+	return quorum(ids)
+}
+
+// Certificate returns the proof as a certificate of the validation package, to be verified
+// by the clients that do not run the engine
+func (f *FinalityProof) Certificate() *validation.Certificate {
+	cert := &validation.Certificate{
+		Seals: make([]validation.Seal, len(f.Seals)),
+	}
+	if f.Proposal != nil {
+		cert.Digest = append([]byte{}, f.Proposal.Hash...)
+	}
+	for i, seal := range f.Seals {
+		cert.Seals[i] = validation.Seal{
+			Signer: string(seal.Signer),
+			Seal:   append([]byte{}, seal.Seal...),
+		}
+	}
+	return cert
+}
+
+// SealedProposal returns the sealed proposal certified by the proof. The proof
+// does not carry the round, so the round of the sealed proposal is zero
+func (f *FinalityProof) SealedProposal() *SealedProposal {
+	seals := make([][]byte, len(f.Seals))
+	for i, seal := range f.Seals {
+		seals[i] = append([]byte{}, seal.Seal...)
+	}
+	return &SealedProposal{
+		Proposal:       f.Proposal.Copy(),
+		CommittedSeals: seals,
+		Proposer:       f.Proposer,
+		Number:         f.Number,
+	}
+}
+
+// CatchUp validates the finality proof of the current height against the validator set of
+// the backend, inserts the finalized proposal and moves the engine to the next height without
+// going through the sync state. The backend must implement CommitSealBackend. It must be
+// called after SetBackend and before Run
+func (p *Pbft) CatchUp(proof *FinalityProof) error {
+	if !atomic.CompareAndSwapUint64(&p.running, runIdle, runResetting) {
+		return fmt.Errorf("cannot catch up while running")
+	}
+	defer atomic.StoreUint64(&p.running, runIdle)
+
+	if proof == nil {
+		return errFinalityProofMissing
+	}
+	if p.getBackend() == nil || p.state.getView() == nil {
+		return errBackendNotSet
+	}
+	// the validator set of the backend is the one of the current height only
+	if proof.Number != p.state.view.Sequence {
+		return fmt.Errorf("proof for another height: current=%d, proof=%d", p.state.view.Sequence, proof.Number)
+	}
+	backend, ok := p.backend.(CommitSealBackend)
+	if !ok {
+		return errUnboundSeals
+	}
+	var verifyErr, validateErr, insertErr error
+	if err := p.guard("VerifyCommitSeal", func() {
+		verifyErr = proof.verify(p.state.validators, signersQuorum(p.state.validators, p.state.NumValid()+1), backend.VerifyCommitSeal)
+	}); err != nil {
+		return err
+	}
+	if verifyErr != nil {
+		return verifyErr
+	}
+	// the seals only certify the hash, the backend checks that it belongs to the proposal
+	if err := p.guard("Validate", func() { validateErr = p.backend.Validate(proof.Proposal) }); err != nil {
+		return err
+	}
+	if validateErr != nil {
+		return fmt.Errorf("invalid proposal in the proof: %v", validateErr)
+	}
+	if err := p.guard("Insert", func() { insertErr = p.backend.Insert(proof.SealedProposal()) }); err != nil {
+		return err
+	}
+	if insertErr != nil {
+		return insertErr
+	}
+
+	p.logger.Printf("[INFO] caught up from finality proof: height=%d", proof.Number)
+	p.notifyFinalized(proof.Proposal.Hash)
+	p.health.progress()
+	p.state.unlock()
+	p.setSequence(proof.Number + 1)
+	return nil
+}
@@ -0,0 +1,93 @@
+package pbft
+
+import (
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// defaultFinalityHistory is the number of heights kept in the finality history by default
+const defaultFinalityHistory = 128
+
+// WithFinalityHistory sets the number of finalized heights kept in the finality history.
+// Zero disables the history
+func WithFinalityHistory(heights int) ConfigOption {
+	return func(c *Config) {
+		c.FinalityHistory = heights
+	}
+}
+
+// HeightFinality is how the node finalized a height
+type HeightFinality struct {
+	// Height is the finalized height
+	Height uint64
+
+	// Rounds is the number of rounds it took to finalize the height
+	Rounds uint64
+
+	// Duration is the time since the node started the height
+	Duration time.Duration
+}
+
+// HeightFinalizedEvent is emitted when the node finalizes a height, to export
+// the rounds to finality and the duration of the heights as metrics
+type HeightFinalizedEvent struct {
+	HeightFinality
+}
+
+func (e *HeightFinalizedEvent) EventName() string {
+	return "HeightFinalized"
+}
+
+// finalityHistory keeps the finality of the last heights
+type finalityHistory struct {
+	lock    sync.Mutex
+	size    int
+	entries []HeightFinality
+}
+
+func newFinalityHistory(size int) *finalityHistory {
+	return &finalityHistory{size: size}
+}
+
+func (f *finalityHistory) add(entry HeightFinality) {
+	if f.size <= 0 {
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.entries = append(f.entries, entry)
+	if len(f.entries) > f.size {
+		f.entries = append([]HeightFinality{}, f.entries[len(f.entries)-f.size:]...)
+	}
+}
+
+func (f *finalityHistory) snapshot() []HeightFinality {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]HeightFinality{}, f.entries...)
+}
+
+// FinalityHistory returns how the last heights were finalized by the node, oldest first
+// (see WithFinalityHistory). It is safe for concurrent use
+func (p *Pbft) FinalityHistory() []HeightFinality {
+	return p.finality.snapshot()
+}
+
+// recordFinality records the finality of the current height
+func (p *Pbft) recordFinality() {
+	entry := HeightFinality{
+		Height:   p.state.view.Sequence,
+		Rounds:   p.state.view.Round + 1,
+		Duration: time.Since(p.heightStart),
+	}
+	p.finality.add(entry)
+	if p.round != nil {
+		p.round.span.SetAttributes(attribute.Int64("rounds_to_finality", int64(entry.Rounds)))
+	}
+	p.emit(&HeightFinalizedEvent{HeightFinality: entry})
+}
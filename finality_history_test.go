@@ -0,0 +1,52 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFinalityHistory_Window(t *testing.T) {
+	f := newFinalityHistory(2)
+	for height := uint64(1); height <= 3; height++ {
+		f.add(HeightFinality{Height: height, Rounds: height})
+	}
+	assert.Equal(t, []HeightFinality{{Height: 2, Rounds: 2}, {Height: 3, Rounds: 3}}, f.snapshot())
+
+	disabled := newFinalityHistory(0)
+	disabled.add(HeightFinality{Height: 1})
+	assert.Empty(t, disabled.snapshot())
+}
+
+func TestPbft_FinalityHistory(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var finalized []*HeightFinalizedEvent
+	m.config.EventHandler = func(e Event) {
+		if evnt, ok := e.(*HeightFinalizedEvent); ok {
+			finalized = append(finalized, evnt)
+		}
+	}
+	assert.Empty(t, m.FinalityHistory())
+
+	// the height is finalized in the third round
+	m.state.setRound(2)
+	m.state.proposer = "A"
+	m.setState(CommitState)
+	m.runCycle(context.Background())
+	assert.True(t, m.IsState(DoneState))
+
+	history := m.FinalityHistory()
+	assert.Len(t, history, 1)
+	assert.Equal(t, uint64(1), history[0].Height)
+	assert.Equal(t, uint64(3), history[0].Rounds)
+	assert.Greater(t, int64(history[0].Duration), int64(0))
+
+	assert.Len(t, finalized, 1)
+	assert.Equal(t, history[0], finalized[0].HeightFinality)
+
+	// the next height starts its own clock
+	start := m.heightStart
+	m.setSequence(2)
+	assert.True(t, m.heightStart.After(start))
+}
@@ -0,0 +1,124 @@
+package pbft
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFinalityProof(number uint64, signers ...NodeID) *FinalityProof {
+	proof := &FinalityProof{
+		Number: number,
+		Proposal: &Proposal{
+			Data: mockProposal,
+			Hash: digest,
+		},
+		Proposer: "A",
+	}
+	for _, signer := range signers {
+		proof.Seals = append(proof.Seals, CommittedSeal{Signer: signer, Seal: digest})
+	}
+	return proof
+}
+
+func TestFinalityProof_Verify(t *testing.T) {
+	validators := newMockValidatorSet([]string{"A", "B", "C", "D"})
+	validateCommit := func(from NodeID, hash, seal []byte) error {
+		if !bytes.Equal(hash, seal) {
+			return errors.New("seal of another hash")
+		}
+		return nil
+	}
+
+	assert.NoError(t, newFinalityProof(5, "A", "B", "C").Verify(validators, validateCommit))
+
+	// not enough seals
+	assert.Error(t, newFinalityProof(5, "A", "B").Verify(validators, validateCommit))
+	// duplicated seals
+	assert.Error(t, newFinalityProof(5, "A", "B", "B").Verify(validators, validateCommit))
+	// seal from a non validator
+	assert.Error(t, newFinalityProof(5, "A", "B", "E").Verify(validators, validateCommit))
+	// invalid seal
+	assert.Error(t, newFinalityProof(5, "A", "B", "C").Verify(validators, func(from NodeID, hash, seal []byte) error {
+		return errors.New("invalid")
+	}))
+	// seals of another proposal
+	proof := newFinalityProof(5, "A", "B", "C")
+	proof.Proposal.Hash = digest1
+	assert.Error(t, proof.Verify(validators, validateCommit))
+}
+
+func TestFinalityProof_Certificate(t *testing.T) {
+	proof := newFinalityProof(5, "A", "B", "C")
+	verify := func(d []byte, signer string, seal []byte) error {
+		if !bytes.Equal(d, digest) || !bytes.Equal(seal, digest) {
+			return errors.New("invalid")
+		}
+		return nil
+	}
+
+	// the proof is verified without the engine
+	cert := proof.Certificate()
+	assert.NoError(t, cert.Verify([]string{"A", "B", "C", "D"}, verify))
+	assert.Error(t, cert.Verify([]string{"A", "B", "C", "D", "E", "F", "G"}, verify))
+
+	// the certificate does not share the seals of the proof
+	cert.Seals[0].Seal[0]++
+	assert.Equal(t, digest, proof.Seals[0].Seal)
+}
+
+// unboundSealBackend does not verify the commit seals against the hash
+type unboundSealBackend struct {
+	Backend
+}
+
+func TestPbft_CatchUp(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.state.view = ViewMsg(5, 0)
+
+	// old and future heights, whose validators may differ from the current ones
+	assert.Error(t, m.CatchUp(newFinalityProof(4, "A", "B", "C")))
+	assert.Error(t, m.CatchUp(newFinalityProof(6, "A", "B", "C")))
+
+	// invalid proof
+	assert.Error(t, m.CatchUp(newFinalityProof(5, "A")))
+
+	// seals of another proposal
+	proof := newFinalityProof(5, "A", "B", "C")
+	proof.Proposal.Hash = digest1
+	assert.Error(t, m.CatchUp(proof))
+
+	// proposal rejected by the backend
+	m.backend.(*mockBackend).validateFn = func(proposal *Proposal) error {
+		return errors.New("invalid proposal")
+	}
+	assert.Error(t, m.CatchUp(newFinalityProof(5, "A", "B", "C")))
+	m.backend.(*mockBackend).validateFn = nil
+
+	// the seals cannot be bound to the hash
+	backend := m.backend
+	m.backend = &unboundSealBackend{Backend: backend}
+	assert.ErrorIs(t, m.CatchUp(newFinalityProof(5, "A", "B", "C")), errUnboundSeals)
+	m.backend = backend
+	assert.Equal(t, uint64(5), m.state.view.Sequence)
+
+	require.NoError(t, m.CatchUp(newFinalityProof(5, "A", "B", "C")))
+	assert.Equal(t, ViewMsg(6, 0), m.state.view)
+}
+
+func TestPbft_CatchUp_NotSet(t *testing.T) {
+	pool := newTesterAccountPool()
+	pool.add("A")
+	p := New(pool.get("A"), nil, WithLogger(log.New(io.Discard, "", 0)))
+
+	assert.ErrorIs(t, p.CatchUp(nil), errFinalityProofMissing)
+	assert.ErrorIs(t, p.CatchUp(newFinalityProof(1, "A")), errBackendNotSet)
+
+	WithFinalityGadget(true)(p.config)
+	assert.ErrorIs(t, p.SubmitCandidate(1, digest), errBackendNotSet)
+}
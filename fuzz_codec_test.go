@@ -0,0 +1,251 @@
+package pbft
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// messageGenerator builds random messages covering every field of the public types
+type messageGenerator struct {
+	r *rand.Rand
+}
+
+func (g *messageGenerator) bytes(max int) []byte {
+	switch g.r.Intn(4) {
+	case 0:
+		return nil
+	case 1:
+		return []byte{}
+	}
+	b := make([]byte, 1+g.r.Intn(max))
+	g.r.Read(b)
+	return b
+}
+
+// nodeID returns a valid utf8 id, including multi byte runes
+func (g *messageGenerator) nodeID() NodeID {
+	runes := []rune{'A', 'z', '0', 'é', '世', '🙂', 0}
+	id := make([]rune, g.r.Intn(8))
+	for i := range id {
+		id[i] = runes[g.r.Intn(len(runes))]
+	}
+	return NodeID(string(id))
+}
+
+func (g *messageGenerator) uint64() uint64 {
+	if g.r.Intn(2) == 0 {
+		return uint64(g.r.Intn(10))
+	}
+	return g.r.Uint64()
+}
+
+func (g *messageGenerator) view() *View {
+	return &View{Sequence: g.uint64(), Round: g.uint64(), Term: g.uint64()}
+}
+
+// message returns a random message, all the fields are set if full is true
+func (g *messageGenerator) message(full bool) *MessageReq {
+	msg := &MessageReq{
+		Type:              MsgType(g.r.Intn(8)),
+		From:              g.nodeID(),
+		Seal:              g.bytes(96),
+		View:              g.view(),
+		Hash:              g.bytes(32),
+		Proposal:          g.bytes(512),
+		ChainID:           g.uint64(),
+		ChunkCount:        g.r.Uint32(),
+		ChunkIndex:        g.r.Uint32(),
+		RoundChangeReason: RoundChangeReason(g.r.Intn(6)),
+	}
+	for i := g.r.Intn(5); i > 0; i-- {
+		msg.CommittedSeals = append(msg.CommittedSeals, CommittedSeal{Signer: g.nodeID(), Seal: g.bytes(96)})
+	}
+	if !full {
+		if g.r.Intn(8) == 0 {
+			// the codecs do not validate the type
+			msg.Type = MsgType(g.r.Int63())
+		}
+		return msg
+	}
+	msg.Type = MsgType(1 + g.r.Intn(7))
+	msg.From = NodeID("From" + string(msg.From))
+	msg.Seal = append([]byte{0x1}, msg.Seal...)
+	msg.View.Sequence++
+	msg.Hash = append([]byte{0x1}, msg.Hash...)
+	msg.Proposal = append([]byte{0x1}, msg.Proposal...)
+	msg.ChainID |= 1
+	msg.ChunkCount |= 1
+	msg.ChunkIndex |= 1
+	msg.RoundChangeReason = RoundChangeTimeout
+	msg.CommittedSeals = append(msg.CommittedSeals, CommittedSeal{Signer: "B", Seal: []byte{0x1}})
+	return msg
+}
+
+func (g *messageGenerator) proposal() *Proposal {
+	zone := time.FixedZone("", (g.r.Intn(48)-24)*30*60)
+	proposal := &Proposal{
+		Data: g.bytes(512),
+		Time: time.Unix(g.r.Int63n(1<<34), g.r.Int63n(1e9)).In(zone),
+		Hash: g.bytes(32),
+	}
+	if g.r.Intn(2) == 0 {
+		maxRound := g.uint64()
+		proposal.MaxRound = &maxRound
+	}
+	return proposal
+}
+
+func TestFuzz_MessageFieldsCovered(t *testing.T) {
+	g := &messageGenerator{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+	// a new field of the message must be added to the generator, the codecs and Copy
+	msg := g.message(true)
+	value := reflect.ValueOf(msg).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		assert.False(t, value.Field(i).IsZero(), "field %s is not covered", value.Type().Field(i).Name)
+	}
+	assert.Equal(t, msg, msg.Copy())
+
+	expected := canonicalMessage(msg)
+	for _, codec := range []Codec{JSONCodec{}, ProtoCodec{}} {
+		data, err := codec.Encode(msg)
+		assert.NoError(t, err, codec.Name())
+
+		decoded, err := codec.Decode(data)
+		assert.NoError(t, err, codec.Name())
+		assert.Equal(t, expected, decoded, codec.Name())
+	}
+}
+
+// TestFuzz_CodecRoundTrip checks that the codecs decode every encoded message to its canonical form
+// and agree with each other. The number of iterations and the seed are set with FUZZ_ITERATIONS and FUZZ_SEED
+func TestFuzz_CodecRoundTrip(t *testing.T) {
+	iterations := fuzzEnvInt(t, "FUZZ_ITERATIONS", 1000)
+	seed := fuzzEnvInt(t, "FUZZ_SEED", time.Now().UnixNano())
+	t.Logf("seed=%d, iterations=%d", seed, iterations)
+
+	g := &messageGenerator{r: rand.New(rand.NewSource(seed))}
+	codecs := []Codec{JSONCodec{}, ProtoCodec{}}
+
+	for i := int64(0); i < iterations; i++ {
+		msg := g.message(g.r.Intn(2) == 0)
+		expected := canonicalMessage(msg)
+		if !assert.Equal(t, msg, msg.Copy(), "copy (seed=%d, iteration=%d)", seed, i) {
+			return
+		}
+
+		for _, codec := range codecs {
+			data, err := codec.Encode(msg)
+			if !assert.NoError(t, err, "%s (seed=%d, iteration=%d)", codec.Name(), seed, i) {
+				return
+			}
+			decoded, err := codec.Decode(data)
+			if !assert.NoError(t, err, "%s (seed=%d, iteration=%d)", codec.Name(), seed, i) {
+				return
+			}
+			if !assert.Equal(t, expected, decoded, "%s (seed=%d, iteration=%d)", codec.Name(), seed, i) {
+				return
+			}
+
+			// the canonical message has a stable encoding
+			canonical, err := codec.Encode(expected)
+			assert.NoError(t, err)
+			again, err := codec.Encode(decoded)
+			assert.NoError(t, err)
+			if !assert.Equal(t, canonical, again, "%s (seed=%d, iteration=%d)", codec.Name(), seed, i) {
+				return
+			}
+		}
+	}
+}
+
+// TestFuzz_ProtoDecode feeds corrupted encodings to the protobuf codec. The accepted
+// inputs must be canonical: encoding the decoded message returns the same bytes
+func TestFuzz_ProtoDecode(t *testing.T) {
+	iterations := fuzzEnvInt(t, "FUZZ_ITERATIONS", 1000)
+	seed := fuzzEnvInt(t, "FUZZ_SEED", time.Now().UnixNano())
+	t.Logf("seed=%d, iterations=%d", seed, iterations)
+
+	g := &messageGenerator{r: rand.New(rand.NewSource(seed))}
+	codec := ProtoCodec{}
+
+	for i := int64(0); i < iterations; i++ {
+		data, err := codec.Encode(g.message(false))
+		assert.NoError(t, err)
+
+		for j := g.r.Intn(3); j >= 0; j-- {
+			switch g.r.Intn(3) {
+			case 0:
+				data[g.r.Intn(len(data))] = byte(g.r.Intn(256))
+			case 1:
+				data = data[:g.r.Intn(len(data))]
+			case 2:
+				data = append(data, g.bytes(16)...)
+			}
+			if len(data) == 0 {
+				break
+			}
+		}
+
+		decoded, err := codec.Decode(data)
+		if err != nil {
+			continue
+		}
+		again, err := codec.Encode(decoded)
+		assert.NoError(t, err)
+		if !assert.Equal(t, data, again, "seed=%d, iteration=%d", seed, i) {
+			return
+		}
+	}
+}
+
+// TestFuzz_JSONRoundTrip checks the json encoding of the public types other than the message.
+// The time of the proposal keeps the instant but not the location nor the monotonic clock
+func TestFuzz_JSONRoundTrip(t *testing.T) {
+	iterations := fuzzEnvInt(t, "FUZZ_ITERATIONS", 1000)
+	seed := fuzzEnvInt(t, "FUZZ_SEED", time.Now().UnixNano())
+	t.Logf("seed=%d, iterations=%d", seed, iterations)
+
+	g := &messageGenerator{r: rand.New(rand.NewSource(seed))}
+
+	roundTrip := func(in, out interface{}) {
+		data, err := json.Marshal(in)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(data, out))
+	}
+
+	for i := int64(0); i < iterations; i++ {
+		view := g.view()
+		decodedView := &View{}
+		roundTrip(view, decodedView)
+		assert.Equal(t, view, decodedView)
+
+		proposal := g.proposal()
+		assert.Equal(t, proposal, proposal.Copy())
+
+		sealed := &SealedProposal{
+			Proposal: proposal,
+			Proposer: g.nodeID(),
+			Number:   g.uint64(),
+			Round:    g.uint64(),
+		}
+		for j := g.r.Intn(4); j > 0; j-- {
+			sealed.CommittedSeals = append(sealed.CommittedSeals, g.bytes(96))
+		}
+		decodedSealed := &SealedProposal{}
+		roundTrip(sealed, decodedSealed)
+
+		if !assert.True(t, proposal.Time.Equal(decodedSealed.Proposal.Time), "seed=%d, iteration=%d", seed, i) {
+			return
+		}
+		decodedSealed.Proposal.Time = proposal.Time
+		if !assert.Equal(t, sealed, decodedSealed, "seed=%d, iteration=%d", seed, i) {
+			return
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package pbft
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// messageMutator builds structurally mutated messages from valid ones
+type messageMutator struct {
+	r          *rand.Rand
+	validators []NodeID
+}
+
+func (m *messageMutator) randomBytes(max int) []byte {
+	if m.r.Intn(4) == 0 {
+		return nil
+	}
+	b := make([]byte, m.r.Intn(max+1))
+	m.r.Read(b)
+	return b
+}
+
+func (m *messageMutator) randomUint64(near uint64) uint64 {
+	switch m.r.Intn(4) {
+	case 0:
+		return near
+	case 1:
+		return near + uint64(m.r.Intn(3))
+	case 2:
+		if near > 0 {
+			return near - 1
+		}
+		return 0
+	default:
+		return m.r.Uint64()
+	}
+}
+
+// base returns a valid message for the view
+func (m *messageMutator) base(view *View) *MessageReq {
+	msg := &MessageReq{
+		Type: MsgType(m.r.Intn(6)),
+		From: m.validators[m.r.Intn(len(m.validators))],
+		View: view.Copy(),
+		Hash: digest,
+		Seal: []byte{0x1},
+	}
+	switch msg.Type {
+	case MessageReq_Preprepare:
+		msg.Proposal = mockProposal
+	case MessageReq_ProposalChunk:
+		msg.Proposal = mockProposal
+		msg.ChunkCount = 1
+	case MessageReq_Committed:
+		msg.CommittedSeals = []CommittedSeal{{Signer: m.validators[0], Seal: []byte{0x1}}}
+	}
+	return msg
+}
+
+// mutate applies a random number of mutations to the message
+func (m *messageMutator) mutate(msg *MessageReq) *MessageReq {
+	for i := m.r.Intn(4); i >= 0; i-- {
+		switch m.r.Intn(12) {
+		case 0:
+			// bad digest
+			msg.Hash = m.randomBytes(40)
+		case 1:
+			// out of range view
+			msg.View = &View{Sequence: m.randomUint64(msg.View.Sequence), Round: m.randomUint64(msg.View.Round)}
+		case 2:
+			msg.View = nil
+			return msg
+		case 3:
+			// nil or random proposal
+			msg.Proposal = m.randomBytes(256)
+		case 4:
+			// truncated seal
+			if len(msg.Seal) > 0 {
+				msg.Seal = msg.Seal[:m.r.Intn(len(msg.Seal))]
+			}
+		case 5:
+			// unknown sender
+			msg.From = NodeID(m.randomBytes(4))
+		case 6:
+			// unknown type
+			msg.Type = MsgType(m.r.Int31n(20) - 5)
+		case 7:
+			msg.ChainID = m.r.Uint64()
+		case 8:
+			msg.ChunkCount = m.r.Uint32()
+			msg.ChunkIndex = m.r.Uint32()
+		case 9:
+			// truncated or forged committed seals
+			for j := m.r.Intn(8); j > 0; j-- {
+				msg.CommittedSeals = append(msg.CommittedSeals, CommittedSeal{
+					Signer: m.validators[m.r.Intn(len(m.validators))],
+					Seal:   m.randomBytes(8),
+				})
+			}
+			if len(msg.CommittedSeals) > 0 {
+				msg.CommittedSeals = msg.CommittedSeals[:m.r.Intn(len(msg.CommittedSeals))]
+			}
+		case 10:
+			msg.View.Term = m.r.Uint64()
+		case 11:
+			// keep the message valid
+		}
+	}
+	return msg
+}
+
+func fuzzEnvInt(t *testing.T, name string, def int64) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	num, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", name, err)
+	}
+	return num
+}
+
+// TestFuzz_PushMessage feeds mutated messages to a single engine while it runs its state machine.
+// The number of iterations and the seed are set with FUZZ_ITERATIONS and FUZZ_SEED
+func TestFuzz_PushMessage(t *testing.T) {
+	iterations := fuzzEnvInt(t, "FUZZ_ITERATIONS", 1000)
+	seed := fuzzEnvInt(t, "FUZZ_SEED", time.Now().UnixNano())
+	t.Logf("seed=%d, iterations=%d", seed, iterations)
+
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.logger.SetOutput(nopWriter{})
+	m.setProposal(&Proposal{Data: mockProposal, Hash: digest})
+	m.setState(AcceptState)
+
+	mutator := &messageMutator{
+		r:          rand.New(rand.NewSource(seed)),
+		validators: []NodeID{"A", "B", "C", "D"},
+	}
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	lastSequence := m.state.view.Sequence
+	for i := int64(0); i < iterations; i++ {
+		msg := mutator.mutate(mutator.base(m.state.getView()))
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("panic at iteration %d (seed=%d): %v, msg=%+v", i, seed, r, msg)
+				}
+			}()
+			m.PushMessage(msg)
+			_ = m.Preflight(msg)
+		}()
+
+		if i%10 != 0 {
+			continue
+		}
+		switch m.getState() {
+		case DoneState, SyncState:
+			// start the next height
+			m.setSequence(m.state.view.Sequence + 1)
+			m.setState(AcceptState)
+		case FaultedState:
+			t.Fatalf("engine faulted at iteration %d (seed=%d)", i, seed)
+		default:
+			ctx, cancelFn := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			m.ctx = ctx
+			m.runCycle(ctx)
+			cancelFn()
+		}
+
+		// the state is never corrupted
+		view := m.state.getView()
+		assert.NotNil(t, view)
+		assert.GreaterOrEqual(t, view.Sequence, lastSequence)
+		assert.LessOrEqual(t, view.Round, uint64(maxFutureRounds)*uint64(iterations))
+		lastSequence = view.Sequence
+		if m.state.locked {
+			assert.NotNil(t, m.state.proposal)
+		}
+	}
+
+	// memory is bounded by the number of messages
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if after.HeapAlloc > before.HeapAlloc {
+		assert.Less(t, after.HeapAlloc-before.HeapAlloc, uint64(iterations)*4096)
+	}
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
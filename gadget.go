@@ -0,0 +1,157 @@
+package pbft
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxCandidates is the maximum number of candidates tracked per sequence
+const maxCandidates = 16
+
+var (
+	errFinalityGadgetDisabled = fmt.Errorf("finality gadget mode is disabled")
+	errCandidateHashEmpty     = fmt.Errorf("candidate hash is empty")
+)
+
+// WithFinalityGadget enables the finality gadget mode. The engine does not build the proposals
+// with the backend, it votes on the hashes of the candidates submitted with SubmitCandidate by
+// an external block producer. The proposer proposes the last candidate of the sequence and the
+// other validators only vote for the candidates they were submitted themselves
+func WithFinalityGadget(enabled bool) ConfigOption {
+	return func(c *Config) {
+		c.FinalityGadget = enabled
+	}
+}
+
+// candidateTracker keeps the hashes of the candidates submitted for every sequence, in order
+type candidateTracker struct {
+	lock       sync.Mutex
+	candidates map[uint64][][]byte
+}
+
+func newCandidateTracker() *candidateTracker {
+	return &candidateTracker{candidates: map[uint64][][]byte{}}
+}
+
+// add adds the candidate, it returns an error if the sequence already has too many
+func (c *candidateTracker) add(sequence uint64, hash []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, h := range c.candidates[sequence] {
+		if bytes.Equal(h, hash) {
+			return nil
+		}
+	}
+	if len(c.candidates[sequence]) >= maxCandidates {
+		return fmt.Errorf("too many candidates for sequence %d", sequence)
+	}
+	c.candidates[sequence] = append(c.candidates[sequence], append([]byte{}, hash...))
+	return nil
+}
+
+// get returns the candidate of the sequence matching the hash, or the last one if the hash is nil
+func (c *candidateTracker) get(sequence uint64, hash []byte) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	candidates := c.candidates[sequence]
+	if hash == nil {
+		if len(candidates) == 0 {
+			return nil, false
+		}
+		return candidates[len(candidates)-1], true
+	}
+	for _, h := range candidates {
+		if bytes.Equal(h, hash) {
+			return h, true
+		}
+	}
+	return nil, false
+}
+
+// prune removes the candidates of the sequences below the given one
+func (c *candidateTracker) prune(sequence uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for s := range c.candidates {
+		if s < sequence {
+			delete(c.candidates, s)
+		}
+	}
+}
+
+// SubmitCandidate submits the hash of a candidate produced by the external block producer for
+// the sequence, in the finality gadget mode. Several candidates can be submitted for the same
+// sequence, the proposer proposes the last one. It is safe for concurrent use
+func (p *Pbft) SubmitCandidate(sequence uint64, hash []byte) error {
+	if !p.config.FinalityGadget {
+		return errFinalityGadgetDisabled
+	}
+	if len(hash) == 0 {
+		return errCandidateHashEmpty
+	}
+	view := p.state.getView()
+	if p.getBackend() == nil || view == nil {
+		return errBackendNotSet
+	}
+	current := view.Sequence
+	if sequence < current {
+		return fmt.Errorf("candidate for an old sequence: current=%d, candidate=%d", current, sequence)
+	}
+	if sequence > saturatingAdd(current, maxFutureSequences) {
+		return fmt.Errorf("candidate too far ahead: current=%d, candidate=%d", current, sequence)
+	}
+	if err := p.candidates.add(sequence, hash); err != nil {
+		return err
+	}
+	p.notifyUpdate()
+	return nil
+}
+
+// waitForCandidate waits until a candidate of the current sequence is submitted, the one
+// matching the hash or any if the hash is nil. It returns false on timeout
+func (p *Pbft) waitForCandidate(span trace.Span, hash []byte, timeout time.Duration) ([]byte, bool) {
+	timeoutCh := p.timer.reset("Candidate", timeout)
+	defer p.timer.stop()
+
+	sequence := p.state.view.Sequence
+	p.candidates.prune(sequence)
+	for {
+		if candidate, ok := p.candidates.get(sequence, hash); ok {
+			return candidate, true
+		}
+
+		select {
+		case <-timeoutCh:
+			span.AddEvent("CandidateTimeout")
+			return nil, false
+		case <-p.ctx.Done():
+			return nil, false
+		case <-p.updateCh:
+		}
+	}
+}
+
+// proposeCandidate sets the last candidate of the sequence as the proposal of the round.
+// It returns false if there is no candidate before the round timeout
+func (p *Pbft) proposeCandidate(span trace.Span) bool {
+	hash, ok := p.waitForCandidate(span, nil, p.roundTimeout(p.state.view.Round))
+	if !ok {
+		if p.ctx.Err() == nil {
+			p.logger.Printf("[ERROR] no candidate submitted: sequence=%d", p.state.view.Sequence)
+			p.roundChange(RoundChangeTimeout)
+		}
+		return false
+	}
+	p.state.proposal = &Proposal{
+		Hash: append([]byte{}, hash...),
+		Time: time.Now(),
+	}
+	return true
+}
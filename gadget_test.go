@@ -0,0 +1,115 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newGadgetMockPbft(t *testing.T, account string) *mockPbft {
+	accounts := []string{"A", "B", "C", "D"}
+	backend := newMockBackend(accounts, nil).HookBuildProposalHandler(func() (*Proposal, error) {
+		t.Fatal("the proposal must not be built in the finality gadget mode")
+		return nil, nil
+	})
+	m := newMockPbft(t, accounts, account, backend)
+	WithFinalityGadget(true)(m.config)
+	m.state.view = ViewMsg(1, 0)
+	m.setState(AcceptState)
+	return m
+}
+
+func TestFinalityGadget_SubmitCandidate(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.state.view = ViewMsg(5, 0)
+	assert.ErrorIs(t, m.SubmitCandidate(5, digest), errFinalityGadgetDisabled)
+
+	WithFinalityGadget(true)(m.config)
+	assert.ErrorIs(t, m.SubmitCandidate(5, nil), errCandidateHashEmpty)
+	assert.Error(t, m.SubmitCandidate(4, digest))
+	assert.Error(t, m.SubmitCandidate(5+maxFutureSequences+1, digest))
+
+	assert.NoError(t, m.SubmitCandidate(5, digest))
+	assert.NoError(t, m.SubmitCandidate(5, digest))
+	for i := 1; i < maxCandidates; i++ {
+		assert.NoError(t, m.SubmitCandidate(5, []byte{0xa0, byte(i)}))
+	}
+	assert.Error(t, m.SubmitCandidate(5, []byte{0xff}))
+
+	// the last candidate is proposed
+	hash, ok := m.candidates.get(5, nil)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0xa0, byte(maxCandidates - 1)}, hash)
+
+	m.candidates.prune(6)
+	_, ok = m.candidates.get(5, nil)
+	assert.False(t, ok)
+}
+
+func TestFinalityGadget_ProposerProposesCandidate(t *testing.T) {
+	m := newGadgetMockPbft(t, "A")
+	assert.NoError(t, m.SubmitCandidate(1, digest))
+
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence: 1,
+		outgoing: 2, // preprepare and prepare
+		state:    ValidateState,
+	})
+	preprepare := m.respMsg[0]
+	assert.Equal(t, MessageReq_Preprepare, preprepare.Type)
+	assert.Equal(t, digest, preprepare.Hash)
+	assert.Empty(t, preprepare.Proposal)
+}
+
+func TestFinalityGadget_ProposerWithoutCandidate(t *testing.T) {
+	m := newGadgetMockPbft(t, "A")
+	// a candidate of another sequence
+	assert.NoError(t, m.SubmitCandidate(2, digest))
+
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence: 1,
+		state:    RoundChangeState,
+	})
+}
+
+func TestFinalityGadget_ValidatorVotesSubmittedCandidate(t *testing.T) {
+	m := newGadgetMockPbft(t, "B")
+	assert.NoError(t, m.SubmitCandidate(1, digest))
+
+	m.emitMsg(&MessageReq{
+		From: "A",
+		Type: MessageReq_Preprepare,
+		Hash: digest,
+		View: ViewMsg(1, 0),
+	})
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence: 1,
+		outgoing: 1, // prepare
+		state:    ValidateState,
+	})
+}
+
+func TestFinalityGadget_ValidatorRejectsUnknownCandidate(t *testing.T) {
+	m := newGadgetMockPbft(t, "B")
+	assert.NoError(t, m.SubmitCandidate(1, []byte{0x2}))
+
+	m.emitMsg(&MessageReq{
+		From: "A",
+		Type: MessageReq_Preprepare,
+		Hash: digest,
+		View: ViewMsg(1, 0),
+	})
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence: 1,
+		state:    RoundChangeState,
+	})
+}
@@ -6,6 +6,7 @@ require (
 	github.com/stretchr/testify v1.7.0
 	go.opentelemetry.io/otel v1.1.0
 	go.opentelemetry.io/otel/trace v1.1.0
+	google.golang.org/protobuf v1.27.1
 )
 
 require (
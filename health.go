@@ -0,0 +1,92 @@
+package pbft
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Health is the status of the state machine, suitable for readiness probes
+type Health struct {
+	// Running is true while the state machine loop is running
+	Running bool
+
+	// State is the current state of the state machine
+	State PbftState
+
+	// View is the current view
+	View *View
+
+	// LastProgress is the time the node last finalized a height, either through
+	// the consensus, a finality proof or the sync of the backend
+	LastProgress time.Time
+
+	// SinceProgress is the time elapsed since the last progress
+	SinceProgress time.Duration
+
+	// AcceptQueueLen is the number of queued preprepare messages
+	AcceptQueueLen int
+
+	// ValidateQueueLen is the number of queued prepare and commit messages
+	ValidateQueueLen int
+
+	// RoundChangeQueueLen is the number of queued round change messages
+	RoundChangeQueueLen int
+
+	// LastError is the last error that moved the state machine to the round change state
+	LastError error
+}
+
+// healthTracker records the progress and the errors of the state machine
+type healthTracker struct {
+	lock         sync.Mutex
+	lastProgress time.Time
+	lastErr      error
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		lastProgress: time.Now(),
+	}
+}
+
+// progress records that the state machine made progress
+func (h *healthTracker) progress() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.lastProgress = time.Now()
+}
+
+// setErr records the last error of the state machine
+func (h *healthTracker) setErr(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.lastErr = err
+}
+
+func (h *healthTracker) get() (time.Time, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.lastProgress, h.lastErr
+}
+
+// Health returns the current status of the state machine. It is safe for concurrent use
+func (p *Pbft) Health() *Health {
+	lastProgress, lastErr := p.health.get()
+	acceptLen, validateLen, roundChangeLen := p.msgQueue.getQueueLens()
+
+	return &Health{
+		Running:             atomic.LoadUint64(&p.running) == runRunning,
+		State:               p.getState(),
+		View:                p.state.getView(),
+		LastProgress:        lastProgress,
+		SinceProgress:       time.Since(lastProgress),
+		AcceptQueueLen:      acceptLen,
+		ValidateQueueLen:    validateLen,
+		RoundChangeQueueLen: roundChangeLen,
+		LastError:           lastErr,
+	}
+}
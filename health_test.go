@@ -0,0 +1,51 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.state.view = ViewMsg(1, 0)
+	m.setState(CommitState)
+
+	m.emitMsg(&MessageReq{
+		From: "B",
+		Type: MessageReq_RoundChange,
+		View: ViewMsg(1, 1),
+	})
+
+	health := m.Health()
+	assert.False(t, health.Running)
+	assert.Equal(t, CommitState, health.State)
+	assert.Equal(t, ViewMsg(1, 0), health.View)
+	assert.Equal(t, 1, health.RoundChangeQueueLen)
+	assert.Nil(t, health.LastError)
+	lastProgress := health.LastProgress
+
+	// insertion fails because there is no proposer set
+	m.runCycle(context.Background())
+
+	health = m.Health()
+	assert.Equal(t, RoundChangeState, health.State)
+	assert.Equal(t, errFailedToInsertProposal, health.LastError)
+	// moving to round change is not considered progress
+	assert.Equal(t, lastProgress, health.LastProgress)
+}
+
+func TestHealth_ProgressOnFinalization(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	lastProgress := m.Health().LastProgress
+
+	// a node looping over the rounds or faulted does not make progress
+	for _, s := range []PbftState{AcceptState, ValidateState, AcceptState, FaultedState} {
+		m.setState(s)
+		assert.Equal(t, lastProgress, m.Health().LastProgress, s.String())
+	}
+
+	m.setState(DoneState)
+	assert.True(t, m.Health().LastProgress.After(lastProgress))
+}
@@ -0,0 +1,73 @@
+package pbft
+
+import "time"
+
+// WithHeartbeatInterval makes the engine emit a HeartbeatEvent at the interval while
+// it waits for messages in a round. Zero disables the heartbeat
+func WithHeartbeatInterval(interval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.HeartbeatInterval = interval
+	}
+}
+
+// HeartbeatEvent is emitted periodically while a round is in progress, so that monitoring
+// can tell a slow round that still collects votes from a stalled one
+type HeartbeatEvent struct {
+	// View is the current view
+	View *View
+
+	// Phase is the current state of the state machine
+	Phase string
+
+	// Proposer is the proposer of the round
+	Proposer NodeID
+
+	// Prepared are the senders of the prepare messages received so far
+	Prepared []NodeID
+
+	// Committed are the senders of the commit messages received so far
+	Committed []NodeID
+
+	// RoundChanges are the senders of the round change messages received so far per round
+	RoundChanges map[uint64][]NodeID
+
+	// Elapsed is the time since the round started
+	Elapsed time.Duration
+
+	// TimeoutRemaining is the time left until the current timeout fires
+	TimeoutRemaining time.Duration
+}
+
+func (e *HeartbeatEvent) EventName() string {
+	return "Heartbeat"
+}
+
+// heartbeatCh returns the channel of the heartbeat ticker, nil (never ready) if it is disabled
+func (p *Pbft) heartbeatCh() <-chan time.Time {
+	if p.heartbeat == nil {
+		return nil
+	}
+	return p.heartbeat.C
+}
+
+// emitHeartbeat emits the heartbeat of the current round. It must be called from the state machine loop
+func (p *Pbft) emitHeartbeat(deadline time.Time) {
+	event := &HeartbeatEvent{
+		View:         p.state.getView(),
+		Phase:        p.getState().String(),
+		Proposer:     p.state.proposer,
+		Prepared:     sortedSenders(p.state.prepared),
+		Committed:    sortedSenders(p.state.committed),
+		RoundChanges: map[uint64][]NodeID{},
+	}
+	for round, msgs := range p.state.roundMessages {
+		event.RoundChanges[round] = sortedSenders(msgs)
+	}
+	if p.round != nil {
+		event.Elapsed = time.Since(p.round.start)
+	}
+	if remaining := time.Until(deadline); remaining > 0 {
+		event.TimeoutRemaining = remaining
+	}
+	p.emit(event)
+}
@@ -0,0 +1,56 @@
+package pbft
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPbft_Heartbeat(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.roundTimeout = func(uint64) time.Duration { return 100 * time.Millisecond }
+	m.heartbeat = time.NewTicker(10 * time.Millisecond)
+	defer m.heartbeat.Stop()
+
+	heartbeats := []*HeartbeatEvent{}
+	m.config.EventHandler = func(e Event) {
+		if event, ok := e.(*HeartbeatEvent); ok {
+			heartbeats = append(heartbeats, event)
+		}
+	}
+
+	m.state.proposer = "A"
+	m.setState(ValidateState)
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+
+	// the round stalls with a single prepare and times out
+	m.runCycle(m.ctx)
+	assert.True(t, m.IsState(RoundChangeState))
+
+	if assert.NotEmpty(t, heartbeats) {
+		last := heartbeats[len(heartbeats)-1]
+		assert.Equal(t, ViewMsg(1, 0), last.View)
+		assert.Equal(t, ValidateState.String(), last.Phase)
+		assert.Equal(t, NodeID("A"), last.Proposer)
+		assert.Equal(t, []NodeID{"B"}, last.Prepared)
+		assert.Empty(t, last.Committed)
+		assert.True(t, last.TimeoutRemaining <= 100*time.Millisecond)
+	}
+}
+
+func TestPbft_Heartbeat_Disabled(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	assert.Nil(t, m.heartbeatCh())
+
+	heartbeats := 0
+	m.config.EventHandler = func(e Event) {
+		if _, ok := e.(*HeartbeatEvent); ok {
+			heartbeats++
+		}
+	}
+	m.roundTimeout = func(uint64) time.Duration { return 30 * time.Millisecond }
+	m.setState(ValidateState)
+	m.runCycle(m.ctx)
+	assert.Zero(t, heartbeats)
+}
@@ -0,0 +1,36 @@
+package pbft
+
+// ingress is the path a message takes to reach the engine
+type ingress uint8
+
+const (
+	// ingressUnverified is used for the messages of the peers that are not authenticated by the transport
+	ingressUnverified ingress = iota
+
+	// ingressVerified is used for the messages of the peers already authenticated by the transport
+	ingressVerified
+
+	// ingressLocal is used for the messages sent by the node to itself
+	ingressLocal
+)
+
+// PushVerifiedMessage pushes a message already authenticated by the transport (i.e. with mTLS
+// or libp2p signed messages) to the message queue, without calling the MessageVerifier.
+//
+// The transport takes the place of the verifier: it must have checked that the message was
+// sent by msg.From. The rest of the checks (validation, view, chain id, required fields in
+// strict mode, membership and seals) still apply. A message that is not authenticated must
+// be pushed with PushMessage instead
+func (p *Pbft) PushVerifiedMessage(msg *MessageReq) {
+	p.pushMessage(msg, ingressVerified)
+}
+
+// countIngress counts the messages received from the peers by ingress
+func (p *Pbft) countIngress(ingress ingress) {
+	switch ingress {
+	case ingressVerified:
+		p.stats.update(func(s *Stats) { s.VerifiedMessages++ })
+	case ingressUnverified:
+		p.stats.update(func(s *Stats) { s.UnverifiedMessages++ })
+	}
+}
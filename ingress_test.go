@@ -0,0 +1,37 @@
+package pbft
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushVerifiedMessage(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.setState(ValidateState)
+
+	verifierCalls := 0
+	WithStrictMode(StrictEnforced, func(msg *MessageReq) error {
+		verifierCalls++
+		return errors.New("bad signature")
+	})(m.config)
+
+	// the verifier is skipped
+	m.PushVerifiedMessage(&MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(1, 0)})
+	assert.Zero(t, verifierCalls)
+	assert.NotNil(t, m.msgQueue.readMessage(ValidateState, m.state.view))
+
+	// the rest of the checks still apply
+	m.PushVerifiedMessage(&MessageReq{Type: MessageReq_Commit, From: "B", Hash: digest, View: ViewMsg(1, 0)})
+	m.PushVerifiedMessage(&MessageReq{Type: MessageReq_Prepare, From: "B", View: ViewMsg(1, 0)})
+	assert.Nil(t, m.msgQueue.readMessage(ValidateState, m.state.view))
+
+	m.PushMessage(&MessageReq{Type: MessageReq_Prepare, From: "C", Hash: digest, View: ViewMsg(1, 0)})
+	assert.Equal(t, 1, verifierCalls)
+	assert.Nil(t, m.msgQueue.readMessage(ValidateState, m.state.view))
+
+	stats := m.Stats()
+	assert.Equal(t, uint64(3), stats.VerifiedMessages)
+	assert.Equal(t, uint64(1), stats.UnverifiedMessages)
+}
@@ -0,0 +1,145 @@
+package pbft
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// roundChangeDomain separates the digest of the round change seals from the other seals
+var roundChangeDomain = []byte("pbft-round-change:")
+
+// RoundChangeDigest returns the digest signed by a validator that is not locked when it moves to
+// the view. A quorum of these seals justifies a fresh proposal in place of a locked one, since
+// the validators that committed a proposal never sign it
+func RoundChangeDigest(view *View) []byte {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], view.Sequence)
+	binary.BigEndian.PutUint64(b[8:], view.Round)
+
+	digest := sha256.Sum256(append(append([]byte{}, roundChangeDomain...), b[:]...))
+	return digest[:]
+}
+
+// sealRoundChange adds the round change seal to the round change message if the node is not locked.
+// The seals are verified against their digest, hence the backend must implement CommitSealBackend
+func (p *Pbft) sealRoundChange(msg *MessageReq) {
+	if _, ok := p.backend.(CommitSealBackend); !ok || p.state.locked {
+		return
+	}
+	seal, err := p.validator.Sign(RoundChangeDigest(msg.View))
+	if err != nil {
+		p.logger.Printf("[ERROR] failed to seal the round change message. Error message: %v", err)
+		return
+	}
+	msg.Seal = seal
+}
+
+// verifiedRoundChanges returns the validators whose round change seal of the view is verified by
+// the backend. It returns the error if the backend panicked
+func (p *Pbft) verifiedRoundChanges(backend CommitSealBackend, view *View, seals []CommittedSeal) (map[NodeID]*MessageReq, error) {
+	digest := RoundChangeDigest(view)
+	roundChanges := map[NodeID]*MessageReq{}
+	for _, seal := range seals {
+		if _, ok := roundChanges[seal.Signer]; ok || len(seal.Seal) == 0 || !p.state.validators.Includes(seal.Signer) {
+			continue
+		}
+		var verifyErr error
+		if err := p.guard("VerifyCommitSeal", func() { verifyErr = backend.VerifyCommitSeal(seal.Signer, digest, seal.Seal) }); err != nil {
+			return nil, err
+		}
+		if verifyErr != nil {
+			p.logger.Printf("[ERROR]: invalid round change seal: from=%s, err=%v", seal.Signer, verifyErr)
+			continue
+		}
+		roundChanges[seal.Signer] = &MessageReq{
+			Type: MessageReq_RoundChange,
+			From: seal.Signer,
+			Seal: seal.Seal,
+			View: view.Copy(),
+		}
+	}
+	return roundChanges, nil
+}
+
+// roundChangeSeals returns the seals of the round change messages sorted by sender
+func roundChangeSeals(roundChanges map[NodeID]*MessageReq) []CommittedSeal {
+	seals := []CommittedSeal{}
+	for _, from := range sortedSenders(roundChanges) {
+		seals = append(seals, CommittedSeal{Signer: from, Seal: roundChanges[from].Seal})
+	}
+	return seals
+}
+
+// awaitsJustification checks whether the node, as the proposer of the round, waits in the round change
+// state for more round change messages of the round. A locked proposer that does not propose its
+// proposal again (see checkReproposal) needs a quorum of seals from unlocked validators, which the round
+// change quorum does not provide since it includes the unsealed message of the proposer itself.
+// It returns the error if the backend panicked
+func (p *Pbft) awaitsJustification(round uint64, roundChanges map[NodeID]*MessageReq) (bool, error) {
+	backend, ok := p.backend.(CommitSealBackend)
+	if !ok || !p.state.locked || p.state.proposal == nil {
+		return false, nil
+	}
+	view := &View{Sequence: p.state.view.Sequence, Round: round, Term: p.state.view.Term}
+	proposer, err := p.proposerOf(view)
+	if err != nil || proposer != p.selfID() {
+		return false, err
+	}
+	if !p.state.proposal.expired(round) {
+		vetoed, err := p.reproposalVetoed(round)
+		if err != nil || !vetoed {
+			return false, err
+		}
+	}
+	verified, err := p.verifiedRoundChanges(backend, view, roundChangeSeals(roundChanges))
+	if err != nil {
+		return false, err
+	}
+	return !p.state.hasQuorum(verified), nil
+}
+
+// justifyFreshProposal looks for a quorum of valid round change seals among the round change
+// messages that moved the proposer to the round. If found, the proposer unlocks and bundles the
+// seals in its preprepare message. It returns whether the fresh proposal is justified, and the
+// error if the backend panicked
+func (p *Pbft) justifyFreshProposal(roundChanges map[NodeID]*MessageReq) (bool, error) {
+	backend, ok := p.backend.(CommitSealBackend)
+	if !ok {
+		return false, nil
+	}
+	verified, err := p.verifiedRoundChanges(backend, p.state.view, roundChangeSeals(roundChanges))
+	if err != nil {
+		return false, err
+	}
+	if !p.state.hasQuorum(verified) {
+		return false, nil
+	}
+	justification := []CommittedSeal{}
+	for _, from := range sortedSenders(verified) {
+		justification = append(justification, CommittedSeal{Signer: from, Seal: verified[from].Seal})
+	}
+	p.state.unlock()
+	p.state.justification = justification
+	return true, nil
+}
+
+// unlockJustified verifies the round change seals bundled in the preprepare message of a proposal
+// that differs from the locked one, and unlocks the state if they form a quorum. It returns whether
+// the state was unlocked, and the error if the backend panicked
+func (p *Pbft) unlockJustified(msg *MessageReq) (bool, error) {
+	backend, ok := p.backend.(CommitSealBackend)
+	if !ok || len(msg.CommittedSeals) == 0 {
+		return false, nil
+	}
+	verified, err := p.verifiedRoundChanges(backend, msg.View, msg.CommittedSeals)
+	if err != nil {
+		return false, err
+	}
+	if !p.state.hasQuorum(verified) {
+		p.logger.Printf("[ERROR]: round change justification without a quorum: from=%s, seals=%d", msg.From, len(msg.CommittedSeals))
+		return false, nil
+	}
+	p.logger.Printf("[INFO] locked proposal released by the round change justification: round=%d", msg.View.Round)
+	p.state.unlock()
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package pbft
+
+// LivenessBackend is an optional interface of the backend for the liveness-aware proposer
+// rotation (see WithLivenessRotation). The data must come from the chain, i.e. the commit
+// seals of the finalized proposals, so that every node deprioritizes the same validators
+type LivenessBackend interface {
+	// LastSigned returns the last height sealed by the validator, zero if none
+	LastSigned(id NodeID) uint64
+}
+
+// WithLivenessRotation deprioritizes in the proposer selection the validators that did not
+// sign any of the last window heights. When the selected proposer is offline, the proposer
+// rotates over the online validators instead. The backend must implement LivenessBackend
+// and the validator set ValidatorLister
+func WithLivenessRotation(window uint64) ConfigOption {
+	return func(c *Config) {
+		c.LivenessWindow = window
+	}
+}
+
+// OfflineProposerSkippedEvent is emitted when the selected proposer did not sign any of the
+// last heights of the liveness window and another validator proposes instead
+type OfflineProposerSkippedEvent struct {
+	// View is the view of the round
+	View *View
+
+	// Proposer is the offline proposer
+	Proposer NodeID
+
+	// Replacement is the online validator that proposes instead
+	Replacement NodeID
+}
+
+func (e *OfflineProposerSkippedEvent) EventName() string {
+	return "OfflineProposerSkipped"
+}
+
+// isOffline returns true if the validator did not sign any height of the window before the sequence
+func isOffline(lastSigned, sequence, window uint64) bool {
+	return sequence > window && lastSigned < sequence-window
+}
+
+// skipOfflineProposer replaces the proposer of the round if it is offline, with a round robin
+// over the online validators rotating with the sequence and the round. The proposer is kept
+// if the rotation is disabled, the validators cannot be listed or none of them is online.
+// It returns the error if the backend panicked
+func (p *Pbft) skipOfflineProposer() error {
+	window := p.config.LivenessWindow
+	backend, ok := p.getBackend().(LivenessBackend)
+	if window == 0 || !ok {
+		return nil
+	}
+	view := p.state.view
+	offline := func(id NodeID) (bool, error) {
+		var lastSigned uint64
+		if err := p.guard("LastSigned", func() { lastSigned = backend.LastSigned(id) }); err != nil {
+			return false, err
+		}
+		return isOffline(lastSigned, view.Sequence, window), nil
+	}
+
+	proposer := p.state.proposer
+	if isOff, err := offline(proposer); err != nil || !isOff {
+		return err
+	}
+	online := []NodeID{}
+	for _, id := range listValidators(p.state.validators) {
+		isOff, err := offline(id)
+		if err != nil {
+			return err
+		}
+		if !isOff {
+			online = append(online, id)
+		}
+	}
+	if len(online) == 0 {
+		return nil
+	}
+	n := uint64(len(online))
+	replacement := online[(view.Sequence%n+view.Round%n)%n]
+
+	p.logger.Printf("[INFO] offline proposer skipped: proposer=%s, replacement=%s, sequence=%d, round=%d", proposer, replacement, view.Sequence, view.Round)
+	p.emit(&OfflineProposerSkippedEvent{
+		View:        view.Copy(),
+		Proposer:    proposer,
+		Replacement: replacement,
+	})
+	p.state.proposer = replacement
+	return nil
+}
@@ -0,0 +1,109 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// livenessBackend is a mock backend with the last height signed by every validator
+type livenessBackend struct {
+	*mockBackend
+	lastSigned map[NodeID]uint64
+}
+
+// the online validators are listed by the engine
+func (l *livenessBackend) ValidatorSet() ValidatorSet {
+	return &listedValString{l.mockBackend.validators}
+}
+
+type listedValString struct {
+	*valString
+}
+
+func (v *listedValString) Validators() []NodeID {
+	return *v.valString
+}
+
+func (l *livenessBackend) LastSigned(id NodeID) uint64 {
+	if id == "panic" {
+		panic("bad validator")
+	}
+	return l.lastSigned[id]
+}
+
+func newLivenessMockPbft(t *testing.T, account string, window uint64, lastSigned map[NodeID]uint64) *mockPbft {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, account)
+	WithLivenessRotation(window)(m.config)
+	m.sequence = 10
+	require.NoError(t, m.SetBackend(&livenessBackend{mockBackend: m.backend.(*mockBackend), lastSigned: lastSigned}))
+	return m
+}
+
+func TestIsOffline(t *testing.T) {
+	assert.False(t, isOffline(9, 10, 5))
+	assert.False(t, isOffline(5, 10, 5))
+	assert.True(t, isOffline(4, 10, 5))
+	// the first heights of the chain are not enough to tell
+	assert.False(t, isOffline(0, 5, 5))
+	assert.True(t, isOffline(0, 6, 5))
+}
+
+func TestLivenessRotation_SkipsOfflineProposer(t *testing.T) {
+	// A is the proposer of round 0 but it did not sign since height 2
+	m := newLivenessMockPbft(t, "C", 5, map[NodeID]uint64{"A": 2, "B": 9, "C": 9, "D": 8})
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	m.setState(AcceptState)
+	m.setProposal(&Proposal{
+		Data: mockProposal,
+		Hash: digest,
+	})
+
+	m.runCycle(context.Background())
+
+	// C is picked among the online validators B, C and D
+	m.expect(expectResult{
+		sequence: 10,
+		state:    ValidateState,
+		outgoing: 2, // preprepare and prepare
+	})
+	require.NotEmpty(t, events)
+	assert.Equal(t, &OfflineProposerSkippedEvent{
+		View:        ViewMsg(10, 0),
+		Proposer:    "A",
+		Replacement: "C",
+	}, events[0])
+}
+
+func TestLivenessRotation_OnlineProposer(t *testing.T) {
+	m := newLivenessMockPbft(t, "C", 5, map[NodeID]uint64{"A": 2, "B": 9, "C": 9, "D": 8})
+
+	// B is the proposer of round 1 and it is online
+	m.state.view = ViewMsg(10, 1)
+	require.NoError(t, m.calcProposer())
+	assert.Equal(t, NodeID("B"), m.state.proposer)
+}
+
+func TestLivenessRotation_Fallback(t *testing.T) {
+	// every validator is offline, the proposer is kept
+	m := newLivenessMockPbft(t, "C", 5, map[NodeID]uint64{})
+	require.NoError(t, m.calcProposer())
+	assert.Equal(t, NodeID("A"), m.state.proposer)
+
+	// disabled
+	m = newLivenessMockPbft(t, "C", 0, map[NodeID]uint64{"B": 9})
+	require.NoError(t, m.calcProposer())
+	assert.Equal(t, NodeID("A"), m.state.proposer)
+}
+
+func TestLivenessRotation_BackendPanic(t *testing.T) {
+	m := newLivenessMockPbft(t, "C", 5, map[NodeID]uint64{})
+	m.state.proposer = "panic"
+	assert.ErrorIs(t, m.skipOfflineProposer(), errBackendPanic)
+	assert.Equal(t, FaultedState, m.getState())
+}
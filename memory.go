@@ -0,0 +1,100 @@
+package pbft
+
+const (
+	// msgOverhead is the estimated size of a message besides its variable length fields
+	// (the struct, the view and the allocation headers)
+	msgOverhead = 256
+
+	// sealOverhead is the estimated size of a committed seal besides its signer and seal
+	sealOverhead = 48
+
+	// the shares of the memory budget, in percent
+	queueShare    = 40
+	storeShare    = 30
+	chunksShare   = 20
+	evidenceShare = 10
+)
+
+// WithMemoryBudget bounds the memory retained by the engine for the messages received
+// from the peers. The budget in bytes is split between:
+//
+//   - the message queue (40%), which buffers the messages of the current and future views
+//   - the MemoryMessageStore (30%), which keeps a copy of the queued messages
+//   - the chunk buffer (20%), which buffers the chunks of the proposals being reassembled
+//   - the evidence pool (10%), which keeps the proofs of equivocation
+//
+// When the queue is full, the message furthest in the future is dropped (DiscardMemoryBudget).
+// When the store is full, the messages of the heights further in the future are dropped and
+// the message is not stored if it is still full. A custom MessageStore is not bounded.
+// When the chunk buffer is full, the chunks of the proposals further in the future are dropped
+// and the chunk is rejected if it is still full. When the evidence pool is full, the oldest
+// evidence is dropped. The sizes are estimated from the length of the variable fields of the
+// messages plus a fixed overhead. Zero (the default) disables the bounds
+func WithMemoryBudget(bytes uint64) ConfigOption {
+	return func(c *Config) {
+		c.MemoryBudget = bytes
+	}
+}
+
+// MemoryUsage is the estimated memory in bytes retained by the bounded structures of the engine
+type MemoryUsage struct {
+	// Queue is the size of the messages in the message queue
+	Queue uint64
+
+	// Store is the size of the messages in the MemoryMessageStore
+	Store uint64
+
+	// Chunks is the size of the chunks in the chunk buffer
+	Chunks uint64
+
+	// Evidence is the size of the messages in the evidence pool
+	Evidence uint64
+}
+
+// Total returns the memory retained by all the structures
+func (m MemoryUsage) Total() uint64 {
+	return m.Queue + m.Store + m.Chunks + m.Evidence
+}
+
+// MemoryUsage returns the memory accounted against the memory budget (see WithMemoryBudget)
+func (p *Pbft) MemoryUsage() MemoryUsage {
+	usage := MemoryUsage{
+		Queue:    p.msgQueue.memory(),
+		Chunks:   p.chunks.memory(),
+		Evidence: p.evidence.memory(),
+	}
+	if store, ok := p.config.MessageStore.(*MemoryMessageStore); ok {
+		usage.Store = store.memory()
+	}
+	return usage
+}
+
+// applyMemoryBudget sizes the bounded structures out of the configured budget
+func (p *Pbft) applyMemoryBudget(budget uint64) {
+	if budget == 0 {
+		return
+	}
+	p.msgQueue.limit = budget / 100 * queueShare
+	if store, ok := p.config.MessageStore.(*MemoryMessageStore); ok {
+		store.limit = budget / 100 * storeShare
+	}
+	p.chunks.limit = budget / 100 * chunksShare
+	p.evidence.limit = budget / 100 * evidenceShare
+}
+
+// msgSize returns the estimated memory retained by the message
+func msgSize(msg *MessageReq) uint64 {
+	size := uint64(msgOverhead + len(msg.From) + len(msg.Seal) + len(msg.Hash) + len(msg.Proposal))
+	for _, seal := range msg.CommittedSeals {
+		size += uint64(sealOverhead + len(seal.Signer) + len(seal.Seal))
+	}
+	return size
+}
+
+// enqueue pushes the message to the message queue and counts the messages dropped by the
+// sender quota or over the budget
+func (p *Pbft) enqueue(msg *MessageReq) {
+	for _, dropped := range p.msgQueue.pushMessage(msg) {
+		p.countDiscard(dropped.msg, dropped.reason)
+	}
+}
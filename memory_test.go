@@ -0,0 +1,202 @@
+package pbft
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgQueue_MemoryLimit(t *testing.T) {
+	m := newMsgQueue()
+	m.limit = 3 * msgSize(mockQueueMsg("A", MessageReq_Prepare, ViewMsg(1, 0)))
+
+	assert.Empty(t, m.pushMessage(mockQueueMsg("A", MessageReq_Prepare, ViewMsg(1, 0))))
+	assert.Empty(t, m.pushMessage(mockQueueMsg("B", MessageReq_Prepare, ViewMsg(3, 0))))
+	assert.Empty(t, m.pushMessage(mockQueueMsg("C", MessageReq_RoundChange, ViewMsg(2, 5))))
+
+	// the furthest message in the future is dropped
+	dropped := m.pushMessage(mockQueueMsg("D", MessageReq_Commit, ViewMsg(1, 0)))
+	require.Len(t, dropped, 1)
+	assert.Equal(t, NodeID("B"), dropped[0].msg.From)
+	assert.Equal(t, DiscardMemoryBudget, dropped[0].reason)
+
+	// the new message is dropped if it is the furthest one
+	dropped = m.pushMessage(mockQueueMsg("E", MessageReq_Prepare, ViewMsg(4, 0)))
+	require.Len(t, dropped, 1)
+	assert.Equal(t, NodeID("E"), dropped[0].msg.From)
+	assert.Equal(t, m.limit, m.memory())
+
+	// reading the messages releases their memory
+	assert.NotNil(t, m.readMessage(ValidateState, ViewMsg(1, 0)))
+	assert.NotNil(t, m.readMessage(ValidateState, ViewMsg(1, 0)))
+	assert.Equal(t, m.limit/3, m.memory())
+}
+
+func TestMsgQueue_SenderQuota(t *testing.T) {
+	prepare := func(from string, hash byte) *MessageReq {
+		msg := mockQueueMsg(from, MessageReq_Prepare, ViewMsg(1, 0))
+		msg.Hash = []byte{hash}
+		return msg
+	}
+
+	m := newMsgQueue()
+	m.limit = 4 * msgSize(prepare("A", 1))
+
+	assert.Empty(t, m.pushMessage(prepare("A", 1)))
+	assert.Empty(t, m.pushMessage(prepare("B", 1)))
+
+	// the duplicate prepare is dropped
+	dropped := m.pushMessage(prepare("B", 1))
+	require.Len(t, dropped, 1)
+	assert.Equal(t, DiscardDuplicate, dropped[0].reason)
+
+	// the conflicting prepare is kept as the evidence of the equivocation
+	assert.Empty(t, m.pushMessage(prepare("B", 2)))
+
+	// the flood of the byzantine sender is dropped by its quota instead of the budget
+	for i := byte(3); i < 10; i++ {
+		dropped := m.pushMessage(prepare("B", i))
+		require.Len(t, dropped, 1)
+		assert.Equal(t, DiscardDuplicate, dropped[0].reason)
+	}
+
+	// the vote of the honest sender is not evicted
+	assert.Empty(t, m.pushMessage(prepare("C", 1)))
+	assert.Equal(t, m.limit, m.memory())
+
+	senders := map[NodeID]int{}
+	for msg := m.readMessage(ValidateState, ViewMsg(1, 0)); msg != nil; msg = m.readMessage(ValidateState, ViewMsg(1, 0)) {
+		senders[msg.From]++
+	}
+	assert.Equal(t, map[NodeID]int{"A": 1, "B": 2, "C": 1}, senders)
+
+	// reading the messages frees the quota
+	assert.Empty(t, m.senders)
+	assert.Empty(t, m.pushMessage(prepare("B", 3)))
+}
+
+func TestChunkBuffer_MemoryLimit(t *testing.T) {
+	c := newChunkBuffer()
+	c.limit = 2 * chunkSize([]byte{1, 2})
+
+	future := proposalChunkMsgs("A", ViewMsg(2, 0), digest, [][]byte{{1, 2}})
+	current := proposalChunkMsgs("A", ViewMsg(1, 0), digest, [][]byte{{1, 2}, {3, 4}})
+
+	require.NoError(t, c.add(future[0]))
+	require.NoError(t, c.add(current[0]))
+
+	// the chunks of the future proposal are dropped
+	require.NoError(t, c.add(current[1]))
+	assert.Equal(t, c.limit, c.memory())
+
+	// the buffer is full with the chunks of the current proposal
+	assert.Error(t, c.add(future[0]))
+
+	c.prune(ViewMsg(1, 1))
+	assert.Zero(t, c.memory())
+}
+
+func TestEvidencePool_MemoryLimit(t *testing.T) {
+	first, second := conflictingCommits("A")
+	evidence, err := NewEvidence(first, second)
+	require.NoError(t, err)
+
+	e := newEvidencePool()
+	e.limit = 2 * evidenceSize(evidence)
+
+	assert.Zero(t, e.add(evidence))
+	assert.Zero(t, e.add(evidence))
+	assert.Equal(t, 1, e.add(evidence))
+	assert.Len(t, e.list(), 2)
+	assert.Equal(t, e.limit, e.memory())
+}
+
+func TestMemoryStore_MemoryLimit(t *testing.T) {
+	s := NewMemoryMessageStore()
+	s.limit = 2 * msgSize(mockQueueMsg("A", MessageReq_Prepare, ViewMsg(1, 0)))
+
+	require.NoError(t, s.Put(mockQueueMsg("A", MessageReq_Prepare, ViewMsg(3, 0))))
+	require.NoError(t, s.Put(mockQueueMsg("B", MessageReq_Prepare, ViewMsg(1, 0))))
+
+	// the messages of the future heights are dropped
+	require.NoError(t, s.Put(mockQueueMsg("C", MessageReq_Prepare, ViewMsg(2, 0))))
+	assert.ErrorIs(t, s.Put(mockQueueMsg("D", MessageReq_Prepare, ViewMsg(2, 0))), errMessageStoreFull)
+
+	msgs, err := s.Messages()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, NodeID("B"), msgs[0].From)
+	assert.Equal(t, NodeID("C"), msgs[1].From)
+
+	require.NoError(t, s.Prune(3))
+	assert.Zero(t, s.memory())
+}
+
+// heapInUse returns the live heap after a garbage collection
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func TestMemoryBudget_HostileTraffic(t *testing.T) {
+	const (
+		budget  = 4 << 20
+		payload = 1 << 10
+		rounds  = 10
+		burst   = 20000
+	)
+
+	validators := []string{"A", "B", "C", "D"}
+	m := newMockPbft(t, validators, "A")
+	m.config.MessageStore = NewMemoryMessageStore()
+	m.applyMemoryBudget(budget)
+
+	r := rand.New(rand.NewSource(1))
+	flood := func() {
+		for i := 0; i < burst; i++ {
+			view := ViewMsg(1+uint64(r.Intn(maxFutureSequences)), uint64(r.Intn(maxFutureRounds)))
+			data := make([]byte, payload)
+			r.Read(data)
+
+			from := NodeID(validators[1+r.Intn(3)])
+			m.PushMessage(&MessageReq{
+				Type:     MessageReq_Preprepare,
+				From:     from,
+				View:     view,
+				Hash:     data[:32],
+				Proposal: data,
+			})
+			chunk := proposalChunkMsgs(from, view, data[:32], [][]byte{data})[0]
+			chunk.ChunkCount = 2
+			m.PushMessage(chunk)
+
+			conflicting := &MessageReq{Type: MessageReq_Commit, From: from, View: view, Hash: data[32:64], Seal: data}
+			m.reportEquivocation(conflicting, &MessageReq{Type: MessageReq_Commit, From: from, View: view, Hash: data[64:96], Seal: data})
+		}
+	}
+
+	// warm up until the structures are full
+	flood()
+	steady := heapInUse()
+
+	for i := 0; i < rounds; i++ {
+		flood()
+
+		usage := m.MemoryUsage()
+		assert.LessOrEqual(t, usage.Total(), uint64(budget))
+		assert.LessOrEqual(t, usage.Queue, uint64(budget/100*queueShare))
+		assert.LessOrEqual(t, usage.Store, uint64(budget/100*storeShare))
+		assert.LessOrEqual(t, usage.Chunks, uint64(budget/100*chunksShare))
+		assert.LessOrEqual(t, usage.Evidence, uint64(budget/100*evidenceShare))
+	}
+
+	// the heap does not grow with the traffic once the structures are full
+	heap := heapInUse()
+	assert.Less(t, heap, steady+budget)
+	assert.NotZero(t, m.Stats().Discards[DiscardMemoryBudget])
+}
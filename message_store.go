@@ -0,0 +1,207 @@
+package pbft
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// errMessageStoreFull is returned by the MemoryMessageStore over its memory budget
+var errMessageStoreFull = errors.New("message store full")
+
+// MessageStore keeps the inbound messages of the current and the future heights. The engine
+// stores every queued message and loads them back into its queue on the first SetBackend,
+// so a store that survives the process (i.e. backed by a database) lets a restarted node
+// resume the round with the votes it had already received. No store is set by default
+type MessageStore interface {
+	// Put stores the message
+	Put(msg *MessageReq) error
+
+	// Messages returns the stored messages
+	Messages() ([]*MessageReq, error)
+
+	// Prune removes the messages of the heights below the sequence
+	Prune(sequence uint64) error
+}
+
+// MessageRoundPruner is an optional interface of the MessageStore that also prunes the rounds
+// of the current height as the engine moves to the next ones
+type MessageRoundPruner interface {
+	// PruneRound removes the messages of the height in the rounds below the round
+	PruneRound(sequence, round uint64) error
+}
+
+// WithMessageStore sets the store of the inbound messages
+func WithMessageStore(store MessageStore) ConfigOption {
+	return func(c *Config) {
+		c.MessageStore = store
+	}
+}
+
+// MemoryMessageStore is an in-memory MessageStore, it does not survive a restart of the process
+// and is meant for the tests and the nodes that restart the engine within the process
+type MemoryMessageStore struct {
+	lock sync.Mutex
+	msgs map[uint64][]*MessageReq
+
+	// size is the estimated memory of the stored messages
+	size uint64
+
+	// limit is the maximum size of the stored messages, zero if unbounded (see WithMemoryBudget)
+	limit uint64
+}
+
+func NewMemoryMessageStore() *MemoryMessageStore {
+	return &MemoryMessageStore{msgs: map[uint64][]*MessageReq{}}
+}
+
+func (m *MemoryMessageStore) Put(msg *MessageReq) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	size := msgSize(msg)
+	if m.limit != 0 && m.size+size > m.limit {
+		// drop the heights further in the future first
+		for seq := range m.msgs {
+			if seq > msg.View.Sequence {
+				m.delete(seq)
+			}
+		}
+		if m.size+size > m.limit {
+			return errMessageStoreFull
+		}
+	}
+	m.msgs[msg.View.Sequence] = append(m.msgs[msg.View.Sequence], msg.Copy())
+	m.size += size
+	return nil
+}
+
+// delete removes the messages of the sequence
+func (m *MemoryMessageStore) delete(sequence uint64) {
+	for _, msg := range m.msgs[sequence] {
+		m.size -= msgSize(msg)
+	}
+	delete(m.msgs, sequence)
+}
+
+// memory returns the estimated memory of the stored messages
+func (m *MemoryMessageStore) memory() uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.size
+}
+
+// Messages returns the stored messages ordered by sequence and arrival
+func (m *MemoryMessageStore) Messages() ([]*MessageReq, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	sequences := make([]uint64, 0, len(m.msgs))
+	for sequence := range m.msgs {
+		sequences = append(sequences, sequence)
+	}
+	sort.Slice(sequences, func(i, j int) bool {
+		return sequences[i] < sequences[j]
+	})
+	res := []*MessageReq{}
+	for _, sequence := range sequences {
+		for _, msg := range m.msgs[sequence] {
+			res = append(res, msg.Copy())
+		}
+	}
+	return res, nil
+}
+
+func (m *MemoryMessageStore) Prune(sequence uint64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for seq := range m.msgs {
+		if seq < sequence {
+			m.delete(seq)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryMessageStore) PruneRound(sequence, round uint64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	kept := []*MessageReq{}
+	for _, msg := range m.msgs[sequence] {
+		if msg.View.Round < round {
+			m.size -= msgSize(msg)
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	if len(kept) == 0 {
+		delete(m.msgs, sequence)
+	} else {
+		m.msgs[sequence] = kept
+	}
+	return nil
+}
+
+// storeMessage stores the queued message, a failure of the store does not affect the consensus
+func (p *Pbft) storeMessage(msg *MessageReq) {
+	if p.config.MessageStore == nil {
+		return
+	}
+	if err := p.config.MessageStore.Put(msg); errors.Is(err, errMessageStoreFull) {
+		p.logger.Printf("[DEBUG] message not stored: from=%s, type=%s, err=%v", msg.From, msg.Type, err)
+	} else if err != nil {
+		p.logger.Printf("[ERROR] failed to store message: from=%s, type=%s, err=%v", msg.From, msg.Type, err)
+	}
+}
+
+// loadMessages queues the stored messages of the current and the future heights.
+// It only runs once, on the first SetBackend after the start of the node
+func (p *Pbft) loadMessages() {
+	if p.storeLoaded || p.config.MessageStore == nil {
+		return
+	}
+	p.storeLoaded = true
+
+	msgs, err := p.config.MessageStore.Messages()
+	if err != nil {
+		p.logger.Printf("[ERROR] failed to load stored messages: %v", err)
+		return
+	}
+	current := p.state.getView()
+	loaded := 0
+	for _, msg := range msgs {
+		if msg.View == nil || msg.View.Sequence < current.Sequence {
+			continue
+		}
+		p.enqueue(msg)
+		loaded++
+	}
+	if loaded != 0 {
+		p.logger.Printf("[INFO] loaded stored messages: sequence=%d, messages=%d", current.Sequence, loaded)
+	}
+}
+
+// pruneMessages removes the stored messages of the heights below the sequence
+func (p *Pbft) pruneMessages(sequence uint64) {
+	if p.config.MessageStore == nil {
+		return
+	}
+	if err := p.config.MessageStore.Prune(sequence); err != nil {
+		p.logger.Printf("[ERROR] failed to prune stored messages: %v", err)
+	}
+}
+
+// pruneRoundMessages removes the stored messages of the rounds of the height below the view,
+// if the store implements MessageRoundPruner. The queue discards them anyway
+func (p *Pbft) pruneRoundMessages(view *View) {
+	pruner, ok := p.config.MessageStore.(MessageRoundPruner)
+	if !ok || view.Round == 0 {
+		return
+	}
+	if err := pruner.PruneRound(view.Sequence, view.Round); err != nil {
+		p.logger.Printf("[ERROR] failed to prune stored rounds: %v", err)
+	}
+}
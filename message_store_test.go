@@ -0,0 +1,132 @@
+package pbft
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryMessageStore(t *testing.T) {
+	s := NewMemoryMessageStore()
+	assert.NoError(t, s.Put(&MessageReq{From: "A", View: ViewMsg(2, 0)}))
+	assert.NoError(t, s.Put(&MessageReq{From: "B", View: ViewMsg(1, 0)}))
+	assert.NoError(t, s.Put(&MessageReq{From: "C", View: ViewMsg(1, 1)}))
+
+	msgs, err := s.Messages()
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 3)
+	for i, from := range []NodeID{"B", "C", "A"} {
+		assert.Equal(t, from, msgs[i].From)
+	}
+
+	assert.NoError(t, s.Prune(2))
+	msgs, err = s.Messages()
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, NodeID("A"), msgs[0].From)
+}
+
+func TestMessageStore_ResumeAfterRestart(t *testing.T) {
+	store := NewMemoryMessageStore()
+
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.MessageStore = store
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	m.emitMsg(&MessageReq{From: "C", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	m.emitMsg(&MessageReq{From: "D", Type: MessageReq_Commit, View: ViewMsg(1, 0)})
+	// a stale height is not loaded back
+	assert.NoError(t, store.Put(&MessageReq{From: "D", Type: MessageReq_Prepare, Hash: digest, View: ViewMsg(0, 0)}))
+
+	// the node restarts with the same store
+	restarted := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	restarted.config.MessageStore = store
+	restarted.storeLoaded = false
+	assert.NoError(t, restarted.SetBackend(restarted.backend))
+
+	restarted.setState(ValidateState)
+	restarted.runCycle(context.Background())
+	assert.Contains(t, restarted.state.prepared, NodeID("B"))
+	assert.Contains(t, restarted.state.prepared, NodeID("C"))
+	assert.Contains(t, restarted.state.committed, NodeID("D"))
+	assert.NotContains(t, restarted.state.prepared, NodeID("D"))
+
+	// the messages are loaded only once
+	assert.NoError(t, restarted.SetBackend(restarted.backend))
+	_, validateLen, _ := restarted.msgQueue.getQueueLens()
+	assert.Equal(t, 0, validateLen)
+
+	// the finished heights are pruned
+	restarted.setSequence(2)
+	msgs, err := store.Messages()
+	assert.NoError(t, err)
+	assert.Empty(t, msgs)
+}
+
+type failingMessageStore struct {
+	*MemoryMessageStore
+}
+
+func (f *failingMessageStore) Put(msg *MessageReq) error {
+	return fmt.Errorf("disk full")
+}
+
+func TestMessageStore_PutFailure(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.MessageStore = &failingMessageStore{NewMemoryMessageStore()}
+	m.setState(ValidateState)
+
+	// the message is queued anyway
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	m.runCycle(context.Background())
+	assert.Contains(t, m.state.prepared, NodeID("B"))
+}
+
+func TestMessageStore_NotSetByDefault(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	assert.Nil(t, m.config.MessageStore)
+
+	// the messages are queued without a store
+	m.setState(ValidateState)
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	m.runCycle(context.Background())
+	assert.Contains(t, m.state.prepared, NodeID("B"))
+	assert.Zero(t, m.MemoryUsage().Store)
+}
+
+func TestMemoryMessageStore_PruneRound(t *testing.T) {
+	s := NewMemoryMessageStore()
+	assert.NoError(t, s.Put(&MessageReq{From: "A", View: ViewMsg(1, 0)}))
+	assert.NoError(t, s.Put(&MessageReq{From: "B", View: ViewMsg(1, 1)}))
+	assert.NoError(t, s.Put(&MessageReq{From: "C", View: ViewMsg(1, 2)}))
+	assert.NoError(t, s.Put(&MessageReq{From: "D", View: ViewMsg(2, 0)}))
+
+	// the next heights are kept
+	assert.NoError(t, s.PruneRound(1, 2))
+	msgs, err := s.Messages()
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, NodeID("C"), msgs[0].From)
+	assert.Equal(t, NodeID("D"), msgs[1].From)
+	assert.Equal(t, msgSize(msgs[0])+msgSize(msgs[1]), s.memory())
+}
+
+func TestMessageStore_PruneRoundOnAccept(t *testing.T) {
+	store := NewMemoryMessageStore()
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.MessageStore = store
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	m.emitMsg(&MessageReq{From: "C", Type: MessageReq_RoundChange, View: ViewMsg(1, 1)})
+
+	// the node starts the round 1
+	m.state.view = ViewMsg(1, 1)
+	m.setState(AcceptState)
+	m.Close()
+	m.runCycle(context.Background())
+
+	msgs, err := store.Messages()
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, NodeID("C"), msgs[0].From)
+}
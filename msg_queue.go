@@ -16,16 +16,154 @@ type msgQueue struct {
 	// Heap implementation for the validate state message queue
 	validateStateQueue msgQueueImpl
 
+	// size is the estimated memory of the queued messages
+	size uint64
+
+	// limit is the maximum size of the queued messages, zero if unbounded (see WithMemoryBudget)
+	limit uint64
+
+	// proposerView and proposer identify the preprepare read ahead of the rest of the queue
+	proposerView *View
+	proposer     NodeID
+
+	// senders are the hashes of the queued messages per sender, type and view (see senderQuota)
+	senders map[senderKey][]string
+
 	queueLock sync.Mutex
 }
 
-// pushMessage adds a new message to a message queue
-func (m *msgQueue) pushMessage(message *MessageReq) {
+// senderQuota is the number of distinct messages of a sender queued per type and view. The first
+// one is the vote, a second one is kept as the evidence of the equivocation (see reportEquivocation),
+// so that a sender flooding a view cannot evict the votes of the other senders from the budget
+const senderQuota = 2
+
+// senderKey identifies the messages of a sender of the same type and view
+type senderKey struct {
+	from     NodeID
+	typ      MsgType
+	sequence uint64
+	round    uint64
+	term     uint64
+}
+
+func newSenderKey(msg *MessageReq) senderKey {
+	return senderKey{from: msg.From, typ: msg.Type, sequence: msg.View.Sequence, round: msg.View.Round, term: msg.View.Term}
+}
+
+// setProposer sets the proposer of the view. Its preprepare for the view is read ahead of
+// the preprepares of the other senders, so that a flood of bogus preprepares for the same
+// view does not delay the proposal
+func (m *msgQueue) setProposer(view *View, proposer NodeID) {
+	m.queueLock.Lock()
+	defer m.queueLock.Unlock()
+
+	m.proposerView = view.Copy()
+	m.proposer = proposer
+}
+
+// proposerMessage removes and returns the preprepare of the proposer for the current view, if queued
+func (m *msgQueue) proposerMessage(current *View) *MessageReq {
+	view := m.proposerView
+	if view == nil || cmpView(view, current) != 0 || view.Term != current.Term {
+		return nil
+	}
+	for i, msg := range m.acceptStateQueue {
+		if msg.From == m.proposer && cmpView(msg.View, view) == 0 && msg.View.Term == view.Term {
+			return m.remove(&m.acceptStateQueue, i)
+		}
+	}
+	return nil
+}
+
+// pushMessage adds a new message to a message queue. The message is dropped if it is already
+// queued or its sender is over its quota for the type and view (see senderQuota). If the queue is
+// then over its limit, it drops the messages furthest in the future, including the new one.
+// It returns the dropped messages with the reason
+func (m *msgQueue) pushMessage(message *MessageReq) []*discardedMsg {
 	m.queueLock.Lock()
 	defer m.queueLock.Unlock()
 
+	key := newSenderKey(message)
+	hashes := m.senders[key]
+	for _, hash := range hashes {
+		if hash == string(message.Hash) {
+			return []*discardedMsg{{msg: message, reason: DiscardDuplicate}}
+		}
+	}
+	if len(hashes) >= senderQuota {
+		return []*discardedMsg{{msg: message, reason: DiscardDuplicate}}
+	}
+
+	dropped := []*discardedMsg{}
+	size := msgSize(message)
+	for m.limit != 0 && m.size+size > m.limit {
+		queue, index := m.furthest()
+		if queue == nil || !msgQueueLess(message, (*queue)[index]) {
+			return append(dropped, &discardedMsg{msg: message, reason: DiscardMemoryBudget})
+		}
+		dropped = append(dropped, &discardedMsg{msg: m.remove(queue, index), reason: DiscardMemoryBudget})
+	}
 	queue := m.getQueue(msgToState(message.Type))
 	heap.Push(queue, message)
+	m.size += size
+	if m.senders == nil {
+		m.senders = map[senderKey][]string{}
+	}
+	m.senders[key] = append(m.senders[key], string(message.Hash))
+	return dropped
+}
+
+// furthest returns the queue and the index of the message furthest in the future, nil if the queues are empty
+func (m *msgQueue) furthest() (*msgQueueImpl, int) {
+	var (
+		furthestQueue *msgQueueImpl
+		furthestIndex int
+	)
+	for _, queue := range []*msgQueueImpl{&m.roundChangeStateQueue, &m.acceptStateQueue, &m.validateStateQueue} {
+		for i, msg := range *queue {
+			if furthestQueue == nil || msgQueueLess((*furthestQueue)[furthestIndex], msg) {
+				furthestQueue, furthestIndex = queue, i
+			}
+		}
+	}
+	return furthestQueue, furthestIndex
+}
+
+// remove removes the message at the index of the queue
+func (m *msgQueue) remove(queue *msgQueueImpl, index int) *MessageReq {
+	msg := heap.Remove(queue, index).(*MessageReq)
+	m.release(msg)
+	return msg
+}
+
+// release subtracts the size of a message removed from the queues and frees its sender quota
+func (m *msgQueue) release(msg *MessageReq) {
+	if size := msgSize(msg); size < m.size {
+		m.size -= size
+	} else {
+		m.size = 0
+	}
+	key := newSenderKey(msg)
+	hashes := m.senders[key]
+	for i, hash := range hashes {
+		if hash == string(msg.Hash) {
+			hashes = append(hashes[:i], hashes[i+1:]...)
+			break
+		}
+	}
+	if len(hashes) == 0 {
+		delete(m.senders, key)
+	} else {
+		m.senders[key] = hashes
+	}
+}
+
+// memory returns the estimated memory of the queued messages
+func (m *msgQueue) memory() uint64 {
+	m.queueLock.Lock()
+	defer m.queueLock.Unlock()
+
+	return m.size
 }
 
 // readMessage reads the message from a message queue, based on the current state and view
@@ -34,11 +172,18 @@ func (m *msgQueue) readMessage(state PbftState, current *View) *MessageReq {
 	return msg
 }
 
-func (m *msgQueue) readMessageWithDiscards(state PbftState, current *View) (*MessageReq, []*MessageReq) {
+// readMessageWithDiscards reads the message from a message queue and returns
+// the old messages discarded along the way with the reason they were discarded
+func (m *msgQueue) readMessageWithDiscards(state PbftState, current *View) (*MessageReq, []*discardedMsg) {
 	m.queueLock.Lock()
 	defer m.queueLock.Unlock()
 
-	discarded := []*MessageReq{}
+	discarded := []*discardedMsg{}
+	if state == AcceptState {
+		if msg := m.proposerMessage(current); msg != nil {
+			return msg, discarded
+		}
+	}
 	queue := m.getQueue(state)
 
 	for {
@@ -47,6 +192,19 @@ func (m *msgQueue) readMessageWithDiscards(state PbftState, current *View) (*Mes
 		}
 		msg := queue.head()
 
+		if msg.View.Sequence == current.Sequence && msg.View.Term != current.Term {
+			if msg.View.Term > current.Term {
+				// the message belongs to the next validator set membership, it is kept
+				// until this node reaches the term
+				return nil, discarded
+			}
+			// the message belongs to a previous validator set membership
+			heap.Pop(queue)
+			m.release(msg)
+			discarded = append(discarded, &discardedMsg{msg: msg, reason: DiscardDifferentTerm})
+			continue
+		}
+
 		// check if the message is from the future
 		if state == RoundChangeState {
 			// if we are in RoundChangeState we only care about sequence
@@ -66,10 +224,15 @@ func (m *msgQueue) readMessageWithDiscards(state PbftState, current *View) (*Mes
 		// at this point, 'msg' is good or old, in either case
 		// we have to remove it from the queue
 		heap.Pop(queue)
+		m.release(msg)
 
 		if cmpView(msg.View, current) < 0 {
 			// old value, try again
-			discarded = append(discarded, msg)
+			reason := DiscardStaleRound
+			if msg.View.Sequence < current.Sequence {
+				reason = DiscardWrongSequence
+			}
+			discarded = append(discarded, &discardedMsg{msg: msg, reason: reason})
 			continue
 		}
 
@@ -78,6 +241,14 @@ func (m *msgQueue) readMessageWithDiscards(state PbftState, current *View) (*Mes
 	}
 }
 
+// getQueueLens returns the number of messages in each queue
+func (m *msgQueue) getQueueLens() (acceptLen, validateLen, roundChangeLen int) {
+	m.queueLock.Lock()
+	defer m.queueLock.Unlock()
+
+	return m.acceptStateQueue.Len(), m.validateStateQueue.Len(), m.roundChangeStateQueue.Len()
+}
+
 // getQueue checks the passed in state, and returns the corresponding message queue
 func (m *msgQueue) getQueue(state PbftState) *msgQueueImpl {
 	if state == RoundChangeState {
@@ -109,7 +280,7 @@ func msgToState(msg MsgType) PbftState {
 	} else if msg == MessageReq_Preprepare {
 		// preprepare
 		return AcceptState
-	} else if msg == MessageReq_Prepare || msg == MessageReq_Commit {
+	} else if msg == MessageReq_Prepare || msg == MessageReq_Commit || msg == MessageReq_Committed {
 		// prepare and commit
 		return ValidateState
 	}
@@ -131,11 +302,20 @@ func (m msgQueueImpl) Len() int {
 
 // Less compares the priorities of two items at the passed in indexes (A < B)
 func (m msgQueueImpl) Less(i, j int) bool {
-	ti, tj := m[i], m[j]
+	return msgQueueLess(m[i], m[j])
+}
+
+// msgQueueLess returns true if the message ti is read before the message tj
+func msgQueueLess(ti, tj *MessageReq) bool {
 	// sort by sequence
 	if ti.View.Sequence != tj.View.Sequence {
 		return ti.View.Sequence < tj.View.Sequence
 	}
+	// sort by term, the messages of the previous terms are discarded
+	// before the ones of the current term and the next terms are read last
+	if ti.View.Term != tj.View.Term {
+		return ti.View.Term < tj.View.Term
+	}
 	// sort by round
 	if ti.View.Round != tj.View.Round {
 		return ti.View.Round < tj.View.Round
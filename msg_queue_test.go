@@ -62,6 +62,91 @@ func TestMsgQueue_RoundChangeState(t *testing.T) {
 	}
 }
 
+func TestMsgQueue_DifferentTerm(t *testing.T) {
+	m := newMsgQueue()
+
+	current := ViewMsg(1, 0)
+	current.Term = 2
+
+	oldTerm := ViewMsg(1, 0)
+	oldTerm.Term = 1
+	m.pushMessage(mockQueueMsg("A", MessageReq_Prepare, oldTerm))
+	m.pushMessage(mockQueueMsg("B", MessageReq_Prepare, current.Copy()))
+
+	msg, discards := m.readMessageWithDiscards(ValidateState, current)
+	assert.Equal(t, NodeID("B"), msg.From)
+	assert.Len(t, discards, 1)
+	assert.Equal(t, NodeID("A"), discards[0].msg.From)
+	assert.Equal(t, DiscardDifferentTerm, discards[0].reason)
+}
+
+func TestMsgQueue_FutureTerm(t *testing.T) {
+	m := newMsgQueue()
+
+	current := ViewMsg(1, 0)
+	current.Term = 1
+
+	// the node is behind at the epoch boundary, the messages of the next term are kept
+	nextTerm := ViewMsg(1, 0)
+	nextTerm.Term = 2
+	m.pushMessage(mockQueueMsg("A", MessageReq_Prepare, nextTerm))
+	m.pushMessage(mockQueueMsg("B", MessageReq_Prepare, current.Copy()))
+
+	msg, discards := m.readMessageWithDiscards(ValidateState, current)
+	assert.Equal(t, NodeID("B"), msg.From)
+	assert.Empty(t, discards)
+
+	msg, discards = m.readMessageWithDiscards(ValidateState, current)
+	assert.Nil(t, msg)
+	assert.Empty(t, discards)
+
+	// and read once the node reaches the term
+	msg, discards = m.readMessageWithDiscards(ValidateState, nextTerm)
+	assert.Equal(t, NodeID("A"), msg.From)
+	assert.Empty(t, discards)
+}
+
+func TestMsgQueue_DiscardReasons(t *testing.T) {
+	m := newMsgQueue()
+
+	m.pushMessage(mockQueueMsg("A", MessageReq_Prepare, ViewMsg(1, 0)))
+	m.pushMessage(mockQueueMsg("B", MessageReq_Prepare, ViewMsg(2, 0)))
+	m.pushMessage(mockQueueMsg("C", MessageReq_Prepare, ViewMsg(2, 1)))
+
+	msg, discards := m.readMessageWithDiscards(ValidateState, ViewMsg(2, 1))
+	assert.Equal(t, NodeID("C"), msg.From)
+	assert.Len(t, discards, 2)
+	assert.Equal(t, DiscardWrongSequence, discards[0].reason)
+	assert.Equal(t, DiscardStaleRound, discards[1].reason)
+}
+
+func TestMsgQueue_ProposerFirst(t *testing.T) {
+	m := newMsgQueue()
+
+	m.pushMessage(mockQueueMsg("C", MessageReq_Preprepare, ViewMsg(1, 0)))
+	m.pushMessage(mockQueueMsg("D", MessageReq_Preprepare, ViewMsg(1, 0)))
+	m.pushMessage(mockQueueMsg("B", MessageReq_Preprepare, ViewMsg(1, 1)))
+	m.pushMessage(mockQueueMsg("B", MessageReq_Preprepare, ViewMsg(1, 0)))
+
+	// without the proposer the messages are read in order
+	assert.Equal(t, NodeID("C"), m.readMessage(AcceptState, ViewMsg(1, 0)).From)
+
+	// the preprepare of the proposer of the current view is read first
+	m.setProposer(ViewMsg(1, 0), "B")
+	msg := m.readMessage(AcceptState, ViewMsg(1, 0))
+	assert.Equal(t, NodeID("B"), msg.From)
+	assert.Equal(t, ViewMsg(1, 0), msg.View)
+	assert.Equal(t, NodeID("D"), m.readMessage(AcceptState, ViewMsg(1, 0)).From)
+
+	// the proposer of another view is ignored
+	m.pushMessage(mockQueueMsg("C", MessageReq_Preprepare, ViewMsg(1, 1)))
+	m.setProposer(ViewMsg(1, 0), "B")
+	assert.Equal(t, NodeID("B"), m.readMessage(AcceptState, ViewMsg(1, 1)).From)
+	m.setProposer(ViewMsg(1, 1), "C")
+	assert.Equal(t, NodeID("C"), m.readMessage(AcceptState, ViewMsg(1, 1)).From)
+	assert.Zero(t, m.memory())
+}
+
 func Test_msgToState(t *testing.T) {
 	expectedResult := map[MsgType]PbftState{
 		MessageReq_RoundChange: RoundChangeState,
@@ -0,0 +1,26 @@
+package pbft
+
+import "time"
+
+// StateNotifier enables the embedder to control the timing of the state machine
+// without forking the run loop (i.e. external schedulers or replay frameworks)
+type StateNotifier interface {
+	// HandleTimeout is called whenever a timeout fires while the state machine waits for messages.
+	// It returns zero to let the timeout take effect, or a positive duration to postpone it
+	HandleTimeout(state PbftState, view *View) time.Duration
+}
+
+func WithNotifier(notifier StateNotifier) ConfigOption {
+	return func(c *Config) {
+		c.Notifier = notifier
+	}
+}
+
+// handleTimeout consults the notifier, if any, about a fired timeout.
+// It returns the duration to postpone the timeout, zero if the timeout takes effect
+func (p *Pbft) handleTimeout() time.Duration {
+	if p.config.Notifier == nil {
+		return 0
+	}
+	return p.config.Notifier.HandleTimeout(p.getState(), p.state.getView())
+}
@@ -0,0 +1,43 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockNotifier struct {
+	timeouts []PbftState
+	postpone []time.Duration
+}
+
+func (m *mockNotifier) HandleTimeout(state PbftState, view *View) time.Duration {
+	m.timeouts = append(m.timeouts, state)
+	if len(m.postpone) == 0 {
+		return 0
+	}
+	postpone := m.postpone[0]
+	m.postpone = m.postpone[1:]
+	return postpone
+}
+
+func TestNotifier_PostponeTimeout(t *testing.T) {
+	notifier := &mockNotifier{
+		postpone: []time.Duration{time.Millisecond, time.Millisecond},
+	}
+
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.Notifier = notifier
+	m.setState(ValidateState)
+
+	m.runCycle(context.Background())
+
+	// the timeout is postponed twice and then it takes effect
+	assert.Equal(t, []PbftState{ValidateState, ValidateState, ValidateState}, notifier.timeouts)
+	m.expect(expectResult{
+		sequence: 1,
+		state:    RoundChangeState,
+	})
+}
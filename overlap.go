@@ -0,0 +1,85 @@
+package pbft
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// OverlappingRunError is reported when the embedder drives the engine in a way that would make
+// the node take part twice in the consensus of the same sequence (i.e. calling Run concurrently,
+// calling SetBackend while Run is running or running again a sequence already finalized).
+// The node could propose or vote twice for the same height and equivocate against itself
+type OverlappingRunError struct {
+	// Sequence is the sequence of the refused run
+	Sequence uint64
+
+	// Reason describes the overlap
+	Reason string
+}
+
+func (e *OverlappingRunError) Error() string {
+	return fmt.Sprintf("overlapping run for sequence %d: %s", e.Sequence, e.Reason)
+}
+
+// the values of the running flag of the engine
+const (
+	runIdle uint64 = iota
+
+	// runRunning is set while the state machine loop is running
+	runRunning
+
+	// runResetting is set while SetBackend or CatchUp reset the engine
+	runResetting
+)
+
+// startRun marks the state machine loop as running. It returns an error if another run is in
+// progress, the engine is being reset, the current sequence was already finalized by a previous
+// run or is below the highest view signed by this node. A run of the sequence of the highest
+// signed view starts at the next round, the node never signs twice for the same view
+func (p *Pbft) startRun() error {
+	if !atomic.CompareAndSwapUint64(&p.running, runIdle, runRunning) {
+		reason := "run in progress"
+		if atomic.LoadUint64(&p.running) == runResetting {
+			reason = "backend set while running"
+		}
+		return &OverlappingRunError{Sequence: p.state.getView().Sequence, Reason: reason}
+	}
+	sequence := p.state.view.Sequence
+	if p.finalizedRun && p.lastFinalized == sequence {
+		atomic.StoreUint64(&p.running, runIdle)
+		return &OverlappingRunError{Sequence: sequence, Reason: "sequence already finalized"}
+	}
+	if signed := p.signedView; signed != nil {
+		if sequence < signed.Sequence {
+			atomic.StoreUint64(&p.running, runIdle)
+			return &OverlappingRunError{Sequence: sequence, Reason: fmt.Sprintf("below the signed view %s", signed)}
+		}
+		if sequence == signed.Sequence && p.state.view.Round <= signed.Round {
+			p.logger.Printf("[INFO] run resumed after the signed view: view=%s", signed)
+			p.state.setRound(signed.Round + 1)
+		}
+	}
+	return nil
+}
+
+// recordSigned records the view of a message signed by this node, if it is the highest one
+func (p *Pbft) recordSigned(view *View) {
+	if p.signedView == nil || cmpView(view, p.signedView) > 0 {
+		p.signedView = view.Copy()
+	}
+}
+
+// endRun marks the state machine loop as stopped and records the finalized sequence, if any
+func (p *Pbft) endRun() {
+	if p.getState() == DoneState {
+		p.finalizedRun = true
+		p.lastFinalized = p.state.view.Sequence
+	}
+	atomic.StoreUint64(&p.running, runIdle)
+}
+
+// refuseRun reports the overlapping run, the engine is left untouched
+func (p *Pbft) refuseRun(err error) {
+	p.logger.Printf("[ERROR] run refused: %v", err)
+	p.health.setErr(err)
+}
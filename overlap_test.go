@@ -0,0 +1,107 @@
+package pbft
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlappingRun_SameSequence(t *testing.T) {
+	m := newMockPbft(t, []string{"A"}, "A")
+	m.config.DevMode = true
+	m.state.devMode = true
+	m.setProposal(&Proposal{Data: mockProposal})
+
+	m.Run(context.Background())
+	assert.True(t, m.IsState(DoneState))
+	sent := len(m.respMsg)
+
+	// the driver loop runs the same sequence again without moving to the next height
+	m.Run(context.Background())
+	assert.True(t, m.IsState(DoneState))
+	assert.Len(t, m.respMsg, sent)
+
+	var overlap *OverlappingRunError
+	assert.True(t, errors.As(m.Health().LastError, &overlap))
+	assert.Equal(t, uint64(1), overlap.Sequence)
+
+	// the next sequence runs
+	m.sequence = 2
+	assert.NoError(t, m.SetBackend(m.backend))
+	m.Run(context.Background())
+	assert.True(t, m.IsState(DoneState))
+}
+
+func TestOverlappingRun_Concurrent(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+
+	// a run is in progress
+	assert.NoError(t, m.startRun())
+
+	m.Run(context.Background())
+	var overlap *OverlappingRunError
+	assert.True(t, errors.As(m.Health().LastError, &overlap))
+	assert.Equal(t, "run in progress", overlap.Reason)
+
+	err := m.SetBackend(m.backend)
+	assert.True(t, errors.As(err, &overlap))
+
+	m.endRun()
+	assert.NoError(t, m.SetBackend(m.backend))
+}
+
+// resetRaceBackend tries to start a run while the engine is being reset
+type resetRaceBackend struct {
+	*mockBackend
+	p   *Pbft
+	err error
+}
+
+func (r *resetRaceBackend) Height() uint64 {
+	r.err = r.p.startRun()
+	return r.mockBackend.Height()
+}
+
+func TestOverlappingRun_DuringSetBackend(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	backend := &resetRaceBackend{mockBackend: m.backend.(*mockBackend), p: m.Pbft}
+
+	// the running flag is held for the whole reset
+	assert.NoError(t, m.SetBackend(backend))
+	var overlap *OverlappingRunError
+	assert.True(t, errors.As(backend.err, &overlap))
+	assert.Equal(t, "backend set while running", overlap.Reason)
+	assert.False(t, m.Health().Running)
+
+	// and released afterwards
+	assert.NoError(t, m.startRun())
+	m.endRun()
+}
+
+func TestOverlappingRun_SignedView(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.setProposal(&Proposal{Data: mockProposal})
+
+	// the proposer signs the preprepare and the prepare of the first round and stops
+	m.setState(AcceptState)
+	m.runCycle(context.Background())
+	assert.Equal(t, ViewMsg(1, 0), m.signedView)
+
+	// the same sequence starts again after the signed round
+	assert.NoError(t, m.SetBackend(m.backend))
+	assert.Equal(t, uint64(0), m.state.view.Round)
+	assert.NoError(t, m.startRun())
+	assert.Equal(t, ViewMsg(1, 1), m.state.view)
+	m.endRun()
+
+	// a sequence below the signed view is refused
+	m.sequence = 0
+	assert.NoError(t, m.SetBackend(m.backend))
+	err := m.startRun()
+	var overlap *OverlappingRunError
+	assert.True(t, errors.As(err, &overlap))
+	assert.Equal(t, uint64(0), overlap.Sequence)
+	assert.False(t, m.Health().Running)
+}
@@ -0,0 +1,39 @@
+package pbft
+
+import (
+	"context"
+	"time"
+)
+
+// WithMinRoundDuration paces the finalization to a target block time: once the commit quorum
+// is reached, the engine waits until the duration has elapsed since the start of the height
+// before inserting the proposal. It is meant for fast networks where the quorum is reached
+// right away, instead of delaying the proposal time. Zero disables the pacing
+func WithMinRoundDuration(d time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.MinRoundDuration = d
+	}
+}
+
+// paceFinalization waits until MinRoundDuration has elapsed since the start of the height,
+// with the timer of the state machine loop. It returns false if the context is done before
+func (p *Pbft) paceFinalization(ctx context.Context) bool {
+	if p.config.MinRoundDuration <= 0 || p.heightStart.IsZero() {
+		return true
+	}
+	remaining := time.Until(p.heightStart.Add(p.config.MinRoundDuration))
+	if remaining <= 0 {
+		return true
+	}
+	p.logger.Printf("[DEBUG] pace finalization: sequence=%d, wait=%s", p.state.view.Sequence, remaining)
+
+	timerCh := p.timer.reset("MinRoundDuration", remaining)
+	defer p.timer.stop()
+
+	select {
+	case <-timerCh:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
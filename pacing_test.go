@@ -0,0 +1,54 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransition_CommitState_MinRoundDuration(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.config.MinRoundDuration = 100 * time.Millisecond
+	m.heightStart = time.Now()
+	m.state.proposer = "A"
+	m.setState(CommitState)
+
+	m.runCycle(context.Background())
+	assert.True(t, m.IsState(DoneState))
+	assert.GreaterOrEqual(t, int64(time.Since(m.heightStart)), int64(m.config.MinRoundDuration))
+	assert.False(t, m.TimerState().Active)
+}
+
+func TestTransition_CommitState_MinRoundDuration_Elapsed(t *testing.T) {
+	// the height already took longer than the minimum duration
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.config.MinRoundDuration = 1 * time.Hour
+	m.heightStart = time.Now().Add(-2 * time.Hour)
+	m.state.proposer = "A"
+	m.setState(CommitState)
+
+	m.runCycle(context.Background())
+	assert.True(t, m.IsState(DoneState))
+}
+
+func TestTransition_CommitState_MinRoundDuration_Closed(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.config.MinRoundDuration = 1 * time.Hour
+	m.heightStart = time.Now()
+	m.state.proposer = "A"
+	m.setState(CommitState)
+
+	go func() {
+		assert.Eventually(t, func() bool {
+			return m.TimerState().Name == "MinRoundDuration"
+		}, time.Second, time.Millisecond)
+		m.Close()
+	}()
+
+	// the node is stopped before the proposal is inserted
+	m.runCycle(context.Background())
+	assert.True(t, m.IsState(CommitState))
+	assert.False(t, m.TimerState().Active)
+}
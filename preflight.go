@@ -0,0 +1,107 @@
+package pbft
+
+import "fmt"
+
+// Preflight checks whether the message would be accepted by the engine in the current
+// state, without queueing it nor changing the state (i.e. for filtering at the transport
+// level or for RPC validation endpoints). It checks the format, the view, the chain,
+// the membership of the sender and the commit seals. It is safe for concurrent use
+// once the backend is set
+func (p *Pbft) Preflight(msg *MessageReq) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	current := p.state.getView()
+	if msg.Type != MessageReq_Status {
+		if err := validateView(msg.View, current); err != nil {
+			return err
+		}
+	}
+	if msg.ChainID != p.config.ChainID {
+		return fmt.Errorf("message from a different chain: chain=%d", msg.ChainID)
+	}
+	if p.config.StrictMode == StrictEnforced {
+		if err := p.strictViolation(msg, true); err != nil {
+			return fmt.Errorf("message discarded: %s: %v", DiscardStrictViolation, err)
+		}
+	}
+	msg, err := p.resolveSender(msg)
+	if err != nil {
+		return fmt.Errorf("message discarded: %s: %v", DiscardUnknownSessionKey, err)
+	}
+	if p.quarantine.contains(msg.From) {
+		return fmt.Errorf("sender %s is quarantined", msg.From)
+	}
+	validators := p.state.getValidators()
+	if current == nil || validators == nil {
+		return fmt.Errorf("backend not set")
+	}
+	if msg.Type == MessageReq_Status {
+		if !validators.Includes(msg.From) {
+			return fmt.Errorf("message discarded: %s", DiscardNotValidator)
+		}
+		return nil
+	}
+	if msg.Type == MessageReq_ProposalRequest {
+		if !validators.Includes(msg.From) {
+			return fmt.Errorf("message discarded: %s", DiscardNotValidator)
+		}
+		return nil
+	}
+	if msg.Type == MessageReq_ProposalChunk {
+		// chunks are reassembled before going through the consensus flow
+		return nil
+	}
+	if cmpView(msg.View, current) < 0 {
+		reason := DiscardStaleRound
+		if msg.View.Sequence < current.Sequence {
+			reason = DiscardWrongSequence
+		}
+		return fmt.Errorf("message discarded: %s", reason)
+	}
+	if msg.View.Sequence == current.Sequence && msg.View.Term < current.Term {
+		return fmt.Errorf("message discarded: %s", DiscardDifferentTerm)
+	}
+	if msg.View.Sequence == current.Sequence && msg.View.Term > current.Term {
+		// the validators of the next term are not known yet, the message is queued
+		return nil
+	}
+	if msg.Type != MessageReq_Committed && !validators.Includes(msg.From) {
+		return fmt.Errorf("message discarded: %s", DiscardNotValidator)
+	}
+
+	switch msg.Type {
+	case MessageReq_Commit:
+		return p.preflightSeal(msg.From, msg.Seal)
+
+	case MessageReq_Prepare:
+		// the prepare messages of the fast path carry the commit seal
+		if p.config.FastPath && msg.View.Round == 0 && len(msg.Seal) != 0 {
+			return p.preflightSeal(msg.From, msg.Seal)
+		}
+
+	case MessageReq_Committed:
+		for _, seal := range msg.CommittedSeals {
+			if !validators.Includes(seal.Signer) {
+				return fmt.Errorf("seal from non validator %s", seal.Signer)
+			}
+			if err := p.preflightSeal(seal.Signer, seal.Seal); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// preflightSeal validates the commit seal with the backend. A panic of the backend is
+// reported as an error and does not move the engine to the faulted state (see recoverBackend)
+func (p *Pbft) preflightSeal(from NodeID, seal []byte) error {
+	var validateErr error
+	if err := recoverBackend("ValidateCommit", func() { validateErr = p.getBackend().ValidateCommit(from, seal) }); err != nil {
+		return err
+	}
+	if validateErr != nil {
+		return fmt.Errorf("invalid seal from %s: %v", from, validateErr)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package pbft
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSealBackend struct {
+	*mockBackend
+	invalid NodeID
+}
+
+func (m *mockSealBackend) ValidateCommit(from NodeID, seal []byte) error {
+	if from == m.invalid {
+		return errors.New("bad seal")
+	}
+	return nil
+}
+
+func TestPreflight(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.backend = &mockSealBackend{mockBackend: m.backend.(*mockBackend), invalid: "C"}
+	m.state.setView(&View{Sequence: 2, Round: 1})
+
+	cases := []struct {
+		name string
+		msg  *MessageReq
+		err  bool
+	}{
+		{"valid prepare", &MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(2, 1)}, false},
+		{"future round", &MessageReq{Type: MessageReq_RoundChange, From: "B", View: ViewMsg(2, 5)}, false},
+		{"missing view", &MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest}, true},
+		{"round too far", &MessageReq{Type: MessageReq_RoundChange, From: "B", View: ViewMsg(2, 1000)}, true},
+		{"stale round", &MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(2, 0)}, true},
+		{"old sequence", &MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(1, 3)}, true},
+		{"different chain", &MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(2, 1), ChainID: 5}, true},
+		{"not validator", &MessageReq{Type: MessageReq_Prepare, From: "X", Hash: digest, View: ViewMsg(2, 1)}, true},
+		{"valid commit", &MessageReq{Type: MessageReq_Commit, From: "B", Hash: digest, View: ViewMsg(2, 1)}, false},
+		{"invalid seal", &MessageReq{Type: MessageReq_Commit, From: "C", Hash: digest, View: ViewMsg(2, 1)}, true},
+		{"invalid relayed seal", &MessageReq{Type: MessageReq_Committed, From: "X", Hash: digest, View: ViewMsg(2, 1), CommittedSeals: []CommittedSeal{{Signer: "B"}, {Signer: "C"}}}, true},
+	}
+	for _, c := range cases {
+		err := m.Preflight(c.msg)
+		assert.Equal(t, c.err, err != nil, c.name)
+	}
+
+	// the checks do not change the state
+	assert.Nil(t, m.msgQueue.readMessage(ValidateState, m.state.view))
+	assert.Empty(t, m.Stats().Discards)
+}
+
+type panicSealBackend struct {
+	*mockBackend
+}
+
+func (p *panicSealBackend) ValidateCommit(from NodeID, seal []byte) error {
+	panic("boom")
+}
+
+func TestPreflight_SealPanic(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.backend = &panicSealBackend{mockBackend: m.backend.(*mockBackend)}
+	m.setState(ValidateState)
+
+	err := m.Preflight(&MessageReq{Type: MessageReq_Commit, From: "B", Hash: digest, View: ViewMsg(1, 0)})
+	assert.True(t, errors.Is(err, errBackendPanic))
+	assert.True(t, m.IsState(ValidateState))
+}
@@ -0,0 +1,40 @@
+package pbft
+
+// ProposalExpiredEvent is emitted when the proposer of the round does not propose its locked
+// proposal again because the round is past its MaxRound
+type ProposalExpiredEvent struct {
+	// View is the view of the round
+	View *View
+
+	// Hash is the hash of the expired proposal
+	Hash []byte
+
+	// MaxRound is the last round in which the proposal could be decided
+	MaxRound uint64
+}
+
+func (e *ProposalExpiredEvent) EventName() string {
+	return "ProposalExpired"
+}
+
+// expired returns true if the proposal cannot be decided in the round
+func (p *Proposal) expired(round uint64) bool {
+	return p.MaxRound != nil && round > *p.MaxRound
+}
+
+// lockExpired checks whether the locked proposal expired and emits the ProposalExpiredEvent.
+// Like a veto of the backend (see ReproposalBackend), an expired lock only stops the proposer
+// from proposing it again: the lock is released by a round change justification only
+func (p *Pbft) lockExpired() bool {
+	proposal := p.state.proposal
+	if !p.state.locked || proposal == nil || !proposal.expired(p.state.view.Round) {
+		return false
+	}
+	p.logger.Printf("[INFO] locked proposal expired: round=%d, max round=%d", p.state.view.Round, *proposal.MaxRound)
+	p.emit(&ProposalExpiredEvent{
+		View:     p.state.view.Copy(),
+		Hash:     proposal.Hash,
+		MaxRound: *proposal.MaxRound,
+	})
+	return true
+}
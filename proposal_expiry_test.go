@@ -0,0 +1,246 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProposal_Expired(t *testing.T) {
+	maxRound := uint64(2)
+	proposal := &Proposal{Hash: digest}
+	assert.False(t, proposal.expired(100))
+
+	proposal.MaxRound = &maxRound
+	assert.False(t, proposal.expired(2))
+	assert.True(t, proposal.expired(3))
+
+	// the copy does not share the max round
+	copied := proposal.Copy()
+	*copied.MaxRound = 5
+	assert.Equal(t, uint64(2), *proposal.MaxRound)
+}
+
+func TestTransition_AcceptState_Proposer_Locked_Expired(t *testing.T) {
+	// A is the proposer of round 1 and the locked proposal expired in round 0, the backend
+	// is not consulted and a fresh proposal needs a round change justification like a veto
+	setup := func(t *testing.T) (*mockPbft, *mockReproposalBackend, *[]Event) {
+		i := newMockPbft(t, []string{"B", "A", "C", "D"}, "A")
+		backend := &mockReproposalBackend{mockBackend: i.backend.(*mockBackend), accept: true}
+		backend.HookBuildProposalHandler(func() (*Proposal, error) {
+			return &Proposal{
+				Data: mockProposal1,
+				Hash: digest1,
+			}, nil
+		})
+		i.backend = backend
+		i.setState(AcceptState)
+		i.state.view.Round = 1
+
+		events := &[]Event{}
+		i.config.EventHandler = func(e Event) {
+			*events = append(*events, e)
+		}
+
+		maxRound := uint64(0)
+		i.state.locked = true
+		i.state.proposal = &Proposal{
+			Data:     mockProposal,
+			Hash:     digest,
+			MaxRound: &maxRound,
+		}
+		return i, backend, events
+	}
+	expired := &ProposalExpiredEvent{
+		View:     ViewMsg(1, 1),
+		Hash:     digest,
+		MaxRound: 0,
+	}
+
+	t.Run("Justified", func(t *testing.T) {
+		i, backend, events := setup(t)
+		for _, seal := range roundChangeJustification(ViewMsg(1, 1), "B", "C", "D") {
+			i.state.AddRoundMessage(&MessageReq{Type: MessageReq_RoundChange, From: seal.Signer, Seal: seal.Seal, View: ViewMsg(1, 1)})
+		}
+
+		i.runCycle(context.Background())
+
+		i.expect(expectResult{
+			sequence: 1,
+			round:    1,
+			state:    ValidateState,
+			outgoing: 2, // preprepare and prepare
+		})
+		assert.Equal(t, mockProposal1, i.state.proposal.Data)
+		assert.Empty(t, backend.rounds)
+		assert.Contains(t, *events, expired)
+		assert.Equal(t, roundChangeJustification(ViewMsg(1, 1), "B", "C", "D"), i.respMsg[0].CommittedSeals)
+	})
+
+	t.Run("NotJustified", func(t *testing.T) {
+		// without the round changes of the round a quorum may have committed the locked proposal
+		i, backend, events := setup(t)
+
+		i.runCycle(context.Background())
+
+		i.expect(expectResult{
+			sequence: 1,
+			round:    1,
+			state:    ValidateState,
+			locked:   true,
+			outgoing: 2, // preprepare and prepare
+		})
+		assert.Equal(t, mockProposal, i.state.proposal.Data)
+		assert.Empty(t, backend.rounds)
+		assert.Contains(t, *events, expired)
+	})
+}
+
+func TestTransition_AcceptState_Validator_Locked_Expired(t *testing.T) {
+	// C is locked on a proposal expired in round 0, B is the proposer of round 1
+	cases := []struct {
+		name     string
+		proposal []byte
+		hash     []byte
+		seals    []CommittedSeal
+		rejected bool
+	}{
+		{"Reproposal", mockProposal, digest, nil, false},
+		{"FreshNotJustified", mockProposal1, digest1, nil, true},
+		{"FreshJustified", mockProposal1, digest1, roundChangeJustification(ViewMsg(1, 1), "A", "B", "D"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			i := newMockPbft(t, []string{"A", "B", "C", "D"}, "C")
+			i.state.view = ViewMsg(1, 1)
+			i.setState(AcceptState)
+
+			maxRound := uint64(0)
+			i.state.proposal = &Proposal{
+				Data:     mockProposal,
+				Hash:     digest,
+				MaxRound: &maxRound,
+			}
+			i.state.lock()
+
+			i.emitMsg(&MessageReq{
+				From:           "B",
+				Type:           MessageReq_Preprepare,
+				Proposal:       c.proposal,
+				Hash:           c.hash,
+				View:           ViewMsg(1, 1),
+				CommittedSeals: c.seals,
+			})
+
+			i.runCycle(context.Background())
+
+			// the expired lock does not release the validator by itself
+			if c.rejected {
+				i.expect(expectResult{
+					sequence: 1,
+					round:    1,
+					state:    RoundChangeState,
+					locked:   true,
+					err:      errIncorrectLockedProposal,
+				})
+				return
+			}
+			assert.Equal(t, ValidateState, i.getState())
+			assert.Equal(t, c.seals == nil, i.state.locked)
+			assert.Equal(t, c.hash, i.state.proposal.Hash)
+		})
+	}
+}
+
+func TestTransition_AcceptState_Proposer_Locked_NotExpired(t *testing.T) {
+	i := newMockPbft(t, []string{"B", "A", "C", "D"}, "A")
+	i.setState(AcceptState)
+	i.state.view.Round = 1
+
+	maxRound := uint64(1)
+	i.state.locked = true
+	i.state.proposal = &Proposal{
+		Data:     mockProposal,
+		Hash:     digest,
+		MaxRound: &maxRound,
+	}
+
+	i.runCycle(context.Background())
+
+	i.expect(expectResult{
+		sequence: 1,
+		round:    1,
+		state:    ValidateState,
+		locked:   true,
+		outgoing: 2, // preprepare and prepare
+	})
+	assert.Equal(t, mockProposal, i.state.proposal.Data)
+}
+
+func TestProposalExpiry_LockReplaced(t *testing.T) {
+	// A and C are locked on a proposal expired in round 0, A is the proposer of round 1. The
+	// round changes sent by the unlocked validators release the locks for a fresh proposal
+	accounts := []string{"B", "A", "C", "D", "E", "F", "G"}
+	i := newMockPbft(t, accounts, "A")
+	i.setProposal(&Proposal{Data: mockProposal1, Time: time.Now()})
+
+	events := &[]Event{}
+	i.config.EventHandler = func(e Event) {
+		*events = append(*events, e)
+	}
+
+	maxRound := uint64(0)
+	i.state.proposal = &Proposal{
+		Data:     mockProposal,
+		Hash:     digest,
+		MaxRound: &maxRound,
+	}
+	i.state.lock()
+	i.setState(RoundChangeState)
+	i.emitMsg(sentRoundChange(t, accounts, "C", true))
+	for _, from := range []string{"B", "D", "E", "F", "G"} {
+		i.emitMsg(sentRoundChange(t, accounts, from, false))
+	}
+
+	i.runCycle(context.Background())
+	i.runCycle(context.Background())
+	i.expect(expectResult{
+		sequence: 1,
+		round:    1,
+		state:    ValidateState,
+		outgoing: 3, // round change, preprepare and prepare
+	})
+	assert.Equal(t, mockProposal1, i.state.proposal.Data)
+
+	expired := 0
+	for _, e := range *events {
+		if _, ok := e.(*ProposalExpiredEvent); ok {
+			expired++
+		}
+	}
+	assert.Equal(t, 1, expired)
+
+	// the validator locked on the expired proposal accepts the justified fresh proposal
+	preprepare := i.respMsg[1]
+	require.Equal(t, MessageReq_Preprepare, preprepare.Type)
+	assert.Equal(t, roundChangeJustification(ViewMsg(1, 1), "B", "D", "E", "F", "G"), preprepare.CommittedSeals)
+
+	c := newMockPbft(t, accounts, "C")
+	c.state.view = ViewMsg(1, 1)
+	c.state.proposal = &Proposal{
+		Data:     mockProposal,
+		Hash:     digest,
+		MaxRound: &maxRound,
+	}
+	c.state.lock()
+	c.setState(AcceptState)
+	c.emitMsg(preprepare)
+
+	c.runCycle(context.Background())
+	assert.Equal(t, ValidateState, c.getState())
+	assert.False(t, c.state.locked)
+	assert.Equal(t, mockProposal1, c.state.proposal.Data)
+}
@@ -0,0 +1,96 @@
+package pbft
+
+import "bytes"
+
+// PreBuildEvent is emitted right before the backend builds a proposal, so that the
+// embedder can reserve the transactions it is about to include
+type PreBuildEvent struct {
+	// View is the view of the proposal
+	View *View
+}
+
+func (e *PreBuildEvent) EventName() string {
+	return "PreBuild"
+}
+
+// PostFinalizeEvent is emitted when the outcome of a proposal is known. Finalized is true
+// for the proposal inserted at the height, and false for a proposal built by this node
+// that was abandoned (the round failed or another proposal was finalized), so that the
+// embedder can release its transactions
+type PostFinalizeEvent struct {
+	// View is the view in which the outcome was decided
+	View *View
+
+	// Hash is the digest of the proposal
+	Hash []byte
+
+	// Finalized is whether the proposal was finalized
+	Finalized bool
+}
+
+func (e *PostFinalizeEvent) EventName() string {
+	return "PostFinalize"
+}
+
+// builtProposal is a proposal built by this node that has no outcome yet
+type builtProposal struct {
+	sequence uint64
+	hash     []byte
+}
+
+// buildProposal builds a new proposal surrounded by the pre-build and post-finalize notifications.
+// A previously built proposal of the same height is released since it is replaced
+func (p *Pbft) buildProposal() (err error) {
+	p.emit(&PreBuildEvent{View: p.state.view.Copy()})
+
+	var buildErr error
+	if err := p.guard("BuildProposal", func() { p.state.proposal, buildErr = p.backend.BuildProposal() }); err != nil {
+		return err
+	}
+	if buildErr != nil {
+		return buildErr
+	}
+	p.releaseBuilt()
+	p.built = &builtProposal{
+		sequence: p.state.view.Sequence,
+		hash:     append([]byte{}, p.state.proposal.Hash...),
+	}
+	return nil
+}
+
+// releaseBuilt notifies that the proposal built by this node, if any, was abandoned
+func (p *Pbft) releaseBuilt() {
+	if p.built == nil {
+		return
+	}
+	p.emit(&PostFinalizeEvent{
+		View: p.state.getView(),
+		Hash: p.built.hash,
+	})
+	p.built = nil
+}
+
+// releaseFailed notifies that the proposal built by this node was abandoned since its round failed,
+// unless the node is locked on it and proposes it again in the next rounds
+func (p *Pbft) releaseFailed() {
+	if p.built == nil {
+		return
+	}
+	if p.state.locked && p.state.proposal != nil && bytes.Equal(p.state.proposal.Hash, p.built.hash) {
+		return
+	}
+	p.releaseBuilt()
+}
+
+// notifyFinalized notifies that the proposal was finalized at the current height
+func (p *Pbft) notifyFinalized(hash []byte) {
+	if p.built != nil && bytes.Equal(p.built.hash, hash) {
+		p.built = nil
+	}
+	p.releaseBuilt()
+	p.emit(&PostFinalizeEvent{
+		View:      p.state.view.Copy(),
+		Hash:      append([]byte{}, hash...),
+		Finalized: true,
+	})
+}
@@ -0,0 +1,100 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProposalHooks_ReleaseAndFinalize(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+
+	// A is the proposer of round 0
+	m.setProposal(&Proposal{Data: mockProposal, Hash: digest})
+	m.setState(AcceptState)
+	m.runCycle(context.Background())
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, &PreBuildEvent{View: ViewMsg(1, 0)}, events[0])
+
+	// the round fails and a new proposal replaces the first one
+	m.state.unlock()
+	m.state.setRound(4)
+	m.setProposal(&Proposal{Data: mockProposal1, Hash: digest1})
+	m.setState(AcceptState)
+	m.runCycle(context.Background())
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, &PreBuildEvent{View: ViewMsg(1, 4)}, events[1])
+	assert.Equal(t, &PostFinalizeEvent{View: ViewMsg(1, 4), Hash: digest}, events[2])
+
+	// the second proposal is finalized
+	m.setState(CommitState)
+	m.runCycle(context.Background())
+
+	assert.True(t, m.IsState(DoneState))
+	assert.Len(t, events, 6)
+	assert.IsType(t, &QuorumSealsEvent{}, events[3])
+	assert.Equal(t, &PostFinalizeEvent{View: ViewMsg(1, 4), Hash: digest1, Finalized: true}, events[4])
+	assert.IsType(t, &HeightFinalizedEvent{}, events[5])
+	assert.Nil(t, m.built)
+}
+
+func TestProposalHooks_ReleaseOnSync(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	m.setProposal(&Proposal{Data: mockProposal, Hash: digest})
+	assert.NoError(t, m.buildProposal())
+
+	// the same height does not release the proposal
+	m.setSequence(1)
+	assert.Len(t, events, 1)
+
+	// the node synced to a later height
+	m.setSequence(3)
+	assert.Len(t, events, 2)
+	assert.Equal(t, &PostFinalizeEvent{View: ViewMsg(3, 0), Hash: digest}, events[1])
+}
+
+func TestProposalHooks_ReleaseOnRoundChange(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	m.setProposal(&Proposal{Data: mockProposal, Hash: digest})
+	assert.NoError(t, m.buildProposal())
+
+	// the node is locked on its proposal, it proposes it again in the next rounds
+	m.state.lock()
+	m.roundChange(RoundChangeTimeout)
+	assert.Len(t, events, 1)
+	assert.NotNil(t, m.built)
+
+	// the round of the proposal fails without a lock on it
+	m.state.locked = false
+	m.roundChange(RoundChangeTimeout)
+	assert.Len(t, events, 2)
+	assert.Equal(t, &PostFinalizeEvent{View: ViewMsg(1, 0), Hash: digest}, events[1])
+	assert.Nil(t, m.built)
+}
+
+func TestProposalHooks_FinalizeOnCatchUp(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	m.state.view = ViewMsg(5, 0)
+
+	assert.NoError(t, m.CatchUp(newFinalityProof(5, "A", "B", "C")))
+	assert.Contains(t, events, &PostFinalizeEvent{View: ViewMsg(5, 0), Hash: digest, Finalized: true})
+}
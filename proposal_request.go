@@ -0,0 +1,188 @@
+package pbft
+
+import (
+	"context"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WithProposalRequests lets a node that receives prepare or commit messages for a proposal
+// whose preprepare it never saw request the preprepare from the senders, up to retries times
+// per round every interval. The nodes always serve the requests of the preprepare they accepted
+func WithProposalRequests(retries int, interval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.ProposalRequestRetries = retries
+		c.ProposalRequestInterval = interval
+	}
+}
+
+// proposalRequests tracks, for the current view, the preprepare accepted by the node (served to
+// the peers that request it) and the digests announced by the prepare and commit messages
+// (requested from their senders while the node waits for the preprepare). It is updated from
+// the transport and from the state machine loop
+type proposalRequests struct {
+	lock sync.Mutex
+	view *View
+
+	// preprepare is the preprepare accepted or sent in the view
+	preprepare *MessageReq
+
+	// digests are the senders of the prepare and commit messages per digest
+	digests map[string][]NodeID
+
+	// served is the number of requests served per peer
+	served map[NodeID]int
+}
+
+func newProposalRequests() *proposalRequests {
+	return &proposalRequests{
+		digests: map[string][]NodeID{},
+		served:  map[NodeID]int{},
+	}
+}
+
+// resetLocked drops the state of the previous view
+func (r *proposalRequests) resetLocked(view *View) {
+	if r.view != nil && r.view.Sequence == view.Sequence && r.view.Round == view.Round {
+		return
+	}
+	r.view = view.Copy()
+	r.preprepare = nil
+	r.digests = map[string][]NodeID{}
+	r.served = map[NodeID]int{}
+}
+
+// setPreprepare stores the preprepare of the view with the whole proposal
+func (r *proposalRequests) setPreprepare(msg *MessageReq, proposal []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.resetLocked(msg.View)
+	preprepare := msg.Copy()
+	preprepare.ChunkCount = 0
+	preprepare.SetProposal(proposal)
+	r.preprepare = preprepare
+}
+
+// observe records the digest of a prepare or commit message of the current view
+func (r *proposalRequests) observe(msg *MessageReq, current *View) {
+	if msg.Type != MessageReq_Prepare && msg.Type != MessageReq_Commit {
+		return
+	}
+	if current == nil || msg.View.Sequence != current.Sequence || msg.View.Round != current.Round {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.resetLocked(current)
+	digest := hex.EncodeToString(msg.Hash)
+	for _, from := range r.digests[digest] {
+		if from == msg.From {
+			return
+		}
+	}
+	r.digests[digest] = append(r.digests[digest], msg.From)
+}
+
+// missing returns the digest announced by most senders in the view, along with the senders,
+// if the node did not see its preprepare
+func (r *proposalRequests) missing(view *View) ([]byte, []NodeID, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.view == nil || r.view.Sequence != view.Sequence || r.view.Round != view.Round {
+		return nil, nil, false
+	}
+	best := ""
+	for digest, senders := range r.digests {
+		if len(senders) > len(r.digests[best]) || (len(senders) == len(r.digests[best]) && digest < best) {
+			best = digest
+		}
+	}
+	if best == "" {
+		return nil, nil, false
+	}
+	hash, _ := hex.DecodeString(best)
+	if r.preprepare != nil && hex.EncodeToString(r.preprepare.Hash) == best {
+		return nil, nil, false
+	}
+	senders := append([]NodeID{}, r.digests[best]...)
+	sort.Slice(senders, func(i, j int) bool { return senders[i] < senders[j] })
+	return hash, senders, true
+}
+
+// serve returns the preprepare requested by the peer, at most maxServed times per view
+func (r *proposalRequests) serve(req *MessageReq, maxServed int) (*MessageReq, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	preprepare := r.preprepare
+	if preprepare == nil || preprepare.View.Sequence != req.View.Sequence || preprepare.View.Round != req.View.Round {
+		return nil, false
+	}
+	if hex.EncodeToString(preprepare.Hash) != hex.EncodeToString(req.Hash) {
+		return nil, false
+	}
+	if r.served[req.From] >= maxServed {
+		return nil, false
+	}
+	r.served[req.From]++
+	return preprepare.Copy(), true
+}
+
+// handleProposalRequest sends the requested preprepare back to the sender, if the node accepted it
+func (p *Pbft) handleProposalRequest(msg *MessageReq) {
+	if validators := p.state.getValidators(); validators == nil || !validators.Includes(msg.From) {
+		p.countDiscard(msg, DiscardNotValidator)
+		return
+	}
+	// serve every retry of the peer, at least once even if the local node does not request
+	maxServed := p.config.ProposalRequestRetries
+	if maxServed <= 0 {
+		maxServed = 1
+	}
+	preprepare, ok := p.proposalRequests.serve(msg, maxServed)
+	if !ok {
+		return
+	}
+	p.logger.Printf("[DEBUG] serve proposal: to=%s, sequence=%d, round=%d", msg.From, msg.View.Sequence, msg.View.Round)
+	p.stats.update(func(s *Stats) { s.ServedProposals++ })
+	p.sendTo([]NodeID{msg.From}, preprepare)
+}
+
+// runProposalRequests requests the missing preprepare of the view from the senders of the
+// prepare and commit messages, every ProposalRequestInterval and at most ProposalRequestRetries
+// times, until the context is canceled (the node accepted a preprepare or left the round)
+func (p *Pbft) runProposalRequests(ctx context.Context, view *View) {
+	if p.config.ProposalRequestRetries <= 0 || p.config.ProposalRequestInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.config.ProposalRequestInterval)
+	defer ticker.Stop()
+
+	for retries := 0; retries < p.config.ProposalRequestRetries; {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		hash, senders, ok := p.proposalRequests.missing(view)
+		if !ok {
+			continue
+		}
+		retries++
+		p.logger.Printf("[INFO] request proposal: sequence=%d, round=%d, attempt=%d", view.Sequence, view.Round, retries)
+		p.stats.update(func(s *Stats) { s.ProposalRequests++ })
+		p.sendTo(senders, &MessageReq{
+			Type:    MessageReq_ProposalRequest,
+			From:    p.validator.NodeID(),
+			ChainID: p.config.ChainID,
+			View:    view.Copy(),
+			Hash:    hash,
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package pbft
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProposalRequests_Missing(t *testing.T) {
+	r := newProposalRequests()
+	view := ViewMsg(1, 0)
+
+	_, _, ok := r.missing(view)
+	assert.False(t, ok)
+
+	// only the prepare and commit messages of the current view are observed
+	r.observe(&MessageReq{From: "A", Type: MessageReq_RoundChange, View: ViewMsg(1, 0)}, view)
+	r.observe(&MessageReq{From: "A", Type: MessageReq_Prepare, View: ViewMsg(1, 1), Hash: []byte{0x2}}, view)
+	_, _, ok = r.missing(view)
+	assert.False(t, ok)
+
+	r.observe(&MessageReq{From: "C", Type: MessageReq_Prepare, View: ViewMsg(1, 0), Hash: digest}, view)
+	r.observe(&MessageReq{From: "B", Type: MessageReq_Commit, View: ViewMsg(1, 0), Hash: digest}, view)
+	r.observe(&MessageReq{From: "B", Type: MessageReq_Prepare, View: ViewMsg(1, 0), Hash: digest}, view)
+	r.observe(&MessageReq{From: "D", Type: MessageReq_Prepare, View: ViewMsg(1, 0), Hash: []byte{0x2}}, view)
+
+	hash, senders, ok := r.missing(view)
+	assert.True(t, ok)
+	assert.Equal(t, digest, hash)
+	assert.Equal(t, []NodeID{"B", "C"}, senders)
+
+	// nothing is missing once the preprepare is accepted
+	r.setPreprepare(&MessageReq{From: "A", Type: MessageReq_Preprepare, View: ViewMsg(1, 0), Hash: digest}, mockProposal)
+	_, _, ok = r.missing(view)
+	assert.False(t, ok)
+
+	// a new view drops the state of the previous one
+	r.observe(&MessageReq{From: "B", Type: MessageReq_Prepare, View: ViewMsg(1, 1), Hash: digest}, ViewMsg(1, 1))
+	_, senders, ok = r.missing(ViewMsg(1, 1))
+	assert.True(t, ok)
+	assert.Equal(t, []NodeID{"B"}, senders)
+}
+
+func TestPbft_ServeProposalRequest(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	transport := &targetedTransport{mockPbft: m, sent: map[NodeID][]*MessageReq{}}
+	m.transport = transport
+
+	request := func(from NodeID, hash []byte) {
+		m.PushMessage(&MessageReq{From: from, Type: MessageReq_ProposalRequest, View: ViewMsg(1, 0), Hash: hash})
+	}
+
+	// nothing to serve before the preprepare is sent
+	request("D", digest)
+	assert.Empty(t, transport.sent)
+
+	m.proposalRequests.setPreprepare(&MessageReq{
+		From:       "A",
+		Type:       MessageReq_Preprepare,
+		View:       ViewMsg(1, 0),
+		Hash:       digest,
+		ChunkCount: 2,
+	}, mockProposal)
+
+	request("D", []byte{0x2})
+	request("xx", digest)
+	assert.Empty(t, transport.sent)
+	assert.Equal(t, uint64(1), m.Stats().Discards[DiscardNotValidator])
+
+	// the preprepare is served with the whole proposal, once per peer if the requests are disabled
+	request("D", digest)
+	request("D", digest)
+	if assert.Len(t, transport.sent["D"], 1) {
+		served := transport.sent["D"][0]
+		assert.Equal(t, MessageReq_Preprepare, served.Type)
+		assert.Equal(t, NodeID("A"), served.From)
+		assert.Equal(t, mockProposal, served.Proposal)
+		assert.Zero(t, served.ChunkCount)
+	}
+	assert.Equal(t, uint64(1), m.Stats().ServedProposals)
+}
+
+// requestTransport answers the proposal requests with the preprepare of the proposer
+type requestTransport struct {
+	*mockPbft
+
+	lock     sync.Mutex
+	requests []*MessageReq
+}
+
+func (r *requestTransport) Send(to []NodeID, msg *MessageReq) error {
+	r.lock.Lock()
+	r.requests = append(r.requests, msg)
+	r.lock.Unlock()
+
+	if msg.Type == MessageReq_ProposalRequest {
+		go r.mockPbft.PushMessage(&MessageReq{
+			From:     "A",
+			Type:     MessageReq_Preprepare,
+			View:     msg.View.Copy(),
+			Hash:     msg.Hash,
+			Proposal: mockProposal,
+		})
+	}
+	return nil
+}
+
+func (r *requestTransport) sentRequests() []*MessageReq {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]*MessageReq{}, r.requests...)
+}
+
+func TestTransition_AcceptState_RequestProposal(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "B")
+	m.config.ProposalRequestRetries = 3
+	m.config.ProposalRequestInterval = 10 * time.Millisecond
+	m.roundTimeout = func(uint64) time.Duration { return 5 * time.Second }
+	transport := &requestTransport{mockPbft: m}
+	m.transport = transport
+	m.setState(AcceptState)
+
+	// the preprepare of A is lost, but C and D prepared it
+	m.emitMsg(&MessageReq{From: "C", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	m.emitMsg(&MessageReq{From: "D", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+
+	m.runCycle(m.ctx)
+	assert.True(t, m.IsState(ValidateState))
+	assert.Equal(t, digest, m.state.proposal.Hash)
+
+	requests := transport.sentRequests()
+	if assert.NotEmpty(t, requests) {
+		assert.Equal(t, MessageReq_ProposalRequest, requests[0].Type)
+		assert.Equal(t, digest, requests[0].Hash)
+	}
+	assert.NotZero(t, m.Stats().ProposalRequests)
+}
+
+func TestTransition_AcceptState_RequestProposal_Bounded(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "B")
+	m.config.ProposalRequestRetries = 2
+	m.config.ProposalRequestInterval = time.Millisecond
+	m.roundTimeout = func(uint64) time.Duration { return 200 * time.Millisecond }
+	transport := &targetedTransport{mockPbft: m, sent: map[NodeID][]*MessageReq{}}
+	m.transport = transport
+	m.setState(AcceptState)
+
+	// nobody answers, the round times out after the retries
+	m.emitMsg(&MessageReq{From: "C", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+
+	m.runCycle(m.ctx)
+	assert.True(t, m.IsState(RoundChangeState))
+	assert.Equal(t, uint64(2), m.Stats().ProposalRequests)
+}
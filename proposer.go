@@ -0,0 +1,112 @@
+package pbft
+
+import "fmt"
+
+var errRoundRobinNotListable = fmt.Errorf("round robin proposer requires a validator set implementing ValidatorLister")
+
+// WithRoundRobinProposer makes the engine select the proposer with a strict round robin over the
+// validators sorted by id, rotating with the sequence and the round, instead of calling the
+// CalcProposer of the validator set. The validator set must implement ValidatorLister
+func WithRoundRobinProposer() ConfigOption {
+	return func(c *Config) {
+		c.RoundRobinProposer = true
+	}
+}
+
+// ValidatorLister is an optional interface of the ValidatorSet that lists its members.
+// It is required by the engine to select a proposer on its own
+type ValidatorLister interface {
+	// Validators returns the members of the validator set
+	Validators() []NodeID
+}
+
+// InvalidProposerEvent is emitted when the ValidatorSet returns a proposer that is not
+// a member of the set. Fallback is the proposer selected by the engine instead, empty
+// if the validator set cannot be listed and the round is left to time out
+type InvalidProposerEvent struct {
+	// View is the view of the round
+	View *View
+
+	// Proposer is the proposer returned by the validator set
+	Proposer NodeID
+
+	// Fallback is the proposer selected by the engine
+	Fallback NodeID
+}
+
+func (e *InvalidProposerEvent) EventName() string {
+	return "InvalidProposer"
+}
+
+// roundRobinProposer selects the proposer of the view deterministically: the validators are
+// sorted by id and the proposer rotates with the sequence and the round. It returns an empty
+// id if the validator set does not implement ValidatorLister
+func roundRobinProposer(validators ValidatorSet, view *View) NodeID {
+	ids := listValidators(validators)
+	if len(ids) == 0 {
+		return NodeID("")
+	}
+	pick := (view.Sequence%uint64(len(ids)) + view.Round%uint64(len(ids))) % uint64(len(ids))
+	return ids[pick]
+}
+
+// listValidators returns the members of the validator set sorted by id, nil if the
+// validator set does not implement ValidatorLister
+func listValidators(validators ValidatorSet) []NodeID {
+	validators = unguardedValidatorSet(validators)
+	if indexed, ok := validators.(*indexedValidatorSet); ok {
+		return indexed.sorted
+	} else if lister, ok := validators.(ValidatorLister); ok {
+		return sortedValidators(lister.Validators())
+	}
+	return nil
+}
+
+// proposerOf returns the proposer of the view as selected by calcProposer, without the rotation of
+// the offline proposers and without any event. It returns the error if the validator set panicked
+func (p *Pbft) proposerOf(view *View) (NodeID, error) {
+	if p.config.RoundRobinProposer {
+		return roundRobinProposer(p.state.validators, view), nil
+	}
+	proposer := p.state.validators.CalcProposer(view.Round)
+	if p.getState() == FaultedState {
+		return "", errBackendPanic
+	}
+	if proposer == "" || !p.state.validators.Includes(proposer) {
+		return roundRobinProposer(p.state.validators, view), nil
+	}
+	return proposer, nil
+}
+
+// calcProposer sets the proposer of the current round. It returns the error if the backend panicked
+func (p *Pbft) calcProposer() error {
+	if p.config.RoundRobinProposer {
+		p.state.proposer = roundRobinProposer(p.state.validators, p.state.view)
+	} else {
+		p.state.CalcProposer()
+		if p.getState() == FaultedState {
+			// the validator set panicked
+			return errBackendPanic
+		}
+		p.validateProposer()
+	}
+	return p.skipOfflineProposer()
+}
+
+// validateProposer checks that the proposer returned by the validator set is one of its
+// members and falls back to the engine round robin selection otherwise
+func (p *Pbft) validateProposer() {
+	proposer := p.state.proposer
+	if proposer != "" && p.state.validators.Includes(proposer) {
+		return
+	}
+	fallback := roundRobinProposer(p.state.validators, p.state.view)
+
+	p.logger.Printf("[ERROR] proposer is not a validator: proposer=%s, fallback=%s", proposer, fallback)
+	p.emit(&InvalidProposerEvent{
+		View:     p.state.view.Copy(),
+		Proposer: proposer,
+		Fallback: fallback,
+	})
+	p.state.proposer = fallback
+}
@@ -0,0 +1,112 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// invalidProposerSet is a validator set whose CalcProposer returns a non member
+type invalidProposerSet struct {
+	*valString
+}
+
+func (v *invalidProposerSet) CalcProposer(round uint64) NodeID {
+	return NodeID("X")
+}
+
+// listedInvalidProposerSet can be listed by the engine
+type listedInvalidProposerSet struct {
+	*invalidProposerSet
+}
+
+func (v *listedInvalidProposerSet) Validators() []NodeID {
+	return *v.valString
+}
+
+func TestRoundRobinProposer(t *testing.T) {
+	validators := &listedInvalidProposerSet{&invalidProposerSet{newMockValidatorSet([]string{"D", "B", "A", "C"}).(*valString)}}
+
+	// validators are sorted so the order of the set does not matter
+	assert.Equal(t, NodeID("B"), roundRobinProposer(validators, ViewMsg(1, 0)))
+	assert.Equal(t, NodeID("C"), roundRobinProposer(validators, ViewMsg(1, 1)))
+	assert.Equal(t, NodeID("A"), roundRobinProposer(validators, ViewMsg(2, 2)))
+	assert.Equal(t, NodeID("C"), roundRobinProposer(validators, ViewMsg(^uint64(0), ^uint64(0))))
+
+	// not listable
+	assert.Equal(t, NodeID(""), roundRobinProposer(validators.invalidProposerSet, ViewMsg(1, 0)))
+}
+
+func TestTransition_AcceptState_InvalidProposer_Fallback(t *testing.T) {
+	// B is the fallback proposer of sequence 1 and round 0
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "B")
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	m.state.validators = &listedInvalidProposerSet{&invalidProposerSet{m.backend.(*mockBackend).validators}}
+	m.setState(AcceptState)
+	m.setProposal(&Proposal{
+		Data: mockProposal,
+		Hash: digest,
+	})
+
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence: 1,
+		state:    ValidateState,
+		outgoing: 2, // preprepare and prepare
+	})
+	// followed by the pre-build notification since B builds the proposal
+	assert.Len(t, events, 2)
+	assert.Equal(t, &InvalidProposerEvent{View: ViewMsg(1, 0), Proposer: "X", Fallback: "B"}, events[0])
+}
+
+func TestTransition_AcceptState_InvalidProposer_NoFallback(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	m.state.validators = &invalidProposerSet{m.backend.(*mockBackend).validators}
+	m.setState(AcceptState)
+
+	// no proposer to wait for, the round times out
+	m.runCycle(context.Background())
+
+	m.expect(expectResult{
+		sequence: 1,
+		state:    RoundChangeState,
+	})
+	assert.Len(t, events, 1)
+	assert.Equal(t, NodeID(""), events[0].(*InvalidProposerEvent).Fallback)
+}
+
+func TestRoundRobinProposer_NotListable(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.RoundRobinProposer = true
+
+	err := m.SetBackend(m.backend)
+	assert.ErrorIs(t, err, errRoundRobinNotListable)
+}
+
+func TestTransition_AcceptState_RoundRobinProposer(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var events []Event
+	m.config.EventHandler = func(e Event) {
+		events = append(events, e)
+	}
+	m.config.RoundRobinProposer = true
+
+	// CalcProposer of the validator set is not called
+	m.state.validators = &listedInvalidProposerSet{&invalidProposerSet{m.backend.(*mockBackend).validators}}
+	m.setState(AcceptState)
+
+	m.runCycle(context.Background())
+
+	// B is the proposer of sequence 1 and round 0
+	assert.Equal(t, NodeID("B"), m.state.proposer)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,68 @@
+package pbft
+
+import (
+	"sync"
+	"time"
+)
+
+// quarantine keeps the set of validators whose messages are ignored until the expiry time
+type quarantine struct {
+	lock  sync.Mutex
+	nodes map[NodeID]time.Time
+}
+
+func newQuarantine() *quarantine {
+	return &quarantine{
+		nodes: map[NodeID]time.Time{},
+	}
+}
+
+// add quarantines the node for the given duration. If the node is already
+// quarantined, the expiry time is overwritten
+func (q *quarantine) add(id NodeID, duration time.Duration) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.nodes[id] = time.Now().Add(duration)
+}
+
+// remove lifts the quarantine of the node
+func (q *quarantine) remove(id NodeID) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	delete(q.nodes, id)
+}
+
+// contains checks whether the node is quarantined. Expired entries are removed
+func (q *quarantine) contains(id NodeID) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	expiry, ok := q.nodes[id]
+	if !ok {
+		return false
+	}
+	if !time.Now().Before(expiry) {
+		delete(q.nodes, id)
+		return false
+	}
+	return true
+}
+
+// list returns the quarantined nodes along with their expiry time
+func (q *quarantine) list() map[NodeID]time.Time {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	now := time.Now()
+	res := map[NodeID]time.Time{}
+	for id, expiry := range q.nodes {
+		if !now.Before(expiry) {
+			delete(q.nodes, id)
+			continue
+		}
+		res[id] = expiry
+	}
+	return res
+}
@@ -0,0 +1,45 @@
+package pbft
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantine_Expiry(t *testing.T) {
+	q := newQuarantine()
+	q.add("A", time.Hour)
+	q.add("B", -time.Second)
+
+	assert.True(t, q.contains("A"))
+	assert.False(t, q.contains("B"))
+	assert.False(t, q.contains("C"))
+
+	list := q.list()
+	assert.Len(t, list, 1)
+	assert.Contains(t, list, NodeID("A"))
+
+	q.remove("A")
+	assert.False(t, q.contains("A"))
+	assert.Empty(t, q.list())
+}
+
+func TestPushMessage_Quarantined(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.Quarantine("B", time.Hour)
+
+	msg := &MessageReq{
+		From: "B",
+		Type: MessageReq_RoundChange,
+		View: ViewMsg(1, 0),
+	}
+	m.emitMsg(msg)
+	assert.Empty(t, m.msgQueue.roundChangeStateQueue)
+	assert.Equal(t, uint64(1), m.Stats().QuarantineDrops)
+	assert.Contains(t, m.Quarantined(), NodeID("B"))
+
+	m.Release("B")
+	m.emitMsg(msg)
+	assert.Len(t, m.msgQueue.roundChangeStateQueue, 1)
+}
@@ -0,0 +1,82 @@
+package pbft
+
+import "sync"
+
+// QuorumSeals are the commit seals collected for a proposal once the quorum is reached
+type QuorumSeals struct {
+	// View is the view in which the quorum was reached
+	View *View
+
+	// Hash is the digest of the committed proposal
+	Hash []byte
+
+	// Seals are the commit seals along with their signers, sorted by signer
+	Seals []CommittedSeal
+}
+
+// Copy returns a deep copy of the seals
+func (q *QuorumSeals) Copy() *QuorumSeals {
+	seals := make([]CommittedSeal, len(q.Seals))
+	for i, seal := range q.Seals {
+		seals[i] = CommittedSeal{
+			Signer: seal.Signer,
+			Seal:   append([]byte{}, seal.Seal...),
+		}
+	}
+	return &QuorumSeals{
+		View:  q.View.Copy(),
+		Hash:  append([]byte{}, q.Hash...),
+		Seals: seals,
+	}
+}
+
+// QuorumSealsEvent is emitted as soon as the quorum of commit seals is reached,
+// before the proposal is inserted
+type QuorumSealsEvent struct {
+	Seals *QuorumSeals
+}
+
+func (e *QuorumSealsEvent) EventName() string {
+	return "QuorumSeals"
+}
+
+// quorumSealsHolder holds the last quorum of commit seals, it is written from the
+// state machine loop and read by the embedder
+type quorumSealsHolder struct {
+	lock  sync.Mutex
+	seals *QuorumSeals
+}
+
+func (q *quorumSealsHolder) set(seals *QuorumSeals) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.seals = seals
+}
+
+func (q *quorumSealsHolder) get() *QuorumSeals {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.seals == nil {
+		return nil
+	}
+	return q.seals.Copy()
+}
+
+// QuorumSeals returns the commit seals of the last proposal that reached the quorum,
+// nil if none did yet. It is safe for concurrent use
+func (p *Pbft) QuorumSeals() *QuorumSeals {
+	return p.quorumSeals.get()
+}
+
+// publishQuorumSeals stores and emits the commit seals of the current proposal
+func (p *Pbft) publishQuorumSeals() {
+	seals := &QuorumSeals{
+		View:  p.state.view.Copy(),
+		Hash:  append([]byte{}, p.state.proposal.Hash...),
+		Seals: p.state.getCommittedSealsWithSigners(),
+	}
+	p.quorumSeals.set(seals)
+	p.emit(&QuorumSealsEvent{Seals: seals.Copy()})
+}
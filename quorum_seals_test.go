@@ -0,0 +1,57 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuorumSeals(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	var events []*QuorumSealsEvent
+	m.config.EventHandler = func(e Event) {
+		if evnt, ok := e.(*QuorumSealsEvent); ok {
+			events = append(events, evnt)
+		}
+	}
+	assert.Nil(t, m.QuorumSeals())
+	m.setState(ValidateState)
+
+	for _, from := range []NodeID{"A", "B", "C"} {
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	}
+	for _, from := range []NodeID{"D", "C"} {
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Seal: []byte(from), View: ViewMsg(1, 0)})
+	}
+
+	m.runCycle(context.Background())
+	m.expect(expectResult{
+		sequence:    1,
+		state:       CommitState,
+		prepareMsgs: 3,
+		commitMsgs:  3,
+		locked:      true,
+		outgoing:    1, // commit
+	})
+
+	// the seals are published before the insertion
+	m.state.proposer = "A"
+	m.runCycle(context.Background())
+	assert.True(t, m.IsState(DoneState))
+
+	assert.Len(t, events, 1)
+	seals := events[0].Seals
+	assert.Equal(t, ViewMsg(1, 0), seals.View)
+	assert.Equal(t, digest, seals.Hash)
+	assert.Len(t, seals.Seals, 3)
+	for i, signer := range []NodeID{"A", "C", "D"} {
+		assert.Equal(t, signer, seals.Seals[i].Signer)
+	}
+	assert.Equal(t, []byte("C"), seals.Seals[1].Seal)
+	assert.Equal(t, seals, m.QuorumSeals())
+
+	// the getter returns a copy
+	m.QuorumSeals().Seals[1].Seal[0] = 0x0
+	assert.Equal(t, seals, m.QuorumSeals())
+}
@@ -0,0 +1,122 @@
+package pbft
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stressBackend is a backend of a single node network that moves to the next height on every insert
+type stressBackend struct {
+	*mockBackend
+	height uint64
+}
+
+func (s *stressBackend) Height() uint64 {
+	return atomic.LoadUint64(&s.height)
+}
+
+func (s *stressBackend) BuildProposal() (*Proposal, error) {
+	return &Proposal{Data: mockProposal, Time: time.Now(), Hash: digest}, nil
+}
+
+func (s *stressBackend) Insert(pp *SealedProposal) error {
+	atomic.AddUint64(&s.height, 1)
+	return nil
+}
+
+// transitionCounter counts the state transitions of the engine
+type transitionCounter struct {
+	transitions uint64
+}
+
+func (t *transitionCounter) RecordStateTransition(seq uint64, from, to PbftState, view *View) {
+	atomic.AddUint64(&t.transitions, 1)
+}
+
+func (t *transitionCounter) RecordMessage(seq uint64, msg *MessageReq) {}
+
+// TestStress_ConcurrentPublicAPI calls the public api of the engine from several goroutines
+// while it runs through the heights. It is meant to be run with -race, the number of heights
+// is set with STRESS_HEIGHTS
+func TestStress_ConcurrentPublicAPI(t *testing.T) {
+	heights := fuzzEnvInt(t, "STRESS_HEIGHTS", 1000)
+
+	m := newMockPbft(t, []string{"A"}, "A")
+	m.logger.SetOutput(nopWriter{})
+	m.config.DevMode = true
+	m.state.devMode = true
+	m.gossipFn = func(*MessageReq) error { return nil }
+
+	counter := &transitionCounter{}
+	m.recorder = newRecorder(counter)
+
+	backend := &stressBackend{mockBackend: newMockBackend([]string{"A"}, m), height: 1}
+	assert.NoError(t, m.SetBackend(backend))
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	readers := []func(){
+		func() { _ = m.GetState() },
+		func() { _ = m.IsState(CommitState) },
+		func() { _ = m.Health() },
+		func() { _ = m.Stats() },
+		func() { _ = m.RoundState() },
+		func() { _ = m.TimerState() },
+		func() { _ = m.Term() },
+		func() { _, _, _ = m.SyncHint() },
+		func() { _ = m.Evidence() },
+		func() { _ = m.Quarantined() },
+		func() { _ = m.QuorumSeals() },
+		func() { _ = m.FinalityHistory() },
+		func() { _, _ = m.GetCommitMessages(backend.Height() - 1) },
+		func() { _ = m.Handshake() },
+		func() {
+			view := m.state.getView()
+			if view == nil {
+				return
+			}
+			msg := &MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: view}
+			_ = m.Preflight(msg)
+			m.PushMessage(msg)
+		},
+		func() {
+			m.PushMessage(&MessageReq{Type: MessageReq_Status, From: "A", View: ViewMsg(backend.Height()+1, 0)})
+		},
+		func() {
+			m.PushMessage(&MessageReq{Type: MessageReq_ProposalRequest, From: "A", Hash: digest, View: ViewMsg(backend.Height(), 0)})
+		},
+	}
+
+	var wg sync.WaitGroup
+	for _, read := range readers {
+		wg.Add(1)
+		go func(read func()) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				read()
+				runtime.Gosched()
+			}
+		}(read)
+	}
+
+	for i := int64(0); i < heights; i++ {
+		m.Run(ctx)
+		if !assert.True(t, m.IsState(DoneState), "height %d", backend.Height()) {
+			break
+		}
+		// the embedder sets the backend before the next height
+		assert.NoError(t, m.SetBackend(backend))
+	}
+	cancelFn()
+	wg.Wait()
+
+	assert.Equal(t, uint64(heights+1), backend.Height())
+	assert.GreaterOrEqual(t, atomic.LoadUint64(&counter.transitions), uint64(3*heights))
+}
@@ -0,0 +1,49 @@
+package pbft
+
+import "sync/atomic"
+
+// RecordSink receives the activity of the engine (state transitions and processed messages)
+// so that it can be recorded and replayed offline. Each record carries a sequence number
+// that increases monotonically across both kinds of records.
+// The calls are made synchronously from the state machine loop, implementations must not block.
+type RecordSink interface {
+	// RecordStateTransition is called whenever the state machine changes its state
+	RecordStateTransition(seq uint64, from, to PbftState, view *View)
+
+	// RecordMessage is called with every message read from the message queue
+	RecordMessage(seq uint64, msg *MessageReq)
+}
+
+func WithRecordSink(sink RecordSink) ConfigOption {
+	return func(c *Config) {
+		c.RecordSink = sink
+	}
+}
+
+// recorder forwards the engine activity to the record sink, if any
+type recorder struct {
+	sink RecordSink
+	seq  uint64
+}
+
+func newRecorder(sink RecordSink) *recorder {
+	return &recorder{sink: sink}
+}
+
+func (r *recorder) next() uint64 {
+	return atomic.AddUint64(&r.seq, 1)
+}
+
+func (r *recorder) recordStateTransition(from, to PbftState, view *View) {
+	if r.sink == nil {
+		return
+	}
+	r.sink.RecordStateTransition(r.next(), from, to, view)
+}
+
+func (r *recorder) recordMessage(msg *MessageReq) {
+	if r.sink == nil {
+		return
+	}
+	r.sink.RecordMessage(r.next(), msg.Copy())
+}
@@ -0,0 +1,58 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRecord struct {
+	seq  uint64
+	to   PbftState
+	msg  *MessageReq
+	view *View
+}
+
+type mockRecordSink struct {
+	records []*mockRecord
+}
+
+func (m *mockRecordSink) RecordStateTransition(seq uint64, from, to PbftState, view *View) {
+	m.records = append(m.records, &mockRecord{seq: seq, to: to, view: view})
+}
+
+func (m *mockRecordSink) RecordMessage(seq uint64, msg *MessageReq) {
+	m.records = append(m.records, &mockRecord{seq: seq, msg: msg})
+}
+
+func TestRecordSink(t *testing.T) {
+	sink := &mockRecordSink{}
+
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.recorder = newRecorder(sink)
+	m.state.view = ViewMsg(1, 0)
+	m.setProposal(&Proposal{
+		Data: mockProposal,
+		Time: time.Now(),
+	})
+
+	m.Run(context.Background())
+	assert.True(t, m.IsState(DoneState))
+
+	states := []PbftState{}
+	msgs := []MsgType{}
+	for i, r := range sink.records {
+		// sequence numbers are increasing and without gaps
+		assert.Equal(t, uint64(i+1), r.seq)
+		if r.msg != nil {
+			msgs = append(msgs, r.msg.Type)
+		} else {
+			assert.Equal(t, ViewMsg(1, 0), r.view)
+			states = append(states, r.to)
+		}
+	}
+	assert.Equal(t, []PbftState{AcceptState, ValidateState, CommitState, DoneState}, states)
+	assert.Equal(t, []MsgType{MessageReq_Prepare, MessageReq_Commit}, msgs)
+}
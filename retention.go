@@ -0,0 +1,92 @@
+package pbft
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+func WithCommitRetention(heights int) ConfigOption {
+	return func(c *Config) {
+		c.CommitRetention = heights
+	}
+}
+
+// commitStore keeps the commit messages of the last heights finalized by the node,
+// so that they can be served to lagging peers
+type commitStore struct {
+	lock    sync.Mutex
+	size    int
+	heights []uint64
+	commits map[uint64][]*MessageReq
+}
+
+func newCommitStore(size int) *commitStore {
+	return &commitStore{
+		size:    size,
+		heights: []uint64{},
+		commits: map[uint64][]*MessageReq{},
+	}
+}
+
+// add stores the commit messages of the height and evicts the oldest height if the store is full
+func (c *commitStore) add(height uint64, committed map[NodeID]*MessageReq) {
+	if c.size <= 0 {
+		return
+	}
+
+	msgs := make([]*MessageReq, 0, len(committed))
+	for _, msg := range committed {
+		msgs = append(msgs, msg.Copy())
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		return msgs[i].From < msgs[j].From
+	})
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.commits[height]; !ok {
+		c.heights = append(c.heights, height)
+	}
+	c.commits[height] = msgs
+
+	for len(c.heights) > c.size {
+		delete(c.commits, c.heights[0])
+		c.heights = c.heights[1:]
+	}
+}
+
+func (c *commitStore) get(height uint64) ([]*MessageReq, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	msgs, ok := c.commits[height]
+	if !ok {
+		return nil, false
+	}
+	res := make([]*MessageReq, 0, len(msgs))
+	for _, msg := range msgs {
+		res = append(res, msg.Copy())
+	}
+	return res, true
+}
+
+// GetCommitMessages returns the commit messages of a height finalized by the node,
+// if it is still in the retention window (see WithCommitRetention). It is safe for concurrent use
+func (p *Pbft) GetCommitMessages(height uint64) ([]*MessageReq, bool) {
+	return p.commits.get(height)
+}
+
+// ServeCommitMessages sends the retained commit messages of the height to the peer, i.e. to
+// answer its pull request. The messages are gossiped if the transport is not a TargetedTransport
+func (p *Pbft) ServeCommitMessages(peer NodeID, height uint64) error {
+	msgs, ok := p.commits.get(height)
+	if !ok {
+		return fmt.Errorf("commit messages of height %d are not retained", height)
+	}
+	for _, msg := range msgs {
+		p.sendTo([]NodeID{peer}, msg)
+	}
+	return nil
+}
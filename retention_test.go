@@ -0,0 +1,101 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommitStore_Window(t *testing.T) {
+	c := newCommitStore(2)
+
+	for height := uint64(1); height <= 3; height++ {
+		c.add(height, map[NodeID]*MessageReq{
+			"B": {From: "B", Type: MessageReq_Commit, View: ViewMsg(height, 0)},
+			"A": {From: "A", Type: MessageReq_Commit, View: ViewMsg(height, 0)},
+		})
+	}
+
+	// the oldest height is evicted
+	_, ok := c.get(1)
+	assert.False(t, ok)
+
+	msgs, ok := c.get(3)
+	assert.True(t, ok)
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, NodeID("A"), msgs[0].From)
+	assert.Equal(t, NodeID("B"), msgs[1].From)
+
+	// the returned messages are copies
+	msgs[0].From = "C"
+	msgs, _ = c.get(3)
+	assert.Equal(t, NodeID("A"), msgs[0].From)
+}
+
+func TestCommitStore_Disabled(t *testing.T) {
+	c := newCommitStore(0)
+	c.add(1, map[NodeID]*MessageReq{
+		"A": {From: "A", Type: MessageReq_Commit, View: ViewMsg(1, 0)},
+	})
+
+	_, ok := c.get(1)
+	assert.False(t, ok)
+}
+
+func TestPbft_GetCommitMessages(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.commits = newCommitStore(1)
+	m.state.view = ViewMsg(1, 0)
+	m.state.proposer = "A"
+	m.state.addCommitted(&MessageReq{
+		From: "B",
+		Type: MessageReq_Commit,
+		View: ViewMsg(1, 0),
+		Seal: []byte{0x1},
+	})
+	m.setState(CommitState)
+
+	m.runCycle(context.Background())
+	assert.True(t, m.IsState(DoneState))
+
+	msgs, ok := m.GetCommitMessages(1)
+	assert.True(t, ok)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, []byte{0x1}, msgs[0].Seal)
+}
+
+// targetedTransport records the messages sent to specific peers
+type targetedTransport struct {
+	*mockPbft
+	sent map[NodeID][]*MessageReq
+}
+
+func (tt *targetedTransport) Send(to []NodeID, msg *MessageReq) error {
+	for _, peer := range to {
+		tt.sent[peer] = append(tt.sent[peer], msg)
+	}
+	return nil
+}
+
+func TestPbft_ServeCommitMessages(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.commits = newCommitStore(1)
+	m.commits.add(1, map[NodeID]*MessageReq{
+		"B": {From: "B", Type: MessageReq_Commit, View: ViewMsg(1, 0), Hash: digest, Seal: []byte{0x1}},
+		"C": {From: "C", Type: MessageReq_Commit, View: ViewMsg(1, 0), Hash: digest, Seal: []byte{0x2}},
+	})
+
+	// gossiped without a targeted transport
+	assert.NoError(t, m.ServeCommitMessages("D", 1))
+	assert.Len(t, m.respMsg, 2)
+
+	transport := &targetedTransport{mockPbft: m, sent: map[NodeID][]*MessageReq{}}
+	m.transport = transport
+	assert.NoError(t, m.ServeCommitMessages("D", 1))
+	assert.Len(t, m.respMsg, 2)
+	assert.Len(t, transport.sent["D"], 2)
+	assert.Equal(t, NodeID("B"), transport.sent["D"][0].From)
+
+	assert.Error(t, m.ServeCommitMessages("D", 2))
+}
@@ -0,0 +1,87 @@
+package pbft
+
+import "fmt"
+
+// RoundChangeReason is the cause of a round change, carried by the round change messages
+// and the RoundChangeEvent so that the round changes can be diagnosed by category
+type RoundChangeReason uint64
+
+const (
+	// RoundChangeUnknown is the reason of the round change messages of the peers that do not report it
+	RoundChangeUnknown RoundChangeReason = iota
+
+	// RoundChangeTimeout is used when the round (or the previous round change) timed out
+	RoundChangeTimeout
+
+	// RoundChangeInvalidProposal is used when the proposal is rejected by the backend
+	// or does not match the locked proposal
+	RoundChangeInvalidProposal
+
+	// RoundChangeProposerNotInSet is used when the round timed out without a valid proposer
+	// (see InvalidProposerEvent)
+	RoundChangeProposerNotInSet
+
+	// RoundChangeBackendError is used when the backend fails to build or insert the proposal
+	// or the proposal cannot be sealed
+	RoundChangeBackendError
+
+	// RoundChangeCatchUp is used when the node moves to the round of the peers
+	RoundChangeCatchUp
+)
+
+func (r RoundChangeReason) String() string {
+	switch r {
+	case RoundChangeUnknown:
+		return "Unknown"
+	case RoundChangeTimeout:
+		return "Timeout"
+	case RoundChangeInvalidProposal:
+		return "InvalidProposal"
+	case RoundChangeProposerNotInSet:
+		return "ProposerNotInSet"
+	case RoundChangeBackendError:
+		return "BackendError"
+	case RoundChangeCatchUp:
+		return "CatchUp"
+	default:
+		return fmt.Sprintf("RoundChangeReason(%d)", uint64(r))
+	}
+}
+
+// RoundChangeEvent is emitted when the node sends a round change message
+type RoundChangeEvent struct {
+	// View is the view of the new round
+	View *View
+
+	// Reason is the cause of the round change
+	Reason RoundChangeReason
+}
+
+func (e *RoundChangeEvent) EventName() string {
+	return "RoundChange"
+}
+
+// roundChange moves the engine to the round change state for the reason
+func (p *Pbft) roundChange(reason RoundChangeReason) {
+	p.state.roundChangeReason = reason
+	p.releaseFailed()
+	p.setState(RoundChangeState)
+}
+
+// timeoutReason returns the reason of a round change after the round timed out
+func (p *Pbft) timeoutReason() RoundChangeReason {
+	if p.state.proposer == "" {
+		return RoundChangeProposerNotInSet
+	}
+	return RoundChangeTimeout
+}
+
+// countReceivedRoundChange counts the round change message of a peer by reason in the stats
+func (p *Pbft) countReceivedRoundChange(reason RoundChangeReason) {
+	p.stats.update(func(s *Stats) {
+		if s.ReceivedRoundChanges == nil {
+			s.ReceivedRoundChanges = map[RoundChangeReason]uint64{}
+		}
+		s.ReceivedRoundChanges[reason]++
+	})
+}
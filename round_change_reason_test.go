@@ -0,0 +1,106 @@
+package pbft
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundChangeReason_String(t *testing.T) {
+	expected := map[RoundChangeReason]string{
+		RoundChangeUnknown:          "Unknown",
+		RoundChangeTimeout:          "Timeout",
+		RoundChangeInvalidProposal:  "InvalidProposal",
+		RoundChangeProposerNotInSet: "ProposerNotInSet",
+		RoundChangeBackendError:     "BackendError",
+		RoundChangeCatchUp:          "CatchUp",
+		RoundChangeReason(100):      "RoundChangeReason(100)",
+	}
+	for reason, str := range expected {
+		assert.Equal(t, str, reason.String())
+	}
+}
+
+// runRoundChange runs the first cycle of the round change state, which sends the round change
+// message, and returns the message along with the round change events
+func runRoundChange(t *testing.T, m *mockPbft) (*MessageReq, []*RoundChangeEvent) {
+	t.Helper()
+
+	events := []*RoundChangeEvent{}
+	m.config.EventHandler = func(e Event) {
+		if event, ok := e.(*RoundChangeEvent); ok {
+			events = append(events, event)
+		}
+	}
+	assert.True(t, m.IsState(RoundChangeState))
+
+	// closed, the round change state returns once the message is sent
+	sent := len(m.respMsg)
+	m.Close()
+	m.runCycle(m.ctx)
+
+	if !assert.Len(t, m.respMsg, sent+1) {
+		return nil, events
+	}
+	msg := m.respMsg[sent]
+	assert.Equal(t, MessageReq_RoundChange, msg.Type)
+	return msg, events
+}
+
+func TestTransition_RoundChangeReason_InvalidProposal(t *testing.T) {
+	backend := newMockBackend([]string{"A", "B", "C"}, nil).HookValidateHandler(func(p *Proposal) error {
+		return errors.New("invalid proposal")
+	})
+	m := newMockPbft(t, []string{"A", "B", "C"}, "C", backend)
+	m.setState(AcceptState)
+	m.emitMsg(&MessageReq{From: "A", Type: MessageReq_Preprepare, View: ViewMsg(1, 0)})
+	m.runCycle(m.ctx)
+
+	msg, events := runRoundChange(t, m)
+	assert.Equal(t, RoundChangeInvalidProposal, msg.RoundChangeReason)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, RoundChangeInvalidProposal, events[0].Reason)
+		assert.Equal(t, uint64(1), events[0].View.Round)
+	}
+	assert.Equal(t, uint64(1), m.Stats().RoundChanges[RoundChangeInvalidProposal])
+}
+
+func TestTransition_RoundChangeReason_Timeout(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.setState(ValidateState)
+	m.runCycle(m.ctx)
+
+	msg, events := runRoundChange(t, m)
+	assert.Equal(t, RoundChangeTimeout, msg.RoundChangeReason)
+	assert.Len(t, events, 1)
+	assert.Equal(t, uint64(1), m.Stats().RoundChanges[RoundChangeTimeout])
+}
+
+func TestTransition_RoundChangeReason_BackendError(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.handleStateErr(RoundChangeBackendError, errFailedToInsertProposal)
+
+	msg, _ := runRoundChange(t, m)
+	assert.Equal(t, RoundChangeBackendError, msg.RoundChangeReason)
+}
+
+func TestTransition_RoundChangeReason_SetFromOutside(t *testing.T) {
+	// without a known cause the round change is a timeout
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+	m.setState(RoundChangeState)
+
+	msg, _ := runRoundChange(t, m)
+	assert.Equal(t, RoundChangeTimeout, msg.RoundChangeReason)
+}
+
+func TestPbft_TimeoutReason(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C"}, "A")
+
+	m.state.proposer = "B"
+	assert.Equal(t, RoundChangeTimeout, m.timeoutReason())
+
+	// no valid proposer for the round (see validateProposer)
+	m.state.proposer = ""
+	assert.Equal(t, RoundChangeProposerNotInSet, m.timeoutReason())
+}
@@ -0,0 +1,138 @@
+package pbft
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RoundStateView is a read-only snapshot of the current round, suitable to be
+// serialized and shipped to external dashboards
+type RoundStateView struct {
+	// NodeID is the identifier of the local node
+	NodeID NodeID
+
+	// View is the current view
+	View *View
+
+	// Phase is the current state of the state machine
+	Phase string
+
+	// Proposer is the proposer of the current round
+	Proposer NodeID
+
+	// Locked signals whether the proposal is locked
+	Locked bool
+
+	// Prepared are the senders of the received prepare messages
+	Prepared []NodeID
+
+	// Committed are the senders of the received commit messages
+	Committed []NodeID
+
+	// RoundChanges are the senders of the received round change messages per round
+	RoundChanges map[uint64][]NodeID
+
+	// TimeoutRemaining is the time left until the current timeout fires
+	TimeoutRemaining time.Duration
+}
+
+// Marshal encodes the round state
+func (r *RoundStateView) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// RoundStateExporter receives the round state snapshots at a fixed interval
+type RoundStateExporter interface {
+	Export(state *RoundStateView)
+}
+
+func WithRoundStateExporter(exporter RoundStateExporter, interval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.RoundStateExporter = exporter
+		c.RoundStateInterval = interval
+	}
+}
+
+// roundStatePublisher holds the last snapshot published by the state machine loop
+type roundStatePublisher struct {
+	lock     sync.Mutex
+	state    *RoundStateView
+	deadline time.Time
+}
+
+func (r *roundStatePublisher) publish(state *RoundStateView, deadline time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.state = state
+	r.deadline = deadline
+}
+
+func (r *roundStatePublisher) get() *RoundStateView {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.state == nil {
+		return nil
+	}
+	state := *r.state
+	if remaining := time.Until(r.deadline); remaining > 0 {
+		state.TimeoutRemaining = remaining
+	}
+	return &state
+}
+
+// publishRoundState publishes a snapshot of the current round.
+// It must be called from the state machine loop
+func (p *Pbft) publishRoundState(deadline time.Time) {
+	state := &RoundStateView{
+		NodeID:       p.selfID(),
+		View:         p.state.getView(),
+		Phase:        p.getState().String(),
+		Proposer:     p.state.proposer,
+		Locked:       p.state.locked,
+		Prepared:     sortedSenders(p.state.prepared),
+		Committed:    sortedSenders(p.state.committed),
+		RoundChanges: map[uint64][]NodeID{},
+	}
+	for round, msgs := range p.state.roundMessages {
+		state.RoundChanges[round] = sortedSenders(msgs)
+	}
+	p.roundState.publish(state, deadline)
+}
+
+// RoundState returns the last snapshot of the current round. It is safe for concurrent use
+func (p *Pbft) RoundState() *RoundStateView {
+	return p.roundState.get()
+}
+
+// runRoundStateExporter exports the round state at the configured interval until the context is done
+func (p *Pbft) runRoundStateExporter(ctx context.Context) {
+	ticker := time.NewTicker(p.config.RoundStateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if state := p.RoundState(); state != nil {
+				p.config.RoundStateExporter.Export(state)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func sortedSenders(msgs map[NodeID]*MessageReq) []NodeID {
+	senders := make([]NodeID, 0, len(msgs))
+	for from := range msgs {
+		senders = append(senders, from)
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return senders[i] < senders[j]
+	})
+	return senders
+}
@@ -0,0 +1,67 @@
+package pbft
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRoundStateExporter chan *RoundStateView
+
+func (m mockRoundStateExporter) Export(state *RoundStateView) {
+	m <- state
+}
+
+func TestRoundState_Snapshot(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.state.view = ViewMsg(1, 0)
+	m.setState(ValidateState)
+	assert.Nil(t, m.RoundState())
+
+	m.emitMsg(&MessageReq{
+		From: "B",
+		Type: MessageReq_Prepare,
+		View: ViewMsg(1, 0),
+	})
+	m.runCycle(context.Background())
+
+	state := m.RoundState()
+	require.NotNil(t, state)
+	assert.Equal(t, NodeID("A"), state.NodeID)
+	assert.Equal(t, ViewMsg(1, 0), state.View)
+	assert.Equal(t, ValidateState.String(), state.Phase)
+	assert.Equal(t, []NodeID{"B"}, state.Prepared)
+	assert.Empty(t, state.Committed)
+
+	data, err := state.Marshal()
+	require.NoError(t, err)
+
+	decoded := &RoundStateView{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	assert.Equal(t, state, decoded)
+}
+
+func TestRoundState_Exporter(t *testing.T) {
+	exporter := make(mockRoundStateExporter, 1)
+
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.RoundStateExporter = exporter
+	m.config.RoundStateInterval = time.Millisecond
+	m.publishRoundState(time.Now().Add(time.Hour))
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	go m.runRoundStateExporter(ctx)
+
+	select {
+	case state := <-exporter:
+		assert.Equal(t, NodeID("A"), state.NodeID)
+		assert.Greater(t, state.TimeoutRemaining, time.Duration(0))
+	case <-time.After(time.Second):
+		t.Fatal("round state not exported")
+	}
+}
@@ -0,0 +1,184 @@
+package pbft
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// sealedProposalVersion is the version of the canonical encodings of the sealed proposals
+const sealedProposalVersion = 3
+
+var errSealedProposalMissing = fmt.Errorf("sealed proposal without proposal")
+
+// MarshalCanonical encodes the content of the sealed proposal with a deterministic binary format,
+// so that every node that finalized the proposal produces the same bytes. Only the content agreed
+// by the consensus is encoded: the version followed by the height and the proposal hash and data,
+// with the integers big endian and the byte slices prefixed with their uint32 length. The proposer,
+// the round, the commit seals and the time of the proposal are not part of the content, see MarshalFinality
+func (s *SealedProposal) MarshalCanonical() ([]byte, error) {
+	w, err := s.writeContent()
+	if err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// MarshalFinality encodes the content of the sealed proposal followed by the round and the
+// commit seals that finalized it. The seals are encoded in the order of the sealed proposal,
+// which the engine sorts by signer, hence the encoding only differs across the nodes that
+// finalized the same proposal with a different round or subset of commits
+func (s *SealedProposal) MarshalFinality() ([]byte, error) {
+	w, err := s.writeContent()
+	if err != nil {
+		return nil, err
+	}
+	w.uint64(s.Round)
+	w.uint64(uint64(len(s.CommittedSeals)))
+	for _, seal := range s.CommittedSeals {
+		w.bytes(seal)
+	}
+	return w.buf.Bytes(), nil
+}
+
+func (s *SealedProposal) writeContent() (*binaryWriter, error) {
+	if s.Proposal == nil {
+		return nil, errSealedProposalMissing
+	}
+	w := &binaryWriter{}
+	w.uint64(sealedProposalVersion)
+	w.uint64(s.Number)
+	w.bytes(s.Proposal.Hash)
+	w.bytes(s.Proposal.Data)
+	return w, nil
+}
+
+// UnmarshalSealedProposal decodes the content of the sealed proposal encoded with MarshalCanonical
+func UnmarshalSealedProposal(data []byte) (*SealedProposal, error) {
+	r, s, err := readContent(data)
+	if err != nil {
+		return nil, err
+	}
+	return s, r.done()
+}
+
+// UnmarshalSealedFinality decodes the sealed proposal encoded with MarshalFinality,
+// without the proposer and the time of the proposal
+func UnmarshalSealedFinality(data []byte) (*SealedProposal, error) {
+	r, s, err := readContent(data)
+	if err != nil {
+		return nil, err
+	}
+	s.Round = r.uint64()
+	count := r.uint64()
+	// every seal takes at least its length prefix
+	if r.err == nil && count > uint64(r.r.Len()/4) {
+		return nil, fmt.Errorf("failed to decode sealed proposal: %d seals", count)
+	}
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		s.CommittedSeals = append(s.CommittedSeals, r.bytes())
+	}
+	return s, r.done()
+}
+
+func readContent(data []byte) (*sealedReader, *SealedProposal, error) {
+	r := &sealedReader{binaryReader{r: bytes.NewReader(data)}}
+	if version := r.uint64(); r.err == nil && version != sealedProposalVersion {
+		return nil, nil, fmt.Errorf("unsupported sealed proposal version %d", version)
+	}
+	s := &SealedProposal{Proposal: &Proposal{}}
+	s.Number = r.uint64()
+	s.Proposal.Hash = r.bytes()
+	s.Proposal.Data = r.bytes()
+	return r, s, nil
+}
+
+type sealedReader struct {
+	binaryReader
+}
+
+// done returns the first decoding error or an error if there are trailing bytes
+func (r *sealedReader) done() error {
+	if r.err != nil {
+		return fmt.Errorf("failed to decode sealed proposal: %v", r.err)
+	}
+	if r.r.Len() != 0 {
+		return fmt.Errorf("failed to decode sealed proposal: %d trailing bytes", r.r.Len())
+	}
+	return nil
+}
+
+// CanonicalHash returns the sha256 digest of the content encoding of the sealed proposal
+func (s *SealedProposal) CanonicalHash() ([]byte, error) {
+	return sealedHash(s.MarshalCanonical())
+}
+
+// FinalityHash returns the sha256 digest of the finality encoding of the sealed proposal
+func (s *SealedProposal) FinalityHash() ([]byte, error) {
+	return sealedHash(s.MarshalFinality())
+}
+
+func sealedHash(data []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
+
+type binaryWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *binaryWriter) uint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *binaryWriter) bytes(b []byte) {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(b)))
+	w.buf.Write(l[:])
+	w.buf.Write(b)
+}
+
+// binaryReader reads the values written by binaryWriter, it keeps the first error
+type binaryReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *binaryReader) uint64() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	var b [8]byte
+	if _, r.err = io.ReadFull(r.r, b[:]); r.err != nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func (r *binaryReader) bytes() []byte {
+	if r.err != nil {
+		return nil
+	}
+	var l [4]byte
+	if _, r.err = io.ReadFull(r.r, l[:]); r.err != nil {
+		return nil
+	}
+	size := binary.BigEndian.Uint32(l[:])
+	if int64(size) > int64(r.r.Len()) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	if size == 0 {
+		return nil
+	}
+	b := make([]byte, size)
+	_, r.err = io.ReadFull(r.r, b)
+	return b
+}
@@ -0,0 +1,256 @@
+package pbft
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mockSealedProposal() *SealedProposal {
+	return &SealedProposal{
+		Proposal: &Proposal{
+			Data: mockProposal,
+			Time: time.Unix(1700000000, 5).UTC(),
+			Hash: digest,
+		},
+		CommittedSeals: [][]byte{{0x3}, {0x1}, {0x2}},
+		Proposer:       "A",
+		Number:         10,
+		Round:          2,
+	}
+}
+
+func TestSealedProposal_CanonicalHash(t *testing.T) {
+	sealed := mockSealedProposal()
+	hash, err := sealed.CanonicalHash()
+	require.NoError(t, err)
+
+	// the encoding is part of the on-chain commitments, it must not change
+	assert.Equal(t, "f3b5512a9b4674a183a785857c212c234899008ef11f317b0e61e8a86822be68", hex.EncodeToString(hash))
+
+	// the fields local to the node do not change the hash
+	maxRound := uint64(3)
+	sealed.CommittedSeals = [][]byte{{0x2}, {0x1}}
+	sealed.Proposal.MaxRound = &maxRound
+	sealed.Proposal.Time = time.Time{}
+	sealed.Round = 3
+	other, err := sealed.CanonicalHash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, other)
+
+	sealed.Proposer = "B"
+	other, err = sealed.CanonicalHash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, other)
+
+	sealed.Proposal.Data = []byte{0x1}
+	other, err = sealed.CanonicalHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestSealedProposal_FinalityHash(t *testing.T) {
+	sealed := mockSealedProposal()
+	hash, err := sealed.FinalityHash()
+	require.NoError(t, err)
+	assert.Equal(t, "29976b73c36e8ed1663866b39fa58e9281b013d628088ef18d670656f881f428", hex.EncodeToString(hash))
+
+	// the proposer and the time of the proposal are not part of the finality
+	sealed.Proposer = "B"
+	sealed.Proposal.Time = time.Time{}
+	other, err := sealed.FinalityHash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, other)
+
+	// the round and the commit seals are
+	sealed.Round = 3
+	other, err = sealed.FinalityHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	sealed = mockSealedProposal()
+	sealed.CommittedSeals = sealed.CommittedSeals[:2]
+	other, err = sealed.FinalityHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	// the content hash is the same for any finality
+	content, err := sealed.CanonicalHash()
+	require.NoError(t, err)
+	other, err = mockSealedProposal().CanonicalHash()
+	require.NoError(t, err)
+	assert.Equal(t, content, other)
+}
+
+func TestSealedProposal_MarshalFinality(t *testing.T) {
+	sealed := mockSealedProposal()
+	data, err := sealed.MarshalFinality()
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalSealedFinality(data)
+	require.NoError(t, err)
+	assert.Equal(t, &SealedProposal{
+		Proposal:       &Proposal{Data: sealed.Proposal.Data, Hash: sealed.Proposal.Hash},
+		CommittedSeals: sealed.CommittedSeals,
+		Number:         sealed.Number,
+		Round:          sealed.Round,
+	}, decoded)
+
+	// the content encoding is a prefix of the finality encoding
+	content, err := sealed.MarshalCanonical()
+	require.NoError(t, err)
+	assert.Equal(t, content, data[:len(content)])
+	_, err = UnmarshalSealedProposal(data)
+	assert.Error(t, err)
+	_, err = UnmarshalSealedFinality(content)
+	assert.Error(t, err)
+
+	_, err = (&SealedProposal{}).MarshalFinality()
+	assert.ErrorIs(t, err, errSealedProposalMissing)
+
+	_, err = UnmarshalSealedFinality(append(data, 0x0))
+	assert.Error(t, err)
+	_, err = UnmarshalSealedFinality(data[:len(data)-1])
+	assert.Error(t, err)
+
+	// a seal count larger than the remaining bytes
+	data[len(content)+15] = 0xff
+	_, err = UnmarshalSealedFinality(data)
+	assert.Error(t, err)
+}
+
+func TestState_CommittedSeals_SortedBySigner(t *testing.T) {
+	s := newState()
+	s.committed = map[NodeID]*MessageReq{
+		"C": {From: "C", Seal: []byte{0x3}},
+		"A": {From: "A", Seal: []byte{0x1}},
+		"B": {From: "B", Seal: []byte{0x2}},
+	}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, [][]byte{{0x1}, {0x2}, {0x3}}, s.getCommittedSeals())
+	}
+}
+
+// finalize runs the engine until it finalizes the height and returns the sealed proposal
+func finalize(t *testing.T, m *mockPbft) *SealedProposal {
+	t.Helper()
+
+	backend := &mockAsyncInsertBackend{mockBackend: m.backend.(*mockBackend), resultCh: make(chan error, 1)}
+	m.backend = backend
+	for i := 0; i < 10 && !m.IsState(DoneState); i++ {
+		m.runCycle(context.Background())
+	}
+	require.True(t, m.IsState(DoneState))
+	require.Len(t, backend.inserted, 1)
+	return backend.inserted[0]
+}
+
+func TestSealedProposal_CanonicalHash_ProposerAndValidator(t *testing.T) {
+	accounts := []string{"A", "B", "C", "D"}
+
+	proposer := newMockPbft(t, accounts, "A")
+	proposer.setState(AcceptState)
+	proposer.setProposal(&Proposal{Data: mockProposal, Time: time.Now()})
+	hash := proposer.proposal.Hash
+
+	validator := newMockPbft(t, accounts, "B")
+	validator.setState(AcceptState)
+
+	votes := func(m *mockPbft, senders ...NodeID) {
+		for _, from := range senders {
+			m.emitMsg(&MessageReq{From: from, Type: MessageReq_Prepare, Hash: hash, View: ViewMsg(1, 0)})
+			m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Hash: hash, Seal: hash, View: ViewMsg(1, 0)})
+		}
+	}
+	// each node finalizes with a different subset of the commits
+	votes(proposer, "B", "C")
+	votes(validator, "A", "D")
+
+	proposer.runCycle(context.Background())
+	require.Equal(t, MessageReq_Preprepare, proposer.respMsg[0].Type)
+	validator.emitMsg(proposer.respMsg[0])
+
+	proposerHash, err := finalize(t, proposer).CanonicalHash()
+	require.NoError(t, err)
+	validatorHash, err := finalize(t, validator).CanonicalHash()
+	require.NoError(t, err)
+	assert.Equal(t, proposerHash, validatorHash)
+
+	// the finality differs with the subset of commits each node finalized with
+	proposerFinality, err := proposer.backend.(*mockAsyncInsertBackend).inserted[0].FinalityHash()
+	require.NoError(t, err)
+	validatorFinality, err := validator.backend.(*mockAsyncInsertBackend).inserted[0].FinalityHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, proposerFinality, validatorFinality)
+}
+
+func TestSealedProposal_MarshalCanonical(t *testing.T) {
+	sealed := mockSealedProposal()
+	data, err := sealed.MarshalCanonical()
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalSealedProposal(data)
+	require.NoError(t, err)
+	assert.Equal(t, &SealedProposal{
+		Proposal: &Proposal{Data: sealed.Proposal.Data, Hash: sealed.Proposal.Hash},
+		Number:   sealed.Number,
+	}, decoded)
+
+	_, err = (&SealedProposal{}).MarshalCanonical()
+	assert.ErrorIs(t, err, errSealedProposalMissing)
+
+	_, err = UnmarshalSealedProposal(append(data, 0x0))
+	assert.Error(t, err)
+	_, err = UnmarshalSealedProposal(data[:len(data)-1])
+	assert.Error(t, err)
+
+	data[7] = 0x1
+	_, err = UnmarshalSealedProposal(data)
+	assert.Error(t, err)
+}
+
+func TestFuzz_SealedProposalCanonical(t *testing.T) {
+	seed := time.Now().UnixNano()
+	g := &messageGenerator{r: rand.New(rand.NewSource(seed))}
+
+	for i := 0; i < 1000; i++ {
+		sealed := &SealedProposal{
+			Proposal: g.proposal(),
+			Proposer: g.nodeID(),
+			Number:   g.uint64(),
+			Round:    g.uint64(),
+		}
+		for j := g.r.Intn(4); j > 0; j-- {
+			sealed.CommittedSeals = append(sealed.CommittedSeals, g.bytes(96))
+		}
+		data, err := sealed.MarshalCanonical()
+		require.NoError(t, err)
+
+		decoded, err := UnmarshalSealedProposal(data)
+		require.NoError(t, err, "seed=%d, iteration=%d", seed, i)
+		assert.Equal(t, sealed.Number, decoded.Number)
+
+		// the decoded proposal encodes to the same bytes
+		reencoded, err := decoded.MarshalCanonical()
+		require.NoError(t, err)
+		if !assert.Equal(t, data, reencoded, "seed=%d, iteration=%d", seed, i) {
+			return
+		}
+
+		data, err = sealed.MarshalFinality()
+		require.NoError(t, err)
+		decoded, err = UnmarshalSealedFinality(data)
+		require.NoError(t, err, "seed=%d, iteration=%d", seed, i)
+		assert.Equal(t, sealed.Round, decoded.Round)
+		reencoded, err = decoded.MarshalFinality()
+		require.NoError(t, err)
+		if !assert.Equal(t, data, reencoded, "seed=%d, iteration=%d", seed, i) {
+			return
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package pbft
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SessionKeyBackend is an optional interface implemented by the backends whose validators
+// sign the consensus messages with rotating session keys. The validator set holds the long
+// term identities and the engine maps the sender of every message to the identity its
+// session key is registered for in the current term, so rotating a key does not require
+// to remove and add the validator again. ValidateCommit receives the identity as the sender
+type SessionKeyBackend interface {
+	// SessionIdentity returns the identity of the validator the session key is registered
+	// for in the term, false if the key is not valid in that term
+	SessionIdentity(session NodeID, term uint64) (NodeID, bool)
+}
+
+// maxSessionTerms is the number of terms whose session keys are cached, the lowest term is
+// evicted first since the messages of the next terms are the ones queued
+const maxSessionTerms = 4
+
+// sessionKeys caches the identities of the session keys per term
+type sessionKeys struct {
+	lock  sync.Mutex
+	terms map[uint64]map[NodeID]NodeID
+}
+
+func newSessionKeys() *sessionKeys {
+	return &sessionKeys{terms: map[uint64]map[NodeID]NodeID{}}
+}
+
+func (s *sessionKeys) get(session NodeID, term uint64) (NodeID, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	identity, ok := s.terms[term][session]
+	return identity, ok
+}
+
+func (s *sessionKeys) set(session, identity NodeID, term uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	identities, ok := s.terms[term]
+	if !ok {
+		if len(s.terms) >= maxSessionTerms {
+			lowest := term
+			for t := range s.terms {
+				if t < lowest {
+					lowest = t
+				}
+			}
+			if lowest == term {
+				// the term is older than every cached one
+				return
+			}
+			delete(s.terms, lowest)
+		}
+		identities = map[NodeID]NodeID{}
+		s.terms[term] = identities
+	}
+	identities[session] = identity
+}
+
+// sessionIdentity returns the identity behind the session key in the term, the key itself if
+// the backend does not use session keys. Like preflightSeal, a panic of the backend is reported
+// as an error and does not move the engine to the faulted state
+func (p *Pbft) sessionIdentity(session NodeID, term uint64) (identity NodeID, err error) {
+	backend, ok := p.getBackend().(SessionKeyBackend)
+	if !ok {
+		return session, nil
+	}
+	if identity, ok := p.sessions.get(session, term); ok {
+		return identity, nil
+	}
+
+	if err := recoverBackend("SessionIdentity", func() { identity, ok = backend.SessionIdentity(session, term) }); err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("unknown session key %s in term %d", session, term)
+	}
+	p.sessions.set(session, identity, term)
+	return identity, nil
+}
+
+// resolveSender returns a copy of the message with the identity of the sender instead of its session
+// key. The key is resolved in the term of the message, which may differ from the current one
+func (p *Pbft) resolveSender(msg *MessageReq) (*MessageReq, error) {
+	if _, ok := p.getBackend().(SessionKeyBackend); !ok {
+		return msg, nil
+	}
+	identity, err := p.sessionIdentity(msg.From, msg.View.Term)
+	if err != nil {
+		return nil, err
+	}
+	resolved := msg.Copy()
+	resolved.From = identity
+	return resolved, nil
+}
+
+// selfID returns the identity of the node in the validator set of the current term
+func (p *Pbft) selfID() NodeID {
+	var term uint64
+	if view := p.state.getView(); view != nil {
+		term = view.Term
+	}
+	identity, err := p.sessionIdentity(p.validator.NodeID(), term)
+	if err != nil {
+		p.logger.Printf("[ERROR] failed to resolve the own session key: %v", err)
+		return p.validator.NodeID()
+	}
+	return identity
+}
@@ -0,0 +1,152 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sessionKeyBackend registers the session keys of the validators per term
+type sessionKeyBackend struct {
+	*mockBackend
+	keys    map[uint64]map[NodeID]NodeID
+	lookups int
+}
+
+func (s *sessionKeyBackend) SessionIdentity(session NodeID, term uint64) (NodeID, bool) {
+	s.lookups++
+	identity, ok := s.keys[term][session]
+	return identity, ok
+}
+
+func newSessionKeyPbft(t *testing.T) (*mockPbft, *sessionKeyBackend) {
+	// the node signs with the session key xx of the validator A
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "")
+	backend := &sessionKeyBackend{
+		mockBackend: m.backend.(*mockBackend),
+		keys: map[uint64]map[NodeID]NodeID{
+			0: {"xx": "A", "b1": "B", "c1": "C"},
+		},
+	}
+	assert.NoError(t, m.SetBackend(backend))
+	return m, backend
+}
+
+func TestSessionKeys_Proposer(t *testing.T) {
+	m, _ := newSessionKeyPbft(t)
+	m.setState(AcceptState)
+	m.setProposal(&Proposal{
+		Data: mockProposal,
+		Time: time.Now(),
+	})
+
+	// A is the proposer of the round, the node proposes with its session key
+	m.runCycle(context.Background())
+	m.expect(expectResult{
+		sequence: 1,
+		outgoing: 2, // preprepare and prepare
+		state:    ValidateState,
+	})
+	assert.Equal(t, NodeID("xx"), m.respMsg[0].From)
+}
+
+func TestSessionKeys_ResolveSender(t *testing.T) {
+	m, backend := newSessionKeyPbft(t)
+	m.setState(ValidateState)
+
+	m.emitMsg(&MessageReq{From: "b1", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	m.emitMsg(&MessageReq{From: "c1", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	m.emitMsg(&MessageReq{From: "b1", Type: MessageReq_Commit, Seal: []byte{0x1}, View: ViewMsg(1, 0)})
+
+	// the long term identity is not a session key
+	m.emitMsg(&MessageReq{From: "D", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	assert.Equal(t, uint64(1), m.Stats().Discards[DiscardUnknownSessionKey])
+
+	// the identities are cached for the term
+	assert.Equal(t, 3, backend.lookups)
+
+	m.runCycle(context.Background())
+	assert.Contains(t, m.state.prepared, NodeID("B"))
+	assert.Contains(t, m.state.prepared, NodeID("C"))
+	assert.Contains(t, m.state.committed, NodeID("B"))
+	assert.Len(t, m.state.prepared, 2)
+
+	assert.Error(t, m.Preflight(&MessageReq{From: "D", Type: MessageReq_Prepare, Hash: digest, View: ViewMsg(1, 0)}))
+	assert.NoError(t, m.Preflight(&MessageReq{From: "b1", Type: MessageReq_Prepare, Hash: digest, View: ViewMsg(1, 0)}))
+}
+
+func TestSessionKeys_Rotation(t *testing.T) {
+	m, backend := newSessionKeyPbft(t)
+	id, err := m.sessionIdentity("b1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, NodeID("B"), id)
+
+	// B rotates its key in the next term
+	backend.keys[1] = map[NodeID]NodeID{"b2": "B"}
+
+	_, err = m.sessionIdentity("b1", 1)
+	assert.Error(t, err)
+	id, err = m.sessionIdentity("b2", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, NodeID("B"), id)
+
+	// the keys of the other terms stay cached
+	lookups := backend.lookups
+	id, err = m.sessionIdentity("b1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, NodeID("B"), id)
+	assert.Equal(t, lookups, backend.lookups)
+}
+
+func TestSessionKeys_FutureTerm(t *testing.T) {
+	m, backend := newSessionKeyPbft(t)
+	backend.keys[1] = map[NodeID]NodeID{"b2": "B"}
+	m.setState(ValidateState)
+
+	// the message of the next term is resolved with the keys of its term and queued
+	m.emitMsg(&MessageReq{From: "b2", Type: MessageReq_Prepare, View: &View{Sequence: 1, Term: 1}})
+	assert.Zero(t, m.Stats().Discards[DiscardUnknownSessionKey])
+
+	m.state.setView(&View{Sequence: 1, Term: 1})
+	m.runCycle(context.Background())
+	assert.Contains(t, m.state.prepared, NodeID("B"))
+}
+
+func TestSessionKeys_CacheTerms(t *testing.T) {
+	s := newSessionKeys()
+	for term := uint64(1); term <= maxSessionTerms; term++ {
+		s.set("b1", "B", term)
+	}
+	// the lowest term is evicted
+	s.set("b1", "B", maxSessionTerms+1)
+	_, ok := s.get("b1", 1)
+	assert.False(t, ok)
+	_, ok = s.get("b1", 2)
+	assert.True(t, ok)
+
+	// a term older than the cached ones is not cached
+	s.set("b1", "B", 0)
+	_, ok = s.get("b1", 0)
+	assert.False(t, ok)
+}
+
+type panicSessionKeyBackend struct {
+	*mockBackend
+}
+
+func (p *panicSessionKeyBackend) SessionIdentity(session NodeID, term uint64) (NodeID, bool) {
+	panic("boom")
+}
+
+func TestSessionKeys_BackendPanic(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	assert.NoError(t, m.SetBackend(&panicSessionKeyBackend{m.backend.(*mockBackend)}))
+	m.setState(ValidateState)
+
+	// the message is discarded without faulting the engine
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+	assert.Equal(t, uint64(1), m.Stats().Discards[DiscardUnknownSessionKey])
+	assert.Equal(t, ValidateState, m.GetState())
+}
@@ -3,6 +3,7 @@ package pbft
 import (
 	"bytes"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -14,6 +15,21 @@ const (
 	MessageReq_Preprepare  MsgType = 1
 	MessageReq_Commit      MsgType = 2
 	MessageReq_Prepare     MsgType = 3
+
+	// MessageReq_ProposalChunk is a chunk of a proposal streamed by the proposer.
+	// It is not part of the consensus flow and it is never queued
+	MessageReq_ProposalChunk MsgType = 4
+
+	// MessageReq_Committed aggregates the commit seals of a quorum of validators
+	MessageReq_Committed MsgType = 5
+
+	// MessageReq_Status announces the view of the sender to detect the lagging nodes.
+	// It is not part of the consensus flow and it is never queued
+	MessageReq_Status MsgType = 6
+
+	// MessageReq_ProposalRequest asks the peers for the preprepare of the view with the hash.
+	// It is not part of the consensus flow and it is never queued
+	MessageReq_ProposalRequest MsgType = 7
 )
 
 func (m MsgType) String() string {
@@ -26,6 +42,14 @@ func (m MsgType) String() string {
 		return "Commit"
 	case MessageReq_Prepare:
 		return "Prepare"
+	case MessageReq_ProposalChunk:
+		return "ProposalChunk"
+	case MessageReq_Committed:
+		return "Committed"
+	case MessageReq_Status:
+		return "Status"
+	case MessageReq_ProposalRequest:
+		return "ProposalRequest"
 	default:
 		panic(fmt.Sprintf("BUG: Bad msgtype %d", m))
 	}
@@ -38,7 +62,9 @@ type MessageReq struct {
 	// from is the address of the sender
 	From NodeID
 
-	// seal is the committed seal for the proposal (only for commit messages)
+	// seal is the committed seal for the proposal (only for commit messages and
+	// the prepare messages of the fast path, see Config.FastPath), or the round
+	// change seal of a node that is not locked (see RoundChangeDigest)
 	Seal []byte
 
 	// view is the view assigned to the message
@@ -49,20 +75,61 @@ type MessageReq struct {
 
 	// proposal is the arbitrary data proposal (only for preprepare messages)
 	Proposal []byte
+
+	// chainID is the identifier of the network the message belongs to
+	ChainID uint64
+
+	// chunkCount is the number of chunks of a streamed proposal
+	// (only for preprepare and proposal chunk messages)
+	ChunkCount uint32
+
+	// chunkIndex is the index of the chunk (only for proposal chunk messages)
+	ChunkIndex uint32
+
+	// committedSeals are the aggregated commit seals (only for committed messages),
+	// or the round change seals justifying a fresh proposal (only for preprepare messages)
+	CommittedSeals []CommittedSeal
+
+	// roundChangeReason is the cause of the round change (only for round change messages)
+	RoundChangeReason RoundChangeReason
 }
 
 func (m *MessageReq) Validate() error {
+	if m.Type < MessageReq_RoundChange || m.Type > MessageReq_ProposalRequest {
+		return fmt.Errorf("unknown message type %d", m.Type)
+	}
+	if m.View == nil {
+		return errViewMissing
+	}
+
 	// Hash field has to exist for state != RoundStateChange
-	if m.Type != MessageReq_RoundChange {
-		if m.Hash == nil {
+	if m.Type != MessageReq_RoundChange && m.Type != MessageReq_Status {
+		if len(m.Hash) == 0 {
 			return fmt.Errorf("hash is empty for type %s", m.Type.String())
 		}
 	}
 
+	// only preprepare and chunk messages carry the proposal payload,
+	// the rest of the messages are digest-only
+	if m.IsDigestOnly() && len(m.Proposal) != 0 {
+		return fmt.Errorf("proposal payload not allowed for type %s", m.Type.String())
+	}
+
 	// TODO
 	return nil
 }
 
+// IsDigestOnly returns whether the message type references the proposal only by its hash.
+// Prepare, Commit and RoundChange messages never carry the proposal payload
+func (m *MessageReq) IsDigestOnly() bool {
+	return m.Type != MessageReq_Preprepare && m.Type != MessageReq_ProposalChunk
+}
+
+// PayloadSize returns the size of the proposal payload carried by the message
+func (m *MessageReq) PayloadSize() int {
+	return len(m.Proposal)
+}
+
 func (m *MessageReq) SetProposal(proposal []byte) {
 	m.Proposal = append([]byte{}, proposal...)
 }
@@ -79,6 +146,15 @@ func (m *MessageReq) Copy() *MessageReq {
 	if m.Seal != nil {
 		mm.Seal = append([]byte{}, m.Seal...)
 	}
+	if m.CommittedSeals != nil {
+		mm.CommittedSeals = make([]CommittedSeal, len(m.CommittedSeals))
+		for i, seal := range m.CommittedSeals {
+			mm.CommittedSeals[i] = CommittedSeal{Signer: seal.Signer}
+			if seal.Seal != nil {
+				mm.CommittedSeals[i].Seal = append([]byte{}, seal.Seal...)
+			}
+		}
+	}
 	return mm
 }
 
@@ -88,6 +164,10 @@ type View struct {
 
 	// Sequence is a sequence number inside the round
 	Sequence uint64
+
+	// Term is the identifier of the validator set membership (epoch).
+	// Messages from a different term are not mixed with the current ones
+	Term uint64
 }
 
 func (v *View) Copy() *View {
@@ -119,6 +199,13 @@ const (
 	CommitState
 	SyncState
 	DoneState
+
+	// FaultedState is reached when a backend callback panics, the state machine stops
+	FaultedState
+
+	// DemotedState is reached when the local node is removed from the validator set
+	// with the DemotionStop policy, the state machine stops
+	DemotedState
 )
 
 // String returns the string representation of the passed in state
@@ -136,6 +223,10 @@ func (i PbftState) String() string {
 		return "SyncState"
 	case DoneState:
 		return "DoneState"
+	case FaultedState:
+		return "FaultedState"
+	case DemotedState:
+		return "DemotedState"
 	}
 	panic(fmt.Sprintf("BUG: Pbft state not found %d", i))
 }
@@ -149,6 +240,10 @@ type Proposal struct {
 
 	// Hash is the digest of the data to seal
 	Hash []byte
+
+	// MaxRound is the last round in which the proposal can be decided, nil if the proposal
+	// does not expire. It is set by the backend in BuildProposal or Validate (see lockExpired)
+	MaxRound *uint64
 }
 
 // Equal compares whether two proposals have the same hash
@@ -161,8 +256,16 @@ func (p *Proposal) Copy() *Proposal {
 	pp := new(Proposal)
 	*pp = *p
 
-	pp.Data = append([]byte{}, p.Data...)
-	pp.Hash = append([]byte{}, p.Hash...)
+	if p.Data != nil {
+		pp.Data = append([]byte{}, p.Data...)
+	}
+	if p.Hash != nil {
+		pp.Hash = append([]byte{}, p.Hash...)
+	}
+	if p.MaxRound != nil {
+		maxRound := *p.MaxRound
+		pp.MaxRound = &maxRound
+	}
 	return pp
 }
 
@@ -183,6 +286,12 @@ type currentState struct {
 	// Current view
 	view *View
 
+	// viewLock guards the updates of the view for concurrent readers
+	viewLock sync.RWMutex
+
+	// validatorsLock guards the updates of the validators for concurrent readers
+	validatorsLock sync.RWMutex
+
 	// List of prepared messages
 	prepared map[NodeID]*MessageReq
 
@@ -192,11 +301,25 @@ type currentState struct {
 	// List of round change messages
 	roundMessages map[uint64]map[NodeID]*MessageReq
 
+	// First prepare and commit messages received from each sender in the round,
+	// regardless of the proposal hash. Used to detect equivocation
+	seen map[MsgType]map[NodeID]*MessageReq
+
 	// Locked signals whether the proposal is locked
 	locked bool
 
 	// Describes whether there has been an error during the computation
 	err error
+
+	// roundChangeReason is the cause of the last round change
+	roundChangeReason RoundChangeReason
+
+	// devMode signals whether the quorum is adjusted for validator sets smaller than 4
+	devMode bool
+
+	// justification are the round change seals bundled by the proposer with a fresh proposal
+	// that replaces its locked proposal in the round
+	justification []CommittedSeal
 }
 
 // newState creates a new state with reset round messages
@@ -211,14 +334,70 @@ func (c *currentState) GetSequence() uint64 {
 	return c.view.Sequence
 }
 
+// setView sets the current view
+func (c *currentState) setView(v *View) {
+	c.viewLock.Lock()
+	defer c.viewLock.Unlock()
+
+	c.view = v
+}
+
+// setRound sets the round of the current view
+func (c *currentState) setRound(round uint64) {
+	c.viewLock.Lock()
+	defer c.viewLock.Unlock()
+
+	c.view.Round = round
+}
+
+// getView returns a copy of the current view, safe for concurrent use
+func (c *currentState) getView() *View {
+	c.viewLock.RLock()
+	defer c.viewLock.RUnlock()
+
+	if c.view == nil {
+		return nil
+	}
+	return c.view.Copy()
+}
+
+// setValidators sets the validator set of the current height
+func (c *currentState) setValidators(validators ValidatorSet) {
+	c.validatorsLock.Lock()
+	defer c.validatorsLock.Unlock()
+
+	c.validators = validators
+}
+
+// getValidators returns the validator set of the current height, safe for concurrent use
+func (c *currentState) getValidators() ValidatorSet {
+	c.validatorsLock.RLock()
+	defer c.validatorsLock.RUnlock()
+
+	return c.validators
+}
+
+// getCommittedSeals returns the commit seals sorted by signer
 func (c *currentState) getCommittedSeals() [][]byte {
 	committedSeals := [][]byte{}
-	for _, commit := range c.committed {
-		committedSeals = append(committedSeals, commit.Seal)
+	for _, from := range sortedSenders(c.committed) {
+		committedSeals = append(committedSeals, c.committed[from].Seal)
 	}
 	return committedSeals
 }
 
+// getCommittedSealsWithSigners returns the commit seals along with their signers, sorted by signer
+func (c *currentState) getCommittedSealsWithSigners() []CommittedSeal {
+	seals := []CommittedSeal{}
+	for _, from := range sortedSenders(c.committed) {
+		seals = append(seals, CommittedSeal{
+			Signer: from,
+			Seal:   c.committed[from].Seal,
+		})
+	}
+	return seals
+}
+
 // getState returns the current state
 func (c *currentState) getState() PbftState {
 	stateAddr := (*uint64)(&c.state)
@@ -235,7 +414,7 @@ func (c *currentState) setState(s PbftState) {
 
 // MaxFaultyNodes returns the maximum number of allowed faulty nodes (F), based on the current validator set size
 func (c *currentState) MaxFaultyNodes() int {
-	return MaxFaultyNodes(c.validators.Len())
+	return MaxFaultyNodes(c.getValidators().Len())
 }
 
 // NumValid returns the number of required messages
@@ -243,7 +422,11 @@ func (c *currentState) NumValid() int {
 	// 2 * F + 1
 	// + 1 is up to the caller to add
 	// the current node tallying the messages will include its own message
-	return QuorumSize(c.validators.Len()) - 1
+	size := c.getValidators().Len()
+	if c.devMode {
+		return DevQuorumSize(size) - 1
+	}
+	return QuorumSize(size) - 1
 }
 
 // getErr returns the current error, if any, and consumes it
@@ -254,11 +437,10 @@ func (c *currentState) getErr() error {
 	return err
 }
 
+// maxRound returns the highest round whose round change messages reach the weak quorum
 func (c *currentState) maxRound() (maxRound uint64, found bool) {
-	num := c.MaxFaultyNodes() + 1
-
 	for currentRound, messages := range c.roundMessages {
-		if len(messages) < num {
+		if !c.hasWeakQuorum(messages) {
 			continue
 		}
 		if maxRound < currentRound {
@@ -274,6 +456,8 @@ func (c *currentState) resetRoundMsgs() {
 	c.prepared = map[NodeID]*MessageReq{}
 	c.committed = map[NodeID]*MessageReq{}
 	c.roundMessages = map[uint64]map[NodeID]*MessageReq{}
+	c.seen = map[MsgType]map[NodeID]*MessageReq{}
+	c.justification = nil
 }
 
 // CalcProposer calculates the proposer and sets it to the state
@@ -346,6 +530,40 @@ func (c *currentState) addMessage(msg *MessageReq) {
 	}
 }
 
+// hasMessage returns whether a prepare or commit message from the same sender was already added
+func (c *currentState) hasMessage(msg *MessageReq) bool {
+	var ok bool
+	switch msg.Type {
+	case MessageReq_Prepare:
+		_, ok = c.prepared[msg.From]
+	case MessageReq_Commit:
+		_, ok = c.committed[msg.From]
+	}
+	return ok
+}
+
+// conflictingMessage returns the previously seen message from the same sender, type and view
+// if it carries a different hash than msg. Otherwise, it records msg and returns nil
+func (c *currentState) conflictingMessage(msg *MessageReq) *MessageReq {
+	if msg.Type != MessageReq_Prepare && msg.Type != MessageReq_Commit {
+		return nil
+	}
+	seen, ok := c.seen[msg.Type]
+	if !ok {
+		seen = map[NodeID]*MessageReq{}
+		c.seen[msg.Type] = seen
+	}
+	prev, ok := seen[msg.From]
+	if !ok {
+		seen[msg.From] = msg
+		return nil
+	}
+	if cmpView(prev.View, msg.View) != 0 || bytes.Equal(prev.Hash, msg.Hash) {
+		return nil
+	}
+	return prev
+}
+
 // numPrepared returns the number of messages in the prepared message list
 func (c *currentState) numPrepared() int {
 	return len(c.prepared)
@@ -356,6 +574,9 @@ func (c *currentState) numCommitted() int {
 	return len(c.committed)
 }
 
+// ValidatorSet is the set of validators of a sequence. Includes is called for every
+// message and is expected to be O(1), a map lookup instead of a scan of the members.
+// The engine indexes the sets implementing ValidatorLister on its own
 type ValidatorSet interface {
 	CalcProposer(round uint64) NodeID
 	Includes(id NodeID) bool
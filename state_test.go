@@ -96,6 +96,28 @@ func Test_QuorumSize(t *testing.T) {
 	}
 }
 
+func Test_DevQuorumSize(t *testing.T) {
+	cases := []struct {
+		TotalNodesCount, QuorumSize int
+	}{
+		{1, 1},
+		{2, 2},
+		{3, 3},
+		{4, 3},
+		{7, 5},
+		{100, 67},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.QuorumSize, DevQuorumSize(c.TotalNodesCount))
+
+		s := newState()
+		s.devMode = true
+		s.validators = convertToMockValidatorSet(generateValidatorNodes(c.TotalNodesCount, "validator"))
+		assert.Equal(t, c.QuorumSize-1, s.NumValid())
+	}
+}
+
 func TestState_ValidNodesCount(t *testing.T) {
 	cases := []struct {
 		TotalNodesCount, ValidNodesCount int
@@ -347,6 +369,12 @@ func TestMsgType_ToString(t *testing.T) {
 		MessageReq_Preprepare:  "Preprepare",
 		MessageReq_Commit:      "Commit",
 		MessageReq_Prepare:     "Prepare",
+
+		MessageReq_ProposalChunk: "ProposalChunk",
+		MessageReq_Committed:     "Committed",
+
+		MessageReq_Status:          "Status",
+		MessageReq_ProposalRequest: "ProposalRequest",
 	}
 
 	for msgType, expected := range expectedMapping {
@@ -354,6 +382,42 @@ func TestMsgType_ToString(t *testing.T) {
 	}
 }
 
+func TestMessageReq_Validate_DigestOnly(t *testing.T) {
+	for _, msgType := range []MsgType{MessageReq_RoundChange, MessageReq_Prepare, MessageReq_Commit} {
+		msg := createMessage("A", msgType)
+		msg.Hash = digest
+		assert.True(t, msg.IsDigestOnly())
+		assert.Error(t, msg.Validate())
+
+		msg.Proposal = nil
+		assert.NoError(t, msg.Validate())
+	}
+
+	for _, msgType := range []MsgType{MessageReq_Preprepare, MessageReq_ProposalChunk} {
+		msg := createMessage("A", msgType)
+		msg.Hash = digest
+		assert.False(t, msg.IsDigestOnly())
+		assert.Equal(t, len(mockProposal), msg.PayloadSize())
+		assert.NoError(t, msg.Validate())
+	}
+}
+
+func TestMessageReq_Validate_EmptyHash(t *testing.T) {
+	for _, hash := range [][]byte{nil, {}} {
+		msg := &MessageReq{Type: MessageReq_Prepare, From: "A", View: ViewMsg(1, 0), Hash: hash}
+		assert.Error(t, msg.Validate())
+	}
+	msg := &MessageReq{Type: MessageReq_RoundChange, From: "A", View: ViewMsg(1, 0)}
+	assert.NoError(t, msg.Validate())
+}
+
+func TestMessageReq_Validate_UnknownType(t *testing.T) {
+	for _, msgType := range []MsgType{-1, MessageReq_ProposalRequest + 1} {
+		msg := &MessageReq{Type: msgType, From: "A", View: ViewMsg(1, 0)}
+		assert.Error(t, msg.Validate())
+	}
+}
+
 func TestPbftState_ToString(t *testing.T) {
 	expectedMapping := map[PbftState]string{
 		AcceptState:      "AcceptState",
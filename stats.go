@@ -0,0 +1,86 @@
+package pbft
+
+import "sync"
+
+// Stats is a snapshot of the counters collected by the engine
+type Stats struct {
+	// CrossChainDrops is the number of messages dropped because
+	// they were generated for a different chain
+	CrossChainDrops uint64
+
+	// QuarantineDrops is the number of messages dropped because
+	// the sender is quarantined
+	QuarantineDrops uint64
+
+	// GossipedMessages is the number of messages sent through the transport
+	GossipedMessages uint64
+
+	// GossipedPayloadBytes is the number of proposal payload bytes sent through the transport
+	GossipedPayloadBytes uint64
+
+	// ProposalRequests is the number of requests sent for a missing preprepare
+	ProposalRequests uint64
+
+	// ServedProposals is the number of preprepare messages sent back to the peers that requested them
+	ServedProposals uint64
+
+	// ProtocolViolations is the number of messages rejected for structural reasons (see WithProtocolViolationHandler)
+	ProtocolViolations uint64
+
+	// StrictViolations is the number of messages that failed the strict mode checks (see WithStrictMode)
+	StrictViolations uint64
+
+	// VerifiedMessages is the number of messages received with PushVerifiedMessage
+	VerifiedMessages uint64
+
+	// UnverifiedMessages is the number of messages received with PushMessage
+	UnverifiedMessages uint64
+
+	// Discards is the number of discarded messages by reason
+	Discards map[DiscardReason]uint64
+
+	// RoundChanges is the number of round change messages sent by reason
+	RoundChanges map[RoundChangeReason]uint64
+
+	// ReceivedRoundChanges is the number of round change messages received from the peers by reason
+	ReceivedRoundChanges map[RoundChangeReason]uint64
+}
+
+// statsCollector holds the engine counters and guards them for concurrent access
+type statsCollector struct {
+	lock  sync.Mutex
+	stats Stats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{}
+}
+
+// update applies the given function to the counters under the lock
+func (s *statsCollector) update(fn func(*Stats)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fn(&s.stats)
+}
+
+// snapshot returns a copy of the current counters
+func (s *statsCollector) snapshot() Stats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := s.stats
+	stats.Discards = map[DiscardReason]uint64{}
+	for reason, num := range s.stats.Discards {
+		stats.Discards[reason] = num
+	}
+	stats.RoundChanges = map[RoundChangeReason]uint64{}
+	for reason, num := range s.stats.RoundChanges {
+		stats.RoundChanges[reason] = num
+	}
+	stats.ReceivedRoundChanges = map[RoundChangeReason]uint64{}
+	for reason, num := range s.stats.ReceivedRoundChanges {
+		stats.ReceivedRoundChanges[reason] = num
+	}
+	return stats
+}
@@ -0,0 +1,169 @@
+package pbft
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// staleStatusIntervals is the number of status intervals after which the status of a peer is stale
+	staleStatusIntervals = 3
+
+	// defaultStatusMaxAge is the age of a stale status if the node does not announce its own
+	defaultStatusMaxAge = time.Minute
+)
+
+func WithStatusInterval(interval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.StatusInterval = interval
+	}
+}
+
+// peerStatus is the last status announced by a validator
+type peerStatus struct {
+	view     *View
+	received time.Time
+}
+
+// statusTracker keeps the last status message of every validator, it is updated from
+// the transport and read from the state machine loop
+type statusTracker struct {
+	lock  sync.Mutex
+	peers map[NodeID]*peerStatus
+
+	// behindCh wakes up the state machine loop once the peers are ahead (see handleStatus)
+	behindCh chan struct{}
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		peers:    map[NodeID]*peerStatus{},
+		behindCh: make(chan struct{}, 1),
+	}
+}
+
+// notifyBehind wakes up the state machine loop, if it is not already notified
+func (s *statusTracker) notifyBehind() {
+	select {
+	case s.behindCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *statusTracker) update(from NodeID, view *View) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.peers[from] = &peerStatus{view: view.Copy(), received: time.Now()}
+}
+
+// evict removes the statuses received before the deadline
+func (s *statusTracker) evict(deadline time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for peer, status := range s.peers {
+		if status.received.Before(deadline) {
+			delete(s.peers, peer)
+		}
+	}
+}
+
+// ahead returns the peer with the highest sequence such that the validators that announced at least
+// that sequence reach the weak quorum (see weakQuorum), and its view. Ties are broken by the node id
+func (s *statusTracker) ahead(validators ValidatorSet) (NodeID, *View, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	peers := []NodeID{}
+	for peer := range s.peers {
+		if validators.Includes(peer) {
+			peers = append(peers, peer)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		a, b := s.peers[peers[i]].view.Sequence, s.peers[peers[j]].view.Sequence
+		return a > b || (a == b && peers[i] < peers[j])
+	})
+	for i, peer := range peers {
+		if weakQuorum(validators, peers[:i+1]) {
+			return peer, s.peers[peer].view.Copy(), true
+		}
+	}
+	return "", nil, false
+}
+
+// statusMaxAge returns the age after which the status of a peer is stale
+func (p *Pbft) statusMaxAge() time.Duration {
+	if p.config.StatusInterval > 0 {
+		return staleStatusIntervals * p.config.StatusInterval
+	}
+	return defaultStatusMaxAge
+}
+
+// SyncHint returns a validator ahead of the local sequence and the height it announced through
+// the status messages (the last finalized proposal of the peer). The height is the highest one
+// announced by a weak quorum of validators (F+1, or more than a third of the voting power), so that
+// the faulty validators cannot trigger a sync on their own, and the stale statuses are evicted. The backend can use it to implement IsStuck and to
+// pick the peer to sync from
+func (p *Pbft) SyncHint() (NodeID, uint64, bool) {
+	current := p.state.getView()
+	validators := p.state.getValidators()
+	if current == nil || validators == nil {
+		return "", 0, false
+	}
+	p.status.evict(time.Now().Add(-p.statusMaxAge()))
+
+	peer, view, ok := p.status.ahead(validators)
+	if !ok || view.Sequence <= current.Sequence {
+		return "", 0, false
+	}
+	return peer, view.Sequence - 1, true
+}
+
+// handleStatus records the status announced by a validator. The view of the status
+// is not bounded by validateView since lagging nodes are the ones that need it
+func (p *Pbft) handleStatus(msg *MessageReq) {
+	if validators := p.state.getValidators(); validators == nil || !validators.Includes(msg.From) {
+		p.countDiscard(msg, DiscardNotValidator)
+		return
+	}
+	p.status.update(msg.From, msg.View)
+
+	// a node in the round change state waits for the round timeout, which grows with the round,
+	// before it checks whether the peers finalized the sequence (i.e. it kept a lock on a proposal
+	// the others did not commit). Wake it up so that it syncs right away
+	if p.getState() == RoundChangeState {
+		if _, ok := p.statusHint(); ok {
+			p.status.notifyBehind()
+		}
+	}
+}
+
+// runStatusGossip announces the current view of the node every StatusInterval
+func (p *Pbft) runStatusGossip(ctx context.Context) {
+	ticker := time.NewTicker(p.config.StatusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.transportGossip(&MessageReq{
+				Type:    MessageReq_Status,
+				From:    p.validator.NodeID(),
+				ChainID: p.config.ChainID,
+				View:    p.state.getView(),
+			})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// statusHint returns whether the peers announced a height beyond the local sequence
+func (p *Pbft) statusHint() (uint64, bool) {
+	_, height, ok := p.SyncHint()
+	return height, ok
+}
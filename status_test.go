@@ -0,0 +1,107 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus_SyncHint(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+
+	_, _, ok := m.SyncHint()
+	assert.False(t, ok)
+
+	// peers at the same sequence are not ahead
+	m.PushMessage(&MessageReq{From: "B", Type: MessageReq_Status, View: ViewMsg(1, 3)})
+	_, _, ok = m.SyncHint()
+	assert.False(t, ok)
+
+	// a single validator far ahead does not trigger a sync, non validators are ignored
+	m.PushMessage(&MessageReq{From: "C", Type: MessageReq_Status, View: ViewMsg(5000, 0)})
+	m.PushMessage(&MessageReq{From: "X", Type: MessageReq_Status, View: ViewMsg(6000, 0)})
+	_, _, ok = m.SyncHint()
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), m.Stats().Discards[DiscardNotValidator])
+
+	// F+1 validators ahead, the height is the highest one announced by both
+	m.PushMessage(&MessageReq{From: "D", Type: MessageReq_Status, View: ViewMsg(40, 0)})
+
+	peer, height, ok := m.SyncHint()
+	assert.True(t, ok)
+	assert.Equal(t, NodeID("D"), peer)
+	assert.Equal(t, uint64(39), height)
+
+	// status messages are never queued
+	assert.Nil(t, m.msgQueue.readMessage(RoundChangeState, m.state.view))
+}
+
+func TestStatus_SyncHint_Stale(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.StatusInterval = time.Millisecond
+
+	m.PushMessage(&MessageReq{From: "B", Type: MessageReq_Status, View: ViewMsg(5, 0)})
+	m.PushMessage(&MessageReq{From: "C", Type: MessageReq_Status, View: ViewMsg(5, 0)})
+	_, _, ok := m.SyncHint()
+	assert.True(t, ok)
+
+	// the statuses are evicted once they are older than a few status intervals
+	time.Sleep(5 * time.Millisecond)
+	_, _, ok = m.SyncHint()
+	assert.False(t, ok)
+	assert.Empty(t, m.status.peers)
+}
+
+func TestTransition_RoundChangeState_StatusAhead(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.SetState(RoundChangeState)
+
+	// the backend is not stuck but F+1 peers announced a higher height
+	m.PushMessage(&MessageReq{From: "B", Type: MessageReq_Status, View: ViewMsg(3, 0)})
+	m.PushMessage(&MessageReq{From: "C", Type: MessageReq_Status, View: ViewMsg(3, 0)})
+
+	m.runCycle(context.Background())
+	assert.True(t, m.IsState(SyncState))
+}
+
+func TestTransition_RoundChangeState_StatusAhead_Waiting(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	// the round change is sent and the round does not time out while the node waits
+	m.roundTimeout = func(uint64) time.Duration { return time.Hour }
+	m.state.err = errIncorrectLockedProposal
+	m.SetState(RoundChangeState)
+	defer time.AfterFunc(time.Second, m.cancelFn).Stop()
+
+	go func() {
+		for !m.TimerState().Active {
+			time.Sleep(time.Millisecond)
+		}
+		// F+1 peers finalized the sequence, the node syncs without waiting for the timeout
+		m.PushMessage(&MessageReq{From: "B", Type: MessageReq_Status, View: ViewMsg(2, 0)})
+		m.PushMessage(&MessageReq{From: "C", Type: MessageReq_Status, View: ViewMsg(2, 0)})
+	}()
+
+	m.runCycle(context.Background())
+	assert.True(t, m.IsState(SyncState))
+	assert.Equal(t, uint64(1), m.state.view.Round)
+}
+
+func TestStatus_Gossip(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.StatusInterval = time.Millisecond
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelFn()
+
+	m.runStatusGossip(ctx)
+
+	assert.NotEmpty(t, m.respMsg)
+	for _, msg := range m.respMsg {
+		assert.Equal(t, MessageReq_Status, msg.Type)
+		assert.Equal(t, NodeID("A"), msg.From)
+		assert.Equal(t, ViewMsg(1, 0), msg.View)
+		assert.NoError(t, msg.Validate())
+	}
+}
@@ -0,0 +1,121 @@
+package pbft
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errMissingFields = fmt.Errorf("missing required fields")
+
+// StrictMode is the handling of the messages that fail the authenticator or miss required fields
+type StrictMode int
+
+const (
+	// StrictDisabled accepts every message that passes the basic validation (the default)
+	StrictDisabled StrictMode = iota
+
+	// StrictLogOnly logs and counts the violations but still processes the messages,
+	// to find the peers that would be rejected before enforcing the strict mode
+	StrictLogOnly
+
+	// StrictEnforced drops and counts the messages with violations
+	StrictEnforced
+)
+
+func (s StrictMode) String() string {
+	switch s {
+	case StrictDisabled:
+		return "Disabled"
+	case StrictLogOnly:
+		return "LogOnly"
+	case StrictEnforced:
+		return "Enforced"
+	default:
+		return fmt.Sprintf("StrictMode(%d)", int(s))
+	}
+}
+
+// WithStrictMode checks that every message received from the peers carries the fields required
+// by its type and is authenticated by the verifier (if not nil). In StrictLogOnly mode the
+// violations are only logged and counted (see Stats.StrictViolations), in StrictEnforced
+// mode the messages are dropped as well
+func WithStrictMode(mode StrictMode, verifier MessageVerifier) ConfigOption {
+	return func(c *Config) {
+		c.StrictMode = mode
+		c.MessageVerifier = verifier
+	}
+}
+
+// requiredFields checks the fields required by the type of the message, beyond the basic
+// validation (see MessageReq.Validate) which already checks the hash
+func requiredFields(msg *MessageReq, finalityGadget bool) error {
+	if msg.From == "" {
+		return fmt.Errorf("sender is empty")
+	}
+	switch msg.Type {
+	case MessageReq_Preprepare:
+		// the candidates of the finality gadget mode are only referenced by their hash
+		if len(msg.Proposal) == 0 && msg.ChunkCount == 0 && !finalityGadget {
+			return fmt.Errorf("proposal is empty")
+		}
+	case MessageReq_ProposalChunk:
+		if len(msg.Proposal) == 0 {
+			return fmt.Errorf("chunk is empty")
+		}
+	case MessageReq_Commit:
+		if len(msg.Seal) == 0 {
+			return fmt.Errorf("seal is empty")
+		}
+	case MessageReq_Committed:
+		if len(msg.CommittedSeals) == 0 {
+			return fmt.Errorf("committed seals are empty")
+		}
+		for _, seal := range msg.CommittedSeals {
+			if seal.Signer == "" || len(seal.Seal) == 0 {
+				return fmt.Errorf("incomplete committed seal")
+			}
+		}
+	}
+	return nil
+}
+
+// strictViolation checks the message against the strict mode, the verifier is called only if
+// authenticate is set. The verifier panics are reported as violations
+func (p *Pbft) strictViolation(msg *MessageReq, authenticate bool) error {
+	if err := requiredFields(msg, p.config.FinalityGadget); err != nil {
+		return fmt.Errorf("%w: %v", errMissingFields, err)
+	}
+	if !authenticate || p.config.MessageVerifier == nil {
+		return nil
+	}
+	var verifyErr error
+	if err := recoverBackend("MessageVerifier", func() { verifyErr = p.config.MessageVerifier(msg) }); err != nil {
+		return err
+	}
+	if verifyErr != nil {
+		return fmt.Errorf("failed to authenticate: %v", verifyErr)
+	}
+	return nil
+}
+
+// strictAllows applies the strict mode to a message of a peer. It returns false if the message must be dropped
+func (p *Pbft) strictAllows(msg *MessageReq, authenticate bool) bool {
+	if p.config.StrictMode == StrictDisabled {
+		return true
+	}
+	err := p.strictViolation(msg, authenticate)
+	if err == nil {
+		return true
+	}
+	p.stats.update(func(s *Stats) { s.StrictViolations++ })
+	if p.config.StrictMode == StrictLogOnly {
+		p.logger.Printf("[WARN] strict mode violation: from=%s, type=%s, err=%v", msg.From, msg.Type, err)
+		return true
+	}
+	p.logger.Printf("[ERROR] strict mode violation: from=%s, type=%s, err=%v", msg.From, msg.Type, err)
+	p.countDiscard(msg, DiscardStrictViolation)
+	if errors.Is(err, errMissingFields) {
+		p.reportViolation(msg, err)
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package pbft
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrictMode_RequiredFields(t *testing.T) {
+	cases := []struct {
+		name string
+		msg  *MessageReq
+		err  bool
+	}{
+		{"prepare", &MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest}, false},
+		{"missing sender", &MessageReq{Type: MessageReq_Prepare, Hash: digest}, true},
+		{"round change", &MessageReq{Type: MessageReq_RoundChange, From: "B"}, false},
+		{"commit", &MessageReq{Type: MessageReq_Commit, From: "B", Hash: digest, Seal: []byte{1}}, false},
+		{"unsealed commit", &MessageReq{Type: MessageReq_Commit, From: "B", Hash: digest}, true},
+		{"empty preprepare", &MessageReq{Type: MessageReq_Preprepare, From: "B", Hash: digest}, true},
+		{"chunked preprepare", &MessageReq{Type: MessageReq_Preprepare, From: "B", Hash: digest, ChunkCount: 2}, false},
+		{"committed", &MessageReq{Type: MessageReq_Committed, From: "B", Hash: digest, CommittedSeals: []CommittedSeal{{Signer: "C", Seal: []byte{1}}}}, false},
+		{"incomplete committed", &MessageReq{Type: MessageReq_Committed, From: "B", Hash: digest, CommittedSeals: []CommittedSeal{{Signer: "C"}}}, true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.err, requiredFields(c.msg, false) != nil, c.name)
+	}
+
+	// the preprepare of the finality gadget mode only carries the hash of the candidate
+	assert.NoError(t, requiredFields(&MessageReq{Type: MessageReq_Preprepare, From: "B", Hash: digest}, true))
+}
+
+func TestStrictMode_Enforced(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.setState(ValidateState)
+	WithStrictMode(StrictEnforced, func(msg *MessageReq) error {
+		if msg.From == "C" {
+			return errors.New("bad signature")
+		}
+		return nil
+	})(m.config)
+
+	// unsealed commit
+	m.PushMessage(&MessageReq{Type: MessageReq_Commit, From: "B", Hash: digest, View: ViewMsg(1, 0)})
+	// authenticator failure
+	m.PushMessage(&MessageReq{Type: MessageReq_Prepare, From: "C", Hash: digest, View: ViewMsg(1, 0)})
+	assert.Nil(t, m.msgQueue.readMessage(ValidateState, m.state.view))
+
+	stats := m.Stats()
+	assert.Equal(t, uint64(2), stats.StrictViolations)
+	assert.Equal(t, uint64(2), stats.Discards[DiscardStrictViolation])
+
+	m.PushMessage(&MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(1, 0)})
+	assert.NotNil(t, m.msgQueue.readMessage(ValidateState, m.state.view))
+
+	err := m.Preflight(&MessageReq{Type: MessageReq_Prepare, From: "C", Hash: digest, View: ViewMsg(1, 0)})
+	assert.Error(t, err)
+}
+
+func TestStrictMode_LogOnly(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.setState(ValidateState)
+	WithStrictMode(StrictLogOnly, func(msg *MessageReq) error {
+		return errors.New("bad signature")
+	})(m.config)
+
+	msg := &MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(1, 0)}
+	assert.NoError(t, m.Preflight(msg))
+
+	m.PushMessage(msg)
+	assert.NotNil(t, m.msgQueue.readMessage(ValidateState, m.state.view))
+
+	stats := m.Stats()
+	assert.Equal(t, uint64(1), stats.StrictViolations)
+	assert.Zero(t, stats.Discards[DiscardStrictViolation])
+}
+
+func TestStrictMode_VerifierPanic(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	WithStrictMode(StrictEnforced, func(msg *MessageReq) error {
+		panic("boom")
+	})(m.config)
+
+	err := m.strictViolation(&MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(1, 0)}, true)
+	assert.True(t, errors.Is(err, errBackendPanic))
+}
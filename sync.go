@@ -0,0 +1,49 @@
+package pbft
+
+import "context"
+
+// SyncProvider is an optional interface implemented by the backends that sync the chain
+// on their own. When the engine enters the SyncState, Run asks the backend to sync and
+// waits for it, then resumes the consensus at the new height instead of returning
+type SyncProvider interface {
+	// Sync starts the sync of the backend and returns a channel that receives the result
+	// of the sync (nil on success). The Height and the ValidatorSet of the backend must
+	// be the ones of the synced chain once it is received
+	Sync() <-chan error
+}
+
+// waitForSync waits for the backend to complete the sync and resets the engine at the
+// synced height. It returns false if the backend does not sync on its own, the sync
+// failed or the context is done, in that case the engine stays in the SyncState
+func (p *Pbft) waitForSync(ctx context.Context) bool {
+	provider, ok := p.backend.(SyncProvider)
+	if !ok {
+		return false
+	}
+	var done <-chan error
+	if err := p.guard("Sync", func() { done = provider.Sync() }); err != nil {
+		return false
+	}
+	p.logger.Printf("[INFO] waiting for the backend to sync: sequence=%d", p.state.view.Sequence)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			p.logger.Printf("[ERROR] failed to sync: %v", err)
+			p.health.setErr(err)
+			return false
+		}
+	case <-ctx.Done():
+		return false
+	}
+
+	// resume at the synced height with its validators
+	if err := p.setBackend(p.backend); err != nil {
+		p.logger.Printf("[ERROR] failed to resume after the sync: %v", err)
+		return false
+	}
+	p.logger.Printf("[INFO] sync completed: sequence=%d", p.state.view.Sequence)
+	p.health.progress()
+	p.setState(AcceptState)
+	return true
+}
@@ -0,0 +1,245 @@
+package pbft
+
+import (
+	"sort"
+	"sync"
+)
+
+// maxFinalityCandidates is the maximum number of proposals tracked per height while syncing
+const maxFinalityCandidates = 16
+
+// FinalityProofBackend is an optional interface implemented by the backends that accept the
+// finality proofs collected by the engine in the SyncState. The engine does not vote while
+// syncing, it only verifies the commit quorums of the heights it is catching up on. The proposal
+// of a proof comes from the preprepare of the proposer of the view and is checked with Validate.
+// The seals are verified against the hash they certify, hence the backend must implement
+// CommitSealBackend, otherwise no proof is collected
+type FinalityProofBackend interface {
+	// FinalityProof receives a verified proof of a height above the current one
+	FinalityProof(proof *FinalityProof)
+}
+
+// FinalityValidatorSetBackend is an optional interface of the FinalityProofBackend that knows the
+// validator sets of the heights above the current one. Without it, only the proofs of the current
+// height are collected since the validator set of the next heights may differ
+type FinalityValidatorSetBackend interface {
+	// ValidatorSetAt returns the validator set of the height, nil if it is not known
+	ValidatorSetAt(height uint64) ValidatorSet
+}
+
+// finalityCandidate is a proposal seen while syncing along with its commit seals
+type finalityCandidate struct {
+	proposal *Proposal
+	proposer NodeID
+	seals    map[NodeID][]byte
+}
+
+// syncFinality collects the preprepare and commit messages received in the SyncState
+type syncFinality struct {
+	lock       sync.Mutex
+	candidates map[uint64]map[string]*finalityCandidate
+	delivered  map[uint64]struct{}
+}
+
+func newSyncFinality() *syncFinality {
+	return &syncFinality{
+		candidates: map[uint64]map[string]*finalityCandidate{},
+		delivered:  map[uint64]struct{}{},
+	}
+}
+
+// candidate returns the candidate of the proposal, nil if the height is already delivered
+// or tracks too many proposals
+func (s *syncFinality) candidate(sequence uint64, hash []byte) *finalityCandidate {
+	if _, ok := s.delivered[sequence]; ok {
+		return nil
+	}
+	candidates, ok := s.candidates[sequence]
+	if !ok {
+		candidates = map[string]*finalityCandidate{}
+		s.candidates[sequence] = candidates
+	}
+	c, ok := candidates[string(hash)]
+	if !ok {
+		if len(candidates) >= maxFinalityCandidates {
+			return nil
+		}
+		c = &finalityCandidate{seals: map[NodeID][]byte{}}
+		candidates[string(hash)] = c
+	}
+	return c
+}
+
+// add records the proposal or the valid seals of the message and returns the finality proof
+// of its height once the proposal has a quorum of seals. The proof is returned only once
+func (s *syncFinality) add(msg *MessageReq, quorum func(signers []NodeID) error, validSeal func(from NodeID, seal []byte) bool) *FinalityProof {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sequence := msg.View.Sequence
+	c := s.candidate(sequence, msg.Hash)
+	if c == nil {
+		return nil
+	}
+	switch msg.Type {
+	case MessageReq_Preprepare:
+		if c.proposal == nil && len(msg.Proposal) != 0 {
+			c.proposal = &Proposal{
+				Data: append([]byte{}, msg.Proposal...),
+				Hash: append([]byte{}, msg.Hash...),
+			}
+			c.proposer = msg.From
+		}
+	case MessageReq_Commit:
+		if _, ok := c.seals[msg.From]; !ok && validSeal(msg.From, msg.Seal) {
+			c.seals[msg.From] = append([]byte{}, msg.Seal...)
+		}
+	case MessageReq_Committed:
+		for _, seal := range msg.CommittedSeals {
+			if _, ok := c.seals[seal.Signer]; !ok && validSeal(seal.Signer, seal.Seal) {
+				c.seals[seal.Signer] = append([]byte{}, seal.Seal...)
+			}
+		}
+	}
+	if c.proposal == nil {
+		return nil
+	}
+	signers := make([]NodeID, 0, len(c.seals))
+	for signer := range c.seals {
+		signers = append(signers, signer)
+	}
+	if quorum(signers) != nil {
+		return nil
+	}
+
+	proof := &FinalityProof{
+		Number:   sequence,
+		Proposal: c.proposal.Copy(),
+		Proposer: c.proposer,
+	}
+	for signer, seal := range c.seals {
+		proof.Seals = append(proof.Seals, CommittedSeal{Signer: signer, Seal: seal})
+	}
+	sort.Slice(proof.Seals, func(i, j int) bool {
+		return proof.Seals[i].Signer < proof.Seals[j].Signer
+	})
+	s.delivered[sequence] = struct{}{}
+	delete(s.candidates, sequence)
+	return proof
+}
+
+// prune drops the heights below the sequence
+func (s *syncFinality) prune(sequence uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for seq := range s.candidates {
+		if seq < sequence {
+			delete(s.candidates, seq)
+		}
+	}
+	for seq := range s.delivered {
+		if seq < sequence {
+			delete(s.delivered, seq)
+		}
+	}
+}
+
+// collectFinality verifies the commit quorums of the heights above the current one while
+// the engine is in the SyncState and hands them to the backend as finality proofs
+func (p *Pbft) collectFinality(msg *MessageReq) {
+	if p.getState() != SyncState {
+		return
+	}
+	backend, ok := p.getBackend().(FinalityProofBackend)
+	if !ok {
+		return
+	}
+	// the seals of the next heights must be bound to the hash of the proof (see errUnboundSeals)
+	sealBackend, ok := backend.(CommitSealBackend)
+	if !ok {
+		return
+	}
+	switch msg.Type {
+	case MessageReq_Preprepare, MessageReq_Commit, MessageReq_Committed:
+	default:
+		return
+	}
+	view := p.state.getView()
+	if view == nil || msg.View.Sequence < view.Sequence {
+		return
+	}
+	validators := p.finalityValidators(view, msg.View.Sequence)
+	if validators == nil || !validators.Includes(msg.From) {
+		return
+	}
+	if msg.Type == MessageReq_Preprepare && !p.validFinalityProposal(validators, msg) {
+		return
+	}
+
+	validSeal := func(from NodeID, seal []byte) bool {
+		if !validators.Includes(from) {
+			return false
+		}
+		var verifyErr error
+		if err := p.guard("VerifyCommitSeal", func() { verifyErr = sealBackend.VerifyCommitSeal(from, msg.Hash, seal) }); err != nil {
+			return false
+		}
+		return verifyErr == nil
+	}
+	quorum := QuorumSize(validators.Len())
+	if p.state.devMode {
+		quorum = DevQuorumSize(validators.Len())
+	}
+	proof := p.syncFinality.add(msg, signersQuorum(validators, quorum), validSeal)
+	if proof == nil {
+		return
+	}
+	p.logger.Printf("[INFO] finality proof collected while syncing: height=%d, seals=%d", proof.Number, len(proof.Seals))
+	_ = p.guard("FinalityProof", func() { backend.FinalityProof(proof) })
+}
+
+// finalityValidators returns the validator set of the height, the current one for the current
+// height and the one of the backend for the heights above. It returns nil if it is not known
+func (p *Pbft) finalityValidators(view *View, height uint64) ValidatorSet {
+	if height == view.Sequence {
+		return p.state.getValidators()
+	}
+	backend, ok := p.getBackend().(FinalityValidatorSetBackend)
+	if !ok {
+		return nil
+	}
+	var validators ValidatorSet
+	if err := p.guard("ValidatorSetAt", func() { validators = backend.ValidatorSetAt(height) }); err != nil {
+		return nil
+	}
+	return validators
+}
+
+// validFinalityProposal checks that the preprepare comes from the proposer of its view in the
+// validator set of the height and that the backend validates its proposal
+func (p *Pbft) validFinalityProposal(validators ValidatorSet, msg *MessageReq) bool {
+	if len(msg.Proposal) == 0 {
+		return false
+	}
+	var proposer NodeID
+	if err := p.guard("CalcProposer", func() {
+		if p.config.RoundRobinProposer {
+			proposer = roundRobinProposer(validators, msg.View)
+		} else {
+			proposer = validators.CalcProposer(msg.View.Round)
+		}
+	}); err != nil || proposer != msg.From {
+		return false
+	}
+	var validateErr error
+	proposal := &Proposal{Data: msg.Proposal, Hash: msg.Hash}
+	if err := p.guard("Validate", func() { validateErr = p.getBackend().Validate(proposal) }); err != nil {
+		return false
+	}
+	if validateErr != nil {
+		p.logger.Printf("[ERROR]: invalid proposal while syncing: from=%s, view=%v, err=%v", msg.From, msg.View, validateErr)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,238 @@
+package pbft
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// finalityProofBackend receives the finality proofs collected while syncing
+// and rejects the seals of D
+type finalityProofBackend struct {
+	*mockBackend
+	proofs []*FinalityProof
+}
+
+func (f *finalityProofBackend) FinalityProof(proof *FinalityProof) {
+	f.proofs = append(f.proofs, proof)
+}
+
+// ValidatorSetAt keeps the validators of the current height
+func (f *finalityProofBackend) ValidatorSetAt(height uint64) ValidatorSet {
+	return f.validators
+}
+
+// VerifyCommitSeal accepts the seals of finalitySeal except the ones of D
+func (f *finalityProofBackend) VerifyCommitSeal(from NodeID, hash, seal []byte) error {
+	if from == "D" {
+		return fmt.Errorf("bad seal")
+	}
+	if !bytes.Equal(seal, finalitySeal(from, hash)) {
+		return fmt.Errorf("seal of another hash")
+	}
+	return nil
+}
+
+// finalitySeal is the commit seal of the validator over the hash
+func finalitySeal(from NodeID, hash []byte) []byte {
+	return append([]byte(from), hash...)
+}
+
+func newSyncingFinalityPbft(t *testing.T) (*mockPbft, *finalityProofBackend) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	backend := &finalityProofBackend{mockBackend: m.backend.(*mockBackend)}
+	assert.NoError(t, m.SetBackend(backend))
+	m.setState(SyncState)
+	return m, backend
+}
+
+func TestSyncFinality_CollectsProof(t *testing.T) {
+	m, backend := newSyncingFinalityPbft(t)
+
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(3, 1)})
+	for _, from := range []NodeID{"C", "B"} {
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Seal: finalitySeal(from, digest), View: ViewMsg(3, 1)})
+	}
+	assert.Empty(t, backend.proofs)
+
+	// the seal of D is invalid and does not count for the quorum
+	m.emitMsg(&MessageReq{From: "D", Type: MessageReq_Commit, Seal: finalitySeal("D", digest), View: ViewMsg(3, 1)})
+	assert.Empty(t, backend.proofs)
+
+	m.emitMsg(&MessageReq{From: "A", Type: MessageReq_Commit, Seal: finalitySeal("A", digest), View: ViewMsg(3, 1)})
+	assert.Len(t, backend.proofs, 1)
+
+	proof := backend.proofs[0]
+	assert.Equal(t, uint64(3), proof.Number)
+	assert.Equal(t, NodeID("B"), proof.Proposer)
+	assert.Equal(t, digest, proof.Proposal.Hash)
+	assert.Equal(t, mockProposal, proof.Proposal.Data)
+	assert.Equal(t, []CommittedSeal{{"A", finalitySeal("A", digest)}, {"B", finalitySeal("B", digest)}, {"C", finalitySeal("C", digest)}}, proof.Seals)
+	assert.NoError(t, proof.Verify(m.state.validators, backend.VerifyCommitSeal))
+
+	// the height is delivered only once
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Commit, Seal: finalitySeal("B", digest), View: ViewMsg(3, 2)})
+	assert.Len(t, backend.proofs, 1)
+
+	// the engine does not vote while syncing
+	assert.Empty(t, m.respMsg)
+}
+
+func TestSyncFinality_CommittedMessage(t *testing.T) {
+	m, backend := newSyncingFinalityPbft(t)
+
+	m.emitMsg(&MessageReq{From: "A", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(2, 0)})
+	m.emitMsg(&MessageReq{
+		From: "C",
+		Type: MessageReq_Committed,
+		View: ViewMsg(2, 0),
+		CommittedSeals: []CommittedSeal{
+			{Signer: "A", Seal: finalitySeal("A", digest)},
+			{Signer: "B", Seal: finalitySeal("B", digest)},
+			{Signer: "C", Seal: finalitySeal("C", digest)},
+		},
+	})
+	assert.Len(t, backend.proofs, 1)
+	assert.Equal(t, uint64(2), backend.proofs[0].Number)
+}
+
+func TestSyncFinality_UnboundSeals(t *testing.T) {
+	m, backend := newSyncingFinalityPbft(t)
+
+	// the seals of another proposal do not count for the proposal of the message
+	m.emitMsg(&MessageReq{From: "A", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(2, 0)})
+	for _, from := range []NodeID{"A", "B", "C"} {
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Seal: finalitySeal(from, digest1), View: ViewMsg(2, 0)})
+	}
+	assert.Empty(t, backend.proofs)
+
+	// without the commit seal backend the seals cannot be bound to the hash
+	m, proofs := newSyncingFinalityPbft(t)
+	assert.NoError(t, m.SetBackend(&unboundFinalityBackend{proofs.mockBackend, proofs}))
+	m.setState(SyncState)
+	m.emitMsg(&MessageReq{From: "A", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(1, 0)})
+	for _, from := range []NodeID{"A", "B", "C"} {
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Seal: finalitySeal(from, digest), View: ViewMsg(1, 0)})
+	}
+	assert.Empty(t, proofs.proofs)
+}
+
+// unboundFinalityBackend collects the finality proofs without implementing CommitSealBackend
+type unboundFinalityBackend struct {
+	Backend
+	proofs *finalityProofBackend
+}
+
+func (u *unboundFinalityBackend) FinalityProof(proof *FinalityProof) {
+	u.proofs.FinalityProof(proof)
+}
+
+func TestSyncFinality_Ignored(t *testing.T) {
+	m, backend := newSyncingFinalityPbft(t)
+
+	// a quorum of seals without the proposal
+	for _, from := range []NodeID{"A", "B", "C"} {
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Seal: finalitySeal(from, digest), View: ViewMsg(4, 0)})
+	}
+	assert.Empty(t, backend.proofs)
+
+	// not syncing
+	m.setState(ValidateState)
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(5, 0)})
+	for _, from := range []NodeID{"A", "B", "C"} {
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Seal: finalitySeal(from, digest), View: ViewMsg(5, 0)})
+	}
+	assert.Empty(t, backend.proofs)
+
+	// the heights below the new sequence are dropped
+	m.setSequence(5)
+	m.syncFinality.lock.Lock()
+	assert.NotContains(t, m.syncFinality.candidates, uint64(4))
+	m.syncFinality.lock.Unlock()
+}
+
+func TestSyncFinality_ProposerPreprepare(t *testing.T) {
+	m, backend := newSyncingFinalityPbft(t)
+	commit := func(view *View) {
+		for _, from := range []NodeID{"A", "B", "C"} {
+			m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Seal: finalitySeal(from, digest), View: view})
+		}
+	}
+
+	// C is not the proposer of the first round
+	m.emitMsg(&MessageReq{From: "C", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(2, 0)})
+	commit(ViewMsg(2, 0))
+	assert.Empty(t, backend.proofs)
+
+	// the proposal of the proposer is rejected by the backend
+	backend.validateFn = func(*Proposal) error {
+		return fmt.Errorf("invalid proposal")
+	}
+	m.emitMsg(&MessageReq{From: "A", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(3, 0)})
+	commit(ViewMsg(3, 0))
+	assert.Empty(t, backend.proofs)
+
+	backend.validateFn = nil
+	m.emitMsg(&MessageReq{From: "A", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(2, 0)})
+	assert.Len(t, backend.proofs, 1)
+	assert.Equal(t, NodeID("A"), backend.proofs[0].Proposer)
+}
+
+// rotatedFinalityBackend replaces D with E from the third height on
+type rotatedFinalityBackend struct {
+	*finalityProofBackend
+}
+
+func (r *rotatedFinalityBackend) ValidatorSetAt(height uint64) ValidatorSet {
+	if height < 3 {
+		return r.validators
+	}
+	return newMockValidatorSet([]string{"A", "B", "C", "E"})
+}
+
+func TestSyncFinality_HeightValidatorSet(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D", "E"}, "A")
+	m.backend.(*mockBackend).validators = newMockValidatorSet([]string{"A", "B", "C", "D"}).(*valString)
+	proofs := &finalityProofBackend{mockBackend: m.backend.(*mockBackend)}
+	assert.NoError(t, m.SetBackend(&rotatedFinalityBackend{proofs}))
+	m.setState(SyncState)
+
+	// E only counts from the third height on
+	for _, height := range []uint64{2, 3} {
+		m.emitMsg(&MessageReq{From: "A", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(height, 0)})
+		for _, from := range []NodeID{"B", "C", "E"} {
+			m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Seal: finalitySeal(from, digest), View: ViewMsg(height, 0)})
+		}
+	}
+	assert.Len(t, proofs.proofs, 1)
+	assert.Equal(t, uint64(3), proofs.proofs[0].Number)
+
+	// without the validator sets of the next heights, only the current height is collected
+	m, backend := newSyncingFinalityPbft(t)
+	assert.NoError(t, m.SetBackend(&finalityOnlyBackend{backend.mockBackend, backend}))
+	m.setState(SyncState)
+	for _, height := range []uint64{1, 2} {
+		m.emitMsg(&MessageReq{From: "A", Type: MessageReq_Preprepare, Proposal: mockProposal, View: ViewMsg(height, 0)})
+		for _, from := range []NodeID{"A", "B", "C"} {
+			m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, Seal: finalitySeal(from, digest), View: ViewMsg(height, 0)})
+		}
+	}
+	assert.Len(t, backend.proofs, 1)
+	assert.Equal(t, uint64(1), backend.proofs[0].Number)
+}
+
+// finalityOnlyBackend collects the finality proofs without the validator sets of the next heights
+type finalityOnlyBackend struct {
+	*mockBackend
+	proofs *finalityProofBackend
+}
+
+func (f *finalityOnlyBackend) FinalityProof(proof *FinalityProof) {
+	f.proofs.FinalityProof(proof)
+}
+
+func (f *finalityOnlyBackend) VerifyCommitSeal(from NodeID, hash, seal []byte) error {
+	return f.proofs.VerifyCommitSeal(from, hash, seal)
+}
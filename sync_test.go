@@ -0,0 +1,99 @@
+package pbft
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncingBackend syncs the chain up to the height on its own
+type syncingBackend struct {
+	*mockBackend
+	height uint64
+	err    error
+	syncs  int
+}
+
+func (s *syncingBackend) Sync() <-chan error {
+	s.syncs++
+	if s.err == nil {
+		s.mock.sequence = s.height
+	}
+	done := make(chan error, 1)
+	done <- s.err
+	return done
+}
+
+func TestWaitForSync(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	backend := &syncingBackend{mockBackend: m.backend.(*mockBackend), height: 5}
+	assert.NoError(t, m.SetBackend(backend))
+	m.setState(SyncState)
+
+	assert.True(t, m.waitForSync(context.Background()))
+	assert.Equal(t, 1, backend.syncs)
+	assert.Equal(t, AcceptState, m.GetState())
+	assert.Equal(t, uint64(5), m.state.view.Sequence)
+}
+
+func TestWaitForSync_Failed(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	backend := &syncingBackend{mockBackend: m.backend.(*mockBackend), err: fmt.Errorf("no peers")}
+	assert.NoError(t, m.SetBackend(backend))
+	m.setState(SyncState)
+
+	assert.False(t, m.waitForSync(context.Background()))
+	assert.Equal(t, SyncState, m.GetState())
+	assert.EqualError(t, m.Health().LastError, "no peers")
+	assert.Equal(t, uint64(1), m.state.view.Sequence)
+}
+
+func TestWaitForSync_NotProvider(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.setState(SyncState)
+
+	// the embedder syncs in its own loop
+	assert.False(t, m.waitForSync(context.Background()))
+	assert.Equal(t, SyncState, m.GetState())
+}
+
+type blockedSyncBackend struct {
+	*mockBackend
+}
+
+func (b *blockedSyncBackend) Sync() <-chan error {
+	return make(chan error)
+}
+
+func TestWaitForSync_Cancel(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	assert.NoError(t, m.SetBackend(&blockedSyncBackend{m.backend.(*mockBackend)}))
+	m.setState(SyncState)
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelFn()
+	assert.False(t, m.waitForSync(ctx))
+	assert.Equal(t, SyncState, m.GetState())
+}
+
+func TestRun_ResumesAfterSync(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "C")
+	backend := &syncingBackend{mockBackend: m.backend.(*mockBackend), height: 5}
+	backend.HookIsStuckHandler(func(num uint64) (uint64, bool) {
+		// stuck until the backend syncs
+		return 5, num < 5
+	})
+	assert.NoError(t, m.SetBackend(backend))
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelFn()
+
+	// Run does not return on the sync state, it keeps running at the synced height
+	m.Run(ctx)
+	assert.Equal(t, 1, backend.syncs)
+	assert.Equal(t, uint64(5), m.state.view.Sequence)
+	assert.NotEqual(t, SyncState, m.GetState())
+}
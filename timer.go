@@ -0,0 +1,71 @@
+package pbft
+
+import (
+	"sync"
+	"time"
+)
+
+// TimerState is the state of the timer of the state machine loop, for debugging
+type TimerState struct {
+	// Active is true if the loop is waiting on the timer
+	Active bool
+
+	// Name is what the loop is waiting for (the state of the round, the proposal delay or the chunks)
+	Name string
+
+	// Timeout is the duration of the timer
+	Timeout time.Duration
+
+	// Deadline is the time the timer fires
+	Deadline time.Time
+}
+
+// loopTimer is the single timer of the state machine loop. It is owned by the loop, every
+// wait resets it and stops it once done, so no timer outlives the wait it was created for
+type loopTimer struct {
+	lock  sync.Mutex
+	timer *time.Timer
+	state TimerState
+}
+
+// reset stops the running timer, if any, and starts a new one
+func (l *loopTimer) reset(name string, timeout time.Duration) <-chan time.Time {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.timer = time.NewTimer(timeout)
+	l.state = TimerState{
+		Active:   true,
+		Name:     name,
+		Timeout:  timeout,
+		Deadline: time.Now().Add(timeout),
+	}
+	return l.timer.C
+}
+
+// stop stops the running timer, if any
+func (l *loopTimer) stop() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.state = TimerState{}
+}
+
+func (l *loopTimer) snapshot() TimerState {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.state
+}
+
+// TimerState returns the state of the timer of the state machine loop. It is safe for concurrent use
+func (p *Pbft) TimerState() TimerState {
+	return p.timer.snapshot()
+}
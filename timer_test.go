@@ -0,0 +1,69 @@
+package pbft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoopTimer(t *testing.T) {
+	l := &loopTimer{}
+	assert.False(t, l.snapshot().Active)
+
+	first := l.reset("first", time.Hour)
+	state := l.snapshot()
+	assert.True(t, state.Active)
+	assert.Equal(t, "first", state.Name)
+	assert.Equal(t, time.Hour, state.Timeout)
+
+	// the reset stops the previous timer
+	second := l.reset("second", time.Millisecond)
+	<-second
+	select {
+	case <-first:
+		t.Fatal("the previous timer fired")
+	default:
+	}
+
+	l.stop()
+	assert.Equal(t, TimerState{}, l.snapshot())
+}
+
+func TestPbft_TimerState(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.roundTimeout = func(uint64) time.Duration { return time.Hour }
+	m.setState(ValidateState)
+
+	done := make(chan struct{})
+	go func() {
+		m.runCycle(context.Background())
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return m.TimerState().Active
+	}, time.Second, 5*time.Millisecond)
+	state := m.TimerState()
+	assert.Equal(t, "ValidateState", state.Name)
+	assert.Equal(t, time.Hour, state.Timeout)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), state.Deadline, time.Second)
+
+	// the timer is stopped when the loop stops waiting
+	m.cancelFn()
+	<-done
+	assert.False(t, m.TimerState().Active)
+}
+
+func TestPbft_TimerStoppedOnRoundChanges(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+
+	// rapid round changes with the timeouts of the mock
+	for i := 0; i < 50; i++ {
+		m.setState(ValidateState)
+		m.runCycle(context.Background())
+		assert.True(t, m.IsState(RoundChangeState))
+		assert.False(t, m.TimerState().Active)
+	}
+}
@@ -0,0 +1,100 @@
+package pbft
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// outcomes of a processed message reported in the traces
+const (
+	msgAccepted  = "accepted"
+	msgDiscarded = "discarded"
+	msgStale     = "stale"
+)
+
+// roundTrace is the span that covers a single round of a sequence
+type roundTrace struct {
+	span trace.Span
+
+	// start is the time when the round started
+	start time.Time
+
+	// quorums is the set of quorums already reached in the round
+	quorums map[string]struct{}
+}
+
+// traceMessage creates a child span of the given span for a processed message
+func (p *Pbft) traceMessage(parent trace.Span, msg *MessageReq, outcome string) {
+	_, span := p.tracer.Start(trace.ContextWithSpan(context.Background(), parent), "Message")
+	span.SetAttributes(
+		// type of message
+		attribute.String("msg", msg.Type.String()),
+
+		// from address of the sender
+		attribute.String("from", string(msg.From)),
+
+		// view sequence
+		attribute.Int64("sequence", int64(msg.View.Sequence)),
+
+		// round sequence
+		attribute.Int64("round", int64(msg.View.Round)),
+
+		// whether the message was accepted, discarded or stale
+		attribute.String("outcome", outcome),
+	)
+	span.End()
+}
+
+// startRoundSpan ends the span of the previous round, if any, and starts a new one
+func (p *Pbft) startRoundSpan(ctx context.Context) {
+	p.endRoundSpan()
+
+	_, span := p.tracer.Start(ctx, fmt.Sprintf("Round-%d", p.state.view.Round))
+	p.round = &roundTrace{
+		span:    span,
+		start:   time.Now(),
+		quorums: map[string]struct{}{},
+	}
+}
+
+// endRoundSpan ends the span of the current round
+func (p *Pbft) endRoundSpan() {
+	if p.round == nil {
+		return
+	}
+	p.round.span.End()
+	p.round = nil
+}
+
+// roundContext returns a context with the current round span, if any
+func (p *Pbft) roundContext(ctx context.Context) context.Context {
+	if p.round == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, p.round.span)
+}
+
+// traceProposer annotates the current round span with the proposer
+func (p *Pbft) traceProposer(proposer NodeID) {
+	if p.round == nil {
+		return
+	}
+	p.round.span.SetAttributes(attribute.String("proposer", string(proposer)))
+}
+
+// traceQuorum annotates the current round span with the time it took
+// to reach the given quorum since the start of the round
+func (p *Pbft) traceQuorum(name string) {
+	if p.round == nil {
+		return
+	}
+	if _, ok := p.round.quorums[name]; ok {
+		return
+	}
+	p.round.quorums[name] = struct{}{}
+	p.round.span.SetAttributes(attribute.Int64(name+"_quorum_ms", time.Since(p.round.start).Milliseconds()))
+}
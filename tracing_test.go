@@ -0,0 +1,137 @@
+package pbft
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type recordedSpan struct {
+	trace.Span
+
+	name   string
+	parent *recordedSpan
+	attrs  map[attribute.Key]attribute.Value
+	ended  bool
+}
+
+func (r *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, i := range kv {
+		r.attrs[i.Key] = i.Value
+	}
+}
+
+func (r *recordedSpan) End(options ...trace.SpanEndOption) {
+	r.ended = true
+}
+
+// recordingTracer is a tracer that keeps all the started spans in memory
+type recordingTracer struct {
+	lock  sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	parent, _ := trace.SpanFromContext(ctx).(*recordedSpan)
+	span := &recordedSpan{
+		Span:   trace.SpanFromContext(context.Background()),
+		name:   name,
+		parent: parent,
+		attrs:  map[attribute.Key]attribute.Value{},
+	}
+	r.spans = append(r.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (r *recordingTracer) find(name string) []*recordedSpan {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	res := []*recordedSpan{}
+	for _, span := range r.spans {
+		if span.name == name {
+			res = append(res, span)
+		}
+	}
+	return res
+}
+
+func TestTracing_MessageAndRoundSpans(t *testing.T) {
+	tracer := &recordingTracer{}
+
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.tracer = tracer
+	m.state.view = ViewMsg(1, 0)
+	m.setProposal(&Proposal{
+		Data: mockProposal,
+		Time: time.Now(),
+	})
+
+	// stale message from the previous sequence
+	m.emitMsg(&MessageReq{
+		From: "B",
+		Type: MessageReq_Prepare,
+		View: ViewMsg(0, 0),
+	})
+	// message with a hash that does not match the proposal
+	m.emitMsg(&MessageReq{
+		From: "D",
+		Type: MessageReq_Commit,
+		View: ViewMsg(1, 0),
+		Hash: digest1,
+	})
+	for _, from := range []NodeID{"B", "C"} {
+		for _, typ := range []MsgType{MessageReq_Prepare, MessageReq_Commit} {
+			m.emitMsg(&MessageReq{
+				From: from,
+				Type: typ,
+				View: ViewMsg(1, 0),
+				Hash: m.proposal.Hash,
+			})
+		}
+	}
+
+	m.Run(context.Background())
+	m.expect(expectResult{
+		state:       DoneState,
+		sequence:    1,
+		prepareMsgs: 3,
+		commitMsgs:  3,
+		outgoing:    3,
+	})
+
+	rounds := tracer.find("Round-0")
+	require.Len(t, rounds, 1)
+	round := rounds[0]
+	assert.True(t, round.ended)
+	assert.Equal(t, "A", round.attrs["proposer"].AsString())
+	assert.Contains(t, round.attrs, attribute.Key("prepare_quorum_ms"))
+	assert.Contains(t, round.attrs, attribute.Key("commit_quorum_ms"))
+
+	outcomes := map[string]int{}
+	for _, span := range tracer.find("Message") {
+		assert.True(t, span.ended)
+		assert.NotNil(t, span.parent)
+		outcomes[span.attrs["outcome"].AsString()]++
+	}
+	assert.Equal(t, 1, outcomes[msgStale])
+	assert.Equal(t, 1, outcomes[msgDiscarded])
+	assert.Equal(t, 6, outcomes[msgAccepted])
+
+	// the state spans are part of the round span
+	for _, span := range tracer.find("ValidateState") {
+		for span.parent != nil && span.parent != round {
+			span = span.parent
+		}
+		assert.Equal(t, round, span.parent)
+	}
+}
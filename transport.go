@@ -1,7 +1,128 @@
 package pbft
 
-// Transport is a generic interface for a gossip transport protocol
+import (
+	"fmt"
+	"time"
+)
+
+// Transport is a generic interface for a gossip transport protocol.
+// The engine guarantees that only Preprepare and ProposalChunk messages carry the proposal
+// payload, the rest of the messages are digest-only (see MessageReq.IsDigestOnly)
 type Transport interface {
 	// Gossip broadcast the message to the network
 	Gossip(msg *MessageReq) error
 }
+
+// CodecTransport is implemented by the transports that negotiate the message encoding
+// with every peer. The engine hands over its handshake on creation and the transport
+// resolves the codec of each peer with Pbft.NegotiateCodec
+type CodecTransport interface {
+	Transport
+
+	// SetHandshake sets the handshake sent to the peers on connection
+	SetHandshake(h *Handshake)
+}
+
+// TargetedTransport is implemented by the transports that deliver a message to a subset of
+// the peers. The engine uses Send for the messages only relevant to some peers and falls
+// back to Gossip when the transport does not implement it
+type TargetedTransport interface {
+	Transport
+
+	// Send delivers the message to the peers
+	Send(to []NodeID, msg *MessageReq) error
+}
+
+// sendTo delivers the message to the peers with the targeted transport if available,
+// otherwise the message is gossiped to the whole network
+func (p *Pbft) sendTo(to []NodeID, msg *MessageReq) {
+	targeted, ok := p.transport.(TargetedTransport)
+	if !ok {
+		p.transportGossip(msg)
+		return
+	}
+	p.stats.update(func(s *Stats) {
+		s.GossipedMessages++
+		s.GossipedPayloadBytes += uint64(msg.PayloadSize())
+	})
+	if err := targeted.Send(to, msg); err != nil {
+		p.logger.Printf("[ERROR] failed to send to %v. Error message: %v", to, err)
+	}
+}
+
+// GossipPriority is the relevance of a message for the progress of the consensus
+type GossipPriority int
+
+const (
+	// PriorityLow is for the messages of an old view and the status announcements
+	PriorityLow GossipPriority = iota
+
+	// PriorityNormal is for the messages of the current view
+	PriorityNormal
+
+	// PriorityUrgent is for the round change messages of the current view,
+	// the network cannot move to the next round without them
+	PriorityUrgent
+)
+
+func (g GossipPriority) String() string {
+	switch g {
+	case PriorityLow:
+		return "Low"
+	case PriorityNormal:
+		return "Normal"
+	case PriorityUrgent:
+		return "Urgent"
+	default:
+		return fmt.Sprintf("GossipPriority(%d)", int(g))
+	}
+}
+
+// GossipHints are the scheduling hints of a gossiped message
+type GossipHints struct {
+	// Priority is the relevance of the message
+	Priority GossipPriority
+
+	// Deadline is the time after which the message is no longer useful to the peers,
+	// zero if the message does not expire
+	Deadline time.Time
+}
+
+// HintedTransport is implemented by the transports that schedule the bandwidth with the
+// priority and the deadline of every message. The engine uses GossipWithHints when the
+// transport implements it and falls back to Gossip otherwise, so the transports that only
+// implement Transport keep working as they are
+type HintedTransport interface {
+	Transport
+
+	// GossipWithHints broadcasts the message to the network with its scheduling hints
+	GossipWithHints(msg *MessageReq, hints GossipHints) error
+}
+
+// gossipHints returns the scheduling hints of a message sent by the engine
+func (p *Pbft) gossipHints(msg *MessageReq) GossipHints {
+	now := time.Now()
+	if msg.Type == MessageReq_Status {
+		// superseded by the next announcement
+		return GossipHints{Priority: PriorityLow, Deadline: now.Add(p.config.StatusInterval)}
+	}
+	if current := p.state.getView(); current != nil && cmpView(msg.View, current) < 0 {
+		return GossipHints{Priority: PriorityLow}
+	}
+	hints := GossipHints{
+		Priority: PriorityNormal,
+		Deadline: now.Add(p.roundTimeout(msg.View.Round)),
+	}
+	if msg.Type == MessageReq_RoundChange {
+		hints.Priority = PriorityUrgent
+	}
+	return hints
+}
+
+// gossipWithHints sends the message with the hinted transport if available
+func (p *Pbft) gossipWithHints(msg *MessageReq) error {
+	if hinted, ok := p.transport.(HintedTransport); ok {
+		return hinted.GossipWithHints(msg, p.gossipHints(msg))
+	}
+	return p.transport.Gossip(msg)
+}
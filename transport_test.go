@@ -0,0 +1,63 @@
+package pbft
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hintedTransport records the hints of the gossiped messages
+type hintedTransport struct {
+	*mockPbft
+	hints map[MsgType]GossipHints
+}
+
+func (h *hintedTransport) GossipWithHints(msg *MessageReq, hints GossipHints) error {
+	h.hints[msg.Type] = hints
+	return nil
+}
+
+func TestGossipHints(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.config.StatusInterval = time.Second
+	transport := &hintedTransport{mockPbft: m, hints: map[MsgType]GossipHints{}}
+	m.transport = transport
+
+	m.state.setView(ViewMsg(2, 1))
+	before := time.Now()
+	m.transportGossip(&MessageReq{Type: MessageReq_RoundChange, From: "A", View: ViewMsg(2, 2)})
+	m.transportGossip(&MessageReq{Type: MessageReq_Prepare, From: "A", Hash: digest, View: ViewMsg(2, 1)})
+	m.transportGossip(&MessageReq{Type: MessageReq_Committed, From: "A", Hash: digest, View: ViewMsg(1, 0)})
+	m.transportGossip(&MessageReq{Type: MessageReq_Status, From: "A", View: ViewMsg(2, 1)})
+
+	// the gossip goes through the hinted transport only
+	assert.Empty(t, m.respMsg)
+
+	assert.Equal(t, PriorityUrgent, transport.hints[MessageReq_RoundChange].Priority)
+	assert.False(t, transport.hints[MessageReq_RoundChange].Deadline.Before(before))
+	assert.Equal(t, PriorityNormal, transport.hints[MessageReq_Prepare].Priority)
+	assert.False(t, transport.hints[MessageReq_Prepare].Deadline.IsZero())
+
+	// the messages of an old view do not expire
+	assert.Equal(t, PriorityLow, transport.hints[MessageReq_Committed].Priority)
+	assert.True(t, transport.hints[MessageReq_Committed].Deadline.IsZero())
+
+	// the status expires with the next announcement
+	status := transport.hints[MessageReq_Status]
+	assert.Equal(t, PriorityLow, status.Priority)
+	assert.False(t, status.Deadline.Before(before.Add(time.Second)))
+}
+
+func TestGossipHints_Fallback(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+
+	// the transport without hints receives the message as is
+	m.transportGossip(&MessageReq{Type: MessageReq_RoundChange, From: "A", View: ViewMsg(1, 1)})
+	assert.Len(t, m.respMsg, 1)
+}
+
+func TestGossipPriority_String(t *testing.T) {
+	assert.Equal(t, "Urgent", PriorityUrgent.String())
+	assert.Equal(t, "GossipPriority(7)", GossipPriority(7).String())
+}
@@ -0,0 +1,145 @@
+// Package validation verifies the finality of the PBFT proposals without the consensus engine.
+// It has no state and no dependency on the engine, so that light clients, bridges and block
+// explorers can check the commit seals of a finalized proposal with the same rules as the nodes
+package validation
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// MaxFaulty returns the number of faulty validators tolerated by a set of the given size.
+// PBFT requires N = 3 * F + 1 validators to tolerate F faults, it takes the floor of the result
+func MaxFaulty(validators int) int {
+	if validators <= 0 {
+		return 0
+	}
+	return (validators - 1) / 3
+}
+
+// QuorumSize returns the number of validators required to reach a quorum (2 * F + 1)
+func QuorumSize(validators int) int {
+	return 2*MaxFaulty(validators) + 1
+}
+
+// DevQuorumSize returns the quorum of the dev mode. The sets smaller than 4 cannot tolerate any
+// faulty validator, every validator is required so that two quorums always intersect
+func DevQuorumSize(validators int) int {
+	if validators < 4 {
+		return validators
+	}
+	return QuorumSize(validators)
+}
+
+// HasPowerQuorum checks that the power is more than two thirds of the total (3 * power > 2 * total).
+// A set without voting power never reaches the quorum
+func HasPowerQuorum(power, total *big.Int) bool {
+	if total == nil || total.Sign() <= 0 || power == nil {
+		return false
+	}
+	lhs := new(big.Int).Mul(power, big.NewInt(3))
+	rhs := new(big.Int).Mul(total, big.NewInt(2))
+	return lhs.Cmp(rhs) > 0
+}
+
+// Seal is the commit seal of a validator
+type Seal struct {
+	// Signer is the validator that sealed the digest
+	Signer string
+
+	// Seal is the signature of the digest
+	Seal []byte
+}
+
+// SealVerifier verifies the seal of the digest by the signer
+type SealVerifier func(digest []byte, signer string, seal []byte) error
+
+// VerifySeals checks that every seal is valid and signed by a distinct validator and returns
+// the signers. It does not check the quorum
+func VerifySeals(digest []byte, seals []Seal, isValidator func(signer string) bool, verify SealVerifier) ([]string, error) {
+	signers := make([]string, 0, len(seals))
+	seen := map[string]struct{}{}
+	for _, seal := range seals {
+		if !isValidator(seal.Signer) {
+			return nil, fmt.Errorf("seal from non validator %s", seal.Signer)
+		}
+		if _, ok := seen[seal.Signer]; ok {
+			return nil, fmt.Errorf("duplicated seal from %s", seal.Signer)
+		}
+		if err := verify(digest, seal.Signer, seal.Seal); err != nil {
+			return nil, fmt.Errorf("invalid seal from %s: %v", seal.Signer, err)
+		}
+		seen[seal.Signer] = struct{}{}
+		signers = append(signers, seal.Signer)
+	}
+	return signers, nil
+}
+
+// VerifyQuorumSeals checks the seals with VerifySeals and that they come from at least quorum validators
+func VerifyQuorumSeals(digest []byte, seals []Seal, isValidator func(signer string) bool, quorum int, verify SealVerifier) error {
+	signers, err := VerifySeals(digest, seals, isValidator, verify)
+	if err != nil {
+		return err
+	}
+	if len(signers) < quorum {
+		return fmt.Errorf("not enough seals: expected=%d, found=%d", quorum, len(signers))
+	}
+	return nil
+}
+
+// Certificate is the proof that a digest was committed by a quorum of the validator set
+type Certificate struct {
+	// Digest is the hash of the committed proposal
+	Digest []byte
+
+	// Seals are the commit seals of the validators
+	Seals []Seal
+}
+
+// Verify checks that the certificate has valid seals from a quorum of the validators
+func (c *Certificate) Verify(validators []string, verify SealVerifier) error {
+	if len(c.Digest) == 0 {
+		return fmt.Errorf("certificate without digest")
+	}
+	members := make(map[string]struct{}, len(validators))
+	for _, validator := range validators {
+		members[validator] = struct{}{}
+	}
+	isValidator := func(signer string) bool {
+		_, ok := members[signer]
+		return ok
+	}
+	return VerifyQuorumSeals(c.Digest, c.Seals, isValidator, QuorumSize(len(members)), verify)
+}
+
+// VerifyWeighted checks that the certificate has valid seals from validators with more than two
+// thirds of the total voting power. The validators are the keys of the powers, a missing or
+// negative power does not count
+func (c *Certificate) VerifyWeighted(powers map[string]*big.Int, verify SealVerifier) error {
+	if len(c.Digest) == 0 {
+		return fmt.Errorf("certificate without digest")
+	}
+	isValidator := func(signer string) bool {
+		_, ok := powers[signer]
+		return ok
+	}
+	signers, err := VerifySeals(c.Digest, c.Seals, isValidator, verify)
+	if err != nil {
+		return err
+	}
+	total, power := new(big.Int), new(big.Int)
+	for _, p := range powers {
+		if p != nil && p.Sign() > 0 {
+			total.Add(total, p)
+		}
+	}
+	for _, signer := range signers {
+		if p := powers[signer]; p != nil && p.Sign() > 0 {
+			power.Add(power, p)
+		}
+	}
+	if !HasPowerQuorum(power, total) {
+		return fmt.Errorf("not enough voting power: power=%s, total=%s", power, total)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package validation
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuorumSize(t *testing.T) {
+	cases := []struct {
+		validators, faulty, quorum, dev int
+	}{
+		{0, 0, 1, 0},
+		{1, 0, 1, 1},
+		{3, 0, 1, 3},
+		{4, 1, 3, 3},
+		{7, 2, 5, 5},
+		{100, 33, 67, 67},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.faulty, MaxFaulty(c.validators))
+		assert.Equal(t, c.quorum, QuorumSize(c.validators))
+		assert.Equal(t, c.dev, DevQuorumSize(c.validators))
+	}
+}
+
+func TestHasPowerQuorum(t *testing.T) {
+	assert.True(t, HasPowerQuorum(big.NewInt(7), big.NewInt(10)))
+	assert.False(t, HasPowerQuorum(big.NewInt(2), big.NewInt(3)))
+	assert.False(t, HasPowerQuorum(big.NewInt(1), big.NewInt(0)))
+	assert.False(t, HasPowerQuorum(nil, big.NewInt(1)))
+}
+
+var digest = []byte{0x1}
+
+func verifySeal(d []byte, signer string, seal []byte) error {
+	if string(seal) != signer+string(d) {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+func newCertificate(signers ...string) *Certificate {
+	cert := &Certificate{Digest: digest}
+	for _, signer := range signers {
+		cert.Seals = append(cert.Seals, Seal{Signer: signer, Seal: []byte(signer + string(digest))})
+	}
+	return cert
+}
+
+func TestCertificate_Verify(t *testing.T) {
+	validators := []string{"A", "B", "C", "D"}
+
+	assert.NoError(t, newCertificate("A", "B", "C").Verify(validators, verifySeal))
+
+	err := newCertificate("A", "B").Verify(validators, verifySeal)
+	assert.EqualError(t, err, "not enough seals: expected=3, found=2")
+
+	err = newCertificate("A", "B", "B").Verify(validators, verifySeal)
+	assert.EqualError(t, err, "duplicated seal from B")
+
+	err = newCertificate("A", "B", "E").Verify(validators, verifySeal)
+	assert.EqualError(t, err, "seal from non validator E")
+
+	cert := newCertificate("A", "B", "C")
+	cert.Seals[1].Seal = []byte("forged")
+	assert.EqualError(t, cert.Verify(validators, verifySeal), "invalid seal from B: bad signature")
+
+	cert = newCertificate("A", "B", "C")
+	cert.Digest = nil
+	assert.Error(t, cert.Verify(validators, verifySeal))
+}
+
+func TestCertificate_VerifyWeighted(t *testing.T) {
+	powers := map[string]*big.Int{
+		"A": big.NewInt(70),
+		"B": big.NewInt(10),
+		"C": big.NewInt(10),
+		"D": big.NewInt(10),
+		"E": big.NewInt(-50),
+	}
+
+	// a single validator with most of the power
+	assert.NoError(t, newCertificate("A").VerifyWeighted(powers, verifySeal))
+
+	// the majority of the validators without enough power
+	err := newCertificate("B", "C", "D", "E").VerifyWeighted(powers, verifySeal)
+	assert.EqualError(t, err, "not enough voting power: power=30, total=100")
+
+	err = newCertificate("A", "F").VerifyWeighted(powers, verifySeal)
+	assert.EqualError(t, err, "seal from non validator F")
+}
@@ -0,0 +1,93 @@
+package pbft
+
+import (
+	"fmt"
+	"sort"
+)
+
+var errTooManyValidators = fmt.Errorf("too many validators")
+
+// WithMaxValidators makes SetBackend reject the validator sets larger than max
+func WithMaxValidators(max int) ConfigOption {
+	return func(c *Config) {
+		c.MaxValidators = max
+	}
+}
+
+// indexedValidatorSet caches the members of a listable validator set, so that the membership
+// checks done for every message are map lookups whatever the implementation of the set.
+// The set is indexed once per sequence in SetBackend
+type indexedValidatorSet struct {
+	ValidatorSet
+
+	// ids are the members in the order of the validator set
+	ids []NodeID
+
+	// sorted are the members sorted by id, for the round robin proposer
+	sorted []NodeID
+
+	// index is the position of every member in ids
+	index map[NodeID]int
+}
+
+// newIndexedValidatorSet indexes the validator set if it implements ValidatorLister,
+// otherwise it returns the set as is
+func newIndexedValidatorSet(validators ValidatorSet) ValidatorSet {
+	if _, ok := validators.(*indexedValidatorSet); ok {
+		return validators
+	}
+	lister, ok := validators.(ValidatorLister)
+	if !ok {
+		return validators
+	}
+	ids := append([]NodeID{}, lister.Validators()...)
+	index := make(map[NodeID]int, len(ids))
+	for i, id := range ids {
+		if _, ok := index[id]; !ok {
+			index[id] = i
+		}
+	}
+	return &indexedValidatorSet{
+		ValidatorSet: validators,
+		ids:          ids,
+		sorted:       sortedValidators(ids),
+		index:        index,
+	}
+}
+
+// Includes checks the membership in O(1)
+func (v *indexedValidatorSet) Includes(id NodeID) bool {
+	_, ok := v.index[id]
+	return ok
+}
+
+// Index returns the position of the validator in the set or -1 if it is not a member
+func (v *indexedValidatorSet) Index(id NodeID) int {
+	if i, ok := v.index[id]; ok {
+		return i
+	}
+	return -1
+}
+
+// Validators implements the ValidatorLister interface
+func (v *indexedValidatorSet) Validators() []NodeID {
+	return v.ids
+}
+
+// baseValidatorSet returns the validator set of the backend, without the guard and the index,
+// to look for the optional interfaces of the set
+func baseValidatorSet(validators ValidatorSet) ValidatorSet {
+	validators = unguardedValidatorSet(validators)
+	if indexed, ok := validators.(*indexedValidatorSet); ok {
+		return indexed.ValidatorSet
+	}
+	return validators
+}
+
+func sortedValidators(ids []NodeID) []NodeID {
+	sorted := append([]NodeID{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
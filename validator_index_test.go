@@ -0,0 +1,71 @@
+package pbft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listedValidatorSet can be listed and indexed by the engine
+type listedValidatorSet struct {
+	*valString
+}
+
+func (v *listedValidatorSet) Validators() []NodeID {
+	return *v.valString
+}
+
+type listedValidatorSetBackend struct {
+	*mockBackend
+}
+
+func (b *listedValidatorSetBackend) ValidatorSet() ValidatorSet {
+	return &listedValidatorSet{b.validators}
+}
+
+func TestIndexedValidatorSet(t *testing.T) {
+	set := &listedValidatorSet{newMockValidatorSet([]string{"D", "B", "A", "C"}).(*valString)}
+	indexed := newIndexedValidatorSet(set).(*indexedValidatorSet)
+
+	assert.True(t, indexed.Includes("A"))
+	assert.False(t, indexed.Includes("E"))
+	assert.Equal(t, 0, indexed.Index("D"))
+	assert.Equal(t, 2, indexed.Index("A"))
+	assert.Equal(t, -1, indexed.Index("E"))
+	assert.Equal(t, 4, indexed.Len())
+	assert.Equal(t, []NodeID{"D", "B", "A", "C"}, indexed.Validators())
+	assert.Equal(t, []NodeID{"A", "B", "C", "D"}, indexed.sorted)
+	assert.Equal(t, set.CalcProposer(1), indexed.CalcProposer(1))
+
+	// the round robin proposer is the same with and without the index
+	for round := uint64(0); round < 8; round++ {
+		assert.Equal(t, roundRobinProposer(set, ViewMsg(3, round)), roundRobinProposer(indexed, ViewMsg(3, round)))
+	}
+
+	// already indexed
+	assert.Same(t, indexed, newIndexedValidatorSet(indexed))
+
+	// not listable
+	notListed := newMockValidatorSet([]string{"A", "B"})
+	assert.Equal(t, notListed, newIndexedValidatorSet(notListed))
+}
+
+func TestSetBackend_IndexesValidators(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+
+	err := m.SetBackend(&listedValidatorSetBackend{m.backend.(*mockBackend)})
+	assert.NoError(t, err)
+	assert.IsType(t, &indexedValidatorSet{}, unguardedValidatorSet(m.state.validators))
+	assert.True(t, m.state.validators.Includes("C"))
+}
+
+func TestSetBackend_MaxValidators(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+
+	m.config.MaxValidators = 3
+	err := m.SetBackend(m.backend)
+	assert.ErrorIs(t, err, errTooManyValidators)
+
+	m.config.MaxValidators = 4
+	assert.NoError(t, m.SetBackend(m.backend))
+}
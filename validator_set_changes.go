@@ -0,0 +1,75 @@
+package pbft
+
+import "math/big"
+
+// ValidatorSetChangedEvent is emitted when the validator set of a height differs from the
+// validator set of the previous height set in the engine. It is not emitted for the first
+// validator set nor for the validator sets that do not implement ValidatorLister
+type ValidatorSetChangedEvent struct {
+	// Sequence is the first height of the new validator set
+	Sequence uint64
+
+	// Added are the new members of the validator set, sorted by id
+	Added []NodeID
+
+	// Removed are the members no longer in the validator set, sorted by id
+	Removed []NodeID
+
+	// Quorum is the number of messages required to reach the quorum with the new validator set,
+	// zero for the weighted validator sets
+	Quorum int
+
+	// QuorumPower is the voting power required to reach the quorum with a weighted validator set
+	QuorumPower *big.Int
+}
+
+func (e *ValidatorSetChangedEvent) EventName() string {
+	return "ValidatorSetChanged"
+}
+
+// diffValidators returns the members of next not in prev and the members of prev not in
+// next. Both lists must be sorted by id, as returned by listValidators
+func diffValidators(prev, next []NodeID) (added, removed []NodeID) {
+	i, j := 0, 0
+	for i < len(prev) || j < len(next) {
+		switch {
+		case j == len(next) || (i < len(prev) && prev[i] < next[j]):
+			removed = append(removed, prev[i])
+			i++
+		case i == len(prev) || next[j] < prev[i]:
+			added = append(added, next[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
+
+// trackValidatorSet emits the ValidatorSetChangedEvent if the validator set of the current
+// height differs from the last one tracked
+func (p *Pbft) trackValidatorSet() {
+	next := listValidators(p.state.validators)
+	if next == nil {
+		return
+	}
+	prev := p.lastValidators
+	p.lastValidators = next
+	if prev == nil {
+		return
+	}
+	added, removed := diffValidators(prev, next)
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	quorum, quorumPower := p.state.quorumSize()
+	p.logger.Printf("[INFO] validator set changed: sequence=%d, added=%v, removed=%v, quorum=%d, power=%v", p.state.view.Sequence, added, removed, quorum, quorumPower)
+	p.emit(&ValidatorSetChangedEvent{
+		Sequence:    p.state.view.Sequence,
+		Added:       added,
+		Removed:     removed,
+		Quorum:      quorum,
+		QuorumPower: quorumPower,
+	})
+}
@@ -0,0 +1,61 @@
+package pbft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffValidators(t *testing.T) {
+	cases := []struct {
+		prev, next, added, removed []NodeID
+	}{
+		{[]NodeID{"A", "B"}, []NodeID{"A", "B"}, nil, nil},
+		{[]NodeID{"A", "B"}, []NodeID{"A", "B", "C"}, []NodeID{"C"}, nil},
+		{[]NodeID{"A", "B", "C"}, []NodeID{"B"}, nil, []NodeID{"A", "C"}},
+		{[]NodeID{"A", "C"}, []NodeID{"B", "D"}, []NodeID{"B", "D"}, []NodeID{"A", "C"}},
+		{[]NodeID{}, []NodeID{"A"}, []NodeID{"A"}, nil},
+	}
+	for _, c := range cases {
+		added, removed := diffValidators(c.prev, c.next)
+		assert.Equal(t, c.added, added)
+		assert.Equal(t, c.removed, removed)
+	}
+}
+
+// listedBackend is a mock backend with a listable validator set
+type listedBackend struct {
+	*mockBackend
+	validators []string
+}
+
+func (l *listedBackend) ValidatorSet() ValidatorSet {
+	return &listedValString{newMockValidatorSet(l.validators).(*valString)}
+}
+
+func TestValidatorSetChangedEvent(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+
+	var events []*ValidatorSetChangedEvent
+	m.config.EventHandler = func(e Event) {
+		if changed, ok := e.(*ValidatorSetChangedEvent); ok {
+			events = append(events, changed)
+		}
+	}
+
+	backend := &listedBackend{mockBackend: m.backend.(*mockBackend), validators: []string{"D", "C", "B", "A"}}
+	require.NoError(t, m.SetBackend(backend))
+	require.NoError(t, m.SetBackend(backend))
+	assert.Empty(t, events)
+
+	backend.validators = []string{"A", "F", "B", "C", "E"}
+	require.NoError(t, m.SetBackend(backend))
+	require.Len(t, events, 1)
+	assert.Equal(t, &ValidatorSetChangedEvent{
+		Sequence: m.state.view.Sequence,
+		Added:    []NodeID{"E", "F"},
+		Removed:  []NodeID{"D"},
+		Quorum:   QuorumSize(5),
+	}, events[0])
+}
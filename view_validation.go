@@ -0,0 +1,64 @@
+package pbft
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// maxFutureSequences is how far ahead of the current sequence a message is accepted
+	maxFutureSequences = 1024
+
+	// maxFutureRounds is how far ahead of the current round a message is accepted
+	maxFutureRounds = 64
+)
+
+var (
+	errViewMissing        = fmt.Errorf("message view is missing")
+	errViewSequenceTooFar = fmt.Errorf("message sequence too far ahead")
+	errViewRoundTooFar    = fmt.Errorf("message round too far ahead")
+)
+
+// validateView rejects the views too far ahead of the current one, so that a single
+// malicious message (i.e. round=2^60) can not drive the state machine to absurd views
+func validateView(view, current *View) error {
+	if view == nil {
+		return errViewMissing
+	}
+	if current == nil {
+		// no backend set yet
+		return nil
+	}
+	if view.Sequence > saturatingAdd(current.Sequence, maxFutureSequences) {
+		return fmt.Errorf("%w: sequence=%d, current=%d", errViewSequenceTooFar, view.Sequence, current.Sequence)
+	}
+
+	// the rounds start again from zero on every sequence
+	roundLimit := uint64(maxFutureRounds)
+	if view.Sequence == current.Sequence {
+		roundLimit = saturatingAdd(current.Round, maxFutureRounds)
+	}
+	if view.Round > roundLimit {
+		return fmt.Errorf("%w: round=%d, limit=%d", errViewRoundTooFar, view.Round, roundLimit)
+	}
+	return nil
+}
+
+func saturatingAdd(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// safeRoundTimeout guards the round timeout function against non positive
+// results (i.e. overflows of custom functions), which fall back to maxTimeout
+func safeRoundTimeout(fn RoundTimeout) RoundTimeout {
+	return func(round uint64) time.Duration {
+		if timeout := fn(round); timeout > 0 {
+			return timeout
+		}
+		return maxTimeout
+	}
+}
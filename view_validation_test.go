@@ -0,0 +1,92 @@
+package pbft
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateView(t *testing.T) {
+	cases := []struct {
+		view, current *View
+		err           error
+	}{
+		{nil, ViewMsg(1, 0), errViewMissing},
+		{ViewMsg(1, 0), nil, nil},
+		{ViewMsg(1, maxFutureRounds), ViewMsg(1, 0), nil},
+		{ViewMsg(1, maxFutureRounds+1), ViewMsg(1, 0), errViewRoundTooFar},
+		{ViewMsg(1, 10+maxFutureRounds), ViewMsg(1, 10), nil},
+		{ViewMsg(2, maxFutureRounds+1), ViewMsg(1, 10), errViewRoundTooFar},
+		{ViewMsg(1+maxFutureSequences, 0), ViewMsg(1, 0), nil},
+		{ViewMsg(2+maxFutureSequences, 0), ViewMsg(1, 0), errViewSequenceTooFar},
+		{ViewMsg(1, 1<<60), ViewMsg(1, 0), errViewRoundTooFar},
+		{ViewMsg(math.MaxUint64, math.MaxUint64), ViewMsg(math.MaxUint64, math.MaxUint64-1), nil},
+		// old views are not rejected here, the message queue discards them
+		{ViewMsg(1, 0), ViewMsg(10, 5), nil},
+	}
+	for _, c := range cases {
+		err := validateView(c.view, c.current)
+		if c.err == nil {
+			assert.NoError(t, err)
+		} else {
+			assert.True(t, errors.Is(err, c.err), err)
+		}
+	}
+}
+
+func TestValidateView_Random(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		view := &View{Sequence: r.Uint64(), Round: r.Uint64()}
+		current := &View{Sequence: r.Uint64() >> uint(r.Intn(64)), Round: r.Uint64() >> uint(r.Intn(64))}
+		if r.Intn(2) == 0 {
+			view.Sequence = current.Sequence
+		}
+
+		if err := validateView(view, current); err == nil {
+			// accepted views are never too far ahead
+			assert.LessOrEqual(t, view.Sequence-current.Sequence, uint64(maxFutureSequences))
+			if view.Sequence == current.Sequence && view.Round > current.Round {
+				assert.LessOrEqual(t, view.Round-current.Round, uint64(maxFutureRounds))
+			}
+		}
+	}
+}
+
+func TestExponentialTimeout_NoOverflow(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	rounds := []uint64{0, 1, maxTimeoutExponent, maxTimeoutExponent + 1, 33, 64, 1 << 60, math.MaxUint64}
+	for i := 0; i < 1000; i++ {
+		rounds = append(rounds, r.Uint64())
+	}
+	for _, round := range rounds {
+		timeout := exponentialTimeout(round)
+		assert.Greater(t, int64(timeout), int64(0))
+		assert.LessOrEqual(t, int64(timeout), int64(maxTimeout))
+	}
+}
+
+func TestSafeRoundTimeout(t *testing.T) {
+	overflow := safeRoundTimeout(func(round uint64) time.Duration {
+		return time.Duration(1<<round) * time.Second
+	})
+	assert.Equal(t, 2*time.Second, overflow(1))
+	assert.Equal(t, maxTimeout, overflow(63))
+}
+
+func TestPbft_PushMessage_InvalidView(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+
+	m.PushMessage(&MessageReq{
+		From: "B",
+		Type: MessageReq_RoundChange,
+		View: ViewMsg(1, 1<<60),
+	})
+	_, _, roundChangeLen := m.msgQueue.getQueueLens()
+	assert.Equal(t, 0, roundChangeLen)
+	assert.Equal(t, uint64(1), m.Stats().Discards[DiscardInvalidView])
+}
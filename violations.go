@@ -0,0 +1,28 @@
+package pbft
+
+// ProtocolViolationHandler receives the sender and the reason of every inbound message rejected
+// for structural reasons: the unknown types, the missing or unexpected fields (see Validate and
+// the strict mode) and the malformed proposal chunks. The sender is the From of the message as
+// received, before resolving the session keys, so it is not authenticated: the transport must
+// score the peer that delivered the message. It is called synchronously from PushMessage and
+// PushVerifiedMessage and must not block.
+//
+// The messages rejected for their view (i.e. too far ahead) are not reported, since honest peers
+// ahead of a lagging node send them as well
+type ProtocolViolationHandler func(from NodeID, err error)
+
+// WithProtocolViolationHandler sets the handler of the messages rejected for structural reasons,
+// i.e. to implement the peer scoring or banning in the transport
+func WithProtocolViolationHandler(handler ProtocolViolationHandler) ConfigOption {
+	return func(c *Config) {
+		c.ProtocolViolationHandler = handler
+	}
+}
+
+// reportViolation passes the structural error of the message to the violation handler, if any
+func (p *Pbft) reportViolation(msg *MessageReq, err error) {
+	p.stats.update(func(s *Stats) { s.ProtocolViolations++ })
+	if p.config.ProtocolViolationHandler != nil {
+		p.config.ProtocolViolationHandler(msg.From, err)
+	}
+}
@@ -0,0 +1,52 @@
+package pbft
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type violation struct {
+	from NodeID
+	err  error
+}
+
+func TestProtocolViolationHandler(t *testing.T) {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.setState(ValidateState)
+
+	violations := []violation{}
+	WithProtocolViolationHandler(func(from NodeID, err error) {
+		violations = append(violations, violation{from, err})
+	})(m.config)
+	WithStrictMode(StrictEnforced, func(msg *MessageReq) error {
+		if msg.From == "C" {
+			return errors.New("bad signature")
+		}
+		return nil
+	})(m.config)
+
+	// unknown type
+	m.PushMessage(&MessageReq{Type: MsgType(100), From: "B", Hash: digest, View: ViewMsg(1, 0)})
+	// payload in a digest-only message
+	m.PushMessage(&MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, Proposal: mockProposal, View: ViewMsg(1, 0)})
+	// unsealed commit
+	m.PushMessage(&MessageReq{Type: MessageReq_Commit, From: "D", Hash: digest, View: ViewMsg(1, 0)})
+	// chunk index out of range
+	m.PushMessage(&MessageReq{Type: MessageReq_ProposalChunk, From: "B", Hash: digest, Proposal: mockProposal, View: ViewMsg(1, 0), ChunkCount: 1, ChunkIndex: 1})
+
+	require.Len(t, violations, 4)
+	assert.Equal(t, NodeID("B"), violations[0].from)
+	assert.Equal(t, NodeID("D"), violations[2].from)
+	assert.True(t, errors.Is(violations[2].err, errMissingFields))
+	assert.True(t, errors.Is(violations[3].err, errInvalidChunk))
+
+	// the authentication failures and the views too far ahead are not structural
+	m.PushMessage(&MessageReq{Type: MessageReq_Prepare, From: "C", Hash: digest, View: ViewMsg(1, 0)})
+	m.PushMessage(&MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(1, maxFutureRounds+1)})
+	m.PushMessage(&MessageReq{Type: MessageReq_Prepare, From: "B", Hash: digest, View: ViewMsg(1, 0)})
+	assert.Len(t, violations, 4)
+	assert.Equal(t, uint64(4), m.Stats().ProtocolViolations)
+}
@@ -0,0 +1,141 @@
+package pbft
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/pbft-consensus/validation"
+)
+
+// VotingPowerSet is an optional interface of the ValidatorSet for the stake weighted networks.
+// The prepare and commit quorums are then reached with more than two thirds of the total
+// voting power instead of a number of validators. The powers are big integers so that the
+// sums of stakes of any scale cannot overflow
+type VotingPowerSet interface {
+	// VotingPower returns the voting power of the validator
+	VotingPower(id NodeID) *big.Int
+
+	// TotalVotingPower returns the sum of the voting power of every validator
+	TotalVotingPower() *big.Int
+}
+
+// votingPower returns the sum of the voting power of the senders. A missing (nil) or negative
+// power does not count, a validator cannot take power away from the others
+func votingPower(set VotingPowerSet, msgs map[NodeID]*MessageReq) *big.Int {
+	sum := new(big.Int)
+	for from := range msgs {
+		addVotingPower(sum, set, from)
+	}
+	return sum
+}
+
+// signersPower returns the sum of the voting power of the signers, see votingPower
+func signersPower(set VotingPowerSet, signers []NodeID) *big.Int {
+	sum := new(big.Int)
+	for _, signer := range signers {
+		addVotingPower(sum, set, signer)
+	}
+	return sum
+}
+
+func addVotingPower(sum *big.Int, set VotingPowerSet, id NodeID) {
+	if power := set.VotingPower(id); power != nil && power.Sign() > 0 {
+		sum.Add(sum, power)
+	}
+}
+
+// hasPowerQuorum checks that the power is more than two thirds of the total (3 * power > 2 * total).
+// A set without voting power never reaches the quorum
+func hasPowerQuorum(power, total *big.Int) bool {
+	return validation.HasPowerQuorum(power, total)
+}
+
+// hasWeakPowerQuorum checks that the power is more than one third of the total (3 * power > total),
+// so that at least one honest validator is among the senders
+func hasWeakPowerQuorum(power, total *big.Int) bool {
+	if total == nil || total.Sign() <= 0 || power == nil {
+		return false
+	}
+	return new(big.Int).Mul(power, big.NewInt(3)).Cmp(total) > 0
+}
+
+// weakQuorum checks whether the signers of the validator set reach the weak quorum: more than one
+// third of the voting power for the weighted validator sets and F+1 validators otherwise
+func weakQuorum(validators ValidatorSet, signers []NodeID) bool {
+	if set, ok := baseValidatorSet(validators).(VotingPowerSet); ok {
+		return hasWeakPowerQuorum(signersPower(set, signers), set.TotalVotingPower())
+	}
+	return len(signers) > MaxFaultyNodes(validators.Len())
+}
+
+// quorumPower returns the lowest voting power that is more than two thirds of the total,
+// nil if the total has no power
+func quorumPower(total *big.Int) *big.Int {
+	if total == nil || total.Sign() <= 0 {
+		return nil
+	}
+	power := new(big.Int).Mul(total, big.NewInt(2))
+	return power.Div(power, big.NewInt(3)).Add(power, big.NewInt(1))
+}
+
+// signersQuorum returns the check of the quorum of distinct signers of the validator set:
+// by voting power for the weighted validator sets and at least quorum signers otherwise
+func signersQuorum(validators ValidatorSet, quorum int) func(signers []NodeID) error {
+	if set, ok := baseValidatorSet(validators).(VotingPowerSet); ok {
+		return func(signers []NodeID) error {
+			power, total := signersPower(set, signers), set.TotalVotingPower()
+			if !hasPowerQuorum(power, total) {
+				return fmt.Errorf("not enough voting power: power=%s, total=%s", power, total)
+			}
+			return nil
+		}
+	}
+	return func(signers []NodeID) error {
+		if len(signers) < quorum {
+			return fmt.Errorf("not enough seals: expected=%d, found=%d", quorum, len(signers))
+		}
+		return nil
+	}
+}
+
+// votingPowerSet returns the voting power of the validator set, if it is weighted
+func (c *currentState) votingPowerSet() (VotingPowerSet, bool) {
+	set, ok := baseValidatorSet(c.validators).(VotingPowerSet)
+	return set, ok
+}
+
+// hasQuorum checks whether the messages reach the quorum, by voting power for the weighted
+// validator sets and by number of validators otherwise
+func (c *currentState) hasQuorum(msgs map[NodeID]*MessageReq) bool {
+	if set, ok := c.votingPowerSet(); ok {
+		return hasPowerQuorum(votingPower(set, msgs), set.TotalVotingPower())
+	}
+	return len(msgs) > c.NumValid()
+}
+
+// hasWeakQuorum checks whether the messages reach the weak quorum (see weakQuorum)
+func (c *currentState) hasWeakQuorum(msgs map[NodeID]*MessageReq) bool {
+	if set, ok := c.votingPowerSet(); ok {
+		return hasWeakPowerQuorum(votingPower(set, msgs), set.TotalVotingPower())
+	}
+	return len(msgs) > c.MaxFaultyNodes()
+}
+
+// hasRoundChangeQuorum checks whether the round change messages of a round move the node to it.
+// The weighted validator sets need the power quorum, the others move on the NumValid-th message
+// since the node counts its own message, and a single validator does not wait for any other one
+func (c *currentState) hasRoundChangeQuorum(msgs map[NodeID]*MessageReq) bool {
+	if set, ok := c.votingPowerSet(); ok {
+		return hasPowerQuorum(votingPower(set, msgs), set.TotalVotingPower())
+	}
+	return len(msgs) == c.NumValid() || c.getValidators().Len() == 1
+}
+
+// quorumSize returns the number of messages required to reach the quorum, and for the weighted
+// validator sets the voting power required instead (the number is then zero)
+func (c *currentState) quorumSize() (int, *big.Int) {
+	if set, ok := c.votingPowerSet(); ok {
+		return 0, quorumPower(set.TotalVotingPower())
+	}
+	return c.NumValid() + 1, nil
+}
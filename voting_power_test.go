@@ -0,0 +1,277 @@
+package pbft
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// weightedValidatorSet assigns a voting power to every validator
+type weightedValidatorSet struct {
+	*valString
+	powers map[NodeID]*big.Int
+}
+
+func (w *weightedValidatorSet) VotingPower(id NodeID) *big.Int {
+	return w.powers[id]
+}
+
+func (w *weightedValidatorSet) TotalVotingPower() *big.Int {
+	total := new(big.Int)
+	for _, power := range w.powers {
+		total.Add(total, power)
+	}
+	return total
+}
+
+func bigPow10(exp int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil)
+}
+
+func votes(ids ...NodeID) map[NodeID]*MessageReq {
+	msgs := map[NodeID]*MessageReq{}
+	for _, id := range ids {
+		msgs[id] = &MessageReq{From: id}
+	}
+	return msgs
+}
+
+func TestHasPowerQuorum(t *testing.T) {
+	maxUint64 := new(big.Int).SetUint64(math.MaxUint64)
+	huge := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	cases := []struct {
+		power, total *big.Int
+		quorum       bool
+	}{
+		{big.NewInt(3), big.NewInt(4), true},
+		// exactly two thirds is not enough
+		{big.NewInt(2), big.NewInt(3), false},
+		{big.NewInt(0), big.NewInt(0), false},
+		{big.NewInt(1), big.NewInt(0), false},
+		{big.NewInt(1), big.NewInt(-3), false},
+		{nil, big.NewInt(3), false},
+		{big.NewInt(3), nil, false},
+		// 3 * power overflows uint64
+		{maxUint64, maxUint64, true},
+		{new(big.Int).Sub(maxUint64, big.NewInt(1)), new(big.Int).Add(maxUint64, maxUint64), false},
+		{new(big.Int).Mul(bigPow10(18), big.NewInt(667)), new(big.Int).Mul(bigPow10(18), big.NewInt(1000)), true},
+		{new(big.Int).Mul(bigPow10(18), big.NewInt(666)), new(big.Int).Mul(bigPow10(18), big.NewInt(999)), false},
+		{new(big.Int).Sub(huge, big.NewInt(1)), huge, true},
+		{new(big.Int).Div(new(big.Int).Mul(huge, big.NewInt(2)), big.NewInt(3)), huge, false},
+	}
+	for i, c := range cases {
+		assert.Equal(t, c.quorum, hasPowerQuorum(c.power, c.total), fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestVotingPower_ExtremeWeights(t *testing.T) {
+	// 1000 validators with 10^18 scale stakes, the total does not fit in an uint64
+	ids := []string{}
+	powers := map[NodeID]*big.Int{}
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("V%d", i)
+		ids = append(ids, id)
+		powers[NodeID(id)] = new(big.Int).Mul(bigPow10(18), big.NewInt(int64(i%10+1)))
+	}
+	set := &weightedValidatorSet{newMockValidatorSet(ids).(*valString), powers}
+	total := set.TotalVotingPower()
+	assert.Equal(t, new(big.Int).Mul(bigPow10(18), big.NewInt(5500)), total)
+
+	all := map[NodeID]*MessageReq{}
+	for id := range powers {
+		all[id] = &MessageReq{From: id}
+	}
+	assert.Equal(t, total, votingPower(set, all))
+	assert.True(t, hasPowerQuorum(votingPower(set, all), total))
+
+	// the 700 lightest validators only have a third of the power
+	light := map[NodeID]*MessageReq{}
+	for id, power := range powers {
+		if power.Cmp(new(big.Int).Mul(bigPow10(18), big.NewInt(7))) <= 0 {
+			light[id] = &MessageReq{From: id}
+		}
+	}
+	assert.Len(t, light, 700)
+	assert.False(t, hasPowerQuorum(votingPower(set, light), total))
+}
+
+func TestVotingPower_InvalidPowers(t *testing.T) {
+	set := &weightedValidatorSet{
+		valString: newMockValidatorSet([]string{"A", "B", "C"}).(*valString),
+		powers: map[NodeID]*big.Int{
+			"A": big.NewInt(5),
+			"B": big.NewInt(-100),
+		},
+	}
+	// negative and missing powers do not count
+	assert.Equal(t, big.NewInt(5), votingPower(set, votes("A", "B", "C", "D")))
+}
+
+func TestTransition_ValidateState_VotingPowerQuorum(t *testing.T) {
+	newWeightedPbft := func() *mockPbft {
+		m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+		// B holds most of the stake
+		m.state.validators = &weightedValidatorSet{
+			valString: m.backend.(*mockBackend).validators,
+			powers: map[NodeID]*big.Int{
+				"A": big.NewInt(1),
+				"B": new(big.Int).Mul(bigPow10(18), big.NewInt(1000)),
+				"C": big.NewInt(1),
+				"D": big.NewInt(1),
+			},
+		}
+		m.setState(ValidateState)
+		return m
+	}
+
+	// three prepares and commits by count but only a fraction of the power
+	m := newWeightedPbft()
+	for _, from := range []NodeID{"A", "C", "D"} {
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_Prepare, View: ViewMsg(1, 0)})
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_Commit, View: ViewMsg(1, 0)})
+	}
+	m.runCycle(context.Background())
+	m.expect(expectResult{
+		sequence:    1,
+		state:       RoundChangeState,
+		prepareMsgs: 3,
+		commitMsgs:  3,
+	})
+
+	// the commit of B alone reaches the quorum
+	m = newWeightedPbft()
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_Commit, View: ViewMsg(1, 0)})
+	m.runCycle(context.Background())
+	m.expect(expectResult{
+		sequence:   1,
+		state:      CommitState,
+		commitMsgs: 1,
+		locked:     true,
+		outgoing:   1, // commit
+	})
+}
+
+func TestCurrentState_HasQuorum_Indexed(t *testing.T) {
+	s := newState()
+	s.validators = newIndexedValidatorSet(&weightedValidatorSet{
+		valString: newMockValidatorSet([]string{"A", "B", "C", "D"}).(*valString),
+		powers:    map[NodeID]*big.Int{"A": big.NewInt(10), "B": big.NewInt(1), "C": big.NewInt(1), "D": big.NewInt(1)},
+	})
+	// the voting power is found behind the index
+	assert.True(t, s.hasQuorum(votes("A")))
+	assert.False(t, s.hasQuorum(votes("B", "C", "D")))
+}
+
+// newHeavyValidatorPbft returns an engine whose validator B holds most of the stake
+func newHeavyValidatorPbft(t *testing.T) *mockPbft {
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.state.validators = &weightedValidatorSet{
+		valString: m.backend.(*mockBackend).validators,
+		powers: map[NodeID]*big.Int{
+			"A": big.NewInt(1),
+			"B": big.NewInt(1000),
+			"C": big.NewInt(1),
+			"D": big.NewInt(1),
+		},
+	}
+	return m
+}
+
+func TestQuorumPower(t *testing.T) {
+	assert.Nil(t, quorumPower(nil))
+	assert.Nil(t, quorumPower(big.NewInt(0)))
+	assert.Equal(t, big.NewInt(3), quorumPower(big.NewInt(4)))
+	assert.Equal(t, big.NewInt(3), quorumPower(big.NewInt(3)))
+	assert.Equal(t, big.NewInt(669), quorumPower(big.NewInt(1003)))
+
+	m := newHeavyValidatorPbft(t)
+	quorum, power := m.state.quorumSize()
+	assert.Zero(t, quorum)
+	assert.Equal(t, big.NewInt(669), power)
+}
+
+func TestTransition_RoundChangeState_VotingPowerQuorum(t *testing.T) {
+	// two round changes by count (F+1) but only a fraction of the power
+	m := newHeavyValidatorPbft(t)
+	m.setState(RoundChangeState)
+	for _, from := range []NodeID{"C", "D"} {
+		m.emitMsg(&MessageReq{From: from, Type: MessageReq_RoundChange, View: ViewMsg(1, 2)})
+	}
+	m.Close()
+	m.runCycle(context.Background())
+	// not even a weak certificate, the node only moves to the next round on its own
+	assert.Equal(t, RoundChangeState, m.getState())
+	assert.Equal(t, uint64(1), m.state.view.Round)
+
+	// the round change of B alone moves the node to the round
+	m = newHeavyValidatorPbft(t)
+	m.setState(RoundChangeState)
+	m.emitMsg(&MessageReq{From: "B", Type: MessageReq_RoundChange, View: ViewMsg(1, 2)})
+	m.runCycle(context.Background())
+	assert.Equal(t, AcceptState, m.getState())
+	assert.Equal(t, uint64(2), m.state.view.Round)
+}
+
+func TestTransition_RoundChangeState_VotingPowerWeakQuorum(t *testing.T) {
+	// D holds half of the power, more than a third but not the quorum
+	m := newMockPbft(t, []string{"A", "B", "C", "D"}, "A")
+	m.state.validators = &weightedValidatorSet{
+		valString: m.backend.(*mockBackend).validators,
+		powers: map[NodeID]*big.Int{
+			"A": big.NewInt(1),
+			"B": big.NewInt(1),
+			"C": big.NewInt(1),
+			"D": big.NewInt(3),
+		},
+	}
+	m.setState(RoundChangeState)
+	m.emitMsg(&MessageReq{From: "D", Type: MessageReq_RoundChange, View: ViewMsg(1, 3)})
+	m.Close()
+	m.runCycle(context.Background())
+
+	// the weak certificate of a single validator catches up with the round
+	assert.Equal(t, RoundChangeState, m.getState())
+	assert.Equal(t, uint64(3), m.state.view.Round)
+}
+
+func TestStatus_SyncHint_VotingPower(t *testing.T) {
+	m := newHeavyValidatorPbft(t)
+
+	// F+1 validators ahead with a fraction of the power do not trigger a sync
+	m.PushMessage(&MessageReq{From: "C", Type: MessageReq_Status, View: ViewMsg(5000, 0)})
+	m.PushMessage(&MessageReq{From: "D", Type: MessageReq_Status, View: ViewMsg(5000, 0)})
+	_, _, ok := m.SyncHint()
+	assert.False(t, ok)
+
+	// the height announced by more than a third of the power
+	m.PushMessage(&MessageReq{From: "B", Type: MessageReq_Status, View: ViewMsg(40, 0)})
+	peer, height, ok := m.SyncHint()
+	assert.True(t, ok)
+	assert.Equal(t, NodeID("B"), peer)
+	assert.Equal(t, uint64(39), height)
+}
+
+func TestPbft_CatchUp_VotingPowerQuorum(t *testing.T) {
+	m := newHeavyValidatorPbft(t)
+	m.state.view = ViewMsg(5, 0)
+
+	assert.Error(t, m.CatchUp(newFinalityProof(5, "A", "C", "D")))
+	assert.NoError(t, m.CatchUp(newFinalityProof(5, "B")))
+	assert.Equal(t, ViewMsg(6, 0), m.state.view)
+}
+
+func TestTransition_AcceptState_RoundInfo_VotingPower(t *testing.T) {
+	m := newHeavyValidatorPbft(t)
+	backend := &mockInitBackend{mockBackend: m.backend.(*mockBackend)}
+	m.backend = backend
+	m.setState(AcceptState)
+	m.runCycle(context.Background())
+
+	assert.Zero(t, backend.info.Quorum)
+	assert.Equal(t, big.NewInt(669), backend.info.QuorumPower)
+}